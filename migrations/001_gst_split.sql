@@ -0,0 +1,30 @@
+-- Place of supply on customers and the CGST/SGST/IGST breakup on transaction lines
+
+ALTER TABLE customer
+	ADD COLUMN gstin VARCHAR(15) NULL,
+	ADD COLUMN placeOfSupply CHAR(2) NULL;
+
+ALTER TABLE transaction
+	ADD COLUMN cgstValue DECIMAL(15, 2) NOT NULL DEFAULT 0 AFTER gstValue,
+	ADD COLUMN sgstValue DECIMAL(15, 2) NOT NULL DEFAULT 0 AFTER cgstValue,
+	ADD COLUMN igstValue DECIMAL(15, 2) NOT NULL DEFAULT 0 AFTER sgstValue,
+	ADD COLUMN placeOfSupply CHAR(2) NULL AFTER igstValue;
+
+-- imports against a bill of entry always attract IGST
+UPDATE transaction SET igstValue = gstValue WHERE comeOrGo = 'in';
+
+-- sales are split as they would be today: no customer has a place of supply recorded yet, so each is taken to be
+-- supplied in the state of the warehouse, half as CGST and half as SGST, while a warehouse without a valid GSTIN
+-- charges it all as IGST; lines of customers outside that state need their split corrected once their place of supply
+-- is known
+UPDATE transaction tr
+	JOIN warehouse wh ON wh.id = tr.warehouseId
+	SET tr.placeOfSupply = LEFT(wh.gstin, 2),
+		tr.cgstValue = ROUND(tr.gstValue / 2, 2),
+		tr.sgstValue = tr.gstValue - ROUND(tr.gstValue / 2, 2)
+	WHERE tr.comeOrGo = 'out' AND wh.gstin REGEXP '^[0-9]{2}[A-Z]{5}[0-9]{4}[A-Z][1-9A-Z]Z[0-9A-Z]$';
+
+UPDATE transaction tr
+	LEFT JOIN warehouse wh ON wh.id = tr.warehouseId
+	SET tr.igstValue = tr.gstValue
+	WHERE tr.comeOrGo = 'out' AND NOT IFNULL(wh.gstin REGEXP '^[0-9]{2}[A-Z]{5}[0-9]{4}[A-Z][1-9A-Z]Z[0-9A-Z]$', false);
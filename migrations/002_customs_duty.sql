@@ -0,0 +1,23 @@
+-- Customs duty breakdown of bill of entry lines and the landed cost used for valuation
+
+CREATE TABLE IF NOT EXISTS customsDuty (
+	id INT NOT NULL AUTO_INCREMENT,
+	transactionId INT NOT NULL,
+	foreignInvoiceValue DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	exchangeRate DECIMAL(12, 6) NOT NULL DEFAULT 0,
+	basicCustomsDuty DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	socialWelfareSurcharge DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	igstOnImport DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	cess DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	landedCost DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	landedCostPerPiece DECIMAL(15, 6) NOT NULL DEFAULT 0,
+	PRIMARY KEY (id),
+	UNIQUE KEY uniqueTransaction (transactionId)
+);
+
+ALTER TABLE transaction
+	ADD COLUMN landedCostPerPiece DECIMAL(15, 6) NULL AFTER valuePerPiece;
+
+UPDATE transaction
+	SET landedCostPerPiece = (assdValue + dutyValue) / totalPcs
+	WHERE comeOrGo = 'in' AND totalPcs > 0;
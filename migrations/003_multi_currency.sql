@@ -0,0 +1,18 @@
+-- Currency and exchange rate on bills of entry and transactions, and the offline rate table
+
+CREATE TABLE IF NOT EXISTS exchangeRate (
+	id INT NOT NULL AUTO_INCREMENT,
+	currency CHAR(3) NOT NULL,
+	effectiveDate DATE NOT NULL,
+	rate DECIMAL(12, 6) NOT NULL,
+	PRIMARY KEY (id),
+	UNIQUE KEY uniqueCurrencyDate (currency, effectiveDate)
+);
+
+ALTER TABLE billOfEntry
+	ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'INR',
+	ADD COLUMN exchangeRate DECIMAL(12, 6) NOT NULL DEFAULT 1;
+
+ALTER TABLE transaction
+	ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'INR' AFTER totalValue,
+	ADD COLUMN exchangeRate DECIMAL(12, 6) NOT NULL DEFAULT 1 AFTER currency;
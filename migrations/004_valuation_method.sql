@@ -0,0 +1,4 @@
+-- Stock valuation method chosen per client, FIFO or weighted average (WAVG)
+
+ALTER TABLE client
+	ADD COLUMN valuationMethod VARCHAR(4) NOT NULL DEFAULT 'FIFO';
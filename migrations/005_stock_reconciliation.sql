@@ -0,0 +1,24 @@
+-- Reconciliation runs of the replayed transaction ledger against inventoryContents
+
+CREATE TABLE IF NOT EXISTS reconciliationRun (
+	id INT NOT NULL AUTO_INCREMENT,
+	runAt DATETIME NOT NULL,
+	driftCount INT NOT NULL DEFAULT 0,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS stockDrift (
+	id INT NOT NULL AUTO_INCREMENT,
+	runId INT NOT NULL,
+	itemId INT NOT NULL,
+	warehouseId INT NOT NULL,
+	clientId INT NOT NULL,
+	ledgerBigcartonQuantity DECIMAL(15, 3) NOT NULL,
+	ledgerSmallboxQuantity DECIMAL(15, 3) NOT NULL,
+	ledgerItemQuantity DECIMAL(15, 3) NOT NULL,
+	contentsBigcartonQuantity DECIMAL(15, 3) NOT NULL,
+	contentsSmallboxQuantity DECIMAL(15, 3) NOT NULL,
+	contentsItemQuantity DECIMAL(15, 3) NOT NULL,
+	PRIMARY KEY (id),
+	KEY runIndex (runId)
+);
@@ -0,0 +1,43 @@
+-- Receipts from customers and their allocation across transaction lines
+
+CREATE TABLE IF NOT EXISTS payment (
+	id INT NOT NULL AUTO_INCREMENT,
+	customerId INT NOT NULL,
+	paymentDate DATE NOT NULL,
+	mode VARCHAR(16) NOT NULL,
+	reference VARCHAR(255) NOT NULL DEFAULT '',
+	amount DECIMAL(15, 2) NOT NULL,
+	PRIMARY KEY (id),
+	KEY customerIndex (customerId)
+);
+
+CREATE TABLE IF NOT EXISTS paymentAllocation (
+	id INT NOT NULL AUTO_INCREMENT,
+	paymentId INT NOT NULL,
+	transactionId INT NOT NULL,
+	amount DECIMAL(15, 2) NOT NULL,
+	PRIMARY KEY (id),
+	KEY paymentIndex (paymentId),
+	KEY transactionIndex (transactionId)
+);
+
+ALTER TABLE transaction
+	ADD COLUMN paymentStatus VARCHAR(8) NOT NULL DEFAULT 'unpaid' AFTER paidAmount;
+
+-- carry the paid amounts typed in so far over as adjustment receipts
+INSERT INTO payment (customerId, paymentDate, mode, reference, amount)
+	SELECT customerId, CURDATE(), 'adjustment', CONCAT('migrated paid amount of transaction ', id), paidAmount
+	FROM transaction WHERE comeOrGo = 'out' AND paidAmount > 0;
+
+INSERT INTO paymentAllocation (paymentId, transactionId, amount)
+	SELECT p.id, tr.id, p.amount
+	FROM payment p, transaction tr
+	WHERE p.reference = CONCAT('migrated paid amount of transaction ', tr.id);
+
+UPDATE transaction
+	SET isPaid = CASE WHEN paidAmount >= totalValue - 0.005 THEN true ELSE false END,
+	paymentStatus = CASE
+		WHEN paidAmount >= totalValue - 0.005 THEN 'paid'
+		WHEN paidAmount > 0 THEN 'partial'
+		ELSE 'unpaid' END
+	WHERE comeOrGo = 'out';
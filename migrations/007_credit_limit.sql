@@ -0,0 +1,24 @@
+-- Credit limits and payment terms of customers, and the outbound transactions let past a limit
+
+ALTER TABLE customer
+	ADD COLUMN creditLimit DECIMAL(15, 2) NULL AFTER placeOfSupply,
+	ADD COLUMN paymentTerms INT NOT NULL DEFAULT 0 AFTER creditLimit,
+	ADD COLUMN creditPolicy VARCHAR(8) NOT NULL DEFAULT 'block' AFTER paymentTerms;
+
+ALTER TABLE user
+	ADD COLUMN permission_creditOverride BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS creditException (
+	id INT NOT NULL AUTO_INCREMENT,
+	transactionId INT NOT NULL,
+	customerId INT NOT NULL,
+	creditLimit DECIMAL(15, 2) NOT NULL,
+	outstanding DECIMAL(15, 2) NOT NULL,
+	totalValue DECIMAL(15, 2) NOT NULL,
+	action VARCHAR(10) NOT NULL,
+	userId INT NULL,
+	reason VARCHAR(255) NOT NULL DEFAULT '',
+	createdAt DATETIME NOT NULL,
+	PRIMARY KEY (id),
+	KEY customerIndex (customerId)
+);
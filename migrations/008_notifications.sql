@@ -0,0 +1,25 @@
+-- Outbox of payment reminders for invoices due soon or overdue
+
+ALTER TABLE customer
+	ADD COLUMN email VARCHAR(255) NULL AFTER placeOfSupply;
+
+CREATE TABLE IF NOT EXISTS notification (
+	id INT NOT NULL AUTO_INCREMENT,
+	kind VARCHAR(10) NOT NULL,
+	salesInvoiceId INT NOT NULL,
+	customerId INT NOT NULL,
+	recipient VARCHAR(255) NOT NULL DEFAULT '',
+	dueDate DATE NOT NULL,
+	outstanding DECIMAL(15, 2) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	message TEXT NOT NULL,
+	status VARCHAR(8) NOT NULL DEFAULT 'pending',
+	channel VARCHAR(16) NOT NULL DEFAULT '',
+	attempts INT NOT NULL DEFAULT 0,
+	lastError VARCHAR(255) NOT NULL DEFAULT '',
+	createdAt DATETIME NOT NULL,
+	sentAt DATETIME NULL,
+	PRIMARY KEY (id),
+	UNIQUE KEY reminderIndex (salesInvoiceId, kind, dueDate),
+	KEY statusIndex (status)
+);
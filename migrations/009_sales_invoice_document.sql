@@ -0,0 +1,32 @@
+-- Sales invoices as documents with their own lines, discount and status
+
+ALTER TABLE salesInvoice
+	ADD COLUMN status VARCHAR(10) NOT NULL DEFAULT 'issued',
+	ADD COLUMN placeOfSupply CHAR(2) NULL,
+	ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'INR',
+	ADD COLUMN exchangeRate DECIMAL(12, 6) NOT NULL DEFAULT 1,
+	ADD COLUMN discountValue DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	ADD COLUMN remarks VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS salesInvoiceLine (
+	id INT NOT NULL AUTO_INCREMENT,
+	salesInvoiceId INT NOT NULL,
+	lineNumber INT NOT NULL,
+	itemId INT NOT NULL,
+	warehouseId INT NOT NULL,
+	clientId INT NOT NULL,
+	bigQuantity DECIMAL(15, 3) NOT NULL,
+	totalPcs DECIMAL(15, 3) NOT NULL,
+	unitPrice DECIMAL(15, 4) NOT NULL,
+	discountValue DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	taxableValue DECIMAL(15, 2) NOT NULL,
+	gstRate DECIMAL(5, 2) NOT NULL,
+	gstValue DECIMAL(15, 2) NOT NULL,
+	cgstValue DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	sgstValue DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	igstValue DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	totalValue DECIMAL(15, 2) NOT NULL,
+	transactionId INT NULL,
+	PRIMARY KEY (id),
+	UNIQUE KEY uniqueLine (salesInvoiceId, lineNumber)
+);
@@ -0,0 +1,44 @@
+-- Bills of entry as documents with their own lines and status
+
+ALTER TABLE billOfEntry
+	ADD COLUMN status VARCHAR(10) NOT NULL DEFAULT 'received',
+	ADD COLUMN remarks VARCHAR(255) NOT NULL DEFAULT '',
+	ADD COLUMN originalTracker VARCHAR(255) NULL;
+
+-- numbers entered by hand have been repeated, so every repeat but the first is renumbered with its id before the
+-- key goes on; the renumbered bills are listed first so that they can be reviewed, and keep the number they were
+-- entered with in originalTracker
+SELECT d.id, d.tracker, CONCAT(d.tracker, '-', d.id) AS renumberedTo
+	FROM billOfEntry d
+	JOIN (SELECT tracker, MIN(id) AS firstId FROM billOfEntry GROUP BY tracker HAVING COUNT(*) > 1) repeated
+		ON repeated.tracker = d.tracker AND d.id <> repeated.firstId;
+
+UPDATE billOfEntry d
+	JOIN (SELECT tracker, MIN(id) AS firstId FROM billOfEntry GROUP BY tracker HAVING COUNT(*) > 1) repeated
+		ON repeated.tracker = d.tracker AND d.id <> repeated.firstId
+	SET d.originalTracker = repeated.tracker, d.tracker = CONCAT(repeated.tracker, '-', d.id);
+
+ALTER TABLE billOfEntry
+	ADD UNIQUE KEY uniqueTracker (tracker);
+
+CREATE TABLE IF NOT EXISTS billOfEntryLine (
+	id INT NOT NULL AUTO_INCREMENT,
+	billOfEntryId INT NOT NULL,
+	lineNumber INT NOT NULL,
+	itemId INT NOT NULL,
+	warehouseId INT NOT NULL,
+	bigQuantity DECIMAL(15, 3) NOT NULL,
+	totalPcs DECIMAL(15, 3) NOT NULL,
+	assdValue DECIMAL(15, 2) NOT NULL,
+	dutyValue DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	gstValue DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	totalValue DECIMAL(15, 2) NOT NULL,
+	foreignInvoiceValue DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	basicCustomsDuty DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	socialWelfareSurcharge DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	igstOnImport DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	cess DECIMAL(15, 2) NOT NULL DEFAULT 0,
+	transactionId INT NULL,
+	PRIMARY KEY (id),
+	UNIQUE KEY uniqueLine (billOfEntryId, lineNumber)
+);
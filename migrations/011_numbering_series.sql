@@ -0,0 +1,28 @@
+-- Gapless document numbering series per warehouse, document type and financial year
+
+CREATE TABLE IF NOT EXISTS numberingSeries (
+	id INT NOT NULL AUTO_INCREMENT,
+	warehouseId INT NOT NULL,
+	documentType VARCHAR(8) NOT NULL,
+	financialYear CHAR(7) NOT NULL,
+	prefix VARCHAR(16) NOT NULL,
+	padding INT NOT NULL DEFAULT 5,
+	nextNumber INT NOT NULL DEFAULT 1,
+	PRIMARY KEY (id),
+	UNIQUE KEY uniqueSeries (warehouseId, documentType, financialYear)
+);
+
+-- numbers entered by hand have been repeated, so every repeat but the first is renumbered with its id before the
+-- key goes on; the renumbered invoices are listed first so that they can be reviewed
+SELECT d.id, d.tracker, CONCAT(d.tracker, '-', d.id) AS renumberedTo
+	FROM salesInvoice d
+	JOIN (SELECT tracker, MIN(id) AS firstId FROM salesInvoice GROUP BY tracker HAVING COUNT(*) > 1) repeated
+		ON repeated.tracker = d.tracker AND d.id <> repeated.firstId;
+
+UPDATE salesInvoice d
+	JOIN (SELECT tracker, MIN(id) AS firstId FROM salesInvoice GROUP BY tracker HAVING COUNT(*) > 1) repeated
+		ON repeated.tracker = d.tracker AND d.id <> repeated.firstId
+	SET d.tracker = CONCAT(d.tracker, '-', d.id);
+
+ALTER TABLE salesInvoice
+	ADD UNIQUE KEY uniqueTracker (tracker);
@@ -0,0 +1,17 @@
+-- Addresses needed by the e-invoice schema, and the IRN returned by the invoice registration portal
+
+ALTER TABLE warehouse
+	ADD COLUMN address VARCHAR(100) NULL,
+	ADD COLUMN pincode CHAR(6) NULL;
+
+ALTER TABLE customer
+	ADD COLUMN address VARCHAR(100) NULL,
+	ADD COLUMN city VARCHAR(50) NULL,
+	ADD COLUMN pincode CHAR(6) NULL;
+
+ALTER TABLE salesInvoice
+	ADD COLUMN irn CHAR(64) NULL,
+	ADD COLUMN ackNumber VARCHAR(20) NULL,
+	ADD COLUMN ackDate DATETIME NULL,
+	ADD COLUMN signedQrCode TEXT NULL,
+	ADD UNIQUE KEY uniqueIrn (irn);
@@ -0,0 +1,8 @@
+-- E-way bills generated for outbound consignments of sales invoices
+-- (stock transfers keep theirs on the stockTransfer document of 016)
+
+ALTER TABLE salesInvoice
+	ADD COLUMN ewayBillNumber CHAR(12) NULL,
+	ADD COLUMN ewayBillDate DATETIME NULL,
+	ADD COLUMN ewayBillValidUpto DATETIME NULL,
+	ADD UNIQUE KEY uniqueEwayBill (ewayBillNumber);
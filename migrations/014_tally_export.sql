@@ -0,0 +1,20 @@
+-- Ledger names used in Tally for each customer and client, and the documents already exported as vouchers
+
+CREATE TABLE IF NOT EXISTS tallyLedger (
+	id INT NOT NULL AUTO_INCREMENT,
+	partyType VARCHAR(8) NOT NULL,
+	partyId INT NOT NULL DEFAULT 0,
+	ledgerRole VARCHAR(16) NOT NULL,
+	ledgerName VARCHAR(255) NOT NULL,
+	PRIMARY KEY (id),
+	UNIQUE KEY uniqueLedger (partyType, partyId, ledgerRole)
+);
+
+CREATE TABLE IF NOT EXISTS tallyExport (
+	id INT NOT NULL AUTO_INCREMENT,
+	voucherType VARCHAR(16) NOT NULL,
+	documentId INT NOT NULL,
+	exportedAt DATETIME NOT NULL,
+	PRIMARY KEY (id),
+	UNIQUE KEY uniqueVoucher (voucherType, documentId)
+);
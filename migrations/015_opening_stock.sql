@@ -0,0 +1,14 @@
+-- Opening stock documents, whose lines are transactions of their own "opening" direction
+
+CREATE TABLE IF NOT EXISTS openingStock (
+	id INT NOT NULL AUTO_INCREMENT,
+	tracker VARCHAR(32) NOT NULL,
+	entryDate DATE NOT NULL,
+	createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id)
+);
+
+ALTER TABLE transaction
+	MODIFY comeOrGo VARCHAR(7) NOT NULL,
+	ADD COLUMN openingStock INT NULL,
+	ADD KEY openingStockIndex (openingStock);
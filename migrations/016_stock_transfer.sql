@@ -0,0 +1,22 @@
+-- Stock transfers between warehouses, whose lines are an outbound transaction at the source and an inbound one at the
+-- destination, and the e-way bills generated for them
+
+CREATE TABLE IF NOT EXISTS stockTransfer (
+	id INT NOT NULL AUTO_INCREMENT,
+	tracker VARCHAR(16) NOT NULL,
+	entryDate DATE NOT NULL,
+	fromWarehouseId INT NOT NULL,
+	toWarehouseId INT NOT NULL,
+	clientId INT NOT NULL,
+	remarks VARCHAR(255) NOT NULL DEFAULT '',
+	ewayBillNumber CHAR(12) NULL,
+	ewayBillDate DATETIME NULL,
+	ewayBillValidUpto DATETIME NULL,
+	createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (id),
+	UNIQUE KEY uniqueEwayBill (ewayBillNumber)
+);
+
+ALTER TABLE transaction
+	ADD COLUMN stockTransfer INT NULL,
+	ADD KEY stockTransferIndex (stockTransfer);
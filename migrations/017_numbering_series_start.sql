@@ -0,0 +1,5 @@
+-- The number each series started on, so that a series that has issued numbers can be told from one not used yet;
+-- series already in use are taken to have started on 1
+
+ALTER TABLE numberingSeries
+	ADD COLUMN startNumber INT NOT NULL DEFAULT 1 AFTER padding;
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/rounakdatta/ainv-backend-go/src/xlsx"
+)
+
+// the dates an invoice can be aged from
+const (
+	AgingByInvoiceDate = "invoice"
+	AgingByDueDate     = "due"
+)
+
+// AgingBuckets is an outstanding amount split by how many days it has been outstanding, NotDue holding what is aged
+// from a due date still ahead
+type AgingBuckets struct {
+	NotDue     float64 `json:"notDue"`
+	Days0To30  float64 `json:"days0To30"`
+	Days31To60 float64 `json:"days31To60"`
+	Days61To90 float64 `json:"days61To90"`
+	Over90     float64 `json:"over90"`
+	Total      float64 `json:"total"`
+}
+
+// add puts an outstanding amount into the bucket for its age
+func (buckets *AgingBuckets) add(days int, outstanding float64) {
+	switch {
+	case days < 0:
+		buckets.NotDue = roundPaise(buckets.NotDue + outstanding)
+	case days <= 30:
+		buckets.Days0To30 = roundPaise(buckets.Days0To30 + outstanding)
+	case days <= 60:
+		buckets.Days31To60 = roundPaise(buckets.Days31To60 + outstanding)
+	case days <= 90:
+		buckets.Days61To90 = roundPaise(buckets.Days61To90 + outstanding)
+	default:
+		buckets.Over90 = roundPaise(buckets.Over90 + outstanding)
+	}
+
+	buckets.Total = roundPaise(buckets.Total + outstanding)
+}
+
+// AgingInvoice is a sales invoice with an outstanding amount
+type AgingInvoice struct {
+	SalesInvoiceId  string  `json:"salesInvoiceId"`
+	SalesInvoice    string  `json:"salesInvoice"`
+	InvoiceDate     string  `json:"invoiceDate"`
+	DueDate         string  `json:"dueDate"`
+	TotalValue      float64 `json:"totalValue"`
+	PaidAmount      float64 `json:"paidAmount"`
+	Outstanding     float64 `json:"outstanding"`
+	DaysOutstanding int     `json:"daysOutstanding"`
+}
+
+// CustomerAging is the receivables of a customer bucketed by age, with the invoices behind them
+type CustomerAging struct {
+	CustomerId   string `json:"customerId"`
+	CustomerName string `json:"customerName"`
+	AgingBuckets
+	Invoices []AgingInvoice `json:"invoices"`
+}
+
+// dueDateExpression is the due date of an invoice, the expected payment date of its lines falling back to the payment terms of the customer
+const dueDateExpression = "IFNULL(MIN(tr.date), DATE_ADD(si.entryDate, INTERVAL cu.paymentTerms DAY))"
+
+// loadCustomerAging buckets the outstanding sales invoices of every customer as of a date
+func loadCustomerAging(asOfDate string, basis string, customerId string) []CustomerAging {
+	var aging []CustomerAging
+
+	ageFrom := "si.entryDate"
+	if basis == AgingByDueDate {
+		ageFrom = dueDateExpression
+	}
+
+	customerCondition := ""
+	if isFiltered(customerId) {
+		customerCondition = fmt.Sprintf(" AND tr.customerId = '%s'", customerId)
+	}
+
+	// only the receipts dated up to the day count as paid, so that a past date shows what was outstanding then
+	paidAsOf := fmt.Sprintf(`IFNULL((SELECT SUM(pa.amount) FROM paymentAllocation pa
+		JOIN payment p ON p.id = pa.paymentId
+		WHERE pa.transactionId = tr.id AND p.paymentDate <= '%s'), 0)`, asOfDate)
+
+	agingQuery := fmt.Sprintf(`SELECT
+		cu.id, cu.customerName,
+		si.id, si.tracker, si.entryDate, %s,
+		SUM(tr.totalValue), SUM(%s),
+		SUM(tr.totalValue) - SUM(%s) AS outstanding,
+		IFNULL(DATEDIFF('%s', %s), 0)
+		FROM transaction tr, salesInvoice si, customer cu
+		WHERE tr.salesInvoice = si.id AND tr.customerId = cu.id AND tr.comeOrGo = 'out' AND tr.isError = 0
+		AND si.entryDate <= '%s'%s
+		GROUP BY cu.id, cu.customerName, cu.paymentTerms, si.id, si.tracker, si.entryDate
+		HAVING outstanding > %f
+		ORDER BY cu.customerName, si.entryDate`, dueDateExpression, paidAsOf, paidAsOf, asOfDate, ageFrom, asOfDate, customerCondition, paymentTolerance)
+
+	allInvoices, err := db.Query(agingQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	index := map[string]int{}
+
+	for allInvoices.Next() {
+		var customerId string
+		var customerName string
+		var invoice AgingInvoice
+
+		err := allInvoices.Scan(&customerId, &customerName, &invoice.SalesInvoiceId, &invoice.SalesInvoice, &invoice.InvoiceDate, &invoice.DueDate, &invoice.TotalValue, &invoice.PaidAmount, &invoice.Outstanding, &invoice.DaysOutstanding)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		i, ok := index[customerId]
+		if !ok {
+			i = len(aging)
+			index[customerId] = i
+			aging = append(aging, CustomerAging{CustomerId: customerId, CustomerName: customerName})
+		}
+
+		aging[i].add(invoice.DaysOutstanding, invoice.Outstanding)
+		aging[i].Invoices = append(aging[i].Invoices, invoice)
+	}
+
+	return aging
+}
+
+// agingCSVHeader and agingInvoiceCSVHeader are the header rows of the summary and the drill-down export
+var agingCSVHeader = []string{"Customer", "Not Due", "0-30 Days", "31-60 Days", "61-90 Days", "90+ Days", "Total Outstanding"}
+var agingInvoiceCSVHeader = []string{"Customer", "Sales Invoice", "Invoice Date", "Due Date", "Total Value", "Paid Amount", "Outstanding", "Days Outstanding"}
+
+// agingRows flattens the report into one row per customer, or one per invoice when drilling down
+func agingRows(aging []CustomerAging, drillDown bool) [][]interface{} {
+	var rows [][]interface{}
+
+	for _, customer := range aging {
+		if !drillDown {
+			rows = append(rows, []interface{}{customer.CustomerName, xlsx.Amount(customer.NotDue), xlsx.Amount(customer.Days0To30), xlsx.Amount(customer.Days31To60), xlsx.Amount(customer.Days61To90), xlsx.Amount(customer.Over90), xlsx.Amount(customer.Total)})
+			continue
+		}
+
+		for _, invoice := range customer.Invoices {
+			rows = append(rows, []interface{}{customer.CustomerName, invoice.SalesInvoice, invoice.InvoiceDate, invoice.DueDate, xlsx.Amount(invoice.TotalValue), xlsx.Amount(invoice.PaidAmount), xlsx.Amount(invoice.Outstanding), invoice.DaysOutstanding})
+		}
+	}
+
+	return rows
+}
+
+// SearchReceivablesAging returns the accounts receivable of each customer bucketed by age as JSON, CSV or XLSX
+func SearchReceivablesAging(w http.ResponseWriter, r *http.Request) {
+
+	asOfDate := r.FormValue("asOfDate")
+	basis := r.FormValue("basis")
+	customerId := r.FormValue("customerId")
+	drillDown := r.FormValue("detail") == "invoice"
+
+	if asOfDate == "" {
+		asOfDate = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", asOfDate); err != nil {
+		writeFailure(w, "asOfDate must be in YYYY-MM-DD format")
+		return
+	}
+	if basis != AgingByDueDate {
+		basis = AgingByInvoiceDate
+	}
+
+	payload := loadCustomerAging(asOfDate, basis, customerId)
+
+	if format := requestedFormat(r); format != FormatJSON {
+		header := agingCSVHeader
+		if drillDown {
+			header = agingInvoiceCSVHeader
+		}
+
+		writeTable(w, format, "receivables-aging", header, agingRows(payload, drillDown))
+		return
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
@@ -12,10 +12,12 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"github.com/rounakdatta/ainv-backend-go/src/validation"
 )
 
 type Rate struct {
@@ -33,13 +35,23 @@ type Warehouse struct {
 }
 
 type Client struct {
-	ClientId   string `json:"clientId"`
-	ClientName string `json:"clientName"`
+	ClientId        string `json:"clientId"`
+	ClientName      string `json:"clientName"`
+	ValuationMethod string `json:"valuationMethod"`
 }
 
 type Customer struct {
-	CustomerId   string `json:"customerId"`
-	CustomerName string `json:"customerName"`
+	CustomerId    string `json:"customerId"`
+	CustomerName  string `json:"customerName"`
+	Gstin         string `json:"gstin"`
+	PlaceOfSupply string `json:"placeOfSupply"`
+	Email         string `json:"email"`
+	CreditLimit   string `json:"creditLimit"`
+	PaymentTerms  int    `json:"paymentTerms"`
+	CreditPolicy  string `json:"creditPolicy"`
+	Address       string `json:"address"`
+	City          string `json:"city"`
+	Pincode       string `json:"pincode"`
 }
 
 type WarehouseEntity struct {
@@ -51,6 +63,8 @@ type BillOfEntry struct {
 	BillOfEntryNumber string `json:"billOfEntryNumber"`
 	BillOfEntryId     string `json:"billOfEntryId"`
 	BillOfEntryDate   string `json:"billOfEntryDate"`
+	Currency          string `json:"currency"`
+	ExchangeRate      string `json:"exchangeRate"`
 }
 
 type SalesInvoice struct {
@@ -62,9 +76,11 @@ type SalesInvoice struct {
 }
 
 type Item struct {
-	Name        string   `json:"name"`
-	Description []string `json:"description"`
-	ItemId      []string `json:"itemId"`
+	Name           string   `json:"name"`
+	Description    []string `json:"description"`
+	ItemId         []string `json:"itemId"`
+	HsnCode        []string `json:"hsnCode"`
+	HsnDescription []string `json:"hsnDescription"`
 }
 
 type searchPayload struct {
@@ -76,6 +92,7 @@ type ItemInventory struct {
 	ItemName          string `json:"itemName"`
 	ItemVariant       string `json:"itemVariant"`
 	HsnCode           string `json:"hsnCode"`
+	HsnDescription    string `json:"hsnDescription"`
 	ItemQuantity      string `json:"itemQuantity"`
 	UomRaw            string `json:"uomRaw"`
 	SmallboxQuantity  string `json:"smallboxQuantity"`
@@ -107,15 +124,25 @@ type SalesTransaction struct {
 	TotalPcs          string  `json:"totalPcs"`
 	MaterialValue     string  `json:"materialValue"`
 	GstValue          string  `json:"gstValue"`
+	CgstValue         string  `json:"cgstValue"`
+	SgstValue         string  `json:"sgstValue"`
+	IgstValue         string  `json:"igstValue"`
+	PlaceOfSupply     string  `json:"placeOfSupply"`
 	TotalValue        string  `json:"totalValue"`
+	Currency          string  `json:"currency"`
+	ExchangeRate      string  `json:"exchangeRate"`
+	BaseTotalValue    string  `json:"baseTotalValue"`
 	ValuePerPiece     float64 `json:"valuePerPiece"`
 	IsPaid            string  `json:"isPaid"`
 	PaidAmount        string  `json:"paidAmount"`
+	PaymentStatus     string  `json:"paymentStatus"`
+	Outstanding       string  `json:"outstanding"`
 	PaymentDate       string  `json:"paymentDate"`
 	Field1            string  `json:"field1"`
 	Field2            string  `json:"field2"`
 	Remarks           string  `json:"remarks"`
 	RawUnit           string  `json:"rawUnit"`
+	BigUnit           string  `json:"bigUnit"`
 }
 
 type OverviewTransaction struct {
@@ -131,6 +158,8 @@ type OverviewTransaction struct {
 	Customer       string `json:"customer"`
 	BigQuantity    string `json:"bigQuantity"`
 	TotalValue     string `json:"totalValue"`
+	Currency       string `json:"currency"`
+	OriginalValue  string `json:"originalValue"`
 	IsPaid         string `json:"isPaid"`
 	PaidAmount     string `json:"paidAmount"`
 	Date           string `json:"date"`
@@ -151,6 +180,14 @@ func main() {
 
 	defer db.Close()
 
+	// ainv import <entity> <file> [--dry-run] imports masters or opening stock from the command line instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImportCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// obtain the cli arguments
 	serviceName := os.Args[1]
 	servicePort := os.Args[2]
@@ -169,13 +206,47 @@ func main() {
 	ainvRouter.HandleFunc("/api/get/all/bills/", GetAllBills).Methods("GET")
 	ainvRouter.HandleFunc("/api/get/all/invoices/", GetAllInvoices).Methods("GET")
 	ainvRouter.HandleFunc("/api/get/rate/", GetRate).Methods("POST")
+	ainvRouter.HandleFunc("/api/get/customsduty/", GetCustomsDuty).Methods("GET")
+	ainvRouter.HandleFunc("/api/get/exchangerates/", GetExchangeRates).Methods("GET")
+	ainvRouter.HandleFunc("/api/get/payments/", GetPayments).Methods("GET")
+	ainvRouter.HandleFunc("/api/get/customer/credit/", GetCustomerCredit).Methods("GET")
+	ainvRouter.HandleFunc("/api/get/creditexceptions/", GetCreditExceptions).Methods("GET")
+	ainvRouter.HandleFunc("/api/get/numberingseries/", GetNumberingSeries).Methods("GET")
+	ainvRouter.HandleFunc("/api/get/tallyledgers/", GetTallyLedgers).Methods("GET")
+	ainvRouter.HandleFunc("/api/salesinvoice/{id}", GetSalesInvoice).Methods("GET")
+	ainvRouter.HandleFunc("/api/billofentry/{id}", GetBillOfEntry).Methods("GET")
+	ainvRouter.HandleFunc("/api/salesinvoice/{id}/pdf/invoice", PrintTaxInvoice).Methods("GET")
+	ainvRouter.HandleFunc("/api/salesinvoice/{id}/pdf/challan", PrintDeliveryChallan).Methods("GET")
+	ainvRouter.HandleFunc("/api/billofentry/{id}/pdf/grn", PrintGoodsReceivedNote).Methods("GET")
+	ainvRouter.HandleFunc("/api/salesinvoice/{id}/einvoice", GetEInvoice).Methods("GET")
+	ainvRouter.HandleFunc("/api/salesinvoice/{id}/ewaybill", GetEwayBill).Methods("GET")
+	ainvRouter.HandleFunc("/api/stocktransfer/{id}", GetStockTransfer).Methods("GET")
+	ainvRouter.HandleFunc("/api/stocktransfer/{id}/ewaybill", GetStockTransferEwayBill).Methods("GET")
+	ainvRouter.HandleFunc("/api/get/ewaybill/missing/", GetMissingEwayBills).Methods("GET")
 
 	ainvRouter.HandleFunc("/api/put/warehouse/", CreateWarehouse).Methods("POST")
 	ainvRouter.HandleFunc("/api/put/itemmaster/", CreateItemMaster).Methods("POST")
 	ainvRouter.HandleFunc("/api/put/transaction/", CreateTransaction).Methods("POST")
 	ainvRouter.HandleFunc("/api/put/client/", CreateClient).Methods("POST")
 	ainvRouter.HandleFunc("/api/put/customer/", CreateCustomer).Methods("POST")
-
+	ainvRouter.HandleFunc("/api/put/exchangerate/", CreateExchangeRate).Methods("POST")
+	ainvRouter.HandleFunc("/api/put/payment/", CreatePayment).Methods("POST")
+	ainvRouter.HandleFunc("/api/put/payment/allocation/", AllocatePayment).Methods("POST")
+	ainvRouter.HandleFunc("/api/put/salesinvoice/", CreateSalesInvoice).Methods("POST")
+	ainvRouter.HandleFunc("/api/put/billofentry/", CreateBillOfEntry).Methods("POST")
+	ainvRouter.HandleFunc("/api/put/numberingseries/", CreateNumberingSeries).Methods("POST")
+	ainvRouter.HandleFunc("/api/put/tallyledger/", CreateTallyLedger).Methods("POST")
+	ainvRouter.HandleFunc("/api/put/stocktransfer/", CreateStockTransfer).Methods("POST")
+
+	ainvRouter.HandleFunc("/api/update/warehouse/", UpdateWarehouse).Methods("POST")
+	ainvRouter.HandleFunc("/api/update/itemmaster/", UpdateItemMaster).Methods("POST")
+	ainvRouter.HandleFunc("/api/update/customer/", UpdateCustomer).Methods("POST")
+	ainvRouter.HandleFunc("/api/update/client/", UpdateClient).Methods("POST")
+	ainvRouter.HandleFunc("/api/update/salesinvoice/status/", UpdateSalesInvoiceStatus).Methods("POST")
+	ainvRouter.HandleFunc("/api/update/billofentry/status/", UpdateBillOfEntryStatus).Methods("POST")
+	ainvRouter.HandleFunc("/api/update/salesinvoice/irn/", RecordEInvoiceRegistration).Methods("POST")
+	ainvRouter.HandleFunc("/api/update/salesinvoice/ewaybill/", RecordEwayBill).Methods("POST")
+	ainvRouter.HandleFunc("/api/update/stocktransfer/ewaybill/", RecordStockTransferEwayBill).Methods("POST")
 	ainvRouter.HandleFunc("/api/update/paidamount/", UpdatePaidAmount).Methods("POST")
 	ainvRouter.HandleFunc("/api/update/paymentdate/", UpdatePaymentDate).Methods("POST")
 	ainvRouter.HandleFunc("/api/update/field1/", UpdateField1).Methods("POST")
@@ -185,12 +256,52 @@ func main() {
 	ainvRouter.HandleFunc("/api/search/items/", SearchItems).Methods("POST")
 	ainvRouter.HandleFunc("/api/search/sales/", SearchSales).Methods("POST")
 	ainvRouter.HandleFunc("/api/search/overview/", SearchOverview).Methods("POST")
+	ainvRouter.HandleFunc("/api/search/valuation/", SearchValuation).Methods("POST")
+	ainvRouter.HandleFunc("/api/search/cogs/", SearchCostOfGoodsSold).Methods("POST")
+	ainvRouter.HandleFunc("/api/search/stock/asof/", SearchStockAsOf).Methods("POST")
+	ainvRouter.HandleFunc("/api/search/ledger/", SearchItemLedger).Methods("POST")
+	ainvRouter.HandleFunc("/api/search/aging/", SearchReceivablesAging).Methods("POST")
+	ainvRouter.HandleFunc("/api/search/gstr1/", SearchGSTR1).Methods("POST")
+	ainvRouter.HandleFunc("/api/search/gstr3b/", SearchGSTR3B).Methods("POST")
+	ainvRouter.HandleFunc("/api/search/tally/", ExportTally).Methods("POST")
+	ainvRouter.HandleFunc("/api/search/global/", GlobalSearch).Methods("POST")
+
+	ainvRouter.HandleFunc("/api/import/{entity}/", ImportMaster).Methods("POST")
+
+	ainvRouter.HandleFunc("/api/reconcile/", ReconcileStock).Methods("POST")
+	ainvRouter.HandleFunc("/api/get/stockdrift/", GetStockDrift).Methods("GET")
+
+	ainvRouter.HandleFunc("/api/notifications/", GetNotifications).Methods("GET")
+	ainvRouter.HandleFunc("/api/notifications/run/", TriggerNotifications).Methods("POST")
 
 	ainvRouter.HandleFunc("/api/register/", RegisterUser).Methods("POST")
 	ainvRouter.HandleFunc("/api/login/", LoginUser).Methods("POST")
 
 	http.Handle("/", router)
 
+	// replay the ledger against inventoryContents periodically, unless disabled with an interval of 0
+	reconciliationInterval, err := time.ParseDuration(os.Getenv("RECONCILIATION_INTERVAL"))
+	if err != nil {
+		reconciliationInterval = 24 * time.Hour
+	}
+	if reconciliationInterval > 0 {
+		StartReconciliationJob(reconciliationInterval)
+	}
+
+	// remind customers of invoices due soon or overdue periodically, unless disabled with an interval of 0
+	notificationSender = notificationSenderFromEnv()
+	if days, err := strconv.Atoi(os.Getenv("DUE_SOON_DAYS")); err == nil && days >= 0 {
+		dueSoonDays = days
+	}
+
+	notificationInterval, err := time.ParseDuration(os.Getenv("NOTIFICATION_INTERVAL"))
+	if err != nil {
+		notificationInterval = time.Hour
+	}
+	if notificationInterval > 0 {
+		StartNotificationJob(notificationInterval)
+	}
+
 	log.Printf("Server started on port %s", servicePort)
 	log.Fatal(http.ListenAndServe(":"+servicePort, nil))
 }
@@ -202,6 +313,22 @@ func GetMD5Hash(text string) string {
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
+// writeFailure writes an unsuccessful status along with the reason for it
+func writeFailure(w http.ResponseWriter, reason string) {
+	result := map[string]interface{}{
+		"success": false,
+		"error":   reason,
+	}
+
+	payloadJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
 // GetRoot returns OK if server is alive
 func GetRoot(w http.ResponseWriter, r *http.Request) {
 	payload := []byte("OK")
@@ -293,13 +420,26 @@ func GetAllWarehouses(w http.ResponseWriter, r *http.Request) {
 // GetAllClients returns all the clients with their ID
 func GetAllClients(w http.ResponseWriter, r *http.Request) {
 
+	page, err := parsePageRequest(r, clientSorting)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
 	var payload []Client
 
 	getClientNamesQuery := `SELECT 
-		id, clientName
+		id, clientName, valuationMethod
 		FROM client`
 
-	allClients, err := db.Query(getClientNamesQuery)
+	total := 0
+	if page.Paged {
+		if total, err = countRows(getClientNamesQuery); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	allClients, err := db.Query(getClientNamesQuery + page.clause())
 	if err != nil {
 		panic(err.Error())
 	}
@@ -307,39 +447,49 @@ func GetAllClients(w http.ResponseWriter, r *http.Request) {
 	for allClients.Next() {
 		var clientId string
 		var clientName string
+		var valuationMethod string
 
-		err := allClients.Scan(&clientId, &clientName)
+		err := allClients.Scan(&clientId, &clientName, &valuationMethod)
 		if err != nil {
 			panic(err.Error())
 		}
 
 		singleObject := Client{
-			ClientId:   clientId,
-			ClientName: clientName,
+			ClientId:        clientId,
+			ClientName:      clientName,
+			ValuationMethod: valuationMethod,
 		}
 
 		payload = append(payload, singleObject)
 	}
 
-	payloadJSON, err := json.Marshal(payload)
-	if err != nil {
-		log.Println(err)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(payloadJSON)
+	writeList(w, page, total, payload)
 }
 
 // GetAllCustomers returns all the clients with their ID
 func GetAllCustomers(w http.ResponseWriter, r *http.Request) {
 
+	page, err := parsePageRequest(r, customerSorting)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
 	var payload []Customer
 
 	getCustomerNamesQuery := `SELECT 
-		id, customerName
+		id, customerName, IFNULL(gstin, ''), IFNULL(placeOfSupply, ''), IFNULL(email, ''),
+		IFNULL(creditLimit, ''), paymentTerms, creditPolicy, IFNULL(address, ''), IFNULL(city, ''), IFNULL(pincode, '')
 		FROM customer`
 
-	allCustomers, err := db.Query(getCustomerNamesQuery)
+	total := 0
+	if page.Paged {
+		if total, err = countRows(getCustomerNamesQuery); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	allCustomers, err := db.Query(getCustomerNamesQuery + page.clause())
 	if err != nil {
 		panic(err.Error())
 	}
@@ -347,39 +497,64 @@ func GetAllCustomers(w http.ResponseWriter, r *http.Request) {
 	for allCustomers.Next() {
 		var customerId string
 		var customerName string
-
-		err := allCustomers.Scan(&customerId, &customerName)
+		var gstin string
+		var placeOfSupply string
+		var email string
+		var creditLimit string
+		var paymentTerms int
+		var creditPolicy string
+		var address string
+		var city string
+		var pincode string
+
+		err := allCustomers.Scan(&customerId, &customerName, &gstin, &placeOfSupply, &email, &creditLimit, &paymentTerms, &creditPolicy, &address, &city, &pincode)
 		if err != nil {
 			panic(err.Error())
 		}
 
 		singleObject := Customer{
-			CustomerId:   customerId,
-			CustomerName: customerName,
+			CustomerId:    customerId,
+			CustomerName:  customerName,
+			Gstin:         gstin,
+			PlaceOfSupply: placeOfSupply,
+			Email:         email,
+			CreditLimit:   creditLimit,
+			PaymentTerms:  paymentTerms,
+			CreditPolicy:  creditPolicy,
+			Address:       address,
+			City:          city,
+			Pincode:       pincode,
 		}
 
 		payload = append(payload, singleObject)
 	}
 
-	payloadJSON, err := json.Marshal(payload)
-	if err != nil {
-		log.Println(err)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(payloadJSON)
+	writeList(w, page, total, payload)
 }
 
 // GetAllBills returns all the Bill of Entry numbers with their IDs
 func GetAllBills(w http.ResponseWriter, r *http.Request) {
 
+	page, err := parsePageRequest(r, billSorting)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
 	var payload []BillOfEntry
 
 	getBillsQuery := `SELECT 
-		id, tracker, entryDate
+		id, tracker, entryDate, currency, exchangeRate
 		FROM billOfEntry`
 
-	allBills, err := db.Query(getBillsQuery)
+	total := 0
+	if page.Paged {
+		if total, err = countRows(getBillsQuery); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	allBills, err := db.Query(getBillsQuery + page.clause())
 	if err != nil {
 		panic(err.Error())
 	}
@@ -388,8 +563,10 @@ func GetAllBills(w http.ResponseWriter, r *http.Request) {
 		var billId string
 		var billNumber string
 		var billDate string
+		var currency string
+		var exchangeRate string
 
-		err := allBills.Scan(&billId, &billNumber, &billDate)
+		err := allBills.Scan(&billId, &billNumber, &billDate, &currency, &exchangeRate)
 		if err != nil {
 			panic(err.Error())
 		}
@@ -398,30 +575,39 @@ func GetAllBills(w http.ResponseWriter, r *http.Request) {
 			BillOfEntryId:     billId,
 			BillOfEntryNumber: billNumber,
 			BillOfEntryDate:   billDate,
+			Currency:          currency,
+			ExchangeRate:      exchangeRate,
 		}
 
 		payload = append(payload, singleObject)
 	}
 
-	payloadJSON, err := json.Marshal(payload)
-	if err != nil {
-		log.Println(err)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(payloadJSON)
+	writeList(w, page, total, payload)
 }
 
 // GetAllInvoices returns all the Sales Invoice numbers with their IDs
 func GetAllInvoices(w http.ResponseWriter, r *http.Request) {
 
+	page, err := parsePageRequest(r, invoiceSorting)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
 	var payload []SalesInvoice
 
 	getInvoicesQuery := `SELECT 
-		id, tracker, entryDate, customerId, (select customerName from customer where id=customerId) as customerId
+		id, tracker, entryDate, customerId, (select customerName from customer where id=customerId) as customerName
 		FROM salesInvoice`
 
-	allInvoices, err := db.Query(getInvoicesQuery)
+	total := 0
+	if page.Paged {
+		if total, err = countRows(getInvoicesQuery); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	allInvoices, err := db.Query(getInvoicesQuery + page.clause())
 	if err != nil {
 		panic(err.Error())
 	}
@@ -449,13 +635,7 @@ func GetAllInvoices(w http.ResponseWriter, r *http.Request) {
 		payload = append(payload, singleObject)
 	}
 
-	payloadJSON, err := json.Marshal(payload)
-	if err != nil {
-		log.Println(err)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(payloadJSON)
+	writeList(w, page, total, payload)
 }
 
 // GetRate returns the rate for a particular item
@@ -556,8 +736,9 @@ func GetItems(w http.ResponseWriter, r *http.Request) {
 
 	getItemDetailsQuery := `SELECT
 		itemName,
-		GROUP_CONCAT(itemVariant SEPARATOR '$') itemVariant,
-		GROUP_CONCAT(id SEPARATOR '$') itemId
+		GROUP_CONCAT(itemVariant ORDER BY id SEPARATOR '$') itemVariant,
+		GROUP_CONCAT(id ORDER BY id SEPARATOR '$') itemId,
+		GROUP_CONCAT(IFNULL(hsnCode, '') ORDER BY id SEPARATOR '$') hsnCode
 	FROM
 		itemMaster
 	GROUP BY
@@ -572,16 +753,26 @@ func GetItems(w http.ResponseWriter, r *http.Request) {
 		var name string
 		var description string
 		var itemId string
+		var hsnCode string
 
-		err := allItems.Scan(&name, &description, &itemId)
+		err := allItems.Scan(&name, &description, &itemId, &hsnCode)
 		if err != nil {
 			panic(err.Error())
 		}
 
+		hsnCodes := strings.Split(hsnCode, "$")
+		var hsnDescriptions []string
+		for _, code := range hsnCodes {
+			hsnDescription, _ := validation.LookupHSN(code)
+			hsnDescriptions = append(hsnDescriptions, hsnDescription)
+		}
+
 		singleObject := Item{
-			Name:        name,
-			Description: strings.Split(description, "$"),
-			ItemId:      strings.Split(itemId, "$"),
+			Name:           name,
+			Description:    strings.Split(description, "$"),
+			ItemId:         strings.Split(itemId, "$"),
+			HsnCode:        hsnCodes,
+			HsnDescription: hsnDescriptions,
 		}
 
 		payload = append(payload, singleObject)
@@ -601,14 +792,27 @@ func CreateWarehouse(w http.ResponseWriter, r *http.Request) {
 
 	warehouseName := r.FormValue("warehouseName")
 	warehouseLocation := r.FormValue("warehouseLocation")
-	gstin := r.FormValue("gstin")
+	gstin := validation.NormalizeGSTIN(r.FormValue("gstin"))
 	contactName := r.FormValue("contactName")
 	contactNumber := r.FormValue("contactNumber")
+	address := strings.TrimSpace(r.FormValue("address"))
+	pincode := validation.NormalizePincode(r.FormValue("pincode"))
+
+	if err := validation.ValidateGSTIN(gstin); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+	if err := validation.ValidatePincode(pincode); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
 
 	warehouseInsertQuery := fmt.Sprintf(`INSERT INTO warehouse
-		(warehouseName, warehouseLocation, gstin, contactName, contactNumber)
+		(warehouseName, warehouseLocation, gstin, contactName, contactNumber, address, pincode)
 		VALUES
-		('%s', '%s', '%s', '%s', '%s')`, warehouseName, warehouseLocation, gstin, contactName, contactNumber)
+		('%s', '%s', '%s', '%s', '%s', NULLIF('%s', ''), NULLIF('%s', ''))`, warehouseName, warehouseLocation, gstin, contactName, contactNumber, escapeQuotes(address), pincode)
 
 	_, err := db.Query(warehouseInsertQuery)
 
@@ -638,13 +842,19 @@ func CreateItemMaster(w http.ResponseWriter, r *http.Request) {
 
 	itemName := r.FormValue("itemName")
 	itemVariant := r.FormValue("itemVariant")
-	hsnCode := r.FormValue("hsnCode")
+	hsnCode := validation.NormalizeHSN(r.FormValue("hsnCode"))
 	uomRaw := r.FormValue("uomRaw")
 	uomSmall := r.FormValue("uomSmall")
 	uomBig := r.FormValue("uomBig")
 	rawPerSmall := r.FormValue("rawPerSmall")
 	smallPerBig := r.FormValue("smallPerBig")
 
+	if err := validation.ValidateHSN(hsnCode); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
 	itemInsertQuery := fmt.Sprintf(`INSERT INTO itemMaster
 	(itemName, itemVariant, hsnCode, uomRaw, uomSmall, uomBig, rawPerSmall, smallPerBig)
 	VALUES
@@ -675,21 +885,169 @@ func CreateItemMaster(w http.ResponseWriter, r *http.Request) {
 	w.Write(payloadJSON)
 }
 
+// UpdateWarehouse updates the details of an existing warehouse and returns the status
+func UpdateWarehouse(w http.ResponseWriter, r *http.Request) {
+
+	warehouseId := r.FormValue("warehouseId")
+	warehouseName := r.FormValue("warehouseName")
+	warehouseLocation := r.FormValue("warehouseLocation")
+	gstin := validation.NormalizeGSTIN(r.FormValue("gstin"))
+	contactName := r.FormValue("contactName")
+	contactNumber := r.FormValue("contactNumber")
+	address := strings.TrimSpace(r.FormValue("address"))
+	pincode := validation.NormalizePincode(r.FormValue("pincode"))
+
+	if err := validation.ValidateGSTIN(gstin); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+	if err := validation.ValidatePincode(pincode); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	warehouseUpdateQuery := fmt.Sprintf(`UPDATE warehouse
+		SET warehouseName = '%s', warehouseLocation = '%s', gstin = '%s', contactName = '%s', contactNumber = '%s',
+		address = NULLIF('%s', ''), pincode = NULLIF('%s', '')
+		WHERE id = '%s'`, warehouseName, warehouseLocation, gstin, contactName, contactNumber, escapeQuotes(address), pincode, warehouseId)
+
+	_, err := db.Query(warehouseUpdateQuery)
+
+	var result map[string]bool
+
+	if err != nil {
+		log.Println(err)
+		result = map[string]bool{
+			"success": false,
+		}
+	} else {
+		result = map[string]bool{
+			"success": true,
+		}
+	}
+
+	payloadJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// UpdateItemMaster updates the details of an existing item and returns the status
+func UpdateItemMaster(w http.ResponseWriter, r *http.Request) {
+
+	itemId := r.FormValue("itemId")
+	itemName := r.FormValue("itemName")
+	itemVariant := r.FormValue("itemVariant")
+	hsnCode := validation.NormalizeHSN(r.FormValue("hsnCode"))
+	uomRaw := r.FormValue("uomRaw")
+	uomSmall := r.FormValue("uomSmall")
+	uomBig := r.FormValue("uomBig")
+	rawPerSmall := r.FormValue("rawPerSmall")
+	smallPerBig := r.FormValue("smallPerBig")
+
+	if err := validation.ValidateHSN(hsnCode); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	itemUpdateQuery := fmt.Sprintf(`UPDATE itemMaster
+		SET itemName = '%s', itemVariant = '%s', hsnCode = '%s', uomRaw = '%s', uomSmall = '%s', uomBig = '%s', rawPerSmall = %s, smallPerBig = %s
+		WHERE id = '%s'`, itemName, itemVariant, hsnCode, uomRaw, uomSmall, uomBig, rawPerSmall, smallPerBig, itemId)
+	log.Println(itemUpdateQuery)
+
+	_, err := db.Query(itemUpdateQuery)
+
+	var result map[string]bool
+
+	if err != nil {
+		log.Println(err)
+		result = map[string]bool{
+			"success": false,
+		}
+	} else {
+		result = map[string]bool{
+			"success": true,
+		}
+	}
+
+	payloadJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
 // CreateClient creates a new client and returns the status
 func CreateClient(w http.ResponseWriter, r *http.Request) {
 
 	clientName := r.FormValue("clientName")
 
+	valuationMethod, err := normalizeValuationMethod(r.FormValue("valuationMethod"))
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
 	clientInsertQuery := fmt.Sprintf(`INSERT INTO client
-		(clientName)
+		(clientName, valuationMethod)
 		VALUES
-		('%s')`, clientName)
+		('%s', '%s')`, clientName, valuationMethod)
+
+	_, err = db.Query(clientInsertQuery)
+
+	var result map[string]bool
+
+	if err != nil {
+		result = map[string]bool{
+			"success": false,
+		}
+	} else {
+		result = map[string]bool{
+			"success": true,
+		}
+	}
+
+	payloadJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// UpdateClient updates the details of an existing client and returns the status
+func UpdateClient(w http.ResponseWriter, r *http.Request) {
+
+	clientId := r.FormValue("clientId")
+	clientName := r.FormValue("clientName")
 
-	_, err := db.Query(clientInsertQuery)
+	valuationMethod, err := normalizeValuationMethod(r.FormValue("valuationMethod"))
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	clientUpdateQuery := fmt.Sprintf(`UPDATE client
+		SET clientName = '%s', valuationMethod = '%s'
+		WHERE id = '%s'`, clientName, valuationMethod, clientId)
+
+	_, err = db.Exec(clientUpdateQuery)
 
 	var result map[string]bool
 
 	if err != nil {
+		log.Println(err)
 		result = map[string]bool{
 			"success": false,
 		}
@@ -712,17 +1070,115 @@ func CreateClient(w http.ResponseWriter, r *http.Request) {
 func CreateCustomer(w http.ResponseWriter, r *http.Request) {
 
 	customerName := r.FormValue("customerName")
+	gstin := validation.NormalizeGSTIN(r.FormValue("gstin"))
+	email := validation.NormalizeEmail(r.FormValue("email"))
+	address := strings.TrimSpace(r.FormValue("address"))
+	city := strings.TrimSpace(r.FormValue("city"))
+	pincode := validation.NormalizePincode(r.FormValue("pincode"))
+
+	placeOfSupply, err := resolvePlaceOfSupply(gstin, r.FormValue("placeOfSupply"))
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	if err := validation.ValidatePincode(pincode); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	if err := validation.ValidateEmail(email); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	creditLimit, paymentTerms, creditPolicy, err := parseCreditTerms(r)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
 
 	customerInsertQuery := fmt.Sprintf(`INSERT INTO customer
-		(customerName)
+		(customerName, gstin, placeOfSupply, email, creditLimit, paymentTerms, creditPolicy, address, city, pincode)
 		VALUES
-		('%s')`, customerName)
+		('%s', NULLIF('%s', ''), NULLIF('%s', ''), NULLIF('%s', ''), NULLIF('%s', ''), '%d', '%s', NULLIF('%s', ''), NULLIF('%s', ''), NULLIF('%s', ''))`, customerName, gstin, placeOfSupply, escapeQuotes(email), creditLimit, paymentTerms, creditPolicy, escapeQuotes(address), escapeQuotes(city), pincode)
+
+	_, err = db.Query(customerInsertQuery)
+
+	var result map[string]bool
+
+	if err != nil {
+		result = map[string]bool{
+			"success": false,
+		}
+	} else {
+		result = map[string]bool{
+			"success": true,
+		}
+	}
 
-	_, err := db.Query(customerInsertQuery)
+	payloadJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// UpdateCustomer updates the details of an existing customer and returns the status
+func UpdateCustomer(w http.ResponseWriter, r *http.Request) {
+
+	customerId := r.FormValue("customerId")
+	customerName := r.FormValue("customerName")
+	gstin := validation.NormalizeGSTIN(r.FormValue("gstin"))
+	email := validation.NormalizeEmail(r.FormValue("email"))
+	address := strings.TrimSpace(r.FormValue("address"))
+	city := strings.TrimSpace(r.FormValue("city"))
+	pincode := validation.NormalizePincode(r.FormValue("pincode"))
+
+	placeOfSupply, err := resolvePlaceOfSupply(gstin, r.FormValue("placeOfSupply"))
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	if err := validation.ValidatePincode(pincode); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	if err := validation.ValidateEmail(email); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	creditLimit, paymentTerms, creditPolicy, err := parseCreditTerms(r)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	customerUpdateQuery := fmt.Sprintf(`UPDATE customer
+		SET customerName = '%s', gstin = NULLIF('%s', ''), placeOfSupply = NULLIF('%s', ''), email = NULLIF('%s', ''),
+		creditLimit = NULLIF('%s', ''), paymentTerms = '%d', creditPolicy = '%s',
+		address = NULLIF('%s', ''), city = NULLIF('%s', ''), pincode = NULLIF('%s', '')
+		WHERE id = '%s'`, customerName, gstin, placeOfSupply, escapeQuotes(email), creditLimit, paymentTerms, creditPolicy, escapeQuotes(address), escapeQuotes(city), pincode, customerId)
+
+	_, err = db.Query(customerUpdateQuery)
 
 	var result map[string]bool
 
 	if err != nil {
+		log.Println(err)
 		result = map[string]bool{
 			"success": false,
 		}
@@ -808,45 +1264,30 @@ func checkCount(rows *sql.Row) (count int) {
 	return count
 }
 
-// CommitInventoryChanges commits the inventory changes to the inventory table
-func CommitInventoryChanges(itemId string, warehouseId string, clientId string, direction string, currentValue string, changeValue string, finalValue string, bigQuantity string, secretRate1 string, secretRate2 string, totalPcs string) bool {
+// postTransactionEffects moves the stock of a transaction line inside its database transaction, refusing it when the
+// stock has changed since the client read the current value, and records the paid amount entered with a sale
+func postTransactionEffects(tx *sql.Tx, transactionId int64, direction string, itemId string, warehouseId string, clientId string, currentValue string, bigQuantity string, secretRate1 string, secretRate2 string, paidAmount string) error {
 	bigQuantityNum, _ := strconv.ParseFloat(bigQuantity, 64)
-	secretRate1Num, _ := strconv.ParseFloat(secretRate1, 64)
-	secretRate2Num, _ := strconv.ParseFloat(secretRate2, 64)
+	currentValueNum, _ := strconv.ParseFloat(currentValue, 64)
+	rates := ItemRates{}
+	rates.SmallPerBig, _ = strconv.ParseFloat(secretRate1, 64)
+	rates.RawPerSmall, _ = strconv.ParseFloat(secretRate2, 64)
 
-	if direction == "out" {
-		bigQuantityNum = -bigQuantityNum
+	change, err := postInventoryChange(tx, itemId, warehouseId, clientId, direction, bigQuantityNum, rates)
+	if err != nil {
+		return err
 	}
-
-	smallboxQuantityNum := bigQuantityNum * secretRate1Num
-	itemQuantityNum := smallboxQuantityNum * secretRate2Num
-
-	var executionQuery string
-
-	updateQuery := fmt.Sprintf(`UPDATE inventoryContents
-		SET bigcartonQuantity = bigcartonQuantity + %f, smallboxQuantity = smallboxQuantity + %f, itemQuantity = itemQuantity + %f
-		WHERE itemId = '%s' AND warehouseId = '%s' AND clientId = '%s' AND bigcartonQuantity = '%s'`, bigQuantityNum, smallboxQuantityNum, itemQuantityNum, itemId, warehouseId, clientId, currentValue)
-
-	insertQuery := fmt.Sprintf(`INSERT INTO inventoryContents
-		(itemId, itemQuantity, smallboxQuantity, bigcartonQuantity, warehouseId, clientId)
-		VALUES
-		('%s', '%f', '%f', '%s', '%s', '%s')`, itemId, itemQuantityNum, smallboxQuantityNum, bigQuantity, warehouseId, clientId)
-
-	checkerQuery := fmt.Sprintf(`SELECT COUNT(*) FROM inventoryContents WHERE itemId = '%s' AND warehouseId = '%s' AND clientId = '%s'`, itemId, warehouseId, clientId)
-	countRow := db.QueryRow(checkerQuery)
-	if checkCount(countRow) < 1 {
-		executionQuery = insertQuery
-	} else {
-		executionQuery = updateQuery
+	if math.Abs(change.CurrentValue-currentValueNum) > driftTolerance {
+		return fmt.Errorf("item %s now has %g in stock at warehouse %s, not %s", itemId, change.CurrentValue, warehouseId, currentValue)
 	}
 
-	_, err := db.Query(executionQuery)
-	if err != nil {
-		log.Println(err)
-		return false
+	// a paid amount entered along with a sale is recorded as a receipt, so that it is derived like every other
+	paidAmountNum, _ := strconv.ParseFloat(paidAmount, 64)
+	if direction == "out" && paidAmountNum > 0 {
+		return adjustPaidAmount(tx, strconv.FormatInt(transactionId, 10), roundPaise(paidAmountNum))
 	}
 
-	return true
+	return nil
 }
 
 // CreateTransaction creates a transaction
@@ -880,6 +1321,12 @@ func CreateTransaction(w http.ResponseWriter, r *http.Request) {
 	field1 := r.FormValue("field1")
 	field2 := r.FormValue("field2")
 	remarks := r.FormValue("remarks")
+	placeOfSupply := r.FormValue("placeOfSupply")
+	currency := r.FormValue("currency")
+	exchangeRate := r.FormValue("exchangeRate")
+	overrideUsername := r.FormValue("overrideUsername")
+	overridePassword := r.FormValue("overridePassword")
+	overrideReason := r.FormValue("overrideReason")
 
 	changeValue = strings.TrimSpace(changeValue)
 	if date == "Expected Date" {
@@ -905,34 +1352,124 @@ func CreateTransaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the place of supply of a line, when given, overrides that of the customer and has to be a known state
+	if placeOfSupply != "" {
+		if _, ok := validation.StateCodes[placeOfSupply]; !ok {
+			writeFailure(w, fmt.Sprintf("place of supply %q is not a known state code", placeOfSupply))
+			return
+		}
+	}
+
+	// lines added to an existing bill of entry are in the currency of that bill, at its rate, unless stated otherwise
+	if comeOrGo == "in" && oldOrNew != "New!" {
+		var billCurrency string
+		var billRate float64
+		var billStatus string
+		billQuery := fmt.Sprintf(`SELECT currency, IFNULL(exchangeRate, 0), status FROM billOfEntry WHERE tracker='%s'`, trackingNumber)
+		db.QueryRow(billQuery).Scan(&billCurrency, &billRate, &billStatus)
+
+		if billStatus == BillClosed {
+			writeFailure(w, fmt.Sprintf("bill of entry %s is closed", trackingNumber))
+			return
+		}
+		// the stock of a pending bill comes in with its own lines when it is received, not line by line
+		if billStatus == BillPending {
+			writeFailure(w, fmt.Sprintf("bill of entry %s is pending, add its lines before it is received", trackingNumber))
+			return
+		}
+		if currency == "" {
+			currency = billCurrency
+		}
+		if exchangeRate == "" && billRate > 0 && NormalizeCurrency(currency) == NormalizeCurrency(billCurrency) {
+			exchangeRate = strconv.FormatFloat(billRate, 'f', -1, 64)
+		}
+	}
+	currency = NormalizeCurrency(currency)
+
+	exchangeRateNum, err := resolveExchangeRate(currency, exchangeRate, entryDate)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	// customs assesses an import in rupees, so the values of an inbound line are in the base currency already and only
+	// the foreign invoice value of its duty breakdown is in the currency of the bill
+	lineCurrency, lineRate := currency, exchangeRateNum
+	if comeOrGo == "in" {
+		lineCurrency, lineRate = BaseCurrency, 1
+	}
+
+	customsDuty, hasCustomsDuty, err := parseCustomsDuty(r, assdValue, totalPcs)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+	if customsDuty.ExchangeRate == 0 {
+		customsDuty.ExchangeRate = exchangeRateNum
+	}
+	if comeOrGo == "in" && hasCustomsDuty {
+		if err := CustomsDutyQualityCheck(customsDuty, dutyValue, gstValue); err != nil {
+			log.Println(err)
+			writeFailure(w, err.Error())
+			return
+		}
+	}
+
+	// a sale past the credit limit of the customer is held unless the customer is only flagged or the limit is overridden
+	var creditCheck CreditCheck
+	var creditOverride *CreditOverride
+	if comeOrGo == "out" {
+		totalValueNum, _ := strconv.ParseFloat(totalValue, 64)
+
+		creditCheck, err = CheckCreditLimit(customerId, totalValueNum*exchangeRateNum)
+		if err != nil {
+			log.Println(err)
+			writeFailure(w, err.Error())
+			return
+		}
+
+		if creditCheck.Exceeded && creditCheck.Policy != CreditPolicyFlag {
+			override, err := authorizeCreditOverride(overrideUsername, overridePassword, overrideReason)
+			if err != nil {
+				log.Println(err)
+				writeFailure(w, fmt.Sprintf("%s: outstanding %.2f plus %.2f exceeds the credit limit of %.2f", err.Error(), creditCheck.Outstanding, creditCheck.NewValue, creditCheck.CreditLimit))
+				return
+			}
+			creditOverride = &override
+		}
+	}
+
+	// the header of a new document, its number and its first line go in together, so that a failed line leaves no gap
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
 	if comeOrGo == "in" {
 		billRef = trackingNumber
 		trackingNumber = "NULL"
 
 		if oldOrNew == "New!" {
 
-			beEntryQuery := fmt.Sprintf(`
-				INSERT INTO billOfEntry (tracker, entryDate, customerId) VALUES ('%s', '%s', '%s')
-			`, billRef, entryDate, clientId)
-			_, err := db.Query(beEntryQuery)
+			// a new bill of entry without a number takes the next one from the series of the warehouse
+			beId, beNumber, err := createDocumentHeader(tx, DocumentBillOfEntry, warehouseId, entryDate, billRef, func(number string) string {
+				return fmt.Sprintf(`
+				INSERT INTO billOfEntry (tracker, entryDate, customerId, currency, exchangeRate) VALUES ('%s', '%s', '%s', '%s', '%f')
+			`, number, entryDate, clientId, currency, exchangeRateNum)
+			})
 
 			if err != nil {
+				tx.Rollback()
 				log.Println(err)
-				result = map[string]bool{
-					"success": false,
-				}
-			} else {
-				result = map[string]bool{
-					"success": true,
-				}
+				writeFailure(w, err.Error())
+				return
 			}
 
-			beIdSelectQuery := fmt.Sprintf(`
-				SELECT id FROM billOfEntry WHERE tracker='%s'
-			`, billRef)
-
-			beData := db.QueryRow(beIdSelectQuery)
-			beData.Scan(&billRef)
+			log.Printf("created bill of entry %s", beNumber)
+			billRef = strconv.FormatInt(beId, 10)
 
 		} else {
 
@@ -940,7 +1477,7 @@ func CreateTransaction(w http.ResponseWriter, r *http.Request) {
 				SELECT id FROM billOfEntry WHERE tracker='%s'
 			`, billRef)
 
-			beData := db.QueryRow(beIdSelectQuery)
+			beData := tx.QueryRow(beIdSelectQuery)
 			beData.Scan(&billRef)
 
 			billRef = fmt.Sprintf("'%s'", billRef)
@@ -948,63 +1485,110 @@ func CreateTransaction(w http.ResponseWriter, r *http.Request) {
 
 	} else {
 		if oldOrNew == "New!" {
-			siEntryQuery := fmt.Sprintf(`
+			// a new sales invoice without a number takes the next one from the series of the warehouse
+			siId, siNumber, err := createDocumentHeader(tx, DocumentSalesInvoice, warehouseId, entryDate, trackingNumber, func(number string) string {
+				return fmt.Sprintf(`
 				INSERT INTO salesInvoice (tracker, entryDate, customerId) VALUES ('%s', '%s', '%s')
-			`, trackingNumber, entryDate, customerId)
-			_, err := db.Query(siEntryQuery)
+			`, number, entryDate, customerId)
+			})
 
 			if err != nil {
+				tx.Rollback()
 				log.Println(err)
-				result = map[string]bool{
-					"success": false,
-				}
-			} else {
-				result = map[string]bool{
-					"success": true,
-				}
+				writeFailure(w, err.Error())
+				return
 			}
 
-			siSelectQuery := fmt.Sprintf(`
-				SELECT id FROM salesInvoice WHERE tracker='%s'
-			`, trackingNumber)
-
-			siData := db.QueryRow(siSelectQuery)
-			siData.Scan(&trackingNumber)
-
-			trackingNumber = fmt.Sprintf("'%s'", trackingNumber)
+			log.Printf("created sales invoice %s", siNumber)
+			trackingNumber = fmt.Sprintf("'%d'", siId)
 		} else {
+			// a line added to an existing invoice must be sent under the same GSTIN as the lines already on it
+			warehouseIds := []string{warehouseId}
+			rows, err := tx.Query(`SELECT DISTINCT warehouseId FROM transaction WHERE salesInvoice = ?`, strings.Trim(oldOrNew, "'"))
+			if err == nil {
+				for rows.Next() {
+					var lineWarehouseId string
+					rows.Scan(&lineWarehouseId)
+					warehouseIds = append(warehouseIds, lineWarehouseId)
+				}
+				err = rows.Close()
+			}
+			if err == nil {
+				_, err = sellerGstin(tx, warehouseIds)
+			}
+			if err != nil {
+				tx.Rollback()
+				log.Println(err)
+				writeFailure(w, err.Error())
+				return
+			}
+
 			trackingNumber = oldOrNew
 		}
 	}
 
+	gstSplit := ComputeGSTSplit(tx, comeOrGo, warehouseId, customerId, placeOfSupply, gstValue)
+
 	transactionQuery := fmt.Sprintf(`INSERT INTO transaction
-	(billOfEntry, salesInvoice, itemId, warehouseId, comeOrGo, clientId, customerId, bigQuantity, currentValue, changeValue, finalValue, secretRate1, secretRate2, totalPcs, assdValue, dutyValue, gstValue, totalValue, valuePerPiece, totalPieces, isPaid, paidAmount, date, delvDate1, delvDate2, remarks)
+	(billOfEntry, salesInvoice, itemId, warehouseId, comeOrGo, clientId, customerId, bigQuantity, currentValue, changeValue, finalValue, secretRate1, secretRate2, totalPcs, assdValue, dutyValue, gstValue, cgstValue, sgstValue, igstValue, placeOfSupply, totalValue, currency, exchangeRate, valuePerPiece, totalPieces, isPaid, paidAmount, date, delvDate1, delvDate2, remarks)
 	VALUES
-	(%s, %s, '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', %s, '%s', '%s', '%s', '%s', '%s')`, billRef, trackingNumber, itemId, warehouseId, comeOrGo, clientId, customerId, bigQuantity, currentValue, changeValue, finalValue, secretRate1, secretRate2, totalPcs, assdValue, dutyValue, gstValue, totalValue, valuePerPiece, totalPieces, isPaid, paidAmount, date, field1, field2, remarks)
+	(%s, %s, '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%s', '%.2f', '%.2f', '%.2f', NULLIF('%s', ''), '%s', '%s', '%f', '%s', '%s', %s, '%s', '%s', '%s', '%s', '%s')`, billRef, trackingNumber, itemId, warehouseId, comeOrGo, clientId, customerId, bigQuantity, currentValue, changeValue, finalValue, secretRate1, secretRate2, totalPcs, assdValue, dutyValue, gstValue, gstSplit.CgstValue, gstSplit.SgstValue, gstSplit.IgstValue, gstSplit.PlaceOfSupply, totalValue, lineCurrency, lineRate, valuePerPiece, totalPieces, isPaid, paidAmount, date, field1, field2, remarks)
 
 	fmt.Println(transactionQuery)
-	_, err := db.Query(transactionQuery)
 
+	transactionResult, err := tx.Exec(transactionQuery)
 	if err != nil {
+		tx.Rollback()
 		log.Println(err)
-		result = map[string]bool{
-			"success": false,
-		}
-	} else {
-		result = map[string]bool{
-			"success": true,
+		writeFailure(w, err.Error())
+		return
+	}
+
+	transactionId, _ := transactionResult.LastInsertId()
+
+	// a sale let through past the credit limit goes in only along with the exception that records it
+	if creditCheck.Exceeded {
+		if err := recordCreditException(tx, transactionId, creditCheck, creditOverride); err != nil {
+			tx.Rollback()
+			log.Println(err)
+			writeFailure(w, fmt.Sprintf("credit exception could not be recorded: %s", err.Error()))
+			return
 		}
 	}
 
-	if err == nil {
-		commitStatus := CommitInventoryChanges(itemId, warehouseId, clientId, comeOrGo, currentValue, changeValue, finalValue, bigQuantity, secretRate1, secretRate2, totalPcs)
-		if !commitStatus {
-			result = map[string]bool{
-				"success": false,
-			}
+	// the stock, the receipt and the landed cost of the line go in with it, so that a failure leaves none of them behind
+	if err := postTransactionEffects(tx, transactionId, comeOrGo, itemId, warehouseId, clientId, currentValue, bigQuantity, secretRate1, secretRate2, paidAmount); err != nil {
+		tx.Rollback()
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	if comeOrGo == "in" {
+		var costStatus bool
+		if hasCustomsDuty {
+			costStatus = CommitCustomsDuty(tx, transactionId, ComputeLandedCost(customsDuty))
+		} else {
+			costStatus = commitLandedCost(tx, transactionId, flatLandedCostPerPiece(assdValue, dutyValue, totalPcs))
+		}
+
+		if !costStatus {
+			tx.Rollback()
+			writeFailure(w, "the landed cost of the line could not be recorded")
+			return
 		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	result = map[string]bool{
+		"success": true,
+	}
+
 	payloadJSON, err := json.Marshal(result)
 	if err != nil {
 		log.Println(err)
@@ -1047,6 +1631,17 @@ func SearchItems(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		panic(err.Error())
 	}
+	defer allContents.Close()
+
+	// spreadsheets are written out row by row as they are read instead of being collected first
+	var table *tableWriter
+	if format := requestedFormat(r); format != FormatJSON {
+		table, err = newTableWriter(w, format, "stock", itemInventoryHeader)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
 
 	for allContents.Next() {
 		var itemName string
@@ -1067,10 +1662,13 @@ func SearchItems(w http.ResponseWriter, r *http.Request) {
 			panic(err.Error())
 		}
 
+		hsnDescription, _ := validation.LookupHSN(hsnCode)
+
 		singleObject := ItemInventory{
 			ItemName:          itemName,
 			ItemVariant:       itemVariant,
 			HsnCode:           hsnCode,
+			HsnDescription:    hsnDescription,
 			ItemQuantity:      itemQuantity,
 			UomRaw:            uomRaw,
 			SmallboxQuantity:  smallboxQuantity,
@@ -1082,9 +1680,24 @@ func SearchItems(w http.ResponseWriter, r *http.Request) {
 			ClientName:        clientName,
 		}
 
+		if table != nil {
+			if err := table.WriteRow(itemInventoryRow(singleObject)); err != nil {
+				log.Println(err)
+				return
+			}
+			continue
+		}
+
 		payload = append(payload, singleObject)
 	}
 
+	if table != nil {
+		if err := table.Close(); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
 		log.Println(err)
@@ -1094,43 +1707,29 @@ func SearchItems(w http.ResponseWriter, r *http.Request) {
 	w.Write(payloadJSON)
 }
 
-// SearchSales searches for the sales transactions by filters
+// SearchSales searches for the sales transactions by filters; receipts, opening stock and transfers have no customer
 func SearchSales(w http.ResponseWriter, r *http.Request) {
 
-	billOfEntry := r.FormValue("billOfEntry")
-	clientId := r.FormValue("clientId")
-	customerId := r.FormValue("customerId")
-	searchFilter := r.FormValue("filter")
+	filter, err := parseTransactionFilter(r)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
 
-	var payload []SalesTransaction
-	var searchQuery string
-
-	var filterSubstring string
-	var billOrSales string
-	if searchFilter == "in" {
-		filterSubstring = " AND tr.comeOrGo = 'in'"
-		billOrSales = "billOfEntry"
-	} else if searchFilter == "out" {
-		filterSubstring = " AND tr.comeOrGo = 'out'"
-		billOrSales = "salesInvoice"
-	} else {
-		filterSubstring = ""
-		billOrSales = "salesInvoice"
+	page, err := parsePageRequest(r, salesSorting)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
 	}
 
+	var payload []SalesTransaction
+
 	searchQuerySubstring := fmt.Sprintf(`
 		SELECT
 		tr.id,
 		IFNULL((select tracker from billOfEntry where id=tr.billOfEntry), 'N/A') as billOfEntry,
 		IFNULL((select tracker from salesInvoice where id=tr.salesInvoice), 'N/A') as salesInvoice,
-	IFNULL((
-	SELECT
-		entryDate
-	FROM
-		%s
-	WHERE
-		id = tr.%s
-	), 'N/A') AS entryDate,
+	IFNULL(%s, 'N/A') AS entryDate,
 	tr.itemId,
 	im.itemName,
 	im.itemVariant,
@@ -1138,60 +1737,66 @@ func SearchSales(w http.ResponseWriter, r *http.Request) {
 	wh.warehouseLocation,
 	tr.clientId,
 	cl.clientName,
-	tr.customerId,
-	cu.customerName,
+	IFNULL(tr.customerId, ''),
+	IFNULL(cu.customerName, 'N/A'),
 	tr.comeOrGo,
 	tr.changeValue,
 	tr.finalValue,
 	tr.totalPcs,
 	tr.dutyValue,
 	tr.gstValue,
+	tr.cgstValue,
+	tr.sgstValue,
+	tr.igstValue,
+	IFNULL(tr.placeOfSupply, ''),
 	tr.totalValue,
+	tr.currency,
+	tr.exchangeRate,
 	tr.isPaid,
 	tr.paidAmount,
+	tr.paymentStatus,
+	tr.totalValue - tr.paidAmount,
 	tr.date,
 	tr.delvDate1,
 	tr.delvDate2,
 	tr.remarks,
-	im.uomRaw
+	im.uomRaw,
+	im.uomBig
 	FROM transaction
-		tr,
-		itemMaster im,
-		warehouse wh,
-		client cl,
-		customer cu
+		tr
+		JOIN itemMaster im ON tr.itemId = im.id
+		JOIN warehouse wh ON tr.warehouseId = wh.id
+		JOIN client cl ON tr.clientId = cl.id
+		LEFT JOIN customer cu ON tr.customerId = cu.id
 	WHERE
-		tr.itemId = im.id AND tr.warehouseId = wh.id AND tr.clientId = cl.id AND tr.customerId = cu.id
-	`, billOrSales, billOrSales)
-
-	searchQuerySubstring = searchQuerySubstring + filterSubstring
-
-	trackingNumberSubstring := fmt.Sprintf("tr.billOfEntry = '%s'", billOfEntry)
-	clientIdSubstring := fmt.Sprintf("tr.clientId = '%s'", clientId)
-	customerIdSubstring := fmt.Sprintf("tr.customerId = '%s'", customerId)
-
-	if billOfEntry == "all" && clientId == "all" && customerId == "all" {
-		searchQuery = fmt.Sprintf("%s", searchQuerySubstring)
-	} else if billOfEntry == "all" && customerId == "all" {
-		searchQuery = fmt.Sprintf("%s AND %s", searchQuerySubstring, clientIdSubstring)
-	} else if clientId == "all" && customerId == "all" {
-		searchQuery = fmt.Sprintf("%s AND %s", searchQuerySubstring, trackingNumberSubstring)
-	} else if billOfEntry == "all" && clientId == "all" {
-		searchQuery = fmt.Sprintf("%s AND %s", searchQuerySubstring, customerIdSubstring)
-	} else if billOfEntry == "all" {
-		searchQuery = fmt.Sprintf("%s AND %s AND %s", searchQuerySubstring, clientIdSubstring, customerIdSubstring)
-	} else if clientId == "all" {
-		searchQuery = fmt.Sprintf("%s AND %s AND %s", searchQuerySubstring, trackingNumberSubstring, customerIdSubstring)
-	} else if customerId == "all" {
-		searchQuery = fmt.Sprintf("%s AND %s AND %s", searchQuerySubstring, trackingNumberSubstring, clientIdSubstring)
-	} else {
-		searchQuery = fmt.Sprintf("%s AND %s AND %s AND %s", searchQuerySubstring, trackingNumberSubstring, clientIdSubstring, customerIdSubstring)
+		1=1
+	`, transactionEntryDate)
+
+	searchQuery := searchQuerySubstring + filter.where()
+
+	format := requestedFormat(r)
+
+	total := 0
+	if page.Paged && format == FormatJSON {
+		if total, err = countRows(searchQuery); err != nil {
+			panic(err.Error())
+		}
 	}
 
-	allTransactions, err := db.Query(searchQuery)
+	allTransactions, err := db.Query(searchQuery + page.clause())
 	if err != nil {
 		panic(err.Error())
 	}
+	defer allTransactions.Close()
+
+	var table *tableWriter
+	if format != FormatJSON {
+		table, err = newTableWriter(w, format, "transactions", salesTransactionHeader)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
 
 	for allTransactions.Next() {
 		var transactionId string
@@ -1213,24 +1818,38 @@ func SearchSales(w http.ResponseWriter, r *http.Request) {
 		var totalPcs string
 		var materialValue string
 		var gstValue string
+		var cgstValue string
+		var sgstValue string
+		var igstValue string
+		var placeOfSupply string
 		var totalValue string
+		var currency string
+		var exchangeRate string
 		var valuePerPiece float64
 		var isPaid string
 		var paidAmount string
+		var paymentStatus string
+		var outstanding string
 		var paymentDate string
 		var field1 string
 		var field2 string
 		var remarks string
 		var rawUnit string
+		var bigUnit string
 
-		err := allTransactions.Scan(&transactionId, &billOfEntry, &salesInvoice, &entryDate, &itemId, &itemName, &itemVariant, &warehouseName, &warehouseLocation, &clientId, &clientName, &customerId, &customerName, &comeOrGo, &changeValue, &finalValue, &totalPcs, &materialValue, &gstValue, &totalValue, &isPaid, &paidAmount, &paymentDate, &field1, &field2, &remarks, &rawUnit)
+		err := allTransactions.Scan(&transactionId, &billOfEntry, &salesInvoice, &entryDate, &itemId, &itemName, &itemVariant, &warehouseName, &warehouseLocation, &clientId, &clientName, &customerId, &customerName, &comeOrGo, &changeValue, &finalValue, &totalPcs, &materialValue, &gstValue, &cgstValue, &sgstValue, &igstValue, &placeOfSupply, &totalValue, &currency, &exchangeRate, &isPaid, &paidAmount, &paymentStatus, &outstanding, &paymentDate, &field1, &field2, &remarks, &rawUnit, &bigUnit)
 		if err != nil {
 			panic(err.Error())
 		}
 
 		totalValueFloat, _ := strconv.ParseFloat(totalValue, 64)
 		totalPcsFloat, _ := strconv.ParseFloat(totalPcs, 64)
-		valuePerPiece = totalValueFloat / totalPcsFloat
+		if totalPcsFloat != 0 {
+			valuePerPiece = totalValueFloat / totalPcsFloat
+		}
+
+		exchangeRateFloat, _ := strconv.ParseFloat(exchangeRate, 64)
+		baseTotalValue := toBaseCurrency(totalValue, exchangeRateFloat)
 
 		singleObject := SalesTransaction{
 			TransactionId:     transactionId,
@@ -1252,55 +1871,70 @@ func SearchSales(w http.ResponseWriter, r *http.Request) {
 			TotalPcs:          totalPcs,
 			MaterialValue:     materialValue,
 			GstValue:          gstValue,
+			CgstValue:         cgstValue,
+			SgstValue:         sgstValue,
+			IgstValue:         igstValue,
+			PlaceOfSupply:     placeOfSupply,
 			TotalValue:        totalValue,
+			Currency:          currency,
+			ExchangeRate:      exchangeRate,
+			BaseTotalValue:    baseTotalValue,
 			ValuePerPiece:     valuePerPiece,
 			IsPaid:            isPaid,
 			PaidAmount:        paidAmount,
+			PaymentStatus:     paymentStatus,
+			Outstanding:       outstanding,
 			PaymentDate:       paymentDate,
 			Field1:            field1,
 			Field2:            field2,
 			Remarks:           remarks,
 			RawUnit:           rawUnit,
+			BigUnit:           bigUnit,
+		}
+
+		if table != nil {
+			if err := table.WriteRow(salesTransactionRow(singleObject)); err != nil {
+				log.Println(err)
+				return
+			}
+			continue
 		}
 
 		payload = append(payload, singleObject)
 	}
 
-	payloadJSON, err := json.Marshal(payload)
-	if err != nil {
-		log.Println(err)
+	if table != nil {
+		if err := table.Close(); err != nil {
+			log.Println(err)
+		}
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(payloadJSON)
+	writeList(w, page, total, payload)
 }
 
 // SearchOverview searches overview of transactions by filters
 func SearchOverview(w http.ResponseWriter, r *http.Request) {
 
-	salesInvoiceNumber := r.FormValue("salesInvoiceNumber")
-	clientId := r.FormValue("clientId")
-	customerId := r.FormValue("customerId")
-	searchFilter := r.FormValue("filter")
 	itemFilter := r.FormValue("itemName")
 
-	var payload []OverviewTransaction
-	var searchQuery string
+	filter, err := parseTransactionFilter(r)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
 
-	var filterSubstring string
-	if searchFilter == "in" {
-		filterSubstring = " AND temp.direction = 'in'"
-	} else if searchFilter == "out" {
-		filterSubstring = " AND temp.direction = 'out'"
-	} else {
-		filterSubstring = ""
+	page, err := parsePageRequest(r, overviewSorting)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
 	}
 
+	var payload []OverviewTransaction
+	// the item name matches exactly as before, on top of the item ids of the filter
 	var itemFilterSubstring string
-	if itemFilter == "all" || itemFilter == "" {
-		itemFilterSubstring = ""
-	} else {
-		itemFilterSubstring = fmt.Sprintf(" AND temp.item = '%s'", itemFilter)
+	if isFiltered(itemFilter) {
+		itemFilterSubstring = fmt.Sprintf(" AND temp.item = '%s'", escapeQuotes(itemFilter))
 	}
 
 	searchQuerySubstring := fmt.Sprintf(`SELECT * FROM
@@ -1319,6 +1953,8 @@ func SearchOverview(w http.ResponseWriter, r *http.Request) {
 		GROUP_CONCAT(DISTINCT(customer)) as customer, 
 		sum(bigQuantity) as bigQuantity, 
 		sum(totalValue) as totalValue, 
+		GROUP_CONCAT(DISTINCT(currency)) as currency, 
+		sum(originalValue) as originalValue, 
 		GROUP_CONCAT(DISTINCT(isPaid)) as isPaid, 
 		sum(paidAmount) as paidAmount, 
 		GROUP_CONCAT(DISTINCT(date)) as date 
@@ -1414,51 +2050,47 @@ func SearchOverview(w http.ResponseWriter, r *http.Request) {
 			) SEPARATOR ' '
 			) AS customer, 
 			Sum(bigQuantity) AS bigQuantity, 
-			Sum(totalValue) AS totalValue, 
+			Sum(totalValue * exchangeRate) AS totalValue, 
+			GROUP_CONCAT(DISTINCT(currency)) AS currency, 
+			Sum(totalValue) AS originalValue, 
 			'...' AS isPaid, 
-			Sum(paidAmount) AS paidAmount, 
+			Sum(paidAmount * exchangeRate) AS paidAmount, 
 			'...' AS date 
 		FROM 
-			transaction 
+			transaction tr
 		WHERE
-			isError=0
+			isError=0%s
 		GROUP BY 
 			billOfEntry, 
 			salesInvoice
 		) agg WHERE 1=1
-	`)
-
-	trackingNumberSubstring := fmt.Sprintf("(agg.billOfEntry = '%s' OR agg.salesInvoice = '%s')", salesInvoiceNumber, salesInvoiceNumber)
-	clientIdSubstring := fmt.Sprintf("agg.clientId = '%s'", clientId)
-	customerIdSubstring := fmt.Sprintf("agg.customerId = '%s'", customerId)
-
-	if salesInvoiceNumber == "all" && clientId == "all" && customerId == "all" {
-		searchQuery = fmt.Sprintf("%s", searchQuerySubstring)
-	} else if salesInvoiceNumber == "all" && customerId == "all" {
-		searchQuery = fmt.Sprintf("%s AND %s", searchQuerySubstring, clientIdSubstring)
-	} else if clientId == "all" && customerId == "all" {
-		searchQuery = fmt.Sprintf("%s AND %s", searchQuerySubstring, trackingNumberSubstring)
-	} else if salesInvoiceNumber == "all" && clientId == "all" {
-		searchQuery = fmt.Sprintf("%s AND %s", searchQuerySubstring, customerIdSubstring)
-	} else if salesInvoiceNumber == "all" {
-		searchQuery = fmt.Sprintf("%s AND %s AND %s", searchQuerySubstring, clientIdSubstring, customerIdSubstring)
-	} else if clientId == "all" {
-		searchQuery = fmt.Sprintf("%s AND %s AND %s", searchQuerySubstring, trackingNumberSubstring, customerIdSubstring)
-	} else if customerId == "all" {
-		searchQuery = fmt.Sprintf("%s AND %s AND %s", searchQuerySubstring, trackingNumberSubstring, clientIdSubstring)
-	} else {
-		searchQuery = fmt.Sprintf("%s AND %s AND %s AND %s", searchQuerySubstring, trackingNumberSubstring, clientIdSubstring, customerIdSubstring)
-	}
+	`, filter.where())
+
+	searchQuery := searchQuerySubstring + " group by billOfEntry, direction) temp WHERE 1=1" + itemFilterSubstring
 
-	searchQuery = searchQuery + " group by billOfEntry, direction) temp WHERE 1=1"
-	searchQuery = searchQuery + filterSubstring
-	searchQuery = searchQuery + itemFilterSubstring
-	searchQuery = searchQuery + " ORDER BY temp.billOfEntryId DESC"
+	format := requestedFormat(r)
 
-	allTransactions, err := db.Query(searchQuery)
+	total := 0
+	if page.Paged && format == FormatJSON {
+		if total, err = countRows(searchQuery); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	allTransactions, err := db.Query(searchQuery + page.clause())
 	if err != nil {
 		panic(err.Error())
 	}
+	defer allTransactions.Close()
+
+	var table *tableWriter
+	if format != FormatJSON {
+		table, err = newTableWriter(w, format, "overview", overviewHeader)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
 
 	for allTransactions.Next() {
 		var billOfEntryId string
@@ -1475,20 +2107,23 @@ func SearchOverview(w http.ResponseWriter, r *http.Request) {
 		var customer string
 		var bigQuantity string
 		var totalValue string
+		var currency string
+		var originalValue string
 		var isPaid string
 		var paidAmount string
 		var date string
 
-		err := allTransactions.Scan(&billOfEntryId, &billOfEntry, &salesInvoiceId, &salesInvoice, &direction, &entryDate, &item, &warehouse, &clientId, &client, &customerId, &customer, &bigQuantity, &totalValue, &isPaid, &paidAmount, &date)
+		err := allTransactions.Scan(&billOfEntryId, &billOfEntry, &salesInvoiceId, &salesInvoice, &direction, &entryDate, &item, &warehouse, &clientId, &client, &customerId, &customer, &bigQuantity, &totalValue, &currency, &originalValue, &isPaid, &paidAmount, &date)
 		if err != nil {
 			panic(err.Error())
 		}
 
-		if len(salesInvoice) > 30 {
+		// the list on screen shortens long values, which spreadsheets show in full
+		if table == nil && len(salesInvoice) > 30 {
 			salesInvoice = salesInvoice[:30] + "..."
 		}
 
-		if len(customer) > 30 {
+		if table == nil && len(customer) > 30 {
 			customer = customer[:30] + "..."
 		}
 
@@ -1505,39 +2140,60 @@ func SearchOverview(w http.ResponseWriter, r *http.Request) {
 			Customer:       customer,
 			BigQuantity:    bigQuantity,
 			TotalValue:     totalValue,
+			Currency:       currency,
+			OriginalValue:  originalValue,
 			IsPaid:         isPaid,
 			PaidAmount:     paidAmount,
 			Date:           date,
 		}
 
+		if table != nil {
+			if err := table.WriteRow(overviewRow(singleObject)); err != nil {
+				log.Println(err)
+				return
+			}
+			continue
+		}
+
 		payload = append(payload, singleObject)
 	}
 
-	payloadJSON, err := json.Marshal(payload)
-	if err != nil {
-		log.Println(err)
+	if table != nil {
+		if err := table.Close(); err != nil {
+			log.Println(err)
+		}
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(payloadJSON)
+	writeList(w, page, total, payload)
 }
 
-// UpdatePaidAmount updates the expected payment date for a particular transaction and returns the status
+// UpdatePaidAmount sets the paid amount of a particular transaction by recording an adjustment receipt and returns the status
 func UpdatePaidAmount(w http.ResponseWriter, r *http.Request) {
 
 	transactionId := r.FormValue("transactionId")
 	paidAmount := r.FormValue("paidAmount")
 
-	updateQuery := fmt.Sprintf(`UPDATE transaction
-		SET paidAmount = '%s',
-		isPaid = CASE WHEN cast(totalValue as unsigned) = cast(paidAmount as unsigned) THEN true ELSE false END
-		WHERE id = '%s'`, paidAmount, transactionId)
+	paidAmountNum, err := strconv.ParseFloat(paidAmount, 64)
+	if err != nil || paidAmountNum < 0 {
+		writeFailure(w, "paidAmount must be a non-negative number")
+		return
+	}
 
-	_, err := db.Query(updateQuery)
+	tx, err := db.Begin()
+	if err == nil {
+		err = adjustPaidAmount(tx, transactionId, roundPaise(paidAmountNum))
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}
 
 	var result map[string]bool
 
 	if err != nil {
+		log.Println(err)
 		result = map[string]bool{
 			"success": false,
 		}
@@ -1731,7 +2387,7 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 
 	password := GetMD5Hash(passwordPlainText)
 
-	userLoginQuery := fmt.Sprintf(`SELECT id, permission_createNew, permission_transactionIn, permission_transactionOut, permission_view FROM user WHERE username='%s' AND password='%s'`, username, password)
+	userLoginQuery := fmt.Sprintf(`SELECT id, permission_createNew, permission_transactionIn, permission_transactionOut, permission_view, permission_creditOverride FROM user WHERE username='%s' AND password='%s'`, username, password)
 	rows, err := db.Query(userLoginQuery)
 
 	var result map[string]bool
@@ -1747,10 +2403,11 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 		var permission_transactionIn bool
 		var permission_transactionOut bool
 		var permission_view bool
+		var permission_creditOverride bool
 
 		for rows.Next() {
 			success = true
-			rows.Scan(&userId, &permission_createNew, &permission_transactionIn, &permission_transactionOut, &permission_view)
+			rows.Scan(&userId, &permission_createNew, &permission_transactionIn, &permission_transactionOut, &permission_view, &permission_creditOverride)
 		}
 
 		if success {
@@ -1760,6 +2417,7 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 				"permission_transactionIn":  permission_transactionIn,
 				"permission_transactionOut": permission_transactionOut,
 				"permission_view":           permission_view,
+				"permission_creditOverride": permission_creditOverride,
 			}
 		} else {
 			result = map[string]bool{
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// StockBalance is the quantity of one item held in one warehouse for one client, in all three UOMs
+type StockBalance struct {
+	ItemId            string  `json:"itemId"`
+	ItemName          string  `json:"itemName"`
+	ItemVariant       string  `json:"itemVariant"`
+	WarehouseId       string  `json:"warehouseId"`
+	WarehouseName     string  `json:"warehouseName"`
+	ClientId          string  `json:"clientId"`
+	ClientName        string  `json:"clientName"`
+	AsOfDate          string  `json:"asOfDate"`
+	BigcartonQuantity float64 `json:"bigcartonQuantity"`
+	SmallboxQuantity  float64 `json:"smallboxQuantity"`
+	ItemQuantity      float64 `json:"itemQuantity"`
+	TotalPcs          float64 `json:"totalPcs"`
+}
+
+// ReplayStockBalance adds up the movements of a single stock into its balance
+func ReplayStockBalance(movements []StockMovement) StockBalance {
+	var balance StockBalance
+
+	for _, movement := range movements {
+		balance.BigcartonQuantity += movement.Signed(movement.BigQuantity)
+		balance.SmallboxQuantity += movement.Signed(movement.SmallQuantity)
+		balance.ItemQuantity += movement.Signed(movement.RawQuantity)
+		balance.TotalPcs += movement.Signed(movement.TotalPcs)
+	}
+
+	return balance
+}
+
+// replayStockBalances replays the ledger up to the filter's date into a balance per item, warehouse and client
+func replayStockBalances(filter stockFilter) []StockBalance {
+	var balances []StockBalance
+
+	keys, groups := groupStockMovements(loadStockMovements(filter))
+
+	for _, key := range keys {
+		balance := ReplayStockBalance(groups[key])
+		names := lookupStockNames(key)
+
+		balance.ItemId = key.ItemId
+		balance.ItemName = names.ItemName
+		balance.ItemVariant = names.ItemVariant
+		balance.WarehouseId = key.WarehouseId
+		balance.WarehouseName = names.WarehouseName
+		balance.ClientId = key.ClientId
+		balance.ClientName = names.ClientName
+		balance.AsOfDate = filter.ToDate
+
+		balances = append(balances, balance)
+	}
+
+	return balances
+}
+
+// SearchStockAsOf returns the stock balance per item, warehouse and client at any point in time
+func SearchStockAsOf(w http.ResponseWriter, r *http.Request) {
+
+	filter := stockFilter{
+		ItemId:      r.FormValue("itemId"),
+		WarehouseId: r.FormValue("warehouseId"),
+		ClientId:    r.FormValue("clientId"),
+		ToDate:      r.FormValue("asOfDate"),
+	}
+
+	payload := replayStockBalances(filter)
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
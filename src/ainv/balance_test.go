@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stockMovements are the movements of two stocks, interleaved the way the ledger returns them
+func stockMovements() []StockMovement {
+	movement := func(id string, date string, item string, direction string, big float64, small float64, raw float64) StockMovement {
+		return StockMovement{
+			TransactionId: id, EntryDate: date, ItemId: item, WarehouseId: "1", ClientId: "1", Direction: direction,
+			BigQuantity: big, SmallQuantity: small, RawQuantity: raw, TotalPcs: big * small * raw,
+		}
+	}
+
+	return []StockMovement{
+		movement("1", "2026-04-01", "1", DirectionOpening, 10, 4, 12),
+		movement("2", "2026-04-03", "2", "in", 5, 2, 6),
+		movement("3", "2026-04-05", "1", "out", 2, 4, 12),
+		movement("4", "2026-04-09", "1", "in", 3, 4, 12),
+		movement("5", "2026-04-12", "2", "out", 1, 2, 6),
+	}
+}
+
+func TestGroupStockMovements(t *testing.T) {
+	keys, groups := groupStockMovements(stockMovements())
+
+	want := []stockKey{{"1", "1", "1"}, {"2", "1", "1"}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("keys = %v, want %v", keys, want)
+	}
+
+	var ids []string
+	for _, movement := range groups[keys[0]] {
+		ids = append(ids, movement.TransactionId)
+	}
+	if strings.Join(ids, ",") != "1,3,4" {
+		t.Errorf("item 1 replays %v, want 1,3,4 in order", ids)
+	}
+}
+
+func TestReplayStockBalance(t *testing.T) {
+	keys, groups := groupStockMovements(stockMovements())
+
+	cases := []struct {
+		key     stockKey
+		balance StockBalance
+	}{
+		{keys[0], StockBalance{BigcartonQuantity: 11, SmallboxQuantity: 4, ItemQuantity: 12, TotalPcs: 528}},
+		{keys[1], StockBalance{BigcartonQuantity: 4, SmallboxQuantity: 0, ItemQuantity: 0, TotalPcs: 48}},
+	}
+
+	for _, c := range cases {
+		if balance := ReplayStockBalance(groups[c.key]); balance != c.balance {
+			t.Errorf("%v: balance = %+v, want %+v", c.key, balance, c.balance)
+		}
+	}
+}
+
+func TestHasDrifted(t *testing.T) {
+	ledger := StockBalance{BigcartonQuantity: 11, SmallboxQuantity: 4, ItemQuantity: 12}
+
+	cases := []struct {
+		contents StockBalance
+		drifted  bool
+	}{
+		{StockBalance{BigcartonQuantity: 11, SmallboxQuantity: 4, ItemQuantity: 12}, false},
+		{StockBalance{BigcartonQuantity: 11.0004, SmallboxQuantity: 4, ItemQuantity: 11.9996}, false},
+		{StockBalance{BigcartonQuantity: 10, SmallboxQuantity: 4, ItemQuantity: 12}, true},
+		{StockBalance{BigcartonQuantity: 11, SmallboxQuantity: 4.01, ItemQuantity: 12}, true},
+		{StockBalance{BigcartonQuantity: 11, SmallboxQuantity: 4, ItemQuantity: 0}, true},
+	}
+
+	for _, c := range cases {
+		if drifted := hasDrifted(ledger, c.contents); drifted != c.drifted {
+			t.Errorf("hasDrifted(%+v, %+v) = %v, want %v", ledger, c.contents, drifted, c.drifted)
+		}
+	}
+}
+
+func TestStockFilterConditions(t *testing.T) {
+	conditions := stockFilter{ItemId: "all", WarehouseId: "", ClientId: "3"}.conditions()
+	if want := []string{"tr.isError = 0", "tr.clientId = '3'"}; !reflect.DeepEqual(conditions, want) {
+		t.Errorf("conditions = %v, want %v", conditions, want)
+	}
+
+	conditions = stockFilter{ItemId: "1", WarehouseId: "2", ToDate: "2026-03-31"}.conditions()
+	if len(conditions) != 4 || conditions[3] != transactionEntryDate+" <= '2026-03-31'" {
+		t.Errorf("conditions = %v, want the item, the warehouse and the as-of date", conditions)
+	}
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// the lifecycle of a bill of entry; stock comes in when it is received and the bill takes no changes once closed
+const (
+	BillPending  = "pending"
+	BillReceived = "received"
+	BillClosed   = "closed"
+)
+
+// BillOfEntryLine is an item received against a bill of entry, with its optional customs duty breakdown
+type BillOfEntryLine struct {
+	LineNumber             int     `json:"lineNumber"`
+	ItemId                 string  `json:"itemId"`
+	ItemName               string  `json:"itemName"`
+	ItemVariant            string  `json:"itemVariant"`
+	HsnCode                string  `json:"hsnCode"`
+	WarehouseId            string  `json:"warehouseId"`
+	BigQuantity            float64 `json:"bigQuantity"`
+	TotalPcs               float64 `json:"totalPcs"`
+	AssdValue              float64 `json:"assdValue"`
+	DutyValue              float64 `json:"dutyValue"`
+	GstValue               float64 `json:"gstValue"`
+	TotalValue             float64 `json:"totalValue"`
+	ForeignInvoiceValue    float64 `json:"foreignInvoiceValue"`
+	BasicCustomsDuty       float64 `json:"basicCustomsDuty"`
+	SocialWelfareSurcharge float64 `json:"socialWelfareSurcharge"`
+	IgstOnImport           float64 `json:"igstOnImport"`
+	Cess                   float64 `json:"cess"`
+	LandedCostPerPiece     float64 `json:"landedCostPerPiece"`
+	TransactionId          string  `json:"transactionId"`
+}
+
+// hasCustomsDuty reports whether the line came with a duty breakdown
+func (line BillOfEntryLine) hasCustomsDuty() bool {
+	return line.ForeignInvoiceValue > 0 || line.BasicCustomsDuty > 0 || line.SocialWelfareSurcharge > 0 || line.IgstOnImport > 0 || line.Cess > 0
+}
+
+// customsDuty is the duty breakdown of the line; customs assesses it in the base currency, so only the foreign invoice
+// value is in the currency of the bill
+func (line BillOfEntryLine) customsDuty(exchangeRate float64) CustomsDuty {
+	return CustomsDuty{
+		ForeignInvoiceValue:    line.ForeignInvoiceValue,
+		ExchangeRate:           exchangeRate,
+		AssessableValue:        line.AssdValue,
+		BasicCustomsDuty:       line.BasicCustomsDuty,
+		SocialWelfareSurcharge: line.SocialWelfareSurcharge,
+		IgstOnImport:           line.IgstOnImport,
+		Cess:                   line.Cess,
+		TotalPcs:               line.TotalPcs,
+	}
+}
+
+// BillOfEntryTotals are the amounts of a bill of entry summed over its lines; every amount but the foreign invoice value
+// is assessed in the base currency
+type BillOfEntryTotals struct {
+	TotalPcs            float64 `json:"totalPcs"`
+	ForeignInvoiceValue float64 `json:"foreignInvoiceValue"`
+	AssdValue           float64 `json:"assdValue"`
+	DutyValue           float64 `json:"dutyValue"`
+	GstValue            float64 `json:"gstValue"`
+	TotalValue          float64 `json:"totalValue"`
+	BaseTotalValue      float64 `json:"baseTotalValue"`
+}
+
+// BillOfEntryDocument is a bill of entry header with its lines and totals
+type BillOfEntryDocument struct {
+	BillOfEntryId     string            `json:"billOfEntryId"`
+	BillOfEntryNumber string            `json:"billOfEntryNumber"`
+	BillOfEntryDate   string            `json:"billOfEntryDate"`
+	ClientId          string            `json:"clientId"`
+	ClientName        string            `json:"clientName"`
+	Currency          string            `json:"currency"`
+	ExchangeRate      float64           `json:"exchangeRate"`
+	Status            string            `json:"status"`
+	Remarks           string            `json:"remarks"`
+	OriginalNumber    string            `json:"originalNumber,omitempty"`
+	Lines             []BillOfEntryLine `json:"lines"`
+	Totals            BillOfEntryTotals `json:"totals"`
+}
+
+// parseBillOfEntryLines reads the lines of a bill of entry, sent as a JSON array in a form value
+func parseBillOfEntryLines(raw string) ([]BillOfEntryLine, error) {
+	var lines []BillOfEntryLine
+
+	if err := json.Unmarshal([]byte(raw), &lines); err != nil {
+		return nil, fmt.Errorf("lines must be a JSON array: %v", err)
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("a bill of entry needs at least one line")
+	}
+
+	for i, line := range lines {
+		if line.ItemId == "" || line.WarehouseId == "" {
+			return nil, fmt.Errorf("line %d must name an itemId and warehouseId", i+1)
+		}
+		if line.BigQuantity <= 0 {
+			return nil, fmt.Errorf("line %d must have a positive bigQuantity", i+1)
+		}
+		if line.AssdValue < 0 || line.DutyValue < 0 || line.GstValue < 0 {
+			return nil, fmt.Errorf("line %d cannot have negative values", i+1)
+		}
+
+		lines[i].LineNumber = i + 1
+		lines[i].TotalValue = roundPaise(line.AssdValue + line.DutyValue + line.GstValue)
+	}
+
+	return lines, nil
+}
+
+// sumBillOfEntryLines adds up the amounts of the lines of a bill of entry
+func sumBillOfEntryLines(lines []BillOfEntryLine) BillOfEntryTotals {
+	var totals BillOfEntryTotals
+
+	for _, line := range lines {
+		totals.TotalPcs += line.TotalPcs
+		totals.ForeignInvoiceValue = roundPaise(totals.ForeignInvoiceValue + line.ForeignInvoiceValue)
+		totals.AssdValue = roundPaise(totals.AssdValue + line.AssdValue)
+		totals.DutyValue = roundPaise(totals.DutyValue + line.DutyValue)
+		totals.GstValue = roundPaise(totals.GstValue + line.GstValue)
+		totals.TotalValue = roundPaise(totals.TotalValue + line.TotalValue)
+	}
+	totals.BaseTotalValue = totals.TotalValue
+
+	return totals
+}
+
+// loadBillOfEntry reads a bill of entry with its lines; transactions raised line by line through CreateTransaction
+// follow its own lines, so that a bill keyed both ways shows all of its stock
+func loadBillOfEntry(q querier, billOfEntryId string) (BillOfEntryDocument, error) {
+	var bill BillOfEntryDocument
+
+	headerQuery := fmt.Sprintf(`SELECT
+		be.id, be.tracker, be.entryDate, be.customerId, IFNULL(cl.clientName, 'N/A'), be.currency, be.exchangeRate, be.status, be.remarks, IFNULL(be.originalTracker, '')
+		FROM billOfEntry be
+		LEFT JOIN client cl ON cl.id = be.customerId
+		WHERE be.id = '%s'`, billOfEntryId)
+
+	err := q.QueryRow(headerQuery).Scan(&bill.BillOfEntryId, &bill.BillOfEntryNumber, &bill.BillOfEntryDate, &bill.ClientId, &bill.ClientName, &bill.Currency, &bill.ExchangeRate, &bill.Status, &bill.Remarks, &bill.OriginalNumber)
+	if err == sql.ErrNoRows {
+		return bill, fmt.Errorf("bill of entry %s does not exist", billOfEntryId)
+	}
+	if err != nil {
+		return bill, err
+	}
+
+	linesQuery := fmt.Sprintf(`SELECT
+		bl.lineNumber, bl.itemId, IFNULL(im.itemName, 'N/A'), IFNULL(im.itemVariant, ''), IFNULL(im.hsnCode, ''),
+		bl.warehouseId, bl.bigQuantity, bl.totalPcs, bl.assdValue, bl.dutyValue, bl.gstValue, bl.totalValue,
+		bl.foreignInvoiceValue, bl.basicCustomsDuty, bl.socialWelfareSurcharge, bl.igstOnImport, bl.cess,
+		IFNULL(tr.landedCostPerPiece, 0), IFNULL(bl.transactionId, '')
+		FROM billOfEntryLine bl
+		LEFT JOIN itemMaster im ON im.id = bl.itemId
+		LEFT JOIN transaction tr ON tr.id = bl.transactionId
+		WHERE bl.billOfEntryId = '%s'
+		ORDER BY bl.lineNumber`, billOfEntryId)
+
+	bill.Lines, err = scanBillOfEntryLines(q, linesQuery)
+	if err != nil {
+		return bill, err
+	}
+
+	transactionLinesQuery := fmt.Sprintf(`SELECT
+		0, tr.itemId, IFNULL(im.itemName, 'N/A'), IFNULL(im.itemVariant, ''), IFNULL(im.hsnCode, ''),
+		tr.warehouseId, tr.bigQuantity, tr.totalPcs, tr.assdValue, tr.dutyValue, tr.gstValue, tr.totalValue,
+		IFNULL(cd.foreignInvoiceValue, 0), IFNULL(cd.basicCustomsDuty, 0), IFNULL(cd.socialWelfareSurcharge, 0), IFNULL(cd.igstOnImport, 0), IFNULL(cd.cess, 0),
+		IFNULL(tr.landedCostPerPiece, 0), tr.id
+		FROM transaction tr
+		LEFT JOIN itemMaster im ON im.id = tr.itemId
+		LEFT JOIN customsDuty cd ON cd.transactionId = tr.id
+		WHERE tr.billOfEntry = '%s' AND tr.isError = 0
+		AND NOT EXISTS (SELECT 1 FROM billOfEntryLine bl WHERE bl.transactionId = tr.id)
+		ORDER BY tr.id`, billOfEntryId)
+
+	transactionLines, err := scanBillOfEntryLines(q, transactionLinesQuery)
+	if err != nil {
+		return bill, err
+	}
+
+	for _, line := range transactionLines {
+		line.LineNumber = 1
+		if count := len(bill.Lines); count > 0 {
+			line.LineNumber = bill.Lines[count-1].LineNumber + 1
+		}
+		bill.Lines = append(bill.Lines, line)
+	}
+
+	bill.Totals = sumBillOfEntryLines(bill.Lines)
+
+	return bill, nil
+}
+
+// scanBillOfEntryLines reads bill of entry lines selected in the column order of billOfEntryLine
+func scanBillOfEntryLines(q querier, linesQuery string) ([]BillOfEntryLine, error) {
+	var lines []BillOfEntryLine
+
+	allLines, err := q.Query(linesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer allLines.Close()
+
+	for allLines.Next() {
+		var line BillOfEntryLine
+
+		err := allLines.Scan(&line.LineNumber, &line.ItemId, &line.ItemName, &line.ItemVariant, &line.HsnCode, &line.WarehouseId, &line.BigQuantity, &line.TotalPcs, &line.AssdValue, &line.DutyValue, &line.GstValue, &line.TotalValue, &line.ForeignInvoiceValue, &line.BasicCustomsDuty, &line.SocialWelfareSurcharge, &line.IgstOnImport, &line.Cess, &line.LandedCostPerPiece, &line.TransactionId)
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, allLines.Err()
+}
+
+// receiveBillOfEntry posts every line of a bill as an inbound transaction with its landed cost and puts the stock into the warehouses
+func receiveBillOfEntry(tx *sql.Tx, bill BillOfEntryDocument) error {
+	for _, line := range bill.Lines {
+		rates, err := lookupItemRates(tx, line.ItemId)
+		if err != nil {
+			return err
+		}
+
+		change, err := postInventoryChange(tx, line.ItemId, line.WarehouseId, bill.ClientId, "in", line.BigQuantity, rates)
+		if err != nil {
+			return err
+		}
+
+		gstSplit := ComputeGSTSplit(tx, "in", line.WarehouseId, "", "", fmt.Sprintf("%.2f", line.GstValue))
+
+		valuePerPiece := 0.0
+		if line.TotalPcs > 0 {
+			valuePerPiece = line.AssdValue / line.TotalPcs
+		}
+
+		transactionQuery := fmt.Sprintf(`INSERT INTO transaction
+		(billOfEntry, salesInvoice, itemId, warehouseId, comeOrGo, clientId, customerId, bigQuantity, currentValue, changeValue, finalValue, secretRate1, secretRate2, totalPcs, assdValue, dutyValue, gstValue, cgstValue, sgstValue, igstValue, placeOfSupply, totalValue, currency, exchangeRate, valuePerPiece, totalPieces, isPaid, paidAmount, date, delvDate1, delvDate2, remarks)
+		VALUES
+		('%s', NULL, '%s', '%s', 'in', '%s', NULL, '%f', '%f', '%f', '%f', '%f', '%f', '%f', '%.2f', '%.2f', '%.2f', '%.2f', '%.2f', '%.2f', NULL, '%.2f', '%s', '%f', '%f', '%f', false, '0', NULL, '', '', '%s')`, bill.BillOfEntryId, line.ItemId, line.WarehouseId, bill.ClientId, line.BigQuantity, change.CurrentValue, change.ChangeValue, change.FinalValue, rates.SmallPerBig, rates.RawPerSmall, line.TotalPcs, line.AssdValue, line.DutyValue, line.GstValue, gstSplit.CgstValue, gstSplit.SgstValue, gstSplit.IgstValue, line.TotalValue, BaseCurrency, 1.0, valuePerPiece, line.TotalPcs, escapeQuotes(bill.Remarks))
+
+		transactionResult, err := tx.Exec(transactionQuery)
+		if err != nil {
+			return err
+		}
+		transactionId, _ := transactionResult.LastInsertId()
+
+		assdValue := fmt.Sprintf("%.2f", line.AssdValue)
+		dutyValue := fmt.Sprintf("%.2f", line.DutyValue)
+
+		var costStatus bool
+		if line.hasCustomsDuty() {
+			costStatus = CommitCustomsDuty(tx, transactionId, ComputeLandedCost(line.customsDuty(bill.ExchangeRate)))
+		} else {
+			costStatus = commitLandedCost(tx, transactionId, flatLandedCostPerPiece(assdValue, dutyValue, fmt.Sprintf("%f", line.TotalPcs)))
+		}
+		if !costStatus {
+			return fmt.Errorf("landed cost of line %d could not be recorded", line.LineNumber)
+		}
+
+		lineUpdateQuery := fmt.Sprintf(`UPDATE billOfEntryLine SET transactionId = '%d' WHERE billOfEntryId = '%s' AND lineNumber = '%d'`, transactionId, bill.BillOfEntryId, line.LineNumber)
+		if _, err := tx.Exec(lineUpdateQuery); err != nil {
+			return err
+		}
+	}
+
+	return setBillOfEntryStatus(tx, bill.BillOfEntryId, BillReceived)
+}
+
+// setBillOfEntryStatus moves a bill of entry to a new status
+func setBillOfEntryStatus(tx *sql.Tx, billOfEntryId string, status string) error {
+	_, err := tx.Exec(fmt.Sprintf(`UPDATE billOfEntry SET status = '%s' WHERE id = '%s'`, status, billOfEntryId))
+	return err
+}
+
+// writeBillOfEntry writes a bill of entry document as JSON
+func writeBillOfEntry(w http.ResponseWriter, billOfEntryId string) {
+	bill, err := loadBillOfEntry(db, billOfEntryId)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	payloadJSON, err := json.Marshal(bill)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// CreateBillOfEntry creates a bill of entry with all its lines in one go, pending or received right away, and returns it
+func CreateBillOfEntry(w http.ResponseWriter, r *http.Request) {
+
+	bill := BillOfEntryDocument{
+		BillOfEntryNumber: strings.TrimSpace(r.FormValue("billOfEntryNumber")),
+		BillOfEntryDate:   r.FormValue("billOfEntryDate"),
+		ClientId:          r.FormValue("clientId"),
+		Currency:          NormalizeCurrency(r.FormValue("currency")),
+		Status:            r.FormValue("status"),
+		Remarks:           r.FormValue("remarks"),
+	}
+
+	if bill.Status == "" {
+		bill.Status = BillPending
+	}
+	if bill.Status != BillPending && bill.Status != BillReceived {
+		writeFailure(w, fmt.Sprintf("a new bill of entry must be %s or %s", BillPending, BillReceived))
+		return
+	}
+	if bill.BillOfEntryDate == "" || bill.ClientId == "" {
+		writeFailure(w, "billOfEntryDate and clientId are required")
+		return
+	}
+
+	lines, err := parseBillOfEntryLines(r.FormValue("lines"))
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	bill.ExchangeRate, err = resolveExchangeRate(bill.Currency, r.FormValue("exchangeRate"), bill.BillOfEntryDate)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	// without a number of its own the bill takes the next one from the series of the warehouse of its first line
+	if bill.BillOfEntryNumber == "" {
+		bill.BillOfEntryNumber, err = allocateDocumentNumber(tx, lines[0].WarehouseId, DocumentBillOfEntry, bill.BillOfEntryDate)
+		if err != nil {
+			tx.Rollback()
+			writeFailure(w, err.Error())
+			return
+		}
+	}
+
+	var existing int
+	err = tx.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM billOfEntry WHERE tracker = '%s'`, bill.BillOfEntryNumber)).Scan(&existing)
+	if err != nil {
+		tx.Rollback()
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+	if existing > 0 {
+		tx.Rollback()
+		writeFailure(w, fmt.Sprintf("bill of entry %s already exists", bill.BillOfEntryNumber))
+		return
+	}
+
+	for i, line := range lines {
+		rates, err := lookupItemRates(tx, line.ItemId)
+		if err != nil {
+			tx.Rollback()
+			writeFailure(w, err.Error())
+			return
+		}
+		lines[i].TotalPcs = line.BigQuantity * rates.SmallPerBig * rates.RawPerSmall
+
+		if lines[i].hasCustomsDuty() {
+			dutyValue := fmt.Sprintf("%.2f", line.DutyValue)
+			gstValue := fmt.Sprintf("%.2f", line.GstValue)
+
+			if err := CustomsDutyQualityCheck(lines[i].customsDuty(bill.ExchangeRate), dutyValue, gstValue); err != nil {
+				tx.Rollback()
+				writeFailure(w, fmt.Sprintf("line %d: %s", line.LineNumber, err.Error()))
+				return
+			}
+		}
+	}
+
+	// the unique tracker still guards against a bill with the same number created concurrently
+	headerInsertQuery := fmt.Sprintf(`INSERT INTO billOfEntry
+		(tracker, entryDate, customerId, currency, exchangeRate, status, remarks)
+		VALUES
+		('%s', '%s', '%s', '%s', '%f', '%s', '%s')`, bill.BillOfEntryNumber, bill.BillOfEntryDate, bill.ClientId, bill.Currency, bill.ExchangeRate, BillPending, escapeQuotes(bill.Remarks))
+
+	headerResult, err := tx.Exec(headerInsertQuery)
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		writeFailure(w, err.Error())
+		return
+	}
+	billOfEntryId, _ := headerResult.LastInsertId()
+	bill.BillOfEntryId = strconv.FormatInt(billOfEntryId, 10)
+
+	for _, line := range lines {
+		lineInsertQuery := fmt.Sprintf(`INSERT INTO billOfEntryLine
+			(billOfEntryId, lineNumber, itemId, warehouseId, bigQuantity, totalPcs, assdValue, dutyValue, gstValue, totalValue, foreignInvoiceValue, basicCustomsDuty, socialWelfareSurcharge, igstOnImport, cess)
+			VALUES
+			('%d', '%d', '%s', '%s', '%f', '%f', '%.2f', '%.2f', '%.2f', '%.2f', '%.2f', '%.2f', '%.2f', '%.2f', '%.2f')`, billOfEntryId, line.LineNumber, line.ItemId, line.WarehouseId, line.BigQuantity, line.TotalPcs, line.AssdValue, line.DutyValue, line.GstValue, line.TotalValue, line.ForeignInvoiceValue, line.BasicCustomsDuty, line.SocialWelfareSurcharge, line.IgstOnImport, line.Cess)
+
+		if _, err := tx.Exec(lineInsertQuery); err != nil {
+			log.Println(err)
+			tx.Rollback()
+			writeFailure(w, err.Error())
+			return
+		}
+	}
+
+	bill.Lines = lines
+
+	if bill.Status == BillReceived {
+		if err := receiveBillOfEntry(tx, bill); err != nil {
+			log.Println(err)
+			tx.Rollback()
+			writeFailure(w, err.Error())
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	writeBillOfEntry(w, bill.BillOfEntryId)
+}
+
+// UpdateBillOfEntryStatus receives a pending bill of entry or closes a received one, and returns it
+func UpdateBillOfEntryStatus(w http.ResponseWriter, r *http.Request) {
+
+	billOfEntryId := r.FormValue("billOfEntryId")
+	status := r.FormValue("status")
+
+	tx, err := db.Begin()
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	// the header is locked so that two requests cannot both receive the same bill
+	var current string
+	err = tx.QueryRow(fmt.Sprintf(`SELECT status FROM billOfEntry WHERE id = '%s' FOR UPDATE`, billOfEntryId)).Scan(&current)
+	if err != nil {
+		tx.Rollback()
+		writeFailure(w, fmt.Sprintf("bill of entry %s does not exist", billOfEntryId))
+		return
+	}
+
+	switch {
+	case current == BillPending && status == BillReceived:
+		var bill BillOfEntryDocument
+		bill, err = loadBillOfEntry(tx, billOfEntryId)
+		if err == nil {
+			err = receiveBillOfEntry(tx, bill)
+		}
+	case current == BillReceived && status == BillClosed:
+		err = setBillOfEntryStatus(tx, billOfEntryId, BillClosed)
+	default:
+		err = fmt.Errorf("a %s bill of entry cannot be moved to %q", current, status)
+	}
+
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		writeFailure(w, err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	writeBillOfEntry(w, billOfEntryId)
+}
+
+// GetBillOfEntry returns a bill of entry with its lines and totals
+func GetBillOfEntry(w http.ResponseWriter, r *http.Request) {
+
+	writeBillOfEntry(w, mux.Vars(r)["id"])
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// what happens to an outbound transaction that takes a customer past its credit limit
+const (
+	CreditPolicyBlock = "block"
+	CreditPolicyFlag  = "flag"
+)
+
+// how a transaction past the credit limit was let through
+const (
+	CreditFlagged    = "flagged"
+	CreditOverridden = "overridden"
+)
+
+// CreditCheck is the exposure of a customer against its credit limit including a new sale
+type CreditCheck struct {
+	CustomerId  string  `json:"customerId"`
+	CreditLimit float64 `json:"creditLimit"`
+	Policy      string  `json:"policy"`
+	Outstanding float64 `json:"outstanding"`
+	NewValue    float64 `json:"newValue"`
+	Exceeded    bool    `json:"exceeded"`
+}
+
+// CreditOverride is an authorized user letting a sale through past the credit limit
+type CreditOverride struct {
+	UserId int
+	Reason string
+}
+
+// CreditException is an outbound transaction that went through past the credit limit of its customer
+type CreditException struct {
+	ExceptionId   string  `json:"exceptionId"`
+	TransactionId string  `json:"transactionId"`
+	CustomerId    string  `json:"customerId"`
+	CustomerName  string  `json:"customerName"`
+	CreditLimit   float64 `json:"creditLimit"`
+	Outstanding   float64 `json:"outstanding"`
+	TotalValue    float64 `json:"totalValue"`
+	Action        string  `json:"action"`
+	Username      string  `json:"username"`
+	Reason        string  `json:"reason"`
+	CreatedAt     string  `json:"createdAt"`
+}
+
+// normalizeCreditPolicy defaults an empty policy to blocking and rejects unknown ones
+func normalizeCreditPolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return CreditPolicyBlock, nil
+	case CreditPolicyBlock, CreditPolicyFlag:
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("credit policy must be %s or %s", CreditPolicyBlock, CreditPolicyFlag)
+}
+
+// parseCreditTerms reads the credit limit and payment terms of a customer, an empty limit meaning no limit
+func parseCreditTerms(r *http.Request) (string, int, string, error) {
+	return creditTerms(r.FormValue("creditLimit"), r.FormValue("paymentTerms"), r.FormValue("creditPolicy"))
+}
+
+// creditTerms validates the credit limit, payment terms and credit policy of a customer
+func creditTerms(creditLimit string, terms string, creditPolicy string) (string, int, string, error) {
+	if creditLimit != "" {
+		limit, err := strconv.ParseFloat(creditLimit, 64)
+		if err != nil || limit < 0 {
+			return "", 0, "", errors.New("credit limit must be a non-negative amount")
+		}
+		creditLimit = fmt.Sprintf("%.2f", limit)
+	}
+
+	paymentTerms := 0
+	if terms != "" {
+		days, err := strconv.Atoi(terms)
+		if err != nil || days < 0 {
+			return "", 0, "", errors.New("payment terms must be a non-negative number of days")
+		}
+		paymentTerms = days
+	}
+
+	policy, err := normalizeCreditPolicy(creditPolicy)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	return creditLimit, paymentTerms, policy, nil
+}
+
+// CheckCreditLimit works out whether a new sale of the given base currency value takes a customer past its limit
+func CheckCreditLimit(customerId string, newValue float64) (CreditCheck, error) {
+	check := CreditCheck{CustomerId: customerId, NewValue: roundPaise(newValue)}
+
+	var creditLimit sql.NullFloat64
+
+	creditQuery := fmt.Sprintf(`SELECT
+		cu.creditLimit, cu.creditPolicy,
+		IFNULL((SELECT SUM((tr.totalValue - tr.paidAmount) * tr.exchangeRate) FROM transaction tr
+			WHERE tr.customerId = cu.id AND tr.comeOrGo = 'out' AND tr.isError = 0), 0)
+		FROM customer cu WHERE cu.id = '%s'`, customerId)
+
+	err := db.QueryRow(creditQuery).Scan(&creditLimit, &check.Policy, &check.Outstanding)
+	if err != nil {
+		return check, err
+	}
+
+	check.Outstanding = roundPaise(check.Outstanding)
+
+	// customers without a limit are never held
+	if !creditLimit.Valid {
+		return check, nil
+	}
+
+	check.CreditLimit = creditLimit.Float64
+	check.Exceeded = check.Outstanding+check.NewValue > check.CreditLimit+paymentTolerance
+
+	return check, nil
+}
+
+// authorizeCreditOverride checks the credentials of the user overriding a credit limit and their permission to do so
+func authorizeCreditOverride(username string, password string, reason string) (CreditOverride, error) {
+	if username == "" {
+		return CreditOverride{}, errors.New("credit limit exceeded")
+	}
+	if reason == "" {
+		return CreditOverride{}, errors.New("a reason is needed to override the credit limit")
+	}
+
+	var override CreditOverride
+	var permitted bool
+
+	userQuery := `SELECT id, permission_creditOverride FROM user WHERE username = ? AND password = ?`
+
+	err := db.QueryRow(userQuery, username, GetMD5Hash(password)).Scan(&override.UserId, &permitted)
+	if err == sql.ErrNoRows {
+		return CreditOverride{}, errors.New("invalid credentials for the credit limit override")
+	}
+	if err != nil {
+		return CreditOverride{}, err
+	}
+	if !permitted {
+		return CreditOverride{}, errors.New("user is not permitted to override credit limits")
+	}
+
+	override.Reason = reason
+	return override, nil
+}
+
+// recordCreditException notes a transaction let through past the credit limit, by policy or by an override
+func recordCreditException(exec execer, transactionId int64, check CreditCheck, override *CreditOverride) error {
+	action := CreditFlagged
+	var userId interface{}
+	reason := ""
+
+	if override != nil {
+		action = CreditOverridden
+		userId = override.UserId
+		reason = override.Reason
+	}
+
+	exceptionInsertQuery := `INSERT INTO creditException
+		(transactionId, customerId, creditLimit, outstanding, totalValue, action, userId, reason, createdAt)
+		VALUES
+		(?, ?, ROUND(?, 2), ROUND(?, 2), ROUND(?, 2), ?, ?, ?, NOW())`
+
+	_, err := exec.Exec(exceptionInsertQuery, transactionId, check.CustomerId, check.CreditLimit, check.Outstanding, check.NewValue, action, userId, reason)
+	return err
+}
+
+// GetCreditExceptions returns the transactions let through past a credit limit, for one customer or all
+func GetCreditExceptions(w http.ResponseWriter, r *http.Request) {
+
+	customerId := r.FormValue("customerId")
+
+	customerCondition := ""
+	if isFiltered(customerId) {
+		customerCondition = fmt.Sprintf(" WHERE ce.customerId = '%s'", customerId)
+	}
+
+	var payload []CreditException
+
+	exceptionsQuery := fmt.Sprintf(`SELECT
+		ce.id, ce.transactionId, ce.customerId, IFNULL(cu.customerName, 'N/A'),
+		ce.creditLimit, ce.outstanding, ce.totalValue, ce.action, IFNULL(us.username, ''), ce.reason, ce.createdAt
+		FROM creditException ce
+		LEFT JOIN customer cu ON cu.id = ce.customerId
+		LEFT JOIN user us ON us.id = ce.userId%s
+		ORDER BY ce.createdAt DESC, ce.id DESC`, customerCondition)
+
+	allExceptions, err := db.Query(exceptionsQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allExceptions.Next() {
+		var exception CreditException
+
+		err := allExceptions.Scan(&exception.ExceptionId, &exception.TransactionId, &exception.CustomerId, &exception.CustomerName, &exception.CreditLimit, &exception.Outstanding, &exception.TotalValue, &exception.Action, &exception.Username, &exception.Reason, &exception.CreatedAt)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		payload = append(payload, exception)
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BaseCurrency is the currency every report and valuation is converted into
+const BaseCurrency = "INR"
+
+// ExchangeRate is the rate of one unit of a currency in the base currency, effective from a date
+type ExchangeRate struct {
+	Currency      string  `json:"currency"`
+	EffectiveDate string  `json:"effectiveDate"`
+	Rate          float64 `json:"rate"`
+}
+
+// NormalizeCurrency upper-cases a currency code, defaulting to the base currency
+func NormalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return BaseCurrency
+	}
+
+	return currency
+}
+
+// LookupExchangeRate returns the rate of a currency effective on the given date from the offline rate table
+func LookupExchangeRate(currency string, date string) (float64, error) {
+	currency = NormalizeCurrency(currency)
+	if currency == BaseCurrency {
+		return 1, nil
+	}
+
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+
+	var rate float64
+	rateQuery := fmt.Sprintf(`SELECT rate FROM exchangeRate
+		WHERE currency = '%s' AND effectiveDate <= '%s'
+		ORDER BY effectiveDate DESC LIMIT 1`, currency, date)
+
+	err := db.QueryRow(rateQuery).Scan(&rate)
+	if err != nil {
+		return 0, fmt.Errorf("no exchange rate for %s effective on %s", currency, date)
+	}
+
+	return rate, nil
+}
+
+// resolveExchangeRate uses the rate given with the request, falling back to the rate table; the base currency only
+// ever has a rate of 1
+func resolveExchangeRate(currency string, exchangeRate string, date string) (float64, error) {
+	if NormalizeCurrency(currency) == BaseCurrency {
+		if rate, err := strconv.ParseFloat(exchangeRate, 64); exchangeRate != "" && (err != nil || rate != 1) {
+			return 0, fmt.Errorf("exchange rate %q must be 1 for %s", exchangeRate, BaseCurrency)
+		}
+
+		return 1, nil
+	}
+
+	if exchangeRate != "" {
+		rate, err := strconv.ParseFloat(exchangeRate, 64)
+		if err != nil || rate <= 0 {
+			return 0, fmt.Errorf("exchange rate %q must be a positive number", exchangeRate)
+		}
+
+		return rate, nil
+	}
+
+	return LookupExchangeRate(currency, date)
+}
+
+// toBaseCurrency converts an amount entered in a transaction currency into the base currency
+func toBaseCurrency(amount string, exchangeRate float64) string {
+	amountNum, _ := strconv.ParseFloat(amount, 64)
+	return strconv.FormatFloat(amountNum*exchangeRate, 'f', 2, 64)
+}
+
+// CreateExchangeRate adds a date-effective rate to the offline rate table and returns the status
+func CreateExchangeRate(w http.ResponseWriter, r *http.Request) {
+
+	currency := NormalizeCurrency(r.FormValue("currency"))
+	effectiveDate := r.FormValue("effectiveDate")
+	rate := r.FormValue("rate")
+
+	rateNum, err := strconv.ParseFloat(rate, 64)
+	if err != nil || rateNum <= 0 {
+		writeFailure(w, fmt.Sprintf("rate %q must be a positive number", rate))
+		return
+	}
+	if currency == BaseCurrency {
+		writeFailure(w, "the base currency always has a rate of 1")
+		return
+	}
+
+	rateInsertQuery := fmt.Sprintf(`INSERT INTO exchangeRate
+		(currency, effectiveDate, rate)
+		VALUES
+		('%s', '%s', '%f')
+		ON DUPLICATE KEY UPDATE rate = VALUES(rate)`, currency, effectiveDate, rateNum)
+
+	_, err = db.Exec(rateInsertQuery)
+
+	var result map[string]bool
+
+	if err != nil {
+		log.Println(err)
+		result = map[string]bool{
+			"success": false,
+		}
+	} else {
+		result = map[string]bool{
+			"success": true,
+		}
+	}
+
+	payloadJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// GetExchangeRates returns the offline rate table, optionally only for one currency
+func GetExchangeRates(w http.ResponseWriter, r *http.Request) {
+
+	currency := r.FormValue("currency")
+
+	var payload []ExchangeRate
+
+	getRatesQuery := `SELECT currency, effectiveDate, rate FROM exchangeRate`
+	if currency != "" {
+		getRatesQuery = getRatesQuery + fmt.Sprintf(" WHERE currency = '%s'", NormalizeCurrency(currency))
+	}
+	getRatesQuery = getRatesQuery + " ORDER BY currency, effectiveDate DESC"
+
+	allRates, err := db.Query(getRatesQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allRates.Next() {
+		var singleObject ExchangeRate
+
+		err := allRates.Scan(&singleObject.Currency, &singleObject.EffectiveDate, &singleObject.Rate)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		payload = append(payload, singleObject)
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// customs assesses duty in whole rupees, so the breakdown may differ from the flat values by that much
+const customsRoundingTolerance = 1.0
+
+// CustomsDuty is the duty breakdown of a single bill of entry line
+type CustomsDuty struct {
+	TransactionId          string  `json:"transactionId"`
+	BillOfEntry            string  `json:"billOfEntry"`
+	ItemName               string  `json:"itemName"`
+	ForeignInvoiceValue    float64 `json:"foreignInvoiceValue"`
+	ExchangeRate           float64 `json:"exchangeRate"`
+	AssessableValue        float64 `json:"assessableValue"`
+	BasicCustomsDuty       float64 `json:"basicCustomsDuty"`
+	SocialWelfareSurcharge float64 `json:"socialWelfareSurcharge"`
+	IgstOnImport           float64 `json:"igstOnImport"`
+	Cess                   float64 `json:"cess"`
+	TotalPcs               float64 `json:"totalPcs"`
+	LandedCost             float64 `json:"landedCost"`
+	LandedCostPerPiece     float64 `json:"landedCostPerPiece"`
+}
+
+// TotalDuty returns the duty that adds to the cost of the goods, leaving out the creditable IGST
+func (duty CustomsDuty) TotalDuty() float64 {
+	return duty.BasicCustomsDuty + duty.SocialWelfareSurcharge + duty.Cess
+}
+
+// parseCustomsDuty reads the duty breakdown of an inbound line, reporting whether one was supplied at all
+func parseCustomsDuty(r *http.Request, assdValue string, totalPcs string) (CustomsDuty, bool, error) {
+	var duty CustomsDuty
+	present := false
+
+	fields := map[string]*float64{
+		"foreignInvoiceValue":    &duty.ForeignInvoiceValue,
+		"exchangeRate":           &duty.ExchangeRate,
+		"basicCustomsDuty":       &duty.BasicCustomsDuty,
+		"socialWelfareSurcharge": &duty.SocialWelfareSurcharge,
+		"igstOnImport":           &duty.IgstOnImport,
+		"cess":                   &duty.Cess,
+	}
+
+	for name, value := range fields {
+		raw := r.FormValue(name)
+		if raw == "" {
+			continue
+		}
+
+		present = true
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed < 0 {
+			return duty, present, fmt.Errorf("%s must be a non-negative number", name)
+		}
+		*value = parsed
+	}
+
+	duty.AssessableValue, _ = strconv.ParseFloat(assdValue, 64)
+	duty.TotalPcs, _ = strconv.ParseFloat(totalPcs, 64)
+
+	return duty, present, nil
+}
+
+// CustomsDutyQualityCheck ensures the duty breakdown adds up to the flat assessable, duty and GST values of the line
+func CustomsDutyQualityCheck(duty CustomsDuty, dutyValue string, gstValue string) error {
+	dutyValueNum, _ := strconv.ParseFloat(dutyValue, 64)
+	gstValueNum, _ := strconv.ParseFloat(gstValue, 64)
+
+	if duty.ForeignInvoiceValue > 0 && duty.ExchangeRate > 0 {
+		convertedValue := duty.ForeignInvoiceValue * duty.ExchangeRate
+		if math.Abs(convertedValue-duty.AssessableValue) > customsRoundingTolerance {
+			return fmt.Errorf("assessable value %.2f does not match foreign invoice value converted at the exchange rate (%.2f)", duty.AssessableValue, convertedValue)
+		}
+	}
+
+	if math.Abs(duty.TotalDuty()-dutyValueNum) > customsRoundingTolerance {
+		return fmt.Errorf("duty value %.2f does not match basic customs duty, social welfare surcharge and cess (%.2f)", dutyValueNum, duty.TotalDuty())
+	}
+
+	if math.Abs(duty.IgstOnImport-gstValueNum) > customsRoundingTolerance {
+		return fmt.Errorf("gst value %.2f does not match IGST on import (%.2f)", gstValueNum, duty.IgstOnImport)
+	}
+
+	log.Println("CustomsDutyQualityCheck succeeded")
+	return nil
+}
+
+// ComputeLandedCost fills in the landed cost of the line and its per piece share
+func ComputeLandedCost(duty CustomsDuty) CustomsDuty {
+	duty.LandedCost = roundPaise(duty.AssessableValue + duty.TotalDuty())
+
+	if duty.TotalPcs > 0 {
+		duty.LandedCostPerPiece = duty.LandedCost / duty.TotalPcs
+	}
+
+	return duty
+}
+
+// flatLandedCostPerPiece computes the landed cost per piece of a line entered without a duty breakdown
+func flatLandedCostPerPiece(assdValue string, dutyValue string, totalPcs string) float64 {
+	assdValueNum, _ := strconv.ParseFloat(assdValue, 64)
+	dutyValueNum, _ := strconv.ParseFloat(dutyValue, 64)
+	totalPcsNum, _ := strconv.ParseFloat(totalPcs, 64)
+
+	if totalPcsNum <= 0 {
+		return 0
+	}
+
+	return (assdValueNum + dutyValueNum) / totalPcsNum
+}
+
+// CommitCustomsDuty stores the duty breakdown of a line and its landed cost for valuation
+func CommitCustomsDuty(exec execer, transactionId int64, duty CustomsDuty) bool {
+	dutyInsertQuery := fmt.Sprintf(`INSERT INTO customsDuty
+		(transactionId, foreignInvoiceValue, exchangeRate, basicCustomsDuty, socialWelfareSurcharge, igstOnImport, cess, landedCost, landedCostPerPiece)
+		VALUES
+		('%d', '%f', '%f', '%f', '%f', '%f', '%f', '%f', '%f')`, transactionId, duty.ForeignInvoiceValue, duty.ExchangeRate, duty.BasicCustomsDuty, duty.SocialWelfareSurcharge, duty.IgstOnImport, duty.Cess, duty.LandedCost, duty.LandedCostPerPiece)
+
+	_, err := exec.Exec(dutyInsertQuery)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	return commitLandedCost(exec, transactionId, duty.LandedCostPerPiece)
+}
+
+// commitLandedCost records the landed cost per piece on the transaction line itself
+func commitLandedCost(exec execer, transactionId int64, landedCostPerPiece float64) bool {
+	updateQuery := fmt.Sprintf(`UPDATE transaction
+		SET landedCostPerPiece = '%f'
+		WHERE id = '%d'`, landedCostPerPiece, transactionId)
+
+	_, err := exec.Exec(updateQuery)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	return true
+}
+
+// GetCustomsDuty returns the duty breakdown of every line of a bill of entry
+func GetCustomsDuty(w http.ResponseWriter, r *http.Request) {
+
+	billOfEntryId := r.FormValue("billOfEntryId")
+
+	var payload []CustomsDuty
+
+	getDutyQuery := fmt.Sprintf(`SELECT
+		tr.id, be.tracker, im.itemName,
+		cd.foreignInvoiceValue, cd.exchangeRate, tr.assdValue,
+		cd.basicCustomsDuty, cd.socialWelfareSurcharge, cd.igstOnImport, cd.cess,
+		tr.totalPcs, cd.landedCost, cd.landedCostPerPiece
+		FROM customsDuty cd, transaction tr, billOfEntry be, itemMaster im
+		WHERE cd.transactionId = tr.id AND tr.billOfEntry = be.id AND tr.itemId = im.id AND be.id = '%s'`, billOfEntryId)
+
+	allDuties, err := db.Query(getDutyQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allDuties.Next() {
+		var duty CustomsDuty
+
+		err := allDuties.Scan(&duty.TransactionId, &duty.BillOfEntry, &duty.ItemName, &duty.ForeignInvoiceValue, &duty.ExchangeRate, &duty.AssessableValue, &duty.BasicCustomsDuty, &duty.SocialWelfareSurcharge, &duty.IgstOnImport, &duty.Cess, &duty.TotalPcs, &duty.LandedCost, &duty.LandedCostPerPiece)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		payload = append(payload, duty)
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
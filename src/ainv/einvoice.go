@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rounakdatta/ainv-backend-go/src/einvoice"
+	"github.com/rounakdatta/ainv-backend-go/src/validation"
+)
+
+// the buyer details the e-invoice schema expects for exports, which have no GSTIN or Indian address
+const (
+	unregisteredGstin = "URP"
+	exportStateCode   = "96"
+	exportPincode     = 999999
+)
+
+var irnPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// signedQrCodePattern is a JWT, three base64url segments joined by dots
+var signedQrCodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+$`)
+
+// roundQuantity rounds a quantity to the three decimals the e-invoice schema allows
+func roundQuantity(quantity float64) float64 {
+	return math.Round(quantity*1000) / 1000
+}
+
+// buildEInvoice lays out an issued sales invoice as an e-invoice, which is only raised for registered buyers and exports
+func buildEInvoice(invoice SalesInvoiceDocument) (einvoice.Invoice, error) {
+	document, err := layoutEInvoice(invoice)
+	if err != nil {
+		return document, err
+	}
+
+	if document.TranDtls.SupTyp == "B2B" && document.BuyerDtls.Gstin == unregisteredGstin {
+		return document, fmt.Errorf("customer %s has no GSTIN, e-invoices are only raised for registered buyers and exports", document.BuyerDtls.LglNm)
+	}
+
+	return document, nil
+}
+
+// layoutEInvoice lays out an issued sales invoice in the form of an e-invoice, converting its amounts to rupees
+func layoutEInvoice(invoice SalesInvoiceDocument) (einvoice.Invoice, error) {
+	var document einvoice.Invoice
+
+	if invoice.Status != InvoiceIssued {
+		return document, fmt.Errorf("sales invoice %s is %s, only issued invoices can be registered", invoice.SalesInvoiceNumber, invoice.Status)
+	}
+	if len(invoice.Lines) == 0 {
+		return document, fmt.Errorf("sales invoice %s has no lines", invoice.SalesInvoiceNumber)
+	}
+
+	entryDate := invoice.SalesInvoiceDate
+	if len(entryDate) > 10 {
+		entryDate = entryDate[:10]
+	}
+	invoiceDate, err := time.Parse("2006-01-02", entryDate)
+	if err != nil {
+		return document, err
+	}
+
+	var sellerGstin, sellerAddress, sellerLocation, sellerPincode string
+	sellerQuery := fmt.Sprintf(`SELECT IFNULL(gstin, ''), IFNULL(address, ''), warehouseLocation, IFNULL(pincode, '') FROM warehouse WHERE id = '%s'`, invoice.Lines[0].WarehouseId)
+	if err := db.QueryRow(sellerQuery).Scan(&sellerGstin, &sellerAddress, &sellerLocation, &sellerPincode); err != nil {
+		return document, err
+	}
+
+	var buyerName, buyerGstin, buyerAddress, buyerCity, buyerPincode string
+	buyerQuery := fmt.Sprintf(`SELECT customerName, IFNULL(gstin, ''), IFNULL(address, ''), IFNULL(city, ''), IFNULL(pincode, '') FROM customer WHERE id = '%s'`, invoice.CustomerId)
+	if err := db.QueryRow(buyerQuery).Scan(&buyerName, &buyerGstin, &buyerAddress, &buyerCity, &buyerPincode); err != nil {
+		return document, err
+	}
+
+	sellerPin, _ := strconv.Atoi(sellerPincode)
+	buyerPin, _ := strconv.Atoi(buyerPincode)
+
+	document = einvoice.Invoice{
+		Version: einvoice.SchemaVersion,
+		TranDtls: einvoice.TransactionDetails{
+			TaxSch:      "GST",
+			SupTyp:      "B2B",
+			RegRev:      "N",
+			IgstOnIntra: "N",
+		},
+		DocDtls: einvoice.DocumentDetails{
+			Typ: "INV",
+			No:  invoice.SalesInvoiceNumber,
+			Dt:  invoiceDate.Format("02/01/2006"),
+		},
+		SellerDtls: einvoice.Seller{
+			Gstin: sellerGstin,
+			LglNm: loadPrintHeader(invoice.Lines[0].WarehouseId).CompanyName,
+			Addr1: sellerAddress,
+			Loc:   sellerLocation,
+			Pin:   sellerPin,
+		},
+		BuyerDtls: einvoice.Buyer{
+			Gstin: buyerGstin,
+			LglNm: buyerName,
+			Pos:   invoice.PlaceOfSupply,
+			Addr1: buyerAddress,
+			Loc:   buyerCity,
+			Pin:   buyerPin,
+		},
+	}
+
+	if stateCode, err := validation.GSTINStateCode(sellerGstin); err == nil {
+		document.SellerDtls.Stcd = stateCode
+	}
+
+	// buyers abroad and in India without a GSTIN are unregistered persons
+	switch {
+	case buyerGstin != "":
+		stateCode, _ := validation.GSTINStateCode(buyerGstin)
+		document.BuyerDtls.Stcd = stateCode
+	case invoice.Currency != BaseCurrency:
+		document.TranDtls.SupTyp = "EXPWOP"
+		if invoice.Totals.IgstValue > 0 {
+			document.TranDtls.SupTyp = "EXPWP"
+		}
+		document.BuyerDtls.Gstin = unregisteredGstin
+		document.BuyerDtls.Pos = exportStateCode
+		document.BuyerDtls.Stcd = exportStateCode
+		document.BuyerDtls.Pin = exportPincode
+	default:
+		document.BuyerDtls.Gstin = unregisteredGstin
+		document.BuyerDtls.Stcd = invoice.PlaceOfSupply
+	}
+
+	rate := invoice.ExchangeRate
+	for _, line := range invoice.Lines {
+		_, uomRaw := itemUnits(line.ItemId)
+
+		isService := "N"
+		if validation.IsSAC(line.HsnCode) {
+			isService = "Y"
+		}
+
+		item := einvoice.Item{
+			SlNo:      strconv.Itoa(line.LineNumber),
+			PrdDesc:   strings.TrimSpace(line.ItemName + " " + line.ItemVariant),
+			IsServc:   isService,
+			HsnCd:     line.HsnCode,
+			Qty:       roundQuantity(line.TotalPcs),
+			Unit:      einvoice.UnitCode(uomRaw),
+			UnitPrice: roundQuantity(line.UnitPrice * rate),
+			TotAmt:    roundPaise((line.TaxableValue + line.DiscountValue) * rate),
+			Discount:  roundPaise(line.DiscountValue * rate),
+			GstRt:     line.GstRate,
+			IgstAmt:   roundPaise(line.IgstValue * rate),
+			CgstAmt:   roundPaise(line.CgstValue * rate),
+			SgstAmt:   roundPaise(line.SgstValue * rate),
+		}
+		item.AssAmt = roundPaise(item.TotAmt - item.Discount)
+		item.TotItemVal = roundPaise(item.AssAmt + item.IgstAmt + item.CgstAmt + item.SgstAmt)
+
+		document.ItemList = append(document.ItemList, item)
+
+		document.ValDtls.AssVal += item.AssAmt
+		document.ValDtls.IgstVal += item.IgstAmt
+		document.ValDtls.CgstVal += item.CgstAmt
+		document.ValDtls.SgstVal += item.SgstAmt
+		document.ValDtls.TotInvVal += item.TotItemVal
+	}
+
+	// the invoice discount is already spread over the lines, so none is left for the totals
+	document.ValDtls.AssVal = roundPaise(document.ValDtls.AssVal)
+	document.ValDtls.IgstVal = roundPaise(document.ValDtls.IgstVal)
+	document.ValDtls.CgstVal = roundPaise(document.ValDtls.CgstVal)
+	document.ValDtls.SgstVal = roundPaise(document.ValDtls.SgstVal)
+	document.ValDtls.TotInvVal = roundPaise(document.ValDtls.TotInvVal)
+
+	return document, nil
+}
+
+// GetEInvoice returns a sales invoice as e-invoice JSON, or everything that keeps it from satisfying the schema
+func GetEInvoice(w http.ResponseWriter, r *http.Request) {
+
+	invoice, err := loadSalesInvoice(db, mux.Vars(r)["id"])
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	document, err := buildEInvoice(invoice)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	schemaErrors, err := einvoice.Validate(document)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	var payloadJSON []byte
+	if len(schemaErrors) > 0 {
+		payloadJSON, err = json.Marshal(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("sales invoice %s does not satisfy the e-invoice schema", invoice.SalesInvoiceNumber),
+			"errors":  schemaErrors,
+		})
+	} else {
+		payloadJSON, err = json.Marshal(document)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// parseRegistration reads the IRN, acknowledgement and signed QR code returned by the invoice registration portal
+func parseRegistration(r *http.Request) (irn string, ackNumber string, ackDate string, signedQrCode string, err error) {
+	irn = strings.ToLower(strings.TrimSpace(r.FormValue("irn")))
+	ackNumber = strings.TrimSpace(r.FormValue("ackNumber"))
+	ackDate = strings.TrimSpace(r.FormValue("ackDate"))
+	signedQrCode = strings.TrimSpace(r.FormValue("signedQrCode"))
+
+	if !irnPattern.MatchString(irn) {
+		return "", "", "", "", errors.New("irn must be the 64 character hash returned by the portal")
+	}
+	if _, parseErr := strconv.ParseUint(ackNumber, 10, 64); parseErr != nil || len(ackNumber) > 20 {
+		return "", "", "", "", errors.New("ackNumber must be the number returned by the portal")
+	}
+	if _, parseErr := time.Parse("2006-01-02 15:04:05", ackDate); parseErr != nil {
+		return "", "", "", "", errors.New("ackDate must be in YYYY-MM-DD HH:MM:SS format")
+	}
+	// the signed QR code is a JWT of a header, the invoice summary and the signature of the portal
+	if !signedQrCodePattern.MatchString(signedQrCode) {
+		return "", "", "", "", errors.New("signedQrCode must be the signed JWT returned by the portal")
+	}
+
+	return irn, ackNumber, ackDate, signedQrCode, nil
+}
+
+// RecordEInvoiceRegistration stores the IRN, acknowledgement and signed QR code of a registered sales invoice and returns it
+func RecordEInvoiceRegistration(w http.ResponseWriter, r *http.Request) {
+
+	salesInvoiceId := r.FormValue("salesInvoiceId")
+
+	irn, ackNumber, ackDate, signedQrCode, err := parseRegistration(r)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	// an IRN is only given once, to an issued invoice
+	registrationUpdateQuery := `UPDATE salesInvoice
+		SET irn = ?, ackNumber = ?, ackDate = ?, signedQrCode = ?
+		WHERE id = ? AND status = ? AND irn IS NULL`
+
+	updateResult, err := db.Exec(registrationUpdateQuery, irn, ackNumber, ackDate, signedQrCode, salesInvoiceId, InvoiceIssued)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	if updated, _ := updateResult.RowsAffected(); updated == 0 {
+		writeFailure(w, fmt.Sprintf("sales invoice %s is not an issued invoice without an IRN", salesInvoiceId))
+		return
+	}
+
+	writeSalesInvoice(w, salesInvoiceId)
+}
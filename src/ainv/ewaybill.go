@@ -0,0 +1,608 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rounakdatta/ainv-backend-go/src/einvoice"
+	"github.com/rounakdatta/ainv-backend-go/src/validation"
+)
+
+// defaultEwayBillThreshold is the consignment value in rupees above which goods cannot move without an e-way bill, unless
+// EWAY_BILL_THRESHOLD sets the limit of the state the warehouses are in
+const defaultEwayBillThreshold = 50000.0
+
+// ewayBillThreshold returns the configured consignment value above which an e-way bill is needed
+func ewayBillThreshold() float64 {
+	if threshold, err := strconv.ParseFloat(os.Getenv("EWAY_BILL_THRESHOLD"), 64); err == nil && threshold >= 0 {
+		return threshold
+	}
+
+	return defaultEwayBillThreshold
+}
+
+// the modes of transport of an e-way bill
+var transportModes = map[string]string{
+	"1": "Road",
+	"2": "Rail",
+	"3": "Air",
+	"4": "Ship",
+}
+
+// EwayBillItem is a line of an e-way bill; the tax rates are in percent
+type EwayBillItem struct {
+	ProductName   string  `json:"productName"`
+	ProductDesc   string  `json:"productDesc"`
+	HsnCode       int     `json:"hsnCode"`
+	Quantity      float64 `json:"quantity"`
+	QtyUnit       string  `json:"qtyUnit"`
+	CgstRate      float64 `json:"cgstRate"`
+	SgstRate      float64 `json:"sgstRate"`
+	IgstRate      float64 `json:"igstRate"`
+	CessRate      float64 `json:"cessRate"`
+	CessNonadvol  float64 `json:"cessNonadvol"`
+	TaxableAmount float64 `json:"taxableAmount"`
+}
+
+// EwayBill is the e-way bill generation request of the e-way bill portal; all amounts are in rupees
+type EwayBill struct {
+	SupplyType        string         `json:"supplyType"`
+	SubSupplyType     string         `json:"subSupplyType"`
+	DocType           string         `json:"docType"`
+	DocNo             string         `json:"docNo"`
+	DocDate           string         `json:"docDate"`
+	FromGstin         string         `json:"fromGstin"`
+	FromTrdName       string         `json:"fromTrdName"`
+	FromAddr1         string         `json:"fromAddr1"`
+	FromPlace         string         `json:"fromPlace"`
+	FromPincode       int            `json:"fromPincode"`
+	FromStateCode     int            `json:"fromStateCode"`
+	ActFromStateCode  int            `json:"actFromStateCode"`
+	ToGstin           string         `json:"toGstin"`
+	ToTrdName         string         `json:"toTrdName"`
+	ToAddr1           string         `json:"toAddr1"`
+	ToPlace           string         `json:"toPlace"`
+	ToPincode         int            `json:"toPincode"`
+	ToStateCode       int            `json:"toStateCode"`
+	ActToStateCode    int            `json:"actToStateCode"`
+	TransactionType   int            `json:"transactionType"`
+	TotalValue        float64        `json:"totalValue"`
+	CgstValue         float64        `json:"cgstValue"`
+	SgstValue         float64        `json:"sgstValue"`
+	IgstValue         float64        `json:"igstValue"`
+	CessValue         float64        `json:"cessValue"`
+	CessNonAdvolValue float64        `json:"cessNonAdvolValue"`
+	OtherValue        float64        `json:"otherValue"`
+	TotInvValue       float64        `json:"totInvValue"`
+	TransporterId     string         `json:"transporterId"`
+	TransporterName   string         `json:"transporterName"`
+	TransMode         string         `json:"transMode"`
+	TransDistance     string         `json:"transDistance"`
+	TransDocNo        string         `json:"transDocNo"`
+	TransDocDate      string         `json:"transDocDate"`
+	VehicleNo         string         `json:"vehicleNo"`
+	VehicleType       string         `json:"vehicleType"`
+	ItemList          []EwayBillItem `json:"itemList"`
+}
+
+// EwayBillTransport is how a consignment moves, given when the e-way bill is prepared
+type EwayBillTransport struct {
+	TransporterId   string
+	TransporterName string
+	TransMode       string
+	TransDistance   string
+	TransDocNo      string
+	TransDocDate    string
+	VehicleNo       string
+	VehicleType     string
+}
+
+// MissingEwayBill is an outbound consignment above the threshold that has moved without an e-way bill, either on a sales
+// invoice to a customer or on a stock transfer to another warehouse
+type MissingEwayBill struct {
+	SalesInvoiceId      string  `json:"salesInvoiceId,omitempty"`
+	SalesInvoiceNumber  string  `json:"salesInvoiceNumber,omitempty"`
+	SalesInvoiceDate    string  `json:"salesInvoiceDate,omitempty"`
+	CustomerName        string  `json:"customerName,omitempty"`
+	StockTransferId     string  `json:"stockTransferId,omitempty"`
+	StockTransferNumber string  `json:"stockTransferNumber,omitempty"`
+	StockTransferDate   string  `json:"stockTransferDate,omitempty"`
+	ToWarehouseName     string  `json:"toWarehouseName,omitempty"`
+	WarehouseName       string  `json:"warehouseName"`
+	BaseValue           float64 `json:"baseValue"`
+}
+
+// ewayBillParty is the consignor or consignee of an e-way bill
+type ewayBillParty struct {
+	Gstin     string
+	TradeName string
+	Address   string
+	Place     string
+	Pincode   int
+}
+
+// loadWarehouseParty reads a warehouse as the consignor or consignee of a consignment
+func loadWarehouseParty(q querier, warehouseId string) (ewayBillParty, error) {
+	var party ewayBillParty
+	var pincode string
+
+	err := q.QueryRow(`SELECT IFNULL(gstin, ''), IFNULL(address, ''), warehouseLocation, IFNULL(pincode, '') FROM warehouse WHERE id = ?`, warehouseId).Scan(&party.Gstin, &party.Address, &party.Place, &pincode)
+	if err == sql.ErrNoRows {
+		return party, fmt.Errorf("warehouse %s does not exist", warehouseId)
+	}
+	if err != nil {
+		return party, err
+	}
+
+	party.TradeName = loadPrintHeader(warehouseId).CompanyName
+	party.Pincode, _ = strconv.Atoi(pincode)
+
+	return party, nil
+}
+
+// parseEwayBillTransport reads the transport details of a consignment; the vehicle may be left out to be given later on the portal
+func parseEwayBillTransport(r *http.Request) (EwayBillTransport, error) {
+	transport := EwayBillTransport{
+		TransporterId:   validation.NormalizeGSTIN(r.FormValue("transporterId")),
+		TransporterName: strings.TrimSpace(r.FormValue("transporterName")),
+		TransMode:       r.FormValue("transMode"),
+		TransDistance:   r.FormValue("transDistance"),
+		TransDocNo:      strings.TrimSpace(r.FormValue("transDocNo")),
+		TransDocDate:    r.FormValue("transDocDate"),
+		VehicleNo:       strings.ToUpper(strings.Replace(r.FormValue("vehicleNo"), " ", "", -1)),
+		VehicleType:     "R",
+	}
+
+	if transport.TransMode == "" {
+		transport.TransMode = "1"
+	}
+	if _, ok := transportModes[transport.TransMode]; !ok {
+		return transport, fmt.Errorf("transMode %q must be 1 (road), 2 (rail), 3 (air) or 4 (ship)", transport.TransMode)
+	}
+
+	distance, err := strconv.Atoi(transport.TransDistance)
+	if err != nil || distance < 0 || distance > 4000 {
+		return transport, errors.New("transDistance must be the distance in kilometres, up to 4000")
+	}
+
+	if transport.TransporterId != "" {
+		if err := validation.ValidateGSTIN(transport.TransporterId); err != nil {
+			return transport, err
+		}
+	}
+
+	if transport.TransDocDate != "" {
+		transDocDate, err := time.Parse("2006-01-02", transport.TransDocDate)
+		if err != nil {
+			return transport, errors.New("transDocDate must be in YYYY-MM-DD format")
+		}
+		transport.TransDocDate = transDocDate.Format("02/01/2006")
+	}
+
+	if r.FormValue("overDimensionalCargo") == "true" {
+		transport.VehicleType = "O"
+	}
+
+	// goods by road move in a vehicle, anything else on a transport document
+	if transport.TransMode == "1" && transport.VehicleNo == "" && transport.TransporterId == "" {
+		return transport, errors.New("a consignment by road needs a vehicleNo, or a transporterId to fill it in later")
+	}
+	if transport.TransMode != "1" && (transport.TransDocNo == "" || transport.TransDocDate == "") {
+		return transport, fmt.Errorf("a consignment by %s needs transDocNo and transDocDate", strings.ToLower(transportModes[transport.TransMode]))
+	}
+
+	return transport, nil
+}
+
+// stateCodeNumber turns a two digit GST state code into the number the e-way bill portal expects
+func stateCodeNumber(stateCode string) int {
+	number, _ := strconv.Atoi(stateCode)
+	return number
+}
+
+// buildEwayBill lays out an issued sales invoice as an e-way bill request, converting its amounts to rupees
+func buildEwayBill(invoice SalesInvoiceDocument, transport EwayBillTransport) (EwayBill, error) {
+	var bill EwayBill
+
+	// the consignor, consignee and lines are the same as those of the e-invoice, but unregistered buyers need an e-way bill too
+	document, err := layoutEInvoice(invoice)
+	if err != nil {
+		return bill, err
+	}
+
+	bill = EwayBill{
+		SupplyType:       "O",
+		SubSupplyType:    "1",
+		DocType:          "INV",
+		DocNo:            document.DocDtls.No,
+		DocDate:          document.DocDtls.Dt,
+		FromGstin:        document.SellerDtls.Gstin,
+		FromTrdName:      document.SellerDtls.LglNm,
+		FromAddr1:        document.SellerDtls.Addr1,
+		FromPlace:        document.SellerDtls.Loc,
+		FromPincode:      document.SellerDtls.Pin,
+		FromStateCode:    stateCodeNumber(document.SellerDtls.Stcd),
+		ActFromStateCode: stateCodeNumber(document.SellerDtls.Stcd),
+		ToGstin:          document.BuyerDtls.Gstin,
+		ToTrdName:        document.BuyerDtls.LglNm,
+		ToAddr1:          document.BuyerDtls.Addr1,
+		ToPlace:          document.BuyerDtls.Loc,
+		ToPincode:        document.BuyerDtls.Pin,
+		ToStateCode:      stateCodeNumber(document.BuyerDtls.Pos),
+		ActToStateCode:   stateCodeNumber(document.BuyerDtls.Pos),
+		TransactionType:  1,
+		TotalValue:       document.ValDtls.AssVal,
+		CgstValue:        document.ValDtls.CgstVal,
+		SgstValue:        document.ValDtls.SgstVal,
+		IgstValue:        document.ValDtls.IgstVal,
+		TotInvValue:      document.ValDtls.TotInvVal,
+		TransporterId:    transport.TransporterId,
+		TransporterName:  transport.TransporterName,
+		TransMode:        transport.TransMode,
+		TransDistance:    transport.TransDistance,
+		TransDocNo:       transport.TransDocNo,
+		TransDocDate:     transport.TransDocDate,
+		VehicleNo:        transport.VehicleNo,
+		VehicleType:      transport.VehicleType,
+	}
+
+	if document.TranDtls.SupTyp == "EXPWP" || document.TranDtls.SupTyp == "EXPWOP" {
+		bill.SubSupplyType = "3"
+	}
+
+	for _, item := range document.ItemList {
+		hsnCode, _ := strconv.Atoi(item.HsnCd)
+
+		ewayItem := EwayBillItem{
+			ProductName:   item.PrdDesc,
+			ProductDesc:   item.PrdDesc,
+			HsnCode:       hsnCode,
+			Quantity:      item.Qty,
+			QtyUnit:       item.Unit,
+			TaxableAmount: item.AssAmt,
+		}
+		if item.IgstAmt > 0 {
+			ewayItem.IgstRate = item.GstRt
+		} else {
+			ewayItem.CgstRate = item.GstRt / 2
+			ewayItem.SgstRate = item.GstRt / 2
+		}
+
+		bill.ItemList = append(bill.ItemList, ewayItem)
+	}
+
+	return bill, nil
+}
+
+// buildTransferEwayBill lays out a stock transfer as an e-way bill request for goods moved under a delivery challan
+// between two warehouses of the same registration, from the consignor GSTIN to the consignee warehouse GSTIN
+func buildTransferEwayBill(transfer StockTransfer, consignor ewayBillParty, consignee ewayBillParty, transport EwayBillTransport) (EwayBill, error) {
+	var bill EwayBill
+
+	if len(transfer.Lines) == 0 {
+		return bill, fmt.Errorf("stock transfer %s has no lines", transfer.StockTransferNumber)
+	}
+
+	fromStateCode, err := validation.GSTINStateCode(consignor.Gstin)
+	if err != nil {
+		return bill, fmt.Errorf("warehouse %s needs a valid GSTIN to send goods on an e-way bill: %v", transfer.FromWarehouseName, err)
+	}
+	toStateCode, err := validation.GSTINStateCode(consignee.Gstin)
+	if err != nil {
+		return bill, fmt.Errorf("warehouse %s needs a valid GSTIN to receive goods on an e-way bill: %v", transfer.ToWarehouseName, err)
+	}
+
+	entryDate := transfer.StockTransferDate
+	if len(entryDate) > 10 {
+		entryDate = entryDate[:10]
+	}
+	transferDate, err := time.Parse("2006-01-02", entryDate)
+	if err != nil {
+		return bill, err
+	}
+
+	bill = EwayBill{
+		SupplyType:       "O",
+		SubSupplyType:    "5",
+		DocType:          "CHL",
+		DocNo:            transfer.StockTransferNumber,
+		DocDate:          transferDate.Format("02/01/2006"),
+		FromGstin:        consignor.Gstin,
+		FromTrdName:      consignor.TradeName,
+		FromAddr1:        consignor.Address,
+		FromPlace:        consignor.Place,
+		FromPincode:      consignor.Pincode,
+		FromStateCode:    stateCodeNumber(fromStateCode),
+		ActFromStateCode: stateCodeNumber(fromStateCode),
+		ToGstin:          consignee.Gstin,
+		ToTrdName:        consignee.TradeName,
+		ToAddr1:          consignee.Address,
+		ToPlace:          consignee.Place,
+		ToPincode:        consignee.Pincode,
+		ToStateCode:      stateCodeNumber(toStateCode),
+		ActToStateCode:   stateCodeNumber(toStateCode),
+		TransactionType:  1,
+		TransporterId:    transport.TransporterId,
+		TransporterName:  transport.TransporterName,
+		TransMode:        transport.TransMode,
+		TransDistance:    transport.TransDistance,
+		TransDocNo:       transport.TransDocNo,
+		TransDocDate:     transport.TransDocDate,
+		VehicleNo:        transport.VehicleNo,
+		VehicleType:      transport.VehicleType,
+	}
+
+	// the goods stay with the same registration, so they move at cost and without tax
+	for _, line := range transfer.Lines {
+		hsnCode, _ := strconv.Atoi(line.HsnCode)
+		description := strings.TrimSpace(line.ItemName + " " + line.ItemVariant)
+
+		bill.ItemList = append(bill.ItemList, EwayBillItem{
+			ProductName:   description,
+			ProductDesc:   description,
+			HsnCode:       hsnCode,
+			Quantity:      roundQuantity(line.TotalPcs),
+			QtyUnit:       einvoice.UnitCode(line.RawUnit),
+			TaxableAmount: roundPaise(line.Value),
+		})
+
+		bill.TotalValue += line.Value
+	}
+	bill.TotalValue = roundPaise(bill.TotalValue)
+	bill.TotInvValue = bill.TotalValue
+
+	return bill, nil
+}
+
+// GetEwayBill returns the e-way bill request of a sales invoice for the transport details given
+func GetEwayBill(w http.ResponseWriter, r *http.Request) {
+
+	transport, err := parseEwayBillTransport(r)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	invoice, err := loadSalesInvoice(db, mux.Vars(r)["id"])
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	bill, err := buildEwayBill(invoice, transport)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	if bill.FromPincode == 0 || bill.ToPincode == 0 {
+		writeFailure(w, "the pincodes of the warehouse and the customer are needed for an e-way bill")
+		return
+	}
+
+	payloadJSON, err := json.Marshal(bill)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// parseEwayBillRecord reads the number and validity of an e-way bill generated on the portal
+func parseEwayBillRecord(r *http.Request) (ewayBillNumber string, ewayBillDate string, validUpto string, err error) {
+	ewayBillNumber = strings.Replace(r.FormValue("ewayBillNumber"), " ", "", -1)
+	ewayBillDate = r.FormValue("ewayBillDate")
+	validUpto = r.FormValue("validUpto")
+
+	if _, err := strconv.ParseUint(ewayBillNumber, 10, 64); err != nil || len(ewayBillNumber) != 12 {
+		return "", "", "", errors.New("ewayBillNumber must be the 12 digit number returned by the portal")
+	}
+
+	generatedAt, err := time.Parse("2006-01-02 15:04:05", ewayBillDate)
+	if err != nil {
+		return "", "", "", errors.New("ewayBillDate must be in YYYY-MM-DD HH:MM:SS format")
+	}
+	validUntil, err := time.Parse("2006-01-02 15:04:05", validUpto)
+	if err != nil || !validUntil.After(generatedAt) {
+		return "", "", "", errors.New("validUpto must be in YYYY-MM-DD HH:MM:SS format and after ewayBillDate")
+	}
+
+	return ewayBillNumber, ewayBillDate, validUpto, nil
+}
+
+// RecordEwayBill stores the number and validity of the e-way bill generated for a sales invoice and returns it
+func RecordEwayBill(w http.ResponseWriter, r *http.Request) {
+
+	salesInvoiceId := r.FormValue("salesInvoiceId")
+
+	ewayBillNumber, ewayBillDate, validUpto, err := parseEwayBillRecord(r)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	// the validity is recorded again when it is extended on the portal
+	updateResult, err := db.Exec(`UPDATE salesInvoice
+		SET ewayBillNumber = ?, ewayBillDate = ?, ewayBillValidUpto = ?
+		WHERE id = ? AND status = ?`, ewayBillNumber, ewayBillDate, validUpto, salesInvoiceId, InvoiceIssued)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	if updated, _ := updateResult.RowsAffected(); updated == 0 {
+		var status string
+		db.QueryRow(`SELECT status FROM salesInvoice WHERE id = ?`, salesInvoiceId).Scan(&status)
+		if status != InvoiceIssued {
+			writeFailure(w, fmt.Sprintf("sales invoice %s is not an issued invoice", salesInvoiceId))
+			return
+		}
+	}
+
+	writeSalesInvoice(w, salesInvoiceId)
+}
+
+// GetStockTransferEwayBill returns the e-way bill request of a stock transfer for the transport details given
+func GetStockTransferEwayBill(w http.ResponseWriter, r *http.Request) {
+
+	transport, err := parseEwayBillTransport(r)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	transfer, err := loadStockTransfer(db, mux.Vars(r)["id"])
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	consignor, err := loadWarehouseParty(db, transfer.FromWarehouseId)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+	consignee, err := loadWarehouseParty(db, transfer.ToWarehouseId)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	bill, err := buildTransferEwayBill(transfer, consignor, consignee, transport)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	if bill.FromPincode == 0 || bill.ToPincode == 0 {
+		writeFailure(w, "the pincodes of both warehouses are needed for an e-way bill")
+		return
+	}
+
+	payloadJSON, err := json.Marshal(bill)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// RecordStockTransferEwayBill stores the number and validity of the e-way bill generated for a stock transfer and returns it
+func RecordStockTransferEwayBill(w http.ResponseWriter, r *http.Request) {
+
+	stockTransferId := r.FormValue("stockTransferId")
+
+	ewayBillNumber, ewayBillDate, validUpto, err := parseEwayBillRecord(r)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	var exists int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM stockTransfer WHERE id = ?`, stockTransferId).Scan(&exists); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+	if exists == 0 {
+		writeFailure(w, fmt.Sprintf("stock transfer %s does not exist", stockTransferId))
+		return
+	}
+
+	// the validity is recorded again when it is extended on the portal
+	_, err = db.Exec(`UPDATE stockTransfer
+		SET ewayBillNumber = ?, ewayBillDate = ?, ewayBillValidUpto = ?
+		WHERE id = ?`, ewayBillNumber, ewayBillDate, validUpto, stockTransferId)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	writeStockTransfer(w, stockTransferId)
+}
+
+// GetMissingEwayBills returns the issued sales invoices and the stock transfers whose consignment is above the threshold
+// but has no e-way bill
+func GetMissingEwayBills(w http.ResponseWriter, r *http.Request) {
+
+	var payload []MissingEwayBill
+	threshold := ewayBillThreshold()
+
+	allMissing, err := db.Query(`SELECT
+		si.id, si.tracker, si.entryDate, IFNULL(cu.customerName, 'N/A'), IFNULL(MIN(wh.warehouseName), 'N/A'),
+		ROUND(SUM(tr.totalValue * tr.exchangeRate), 2) AS baseValue
+		FROM salesInvoice si
+		JOIN transaction tr ON tr.salesInvoice = si.id AND tr.isError = 0 AND tr.comeOrGo = 'out'
+		LEFT JOIN customer cu ON cu.id = si.customerId
+		LEFT JOIN warehouse wh ON wh.id = tr.warehouseId
+		WHERE si.status = ? AND si.ewayBillNumber IS NULL
+		GROUP BY si.id, si.tracker, si.entryDate, cu.customerName
+		HAVING baseValue > ?
+		ORDER BY si.entryDate, si.id`, InvoiceIssued, threshold)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allMissing.Next() {
+		var missing MissingEwayBill
+
+		err := allMissing.Scan(&missing.SalesInvoiceId, &missing.SalesInvoiceNumber, &missing.SalesInvoiceDate, &missing.CustomerName, &missing.WarehouseName, &missing.BaseValue)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		payload = append(payload, missing)
+	}
+
+	// a transfer is valued at the cost of the goods, in the base currency
+	allTransfers, err := db.Query(`SELECT
+		st.id, st.tracker, st.entryDate, IFNULL(fw.warehouseName, 'N/A'), IFNULL(tw.warehouseName, 'N/A'),
+		ROUND(SUM(tr.totalValue), 2) AS baseValue
+		FROM stockTransfer st
+		JOIN transaction tr ON tr.stockTransfer = st.id AND tr.isError = 0 AND tr.comeOrGo = 'out'
+		LEFT JOIN warehouse fw ON fw.id = st.fromWarehouseId
+		LEFT JOIN warehouse tw ON tw.id = st.toWarehouseId
+		WHERE st.ewayBillNumber IS NULL
+		GROUP BY st.id, st.tracker, st.entryDate, fw.warehouseName, tw.warehouseName
+		HAVING baseValue > ?
+		ORDER BY st.entryDate, st.id`, threshold)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allTransfers.Next() {
+		var missing MissingEwayBill
+
+		err := allTransfers.Scan(&missing.StockTransferId, &missing.StockTransferNumber, &missing.StockTransferDate, &missing.WarehouseName, &missing.ToWarehouseName, &missing.BaseValue)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		payload = append(payload, missing)
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEwayBillThreshold(t *testing.T) {
+	cases := []struct {
+		setting   string
+		threshold float64
+	}{
+		{"", 50000},
+		{"100000", 100000},
+		{"-1", 50000},
+		{"fifty thousand", 50000},
+	}
+
+	defer os.Unsetenv("EWAY_BILL_THRESHOLD")
+	for _, c := range cases {
+		os.Setenv("EWAY_BILL_THRESHOLD", c.setting)
+		if threshold := ewayBillThreshold(); threshold != c.threshold {
+			t.Errorf("EWAY_BILL_THRESHOLD=%q: threshold = %g, want %g", c.setting, threshold, c.threshold)
+		}
+	}
+}
+
+func TestParseStockTransferLines(t *testing.T) {
+	cases := []struct {
+		raw   string
+		lines int
+		valid bool
+	}{
+		{`[{"itemId": "1", "bigQuantity": 2}, {"itemId": "2", "bigQuantity": 0.5}]`, 2, true},
+		{`[]`, 0, false},
+		{`{"itemId": "1"}`, 0, false},
+		{`[{"bigQuantity": 2}]`, 0, false},
+		{`[{"itemId": "1", "bigQuantity": 0}]`, 0, false},
+		{`[{"itemId": "1", "bigQuantity": 2}, {"itemId": "1", "bigQuantity": 3}]`, 0, false},
+	}
+
+	for _, c := range cases {
+		lines, err := parseStockTransferLines(c.raw)
+		if (err == nil) != c.valid {
+			t.Errorf("parseStockTransferLines(%s) error = %v, want valid %v", c.raw, err, c.valid)
+			continue
+		}
+		if len(lines) != c.lines {
+			t.Errorf("parseStockTransferLines(%s) = %d lines, want %d", c.raw, len(lines), c.lines)
+		}
+		for i, line := range lines {
+			if line.LineNumber != i+1 {
+				t.Errorf("parseStockTransferLines(%s) line %d is numbered %d", c.raw, i+1, line.LineNumber)
+			}
+		}
+	}
+}
+
+func TestBuildTransferEwayBill(t *testing.T) {
+	transfer := StockTransfer{
+		StockTransferNumber: "TRF-12",
+		StockTransferDate:   "2026-05-04",
+		FromWarehouseName:   "Bhiwandi",
+		ToWarehouseName:     "Pune",
+		Lines: []StockTransferLine{
+			{ItemId: "1", ItemName: "Ceramic Mug", ItemVariant: "Blue", HsnCode: "6912", TotalPcs: 480, RawUnit: "pcs", Value: 36000},
+			{ItemId: "2", ItemName: "Teapot", HsnCode: "6912", TotalPcs: 60, RawUnit: "pcs", Value: 18000.456},
+		},
+	}
+	consignor := ewayBillParty{Gstin: "27AAPFU0939F1ZV", TradeName: "Ainv Logistics", Address: "Plot 4, MIDC", Place: "Bhiwandi", Pincode: 421302}
+	consignee := ewayBillParty{Gstin: "27AAPFU0939F1ZV", TradeName: "Ainv Logistics", Address: "Gate 2, Chakan", Place: "Pune", Pincode: 410501}
+	transport := EwayBillTransport{TransMode: "1", TransDistance: "160", VehicleNo: "MH04AB1234", VehicleType: "R"}
+
+	bill, err := buildTransferEwayBill(transfer, consignor, consignee, transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bill.SupplyType != "O" || bill.SubSupplyType != "5" || bill.DocType != "CHL" {
+		t.Errorf("supply %s/%s on %s, want O/5 on CHL", bill.SupplyType, bill.SubSupplyType, bill.DocType)
+	}
+	if bill.DocNo != "TRF-12" || bill.DocDate != "04/05/2026" {
+		t.Errorf("document %s of %s, want TRF-12 of 04/05/2026", bill.DocNo, bill.DocDate)
+	}
+	if bill.FromGstin != consignor.Gstin || bill.ToGstin != consignee.Gstin || bill.FromStateCode != 27 || bill.ToStateCode != 27 {
+		t.Errorf("from %s (%d) to %s (%d)", bill.FromGstin, bill.FromStateCode, bill.ToGstin, bill.ToStateCode)
+	}
+	if bill.FromPlace != "Bhiwandi" || bill.ToPincode != 410501 || bill.VehicleNo != "MH04AB1234" {
+		t.Errorf("consignment from %s to %d in %s", bill.FromPlace, bill.ToPincode, bill.VehicleNo)
+	}
+	if bill.TotalValue != 54000.46 || bill.TotInvValue != 54000.46 || bill.IgstValue != 0 || bill.CgstValue != 0 {
+		t.Errorf("values %.2f, invoice %.2f, IGST %.2f, CGST %.2f", bill.TotalValue, bill.TotInvValue, bill.IgstValue, bill.CgstValue)
+	}
+	if len(bill.ItemList) != 2 {
+		t.Fatalf("items = %v, want 2", bill.ItemList)
+	}
+	if item := bill.ItemList[0]; item.ProductName != "Ceramic Mug Blue" || item.HsnCode != 6912 || item.Quantity != 480 || item.QtyUnit != "PCS" || item.TaxableAmount != 36000 {
+		t.Errorf("first item = %+v", item)
+	}
+
+	unregistered := consignee
+	unregistered.Gstin = ""
+	if _, err := buildTransferEwayBill(transfer, consignor, unregistered, transport); err == nil {
+		t.Error("a consignee warehouse without a GSTIN was accepted")
+	}
+
+	transfer.Lines = nil
+	if _, err := buildTransferEwayBill(transfer, consignor, consignee, transport); err == nil {
+		t.Error("a transfer without lines was accepted")
+	}
+}
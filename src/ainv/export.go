@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rounakdatta/ainv-backend-go/src/xlsx"
+)
+
+// the output formats a report can be requested in
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// requestedFormat picks the output format from the format parameter, falling back to the Accept header
+func requestedFormat(r *http.Request) string {
+	format := strings.ToLower(r.FormValue("format"))
+	if format == FormatCSV || format == FormatXLSX || format == FormatJSON {
+		return format
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.Contains(accept, "text/csv") {
+		return FormatCSV
+	}
+	if strings.Contains(accept, xlsxContentType) {
+		return FormatXLSX
+	}
+
+	return FormatJSON
+}
+
+// writeCSV writes the rows under the header as a downloadable CSV file
+func writeCSV(w http.ResponseWriter, filename string, header []string, rows [][]string) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", filename))
+
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(header); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		log.Println(err)
+	}
+}
+
+// tableWriter streams typed rows as CSV or XLSX, so that a large export is written out as it is read
+type tableWriter struct {
+	csv  *csv.Writer
+	xlsx *xlsx.Writer
+}
+
+// newTableWriter starts a downloadable CSV or XLSX file with its header row
+func newTableWriter(w http.ResponseWriter, format string, filename string, header []string) (*tableWriter, error) {
+	if format == FormatXLSX {
+		w.Header().Set("Content-Type", xlsxContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.xlsx\"", filename))
+
+		writer, err := xlsx.NewWriter(w, filename, header)
+		if err != nil {
+			return nil, err
+		}
+		return &tableWriter{xlsx: writer}, nil
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", filename))
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	return &tableWriter{csv: writer}, nil
+}
+
+// WriteRow appends a row of typed cells
+func (table *tableWriter) WriteRow(cells []interface{}) error {
+	if table.xlsx != nil {
+		return table.xlsx.WriteRow(cells)
+	}
+
+	return table.csv.Write(formatCells(cells))
+}
+
+// Close finishes the file
+func (table *tableWriter) Close() error {
+	if table.xlsx != nil {
+		return table.xlsx.Close()
+	}
+
+	table.csv.Flush()
+	return table.csv.Error()
+}
+
+// writeTable writes typed rows as CSV or XLSX, whichever was requested
+func writeTable(w http.ResponseWriter, format string, filename string, header []string, rows [][]interface{}) {
+	table, err := newTableWriter(w, format, filename, header)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, row := range rows {
+		if err := table.WriteRow(row); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+
+	if err := table.Close(); err != nil {
+		log.Println(err)
+	}
+}
+
+// formatCells renders typed cells as text for CSV output
+func formatCells(cells []interface{}) []string {
+	var formatted []string
+
+	for _, cell := range cells {
+		switch value := cell.(type) {
+		case xlsx.Amount:
+			formatted = append(formatted, strconv.FormatFloat(float64(value), 'f', 2, 64))
+		case float64:
+			formatted = append(formatted, formatQuantity(value))
+		default:
+			formatted = append(formatted, fmt.Sprint(value))
+		}
+	}
+
+	return formatted
+}
+
+// formatQuantity renders a quantity for CSV output without trailing zeros
+func formatQuantity(quantity float64) string {
+	return strconv.FormatFloat(math.Round(quantity*1000)/1000, 'f', -1, 64)
+}
+
+// parseNumber reads a number the database returned as text, anything else counting as zero
+func parseNumber(value string) float64 {
+	number, _ := strconv.ParseFloat(value, 64)
+	return number
+}
+
+// itemInventoryHeader is the header row of the stock search export
+var itemInventoryHeader = []string{
+	"Item", "Variant", "HSN", "HSN Description",
+	"Quantity (Big)", "UOM (Big)", "Quantity (Small)", "UOM (Small)", "Quantity (Raw)", "UOM (Raw)",
+	"Warehouse", "Location", "Client",
+}
+
+func itemInventoryRow(item ItemInventory) []interface{} {
+	return []interface{}{
+		item.ItemName, item.ItemVariant, item.HsnCode, item.HsnDescription,
+		parseNumber(item.BigcartonQuantity), item.UomBig, parseNumber(item.SmallboxQuantity), item.UomSmall, parseNumber(item.ItemQuantity), item.UomRaw,
+		item.WarehouseName, item.WarehouseLocation, item.ClientName,
+	}
+}
+
+// salesTransactionHeader is the header row of the transaction search export
+var salesTransactionHeader = []string{
+	"Transaction", "Bill of Entry", "Sales Invoice", "Date", "Direction",
+	"Item", "Variant", "Warehouse", "Location", "Client", "Customer",
+	"Change (Big)", "Stock (Big)", "UOM (Big)", "Total Pcs", "UOM (Raw)",
+	"Material Value", "GST", "CGST", "SGST", "IGST", "Place of Supply", "Total Value", "Currency", "Exchange Rate",
+	"Total Value (" + BaseCurrency + ")", "Value per Piece", "Paid Amount", "Outstanding", "Payment Status", "Payment Date",
+	"Field 1", "Field 2", "Remarks",
+}
+
+func salesTransactionRow(sale SalesTransaction) []interface{} {
+	return []interface{}{
+		sale.TransactionId, sale.BillOfEntry, sale.SalesInvoice, sale.EntryDate, sale.ComeOrGo,
+		sale.ItemName, sale.ItemVariant, sale.WarehouseName, sale.WarehouseLocation, sale.ClientName, sale.CustomerName,
+		parseNumber(sale.ChangeStock), parseNumber(sale.FinalStock), sale.BigUnit, parseNumber(sale.TotalPcs), sale.RawUnit,
+		xlsx.Amount(parseNumber(sale.MaterialValue)), xlsx.Amount(parseNumber(sale.GstValue)), xlsx.Amount(parseNumber(sale.CgstValue)),
+		xlsx.Amount(parseNumber(sale.SgstValue)), xlsx.Amount(parseNumber(sale.IgstValue)), sale.PlaceOfSupply,
+		xlsx.Amount(parseNumber(sale.TotalValue)), sale.Currency, parseNumber(sale.ExchangeRate),
+		xlsx.Amount(parseNumber(sale.BaseTotalValue)), xlsx.Amount(sale.ValuePerPiece), xlsx.Amount(parseNumber(sale.PaidAmount)),
+		xlsx.Amount(parseNumber(sale.Outstanding)), sale.PaymentStatus, sale.PaymentDate,
+		sale.Field1, sale.Field2, sale.Remarks,
+	}
+}
+
+// overviewHeader is the header row of the overview export, one row per document and direction
+var overviewHeader = []string{
+	"Bill of Entry", "Sales Invoice", "Direction", "Date", "Items", "Warehouses", "Client", "Customer",
+	"Quantity (Big)", "Total Value (" + BaseCurrency + ")", "Currency", "Original Value", "Paid Amount",
+}
+
+func overviewRow(overview OverviewTransaction) []interface{} {
+	return []interface{}{
+		overview.BillOfEntry, overview.SalesInvoice, overview.Direction, overview.EntryDate, overview.Item, overview.Warehouse, overview.Client, overview.Customer,
+		parseNumber(overview.BigQuantity), xlsx.Amount(parseNumber(overview.TotalValue)), overview.Currency, xlsx.Amount(parseNumber(overview.OriginalValue)), xlsx.Amount(parseNumber(overview.PaidAmount)),
+	}
+}
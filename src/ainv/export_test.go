@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/rounakdatta/ainv-backend-go/src/xlsx"
+)
+
+func TestRequestedFormat(t *testing.T) {
+	cases := []struct {
+		target string
+		accept string
+		format string
+	}{
+		{"/export?format=CSV", "", FormatCSV},
+		{"/export?format=xlsx", "text/csv", FormatXLSX},
+		{"/export?format=pdf", "text/csv, */*", FormatCSV},
+		{"/export", xlsxContentType, FormatXLSX},
+		{"/export", "application/json", FormatJSON},
+		{"/export", "", FormatJSON},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", c.target, nil)
+		r.Header.Set("Accept", c.accept)
+		if format := requestedFormat(r); format != c.format {
+			t.Errorf("%s accepting %q = %s, want %s", c.target, c.accept, format, c.format)
+		}
+	}
+}
+
+func TestFormatCells(t *testing.T) {
+	cells := formatCells([]interface{}{"Mug", 2.5, 3.0, 1.23456, xlsx.Amount(1180), xlsx.Amount(0.126), 7})
+	if want := []string{"Mug", "2.5", "3", "1.235", "1180.00", "0.13", "7"}; !reflect.DeepEqual(cells, want) {
+		t.Errorf("formatCells = %q, want %q", cells, want)
+	}
+}
+
+func TestExportRowsMatchHeaders(t *testing.T) {
+	if row := itemInventoryRow(ItemInventory{}); len(row) != len(itemInventoryHeader) {
+		t.Errorf("stock rows have %d cells for %d columns", len(row), len(itemInventoryHeader))
+	}
+	if row := salesTransactionRow(SalesTransaction{}); len(row) != len(salesTransactionHeader) {
+		t.Errorf("transaction rows have %d cells for %d columns", len(row), len(salesTransactionHeader))
+	}
+	if row := overviewRow(OverviewTransaction{}); len(row) != len(overviewHeader) {
+		t.Errorf("overview rows have %d cells for %d columns", len(row), len(overviewHeader))
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	header := []string{"Item", "Quantity (Big)", "Total Value"}
+	rows := [][]interface{}{
+		{"Mug, blue", 2.5, xlsx.Amount(1180)},
+		{"Teapot", 1.0, xlsx.Amount(450.5)},
+	}
+
+	w := httptest.NewRecorder()
+	writeTable(w, FormatCSV, "stock", header, rows)
+
+	if w.Header().Get("Content-Type") != "text/csv" || w.Header().Get("Content-Disposition") != `attachment; filename="stock.csv"` {
+		t.Errorf("CSV headers = %v", w.Header())
+	}
+	if want := "Item,Quantity (Big),Total Value\n\"Mug, blue\",2.5,1180.00\nTeapot,1,450.50\n"; w.Body.String() != want {
+		t.Errorf("CSV = %q, want %q", w.Body.String(), want)
+	}
+
+	w = httptest.NewRecorder()
+	writeTable(w, FormatXLSX, "stock", header, rows)
+
+	if w.Header().Get("Content-Type") != xlsxContentType || w.Header().Get("Content-Disposition") != `attachment; filename="stock.xlsx"` {
+		t.Errorf("XLSX headers = %v", w.Header())
+	}
+
+	written := w.Body.Bytes()
+	records, err := xlsx.ReadRows(bytes.NewReader(written), int64(len(written)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{header, {"Mug, blue", "2.5", "1180"}, {"Teapot", "1", "450.5"}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("XLSX rows = %q, want %q", records, want)
+	}
+}
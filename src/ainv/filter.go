@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// transactionFilter narrows the transaction searches; every field left empty or "all" leaves them as wide as before,
+// and the fields that are set all have to match
+type transactionFilter struct {
+	BillOfEntryId   string
+	DocumentNumber  string
+	ClientId        string
+	CustomerId      string
+	Direction       string
+	ItemIds         []string
+	WarehouseIds    []string
+	FromDate        string
+	ToDate          string
+	PaymentFromDate string
+	PaymentToDate   string
+	PaymentStatuses []string
+	Remarks         string
+}
+
+// splitList reads a list of values separated by commas or spaces, "all" meaning no list at all
+func splitList(value string) []string {
+	values := strings.FieldsFunc(value, func(c rune) bool {
+		return c == ',' || c == ' '
+	})
+	if len(values) == 1 && !isFiltered(values[0]) {
+		return nil
+	}
+
+	return values
+}
+
+// parseTransactionFilter reads the filter of a transaction search, checking the values that end up in its SQL
+func parseTransactionFilter(r *http.Request) (transactionFilter, error) {
+	filter := transactionFilter{
+		BillOfEntryId:   r.FormValue("billOfEntry"),
+		DocumentNumber:  strings.TrimSpace(r.FormValue("salesInvoiceNumber")),
+		ClientId:        r.FormValue("clientId"),
+		CustomerId:      r.FormValue("customerId"),
+		Direction:       r.FormValue("filter"),
+		ItemIds:         splitList(r.FormValue("itemIds")),
+		WarehouseIds:    splitList(r.FormValue("warehouseIds")),
+		FromDate:        r.FormValue("fromDate"),
+		ToDate:          r.FormValue("toDate"),
+		PaymentFromDate: r.FormValue("paymentFromDate"),
+		PaymentToDate:   r.FormValue("paymentToDate"),
+		PaymentStatuses: splitList(strings.ToLower(r.FormValue("paymentStatus"))),
+		Remarks:         strings.TrimSpace(r.FormValue("remarks")),
+	}
+
+	ids := map[string]string{"billOfEntry": filter.BillOfEntryId, "clientId": filter.ClientId, "customerId": filter.CustomerId}
+	for name, id := range ids {
+		if isFiltered(id) {
+			if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+				return filter, fmt.Errorf("%s %q must be an id", name, id)
+			}
+		}
+	}
+	for name, list := range map[string][]string{"itemIds": filter.ItemIds, "warehouseIds": filter.WarehouseIds} {
+		for _, id := range list {
+			if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+				return filter, fmt.Errorf("%s must be ids separated by commas, %q is not one", name, id)
+			}
+		}
+	}
+
+	// the searches have always shown every direction for any filter other than in, out and opening
+	if filter.Direction != "in" && filter.Direction != "out" && filter.Direction != DirectionOpening {
+		filter.Direction = ""
+	}
+
+	dates := map[string]string{"fromDate": filter.FromDate, "toDate": filter.ToDate, "paymentFromDate": filter.PaymentFromDate, "paymentToDate": filter.PaymentToDate}
+	for name, date := range dates {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return filter, fmt.Errorf("%s must be in YYYY-MM-DD format", name)
+		}
+	}
+
+	for _, status := range filter.PaymentStatuses {
+		if status != PaymentUnpaid && status != PaymentPartial && status != PaymentPaid {
+			return filter, fmt.Errorf("paymentStatus must be %s, %s or %s", PaymentUnpaid, PaymentPartial, PaymentPaid)
+		}
+	}
+
+	return filter, nil
+}
+
+// likePattern escapes text for use inside a LIKE pattern, so that its quotes and wildcards match themselves
+func likePattern(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`, `%`, `\%`, `_`, `\_`).Replace(text)
+}
+
+// conditions builds the WHERE conditions of the filter against the transaction table aliased as tr
+func (filter transactionFilter) conditions() []string {
+	var conditions []string
+
+	if isFiltered(filter.BillOfEntryId) {
+		conditions = append(conditions, fmt.Sprintf("tr.billOfEntry = '%s'", filter.BillOfEntryId))
+	}
+	if isFiltered(filter.DocumentNumber) {
+		number := escapeQuotes(filter.DocumentNumber)
+		conditions = append(conditions, fmt.Sprintf(`(tr.billOfEntry IN (SELECT id FROM billOfEntry WHERE tracker = '%s')
+			OR tr.salesInvoice IN (SELECT id FROM salesInvoice WHERE tracker = '%s')
+			OR tr.openingStock IN (SELECT id FROM openingStock WHERE tracker = '%s')
+			OR tr.stockTransfer IN (SELECT id FROM stockTransfer WHERE tracker = '%s'))`, number, number, number, number))
+	}
+	if isFiltered(filter.ClientId) {
+		conditions = append(conditions, fmt.Sprintf("tr.clientId = '%s'", filter.ClientId))
+	}
+	if isFiltered(filter.CustomerId) {
+		conditions = append(conditions, fmt.Sprintf("tr.customerId = '%s'", filter.CustomerId))
+	}
+	if isFiltered(filter.Direction) {
+		conditions = append(conditions, fmt.Sprintf("tr.comeOrGo = '%s'", filter.Direction))
+	}
+	if len(filter.ItemIds) > 0 {
+		conditions = append(conditions, fmt.Sprintf("tr.itemId IN (%s)", strings.Join(filter.ItemIds, ", ")))
+	}
+	if len(filter.WarehouseIds) > 0 {
+		conditions = append(conditions, fmt.Sprintf("tr.warehouseId IN (%s)", strings.Join(filter.WarehouseIds, ", ")))
+	}
+	if filter.FromDate != "" {
+		conditions = append(conditions, fmt.Sprintf("%s >= '%s'", transactionEntryDate, filter.FromDate))
+	}
+	if filter.ToDate != "" {
+		conditions = append(conditions, fmt.Sprintf("%s <= '%s'", transactionEntryDate, filter.ToDate))
+	}
+	if filter.PaymentFromDate != "" {
+		conditions = append(conditions, fmt.Sprintf("tr.date >= '%s'", filter.PaymentFromDate))
+	}
+	if filter.PaymentToDate != "" {
+		conditions = append(conditions, fmt.Sprintf("tr.date <= '%s'", filter.PaymentToDate))
+	}
+	if len(filter.PaymentStatuses) > 0 {
+		conditions = append(conditions, fmt.Sprintf("tr.paymentStatus IN ('%s')", strings.Join(filter.PaymentStatuses, "', '")))
+	}
+	if filter.Remarks != "" {
+		conditions = append(conditions, fmt.Sprintf("tr.remarks LIKE '%%%s%%'", likePattern(filter.Remarks)))
+	}
+
+	return conditions
+}
+
+// where joins the conditions of the filter onto a query that already has a WHERE clause
+func (filter transactionFilter) where() string {
+	var clause strings.Builder
+	for _, condition := range filter.conditions() {
+		clause.WriteString(" AND ")
+		clause.WriteString(condition)
+	}
+
+	return clause.String()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSplitList(t *testing.T) {
+	cases := []struct {
+		value string
+		list  []string
+	}{
+		{"", []string{}},
+		{"all", nil},
+		{"1", []string{"1"}},
+		{"1, 2 3,,4", []string{"1", "2", "3", "4"}},
+	}
+
+	for _, c := range cases {
+		if list := splitList(c.value); !reflect.DeepEqual(list, c.list) {
+			t.Errorf("splitList(%q) = %q, want %q", c.value, list, c.list)
+		}
+	}
+}
+
+func TestParseTransactionFilter(t *testing.T) {
+	cases := []struct {
+		query     string
+		direction string
+		valid     bool
+	}{
+		{"filter=in", "in", true},
+		{"filter=out", "out", true},
+		{"filter=opening", DirectionOpening, true},
+		{"filter=both", "", true},
+		{"filter=", "", true},
+		{"clientId=all&itemIds=all&paymentStatus=all", "", true},
+		{"clientId=3&customerId=4&billOfEntry=5&itemIds=1,2&warehouseIds=7&fromDate=2026-04-01&toDate=2026-04-30&paymentStatus=Paid,partial", "", true},
+		{"clientId=3'", "", false},
+		{"customerId=-4", "", false},
+		{"itemIds=1,two", "", false},
+		{"warehouseIds=7.5", "", false},
+		{"fromDate=01-04-2026", "", false},
+		{"paymentToDate=2026-02-30", "", false},
+		{"paymentStatus=overdue", "", false},
+	}
+
+	for _, c := range cases {
+		filter, err := parseTransactionFilter(httptest.NewRequest("GET", "/api/search/sales?"+c.query, nil))
+		if (err == nil) != c.valid {
+			t.Errorf("%q: error = %v, want valid %v", c.query, err, c.valid)
+			continue
+		}
+		if c.valid && filter.Direction != c.direction {
+			t.Errorf("%q: direction = %q, want %q", c.query, filter.Direction, c.direction)
+		}
+	}
+}
+
+func TestTransactionFilterConditions(t *testing.T) {
+	if where := (transactionFilter{ClientId: "all", Direction: ""}).where(); where != "" {
+		t.Errorf("an empty filter narrows the search with %q", where)
+	}
+
+	filter, err := parseTransactionFilter(httptest.NewRequest("GET", "/api/search/sales?clientId=3&filter=opening&itemIds=1,2&paymentStatus=PAID,partial&paymentFromDate=2026-05-01&remarks=50%25+off_", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"tr.clientId = '3'",
+		"tr.comeOrGo = 'opening'",
+		"tr.itemId IN (1, 2)",
+		"tr.date >= '2026-05-01'",
+		"tr.paymentStatus IN ('paid', 'partial')",
+		`tr.remarks LIKE '%50\% off\_%'`,
+	}
+	if conditions := filter.conditions(); !reflect.DeepEqual(conditions, want) {
+		t.Errorf("conditions = %q, want %q", conditions, want)
+	}
+	if where := filter.where(); where[:len(" AND tr.clientId")] != " AND tr.clientId" {
+		t.Errorf("where = %q, want the conditions joined after AND", where)
+	}
+
+	dated := transactionFilter{FromDate: "2026-04-01", ToDate: "2026-04-30"}.conditions()
+	if want := []string{transactionEntryDate + " >= '2026-04-01'", transactionEntryDate + " <= '2026-04-30'"}; !reflect.DeepEqual(dated, want) {
+		t.Errorf("dated conditions = %q", dated)
+	}
+}
+
+func TestLikePattern(t *testing.T) {
+	if pattern := likePattern(`O'Neil 100% _new_ \ box`); pattern != `O\'Neil 100\% \_new\_ \\ box` {
+		t.Errorf("likePattern = %s", pattern)
+	}
+}
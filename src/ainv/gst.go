@@ -0,0 +1,111 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/rounakdatta/ainv-backend-go/src/validation"
+)
+
+// GSTSplit is the breakup of the GST on a transaction line into its central, state and integrated parts
+type GSTSplit struct {
+	SupplierState string  `json:"supplierState"`
+	PlaceOfSupply string  `json:"placeOfSupply"`
+	CgstValue     float64 `json:"cgstValue"`
+	SgstValue     float64 `json:"sgstValue"`
+	IgstValue     float64 `json:"igstValue"`
+}
+
+// roundPaise rounds an amount to 2 decimal places
+func roundPaise(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// SplitGST splits the GST amount into CGST+SGST for intra-state supplies and IGST for inter-state supplies
+func SplitGST(gstValue float64, supplierState string, placeOfSupply string) GSTSplit {
+	split := GSTSplit{
+		SupplierState: supplierState,
+		PlaceOfSupply: placeOfSupply,
+	}
+
+	if supplierState != "" && supplierState == placeOfSupply {
+		split.CgstValue = roundPaise(gstValue / 2)
+		split.SgstValue = roundPaise(gstValue - split.CgstValue)
+	} else {
+		split.IgstValue = roundPaise(gstValue)
+	}
+
+	return split
+}
+
+// ComputeGSTSplit looks up the warehouse state and the customer's place of supply and splits the GST of a transaction line,
+// reading through q so that a line posted in a transaction sees the rows written before it
+func ComputeGSTSplit(q querier, direction string, warehouseId string, customerId string, placeOfSupply string, gstValue string) GSTSplit {
+	gstValueNum, _ := strconv.ParseFloat(gstValue, 64)
+
+	var warehouseGstin string
+	q.QueryRow(`SELECT IFNULL(gstin, '') FROM warehouse WHERE id = ?`, warehouseId).Scan(&warehouseGstin)
+
+	supplierState, err := validation.GSTINStateCode(warehouseGstin)
+	if err != nil {
+		log.Println(err)
+	}
+
+	// imports against a bill of entry always attract IGST
+	if direction == "in" {
+		return SplitGST(gstValueNum, supplierState, "")
+	}
+
+	if placeOfSupply == "" {
+		q.QueryRow(`SELECT IFNULL(placeOfSupply, '') FROM customer WHERE id = ?`, customerId).Scan(&placeOfSupply)
+	}
+
+	// an unregistered customer with no recorded state is supplied at the warehouse itself
+	if placeOfSupply == "" {
+		placeOfSupply = supplierState
+	}
+
+	return SplitGST(gstValueNum, supplierState, placeOfSupply)
+}
+
+// resolvePlaceOfSupply derives the place of supply of a customer from the GSTIN, or from the state code given explicitly
+func resolvePlaceOfSupply(gstin string, placeOfSupply string) (string, error) {
+	if gstin != "" {
+		return validation.GSTINStateCode(gstin)
+	}
+
+	if placeOfSupply == "" {
+		return "", nil
+	}
+	if _, ok := validation.StateCodes[placeOfSupply]; !ok {
+		return "", fmt.Errorf("place of supply %q is not a known state code", placeOfSupply)
+	}
+
+	return placeOfSupply, nil
+}
+
+// sellerGstin returns the one GSTIN the given warehouses are registered under, since an invoice has a single seller and
+// its place of supply, e-invoice and print are all taken from the warehouse of its first line
+func sellerGstin(q querier, warehouseIds []string) (string, error) {
+	gstin, from := "", ""
+	for _, warehouseId := range warehouseIds {
+		var warehouseGstin string
+		err := q.QueryRow(`SELECT IFNULL(gstin, '') FROM warehouse WHERE id = ?`, warehouseId).Scan(&warehouseGstin)
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("warehouse %s does not exist", warehouseId)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if from != "" && warehouseGstin != gstin {
+			return "", fmt.Errorf("warehouses %s and %s are registered under different GSTINs, raise one invoice per GSTIN", from, warehouseId)
+		}
+		gstin, from = warehouseGstin, warehouseId
+	}
+
+	return gstin, nil
+}
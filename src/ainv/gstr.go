@@ -0,0 +1,613 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rounakdatta/ainv-backend-go/src/einvoice"
+	"github.com/rounakdatta/ainv-backend-go/src/validation"
+	"github.com/rounakdatta/ainv-backend-go/src/xlsx"
+)
+
+// b2clThreshold is the invoice value above which an inter-state sale to an unregistered buyer is reported invoice by invoice
+const b2clThreshold = 100000.0
+
+// gstrExclusions are the supplies the returns leave out because no document records them yet, listed in the
+// report so that they are added on the portal rather than missed
+var gstrExclusions = []string{"credit and debit notes (cdnr, cdnur and their 3.1 adjustments) are not recorded and are not netted out"}
+
+// gstRates are the rates GST is levied at, which the rate of a line is snapped to
+var gstRates = []float64{0, 0.1, 0.25, 1, 1.5, 3, 5, 6, 7.5, 12, 18, 28}
+
+// the sections of GSTR-1 a report can be exported by
+const (
+	SectionB2B     = "b2b"
+	SectionB2CL    = "b2cl"
+	SectionB2CS    = "b2cs"
+	SectionExports = "exp"
+	SectionHSN     = "hsn"
+)
+
+// GSTR1B2BRow is the supplies at one rate on an invoice to a registered buyer
+type GSTR1B2BRow struct {
+	Ctin          string  `json:"ctin"`
+	ReceiverName  string  `json:"receiverName"`
+	InvoiceNumber string  `json:"invoiceNumber"`
+	InvoiceDate   string  `json:"invoiceDate"`
+	InvoiceValue  float64 `json:"invoiceValue"`
+	PlaceOfSupply string  `json:"placeOfSupply"`
+	ReverseCharge string  `json:"reverseCharge"`
+	InvoiceType   string  `json:"invoiceType"`
+	Rate          float64 `json:"rate"`
+	TaxableValue  float64 `json:"taxableValue"`
+	IgstValue     float64 `json:"igstValue"`
+	CgstValue     float64 `json:"cgstValue"`
+	SgstValue     float64 `json:"sgstValue"`
+	CessValue     float64 `json:"cessValue"`
+}
+
+// GSTR1B2CLRow is the supplies at one rate on a large inter-state invoice to an unregistered buyer
+type GSTR1B2CLRow struct {
+	InvoiceNumber string  `json:"invoiceNumber"`
+	InvoiceDate   string  `json:"invoiceDate"`
+	InvoiceValue  float64 `json:"invoiceValue"`
+	PlaceOfSupply string  `json:"placeOfSupply"`
+	Rate          float64 `json:"rate"`
+	TaxableValue  float64 `json:"taxableValue"`
+	IgstValue     float64 `json:"igstValue"`
+	CessValue     float64 `json:"cessValue"`
+}
+
+// GSTR1B2CSRow is the other supplies to unregistered buyers, summed by place of supply and rate
+type GSTR1B2CSRow struct {
+	Type          string  `json:"type"`
+	SupplyType    string  `json:"supplyType"`
+	PlaceOfSupply string  `json:"placeOfSupply"`
+	Rate          float64 `json:"rate"`
+	TaxableValue  float64 `json:"taxableValue"`
+	IgstValue     float64 `json:"igstValue"`
+	CgstValue     float64 `json:"cgstValue"`
+	SgstValue     float64 `json:"sgstValue"`
+	CessValue     float64 `json:"cessValue"`
+}
+
+// GSTR1ExportRow is the supplies at one rate on an export invoice, with or without payment of IGST
+type GSTR1ExportRow struct {
+	ExportType    string  `json:"exportType"`
+	InvoiceNumber string  `json:"invoiceNumber"`
+	InvoiceDate   string  `json:"invoiceDate"`
+	InvoiceValue  float64 `json:"invoiceValue"`
+	Rate          float64 `json:"rate"`
+	TaxableValue  float64 `json:"taxableValue"`
+	IgstValue     float64 `json:"igstValue"`
+	CessValue     float64 `json:"cessValue"`
+}
+
+// GSTR1HSNRow is the supplies of one HSN code at one rate
+type GSTR1HSNRow struct {
+	HsnCode       string  `json:"hsnCode"`
+	Description   string  `json:"description"`
+	Uqc           string  `json:"uqc"`
+	TotalQuantity float64 `json:"totalQuantity"`
+	TotalValue    float64 `json:"totalValue"`
+	Rate          float64 `json:"rate"`
+	TaxableValue  float64 `json:"taxableValue"`
+	IgstValue     float64 `json:"igstValue"`
+	CgstValue     float64 `json:"cgstValue"`
+	SgstValue     float64 `json:"sgstValue"`
+	CessValue     float64 `json:"cessValue"`
+}
+
+// GSTR1 is the outward supplies of a return period in the sections of the GSTR-1 return
+type GSTR1 struct {
+	Gstin    string           `json:"gstin"`
+	FromDate string           `json:"fromDate"`
+	ToDate   string           `json:"toDate"`
+	B2B      []GSTR1B2BRow    `json:"b2b"`
+	B2CL     []GSTR1B2CLRow   `json:"b2cl"`
+	B2CS     []GSTR1B2CSRow   `json:"b2cs"`
+	Exports  []GSTR1ExportRow `json:"exp"`
+	HSN      []GSTR1HSNRow    `json:"hsn"`
+	Excluded []string         `json:"excluded"`
+}
+
+// GSTR3BRow is a line of the GSTR-3B return
+type GSTR3BRow struct {
+	Section      string  `json:"section"`
+	Description  string  `json:"description"`
+	TaxableValue float64 `json:"taxableValue"`
+	IgstValue    float64 `json:"igstValue"`
+	CgstValue    float64 `json:"cgstValue"`
+	SgstValue    float64 `json:"sgstValue"`
+	CessValue    float64 `json:"cessValue"`
+}
+
+// GSTR3B is the tax liability of a return period in the layout of the GSTR-3B return
+type GSTR3B struct {
+	Gstin                  string      `json:"gstin"`
+	FromDate               string      `json:"fromDate"`
+	ToDate                 string      `json:"toDate"`
+	OutwardSupplies        []GSTR3BRow `json:"outwardSupplies"`
+	InterStateUnregistered []GSTR3BRow `json:"interStateUnregistered"`
+	InputTaxCredit         []GSTR3BRow `json:"inputTaxCredit"`
+	TaxPayable             GSTR3BRow   `json:"taxPayable"`
+	Excluded               []string    `json:"excluded"`
+}
+
+// outwardLine is an outbound transaction line of a return period, in the base currency
+type outwardLine struct {
+	SalesInvoiceId string
+	InvoiceNumber  string
+	InvoiceDate    string
+	CustomerName   string
+	CustomerGstin  string
+	PlaceOfSupply  string
+	SupplierState  string
+	Currency       string
+	HsnCode        string
+	ItemName       string
+	Unit           string
+	Quantity       float64
+	Rate           float64
+	TaxableValue   float64
+	IgstValue      float64
+	CgstValue      float64
+	SgstValue      float64
+	TotalValue     float64
+}
+
+// isExport reports whether the line was sold abroad, in foreign currency to a buyer without a GSTIN
+func (line outwardLine) isExport() bool {
+	return line.CustomerGstin == "" && line.Currency != BaseCurrency
+}
+
+// isInterState reports whether the line was supplied to another state than that of the warehouse
+func (line outwardLine) isInterState() bool {
+	return line.IgstValue > 0 || (line.PlaceOfSupply != "" && line.PlaceOfSupply != line.SupplierState)
+}
+
+// snapGSTRate returns the GST rate nearest to a rate worked out from amounts
+func snapGSTRate(rate float64) float64 {
+	nearest := gstRates[0]
+	for _, candidate := range gstRates {
+		if math.Abs(candidate-rate) < math.Abs(nearest-rate) {
+			nearest = candidate
+		}
+	}
+
+	return nearest
+}
+
+// parseReturnPeriod reads the return period as a month in MMYYYY, the way the GST portal names it, or as a date range
+func parseReturnPeriod(r *http.Request) (string, string, error) {
+	if period := r.FormValue("period"); period != "" {
+		month, err := time.Parse("012006", period)
+		if err != nil {
+			return "", "", fmt.Errorf("period %q must be a month in MMYYYY format", period)
+		}
+
+		return month.Format("2006-01-02"), month.AddDate(0, 1, -1).Format("2006-01-02"), nil
+	}
+
+	fromDate := r.FormValue("fromDate")
+	toDate := r.FormValue("toDate")
+	if _, err := time.Parse("2006-01-02", fromDate); err != nil {
+		return "", "", errors.New("a period in MMYYYY or fromDate and toDate in YYYY-MM-DD are required")
+	}
+	if _, err := time.Parse("2006-01-02", toDate); err != nil || toDate < fromDate {
+		return "", "", errors.New("toDate must be in YYYY-MM-DD format and not before fromDate")
+	}
+
+	return fromDate, toDate, nil
+}
+
+// parseReturnGSTIN reads the GSTIN a return is filed under, empty or "all" for every warehouse
+func parseReturnGSTIN(r *http.Request) (string, error) {
+	gstin := r.FormValue("gstin")
+	if !isFiltered(gstin) {
+		return gstin, nil
+	}
+	if err := validation.ValidateGSTIN(gstin); err != nil {
+		return "", err
+	}
+
+	return validation.NormalizeGSTIN(gstin), nil
+}
+
+// loadOutwardLines reads the outbound transaction lines invoiced in a period, of the warehouses registered under a GSTIN if one is given
+func loadOutwardLines(fromDate string, toDate string, gstin string) []outwardLine {
+	var lines []outwardLine
+
+	gstinCondition := ""
+	var args []interface{}
+	if isFiltered(gstin) {
+		gstinCondition = " AND wh.gstin = ?"
+		args = append(args, gstin)
+	}
+
+	outwardQuery := fmt.Sprintf(`SELECT
+		si.id, si.tracker, si.entryDate, IFNULL(cu.customerName, 'N/A'), IFNULL(cu.gstin, ''),
+		IFNULL(tr.placeOfSupply, IFNULL(cu.placeOfSupply, '')), IFNULL(wh.gstin, ''), tr.currency,
+		IFNULL(im.hsnCode, ''), IFNULL(im.itemName, 'N/A'), IFNULL(im.uomRaw, ''), tr.totalPcs,
+		IF(tr.assdValue > 0, tr.gstValue * 100 / tr.assdValue, 0),
+		tr.assdValue * tr.exchangeRate, tr.igstValue * tr.exchangeRate, tr.cgstValue * tr.exchangeRate, tr.sgstValue * tr.exchangeRate,
+		tr.totalValue * tr.exchangeRate
+		FROM transaction tr
+		JOIN salesInvoice si ON si.id = tr.salesInvoice
+		LEFT JOIN customer cu ON cu.id = tr.customerId
+		LEFT JOIN itemMaster im ON im.id = tr.itemId
+		LEFT JOIN warehouse wh ON wh.id = tr.warehouseId
+		WHERE tr.comeOrGo = 'out' AND tr.isError = 0 AND si.entryDate BETWEEN '%s' AND '%s'%s
+		ORDER BY si.entryDate, si.id, tr.id`, fromDate, toDate, gstinCondition)
+
+	allLines, err := db.Query(outwardQuery, args...)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allLines.Next() {
+		var line outwardLine
+		var warehouseGstin string
+
+		err := allLines.Scan(&line.SalesInvoiceId, &line.InvoiceNumber, &line.InvoiceDate, &line.CustomerName, &line.CustomerGstin, &line.PlaceOfSupply, &warehouseGstin, &line.Currency, &line.HsnCode, &line.ItemName, &line.Unit, &line.Quantity, &line.Rate, &line.TaxableValue, &line.IgstValue, &line.CgstValue, &line.SgstValue, &line.TotalValue)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		line.SupplierState, _ = validation.GSTINStateCode(warehouseGstin)
+		line.Rate = snapGSTRate(line.Rate)
+		if len(line.InvoiceDate) > 10 {
+			line.InvoiceDate = line.InvoiceDate[:10]
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// buildGSTR1 sorts the outbound lines of a period into the sections of GSTR-1
+func buildGSTR1(lines []outwardLine) GSTR1 {
+	var report GSTR1
+
+	invoiceValues := map[string]float64{}
+	for _, line := range lines {
+		invoiceValues[line.SalesInvoiceId] += line.TotalValue
+	}
+
+	b2bIndex := map[string]int{}
+	b2clIndex := map[string]int{}
+	b2csIndex := map[string]int{}
+	exportIndex := map[string]int{}
+	hsnIndex := map[string]int{}
+
+	for _, line := range lines {
+		invoiceValue := roundPaise(invoiceValues[line.SalesInvoiceId])
+		rateKey := fmt.Sprintf("%s|%g", line.SalesInvoiceId, line.Rate)
+
+		switch {
+		case line.CustomerGstin != "":
+			i, ok := b2bIndex[rateKey]
+			if !ok {
+				i = len(report.B2B)
+				b2bIndex[rateKey] = i
+				report.B2B = append(report.B2B, GSTR1B2BRow{Ctin: line.CustomerGstin, ReceiverName: line.CustomerName, InvoiceNumber: line.InvoiceNumber, InvoiceDate: line.InvoiceDate, InvoiceValue: invoiceValue, PlaceOfSupply: line.PlaceOfSupply, ReverseCharge: "N", InvoiceType: "Regular", Rate: line.Rate})
+			}
+			row := &report.B2B[i]
+			row.TaxableValue = roundPaise(row.TaxableValue + line.TaxableValue)
+			row.IgstValue = roundPaise(row.IgstValue + line.IgstValue)
+			row.CgstValue = roundPaise(row.CgstValue + line.CgstValue)
+			row.SgstValue = roundPaise(row.SgstValue + line.SgstValue)
+
+		case line.isExport():
+			i, ok := exportIndex[rateKey]
+			if !ok {
+				exportType := "WOPAY"
+				if line.IgstValue > 0 {
+					exportType = "WPAY"
+				}
+				i = len(report.Exports)
+				exportIndex[rateKey] = i
+				report.Exports = append(report.Exports, GSTR1ExportRow{ExportType: exportType, InvoiceNumber: line.InvoiceNumber, InvoiceDate: line.InvoiceDate, InvoiceValue: invoiceValue, Rate: line.Rate})
+			}
+			row := &report.Exports[i]
+			row.TaxableValue = roundPaise(row.TaxableValue + line.TaxableValue)
+			row.IgstValue = roundPaise(row.IgstValue + line.IgstValue)
+
+		case line.isInterState() && invoiceValue > b2clThreshold:
+			i, ok := b2clIndex[rateKey]
+			if !ok {
+				i = len(report.B2CL)
+				b2clIndex[rateKey] = i
+				report.B2CL = append(report.B2CL, GSTR1B2CLRow{InvoiceNumber: line.InvoiceNumber, InvoiceDate: line.InvoiceDate, InvoiceValue: invoiceValue, PlaceOfSupply: line.PlaceOfSupply, Rate: line.Rate})
+			}
+			row := &report.B2CL[i]
+			row.TaxableValue = roundPaise(row.TaxableValue + line.TaxableValue)
+			row.IgstValue = roundPaise(row.IgstValue + line.IgstValue)
+
+		default:
+			supplyType := "INTRA"
+			if line.isInterState() {
+				supplyType = "INTER"
+			}
+			key := fmt.Sprintf("%s|%s|%g", supplyType, line.PlaceOfSupply, line.Rate)
+			i, ok := b2csIndex[key]
+			if !ok {
+				i = len(report.B2CS)
+				b2csIndex[key] = i
+				report.B2CS = append(report.B2CS, GSTR1B2CSRow{Type: "OE", SupplyType: supplyType, PlaceOfSupply: line.PlaceOfSupply, Rate: line.Rate})
+			}
+			row := &report.B2CS[i]
+			row.TaxableValue = roundPaise(row.TaxableValue + line.TaxableValue)
+			row.IgstValue = roundPaise(row.IgstValue + line.IgstValue)
+			row.CgstValue = roundPaise(row.CgstValue + line.CgstValue)
+			row.SgstValue = roundPaise(row.SgstValue + line.SgstValue)
+		}
+
+		uqc := einvoice.UnitCode(line.Unit)
+		hsnKey := fmt.Sprintf("%s|%s|%g", line.HsnCode, uqc, line.Rate)
+		i, ok := hsnIndex[hsnKey]
+		if !ok {
+			description, _ := validation.LookupHSN(line.HsnCode)
+			if description == "" {
+				description = line.ItemName
+			}
+			i = len(report.HSN)
+			hsnIndex[hsnKey] = i
+			report.HSN = append(report.HSN, GSTR1HSNRow{HsnCode: line.HsnCode, Description: description, Uqc: uqc, Rate: line.Rate})
+		}
+		row := &report.HSN[i]
+		row.TotalQuantity = roundQuantity(row.TotalQuantity + line.Quantity)
+		row.TotalValue = roundPaise(row.TotalValue + line.TotalValue)
+		row.TaxableValue = roundPaise(row.TaxableValue + line.TaxableValue)
+		row.IgstValue = roundPaise(row.IgstValue + line.IgstValue)
+		row.CgstValue = roundPaise(row.CgstValue + line.CgstValue)
+		row.SgstValue = roundPaise(row.SgstValue + line.SgstValue)
+	}
+
+	return report
+}
+
+// gstr1Table lays out one section of GSTR-1 as rows under a header
+func gstr1Table(report GSTR1, section string) ([]string, [][]interface{}) {
+	var rows [][]interface{}
+
+	switch section {
+	case SectionB2CL:
+		for _, row := range report.B2CL {
+			rows = append(rows, []interface{}{row.InvoiceNumber, row.InvoiceDate, xlsx.Amount(row.InvoiceValue), row.PlaceOfSupply, row.Rate, xlsx.Amount(row.TaxableValue), xlsx.Amount(row.IgstValue), xlsx.Amount(row.CessValue)})
+		}
+		return []string{"Invoice Number", "Invoice Date", "Invoice Value", "Place Of Supply", "Rate", "Taxable Value", "Integrated Tax", "Cess Amount"}, rows
+
+	case SectionB2CS:
+		for _, row := range report.B2CS {
+			rows = append(rows, []interface{}{row.Type, row.SupplyType, row.PlaceOfSupply, row.Rate, xlsx.Amount(row.TaxableValue), xlsx.Amount(row.IgstValue), xlsx.Amount(row.CgstValue), xlsx.Amount(row.SgstValue), xlsx.Amount(row.CessValue)})
+		}
+		return []string{"Type", "Supply Type", "Place Of Supply", "Rate", "Taxable Value", "Integrated Tax", "Central Tax", "State Tax", "Cess Amount"}, rows
+
+	case SectionExports:
+		for _, row := range report.Exports {
+			rows = append(rows, []interface{}{row.ExportType, row.InvoiceNumber, row.InvoiceDate, xlsx.Amount(row.InvoiceValue), row.Rate, xlsx.Amount(row.TaxableValue), xlsx.Amount(row.IgstValue), xlsx.Amount(row.CessValue)})
+		}
+		return []string{"Export Type", "Invoice Number", "Invoice Date", "Invoice Value", "Rate", "Taxable Value", "Integrated Tax", "Cess Amount"}, rows
+
+	case SectionHSN:
+		for _, row := range report.HSN {
+			rows = append(rows, []interface{}{row.HsnCode, row.Description, row.Uqc, row.TotalQuantity, xlsx.Amount(row.TotalValue), row.Rate, xlsx.Amount(row.TaxableValue), xlsx.Amount(row.IgstValue), xlsx.Amount(row.CgstValue), xlsx.Amount(row.SgstValue), xlsx.Amount(row.CessValue)})
+		}
+		return []string{"HSN", "Description", "UQC", "Total Quantity", "Total Value", "Rate", "Taxable Value", "Integrated Tax", "Central Tax", "State Tax", "Cess Amount"}, rows
+
+	default:
+		for _, row := range report.B2B {
+			rows = append(rows, []interface{}{row.Ctin, row.ReceiverName, row.InvoiceNumber, row.InvoiceDate, xlsx.Amount(row.InvoiceValue), row.PlaceOfSupply, row.ReverseCharge, row.InvoiceType, row.Rate, xlsx.Amount(row.TaxableValue), xlsx.Amount(row.IgstValue), xlsx.Amount(row.CgstValue), xlsx.Amount(row.SgstValue), xlsx.Amount(row.CessValue)})
+		}
+		return []string{"GSTIN of Recipient", "Receiver Name", "Invoice Number", "Invoice Date", "Invoice Value", "Place Of Supply", "Reverse Charge", "Invoice Type", "Rate", "Taxable Value", "Integrated Tax", "Central Tax", "State Tax", "Cess Amount"}, rows
+	}
+}
+
+// buildGSTR3B sums the outbound lines and the tax paid on imports of a period into the tax liability of GSTR-3B
+func buildGSTR3B(lines []outwardLine, fromDate string, toDate string, gstin string) GSTR3B {
+	report := summarizeOutwardSupplies(lines)
+
+	imports := loadImportCredit(fromDate, toDate, gstin)
+	report.InputTaxCredit = []GSTR3BRow{imports}
+
+	taxable, zeroRated := report.OutwardSupplies[0], report.OutwardSupplies[1]
+	payable := GSTR3BRow{IgstValue: taxable.IgstValue + zeroRated.IgstValue, CgstValue: taxable.CgstValue, SgstValue: taxable.SgstValue}
+	report.TaxPayable = taxPayableInCash(payable, imports)
+
+	return report
+}
+
+// summarizeOutwardSupplies sums the outbound lines into the outward supplies of GSTR-3B and the inter-state supplies
+// to unregistered persons by place of supply
+func summarizeOutwardSupplies(lines []outwardLine) GSTR3B {
+	var report GSTR3B
+
+	taxable := GSTR3BRow{Section: "3.1(a)", Description: "Outward taxable supplies (other than zero rated, nil rated and exempted)"}
+	zeroRated := GSTR3BRow{Section: "3.1(b)", Description: "Outward taxable supplies (zero rated)"}
+	nilRated := GSTR3BRow{Section: "3.1(c)", Description: "Other outward supplies (nil rated, exempted)"}
+
+	placeIndex := map[string]int{}
+
+	for _, line := range lines {
+		row := &taxable
+		switch {
+		case line.isExport():
+			row = &zeroRated
+		case line.Rate == 0:
+			row = &nilRated
+		}
+
+		row.TaxableValue = roundPaise(row.TaxableValue + line.TaxableValue)
+		row.IgstValue = roundPaise(row.IgstValue + line.IgstValue)
+		row.CgstValue = roundPaise(row.CgstValue + line.CgstValue)
+		row.SgstValue = roundPaise(row.SgstValue + line.SgstValue)
+
+		if line.CustomerGstin == "" && !line.isExport() && line.isInterState() {
+			i, ok := placeIndex[line.PlaceOfSupply]
+			if !ok {
+				i = len(report.InterStateUnregistered)
+				placeIndex[line.PlaceOfSupply] = i
+				report.InterStateUnregistered = append(report.InterStateUnregistered, GSTR3BRow{Section: "3.2", Description: "Supplies to unregistered persons in " + stateName(line.PlaceOfSupply)})
+			}
+			place := &report.InterStateUnregistered[i]
+			place.TaxableValue = roundPaise(place.TaxableValue + line.TaxableValue)
+			place.IgstValue = roundPaise(place.IgstValue + line.IgstValue)
+		}
+	}
+
+	report.OutwardSupplies = []GSTR3BRow{taxable, zeroRated, nilRated}
+
+	return report
+}
+
+// loadImportCredit sums the IGST paid at customs on goods received against a bill of entry, which is available as credit
+func loadImportCredit(fromDate string, toDate string, gstin string) GSTR3BRow {
+	gstinCondition := ""
+	var args []interface{}
+	if isFiltered(gstin) {
+		gstinCondition = " AND wh.gstin = ?"
+		args = append(args, gstin)
+	}
+
+	imports := GSTR3BRow{Section: "4(A)(1)", Description: "Import of goods"}
+	importsQuery := fmt.Sprintf(`SELECT
+		IFNULL(SUM(tr.assdValue * tr.exchangeRate), 0), IFNULL(SUM(tr.igstValue * tr.exchangeRate), 0),
+		IFNULL(SUM(tr.cgstValue * tr.exchangeRate), 0), IFNULL(SUM(tr.sgstValue * tr.exchangeRate), 0)
+		FROM transaction tr
+		JOIN billOfEntry be ON be.id = tr.billOfEntry
+		LEFT JOIN warehouse wh ON wh.id = tr.warehouseId
+		WHERE tr.comeOrGo = 'in' AND tr.isError = 0 AND be.entryDate BETWEEN '%s' AND '%s'%s`, fromDate, toDate, gstinCondition)
+
+	if err := db.QueryRow(importsQuery, args...).Scan(&imports.TaxableValue, &imports.IgstValue, &imports.CgstValue, &imports.SgstValue); err != nil {
+		log.Println(err)
+	}
+	imports.TaxableValue = roundPaise(imports.TaxableValue)
+	imports.IgstValue = roundPaise(imports.IgstValue)
+	imports.CgstValue = roundPaise(imports.CgstValue)
+	imports.SgstValue = roundPaise(imports.SgstValue)
+
+	return imports
+}
+
+// taxPayableInCash sets the input tax credit off against the tax payable and returns what is left to pay in cash
+func taxPayableInCash(payable GSTR3BRow, credit GSTR3BRow) GSTR3BRow {
+	igstPayable, cgstPayable, sgstPayable := payable.IgstValue, payable.CgstValue, payable.SgstValue
+	igstCredit, cgstCredit, sgstCredit := credit.IgstValue, credit.CgstValue, credit.SgstValue
+
+	setOff := func(payable *float64, credit *float64) {
+		used := math.Min(*payable, *credit)
+		*payable -= used
+		*credit -= used
+	}
+
+	// IGST credit goes against IGST, then CGST and SGST; CGST and SGST credit against their own tax, then IGST
+	setOff(&igstPayable, &igstCredit)
+	setOff(&cgstPayable, &igstCredit)
+	setOff(&sgstPayable, &igstCredit)
+	setOff(&cgstPayable, &cgstCredit)
+	setOff(&igstPayable, &cgstCredit)
+	setOff(&sgstPayable, &sgstCredit)
+	setOff(&igstPayable, &sgstCredit)
+
+	return GSTR3BRow{Section: "6.1", Description: "Tax payable in cash", IgstValue: roundPaise(igstPayable), CgstValue: roundPaise(cgstPayable), SgstValue: roundPaise(sgstPayable)}
+}
+
+// gstr3bRows flattens GSTR-3B into one row per line of the return
+func gstr3bRows(report GSTR3B) [][]interface{} {
+	var rows [][]interface{}
+
+	all := append(append(append([]GSTR3BRow{}, report.OutwardSupplies...), report.InterStateUnregistered...), report.InputTaxCredit...)
+	all = append(all, report.TaxPayable)
+
+	for _, row := range all {
+		rows = append(rows, []interface{}{row.Section, row.Description, xlsx.Amount(row.TaxableValue), xlsx.Amount(row.IgstValue), xlsx.Amount(row.CgstValue), xlsx.Amount(row.SgstValue), xlsx.Amount(row.CessValue)})
+	}
+
+	return rows
+}
+
+var gstr3bCSVHeader = []string{"Section", "Description", "Taxable Value", "Integrated Tax", "Central Tax", "State Tax", "Cess"}
+
+// SearchGSTR1 returns the outward supplies of a return period in the GSTR-1 sections as JSON, or one section as CSV or XLSX
+func SearchGSTR1(w http.ResponseWriter, r *http.Request) {
+
+	gstin, err := parseReturnGSTIN(r)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+	section := strings.ToLower(r.FormValue("section"))
+
+	fromDate, toDate, err := parseReturnPeriod(r)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	payload := buildGSTR1(loadOutwardLines(fromDate, toDate, gstin))
+	payload.Gstin = gstin
+	payload.FromDate = fromDate
+	payload.ToDate = toDate
+	payload.Excluded = gstrExclusions
+
+	if format := requestedFormat(r); format != FormatJSON {
+		if section == "" {
+			section = SectionB2B
+		}
+
+		header, rows := gstr1Table(payload, section)
+		writeTable(w, format, fmt.Sprintf("gstr1-%s-%s-%s", section, fromDate, toDate), header, rows)
+		return
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// SearchGSTR3B returns the tax liability of a return period in the GSTR-3B layout as JSON, CSV or XLSX
+func SearchGSTR3B(w http.ResponseWriter, r *http.Request) {
+
+	gstin, err := parseReturnGSTIN(r)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	fromDate, toDate, err := parseReturnPeriod(r)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	payload := buildGSTR3B(loadOutwardLines(fromDate, toDate, gstin), fromDate, toDate, gstin)
+	payload.Gstin = gstin
+	payload.FromDate = fromDate
+	payload.ToDate = toDate
+	payload.Excluded = gstrExclusions
+
+	if format := requestedFormat(r); format != FormatJSON {
+		writeTable(w, format, fmt.Sprintf("gstr3b-%s-%s", fromDate, toDate), gstr3bCSVHeader, gstr3bRows(payload))
+		return
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
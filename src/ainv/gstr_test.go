@@ -0,0 +1,134 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// returnLines are the outbound lines of a period from a warehouse in Maharashtra (27), one or more per invoice
+func returnLines() []outwardLine {
+	line := func(invoice string, gstin string, placeOfSupply string, currency string, rate float64, taxable float64, igst float64, cgst float64, sgst float64) outwardLine {
+		return outwardLine{
+			SalesInvoiceId: invoice, InvoiceNumber: "WH1/26-27/" + invoice, InvoiceDate: "2026-04-10", CustomerName: "Customer " + invoice,
+			CustomerGstin: gstin, PlaceOfSupply: placeOfSupply, SupplierState: "27", Currency: currency, HsnCode: "8471", ItemName: "Laptop",
+			Unit: "PCS", Quantity: 1, Rate: rate, TaxableValue: taxable, IgstValue: igst, CgstValue: cgst, SgstValue: sgst,
+			TotalValue: taxable + igst + cgst + sgst,
+		}
+	}
+
+	return []outwardLine{
+		line("1", "27AAPFU0939F1ZV", "27", BaseCurrency, 18, 1000, 0, 90, 90),
+		line("1", "27AAPFU0939F1ZV", "27", BaseCurrency, 18, 1000, 0, 90, 90),
+		line("1", "27AAPFU0939F1ZV", "27", BaseCurrency, 5, 200, 0, 5, 5),
+		line("2", "", "", "USD", 0, 50000, 0, 0, 0),
+		line("3", "", "", "USD", 18, 10000, 1800, 0, 0),
+		line("4", "", "29", BaseCurrency, 18, 100000, 18000, 0, 0),
+		line("5", "", "29", BaseCurrency, 18, 1000, 180, 0, 0),
+		line("6", "", "27", BaseCurrency, 18, 1000, 0, 90, 90),
+		line("7", "", "27", BaseCurrency, 18, 1000, 0, 90, 90),
+		line("8", "", "27", BaseCurrency, 0, 500, 0, 0, 0),
+	}
+}
+
+func TestBuildGSTR1(t *testing.T) {
+	report := buildGSTR1(returnLines())
+
+	b2b := []GSTR1B2BRow{
+		{Ctin: "27AAPFU0939F1ZV", ReceiverName: "Customer 1", InvoiceNumber: "WH1/26-27/1", InvoiceDate: "2026-04-10", InvoiceValue: 2570, PlaceOfSupply: "27", ReverseCharge: "N", InvoiceType: "Regular", Rate: 18, TaxableValue: 2000, CgstValue: 180, SgstValue: 180},
+		{Ctin: "27AAPFU0939F1ZV", ReceiverName: "Customer 1", InvoiceNumber: "WH1/26-27/1", InvoiceDate: "2026-04-10", InvoiceValue: 2570, PlaceOfSupply: "27", ReverseCharge: "N", InvoiceType: "Regular", Rate: 5, TaxableValue: 200, CgstValue: 5, SgstValue: 5},
+	}
+	if !reflect.DeepEqual(report.B2B, b2b) {
+		t.Errorf("B2B = %+v, want %+v", report.B2B, b2b)
+	}
+
+	exports := []GSTR1ExportRow{
+		{ExportType: "WOPAY", InvoiceNumber: "WH1/26-27/2", InvoiceDate: "2026-04-10", InvoiceValue: 50000, Rate: 0, TaxableValue: 50000},
+		{ExportType: "WPAY", InvoiceNumber: "WH1/26-27/3", InvoiceDate: "2026-04-10", InvoiceValue: 11800, Rate: 18, TaxableValue: 10000, IgstValue: 1800},
+	}
+	if !reflect.DeepEqual(report.Exports, exports) {
+		t.Errorf("Exports = %+v, want %+v", report.Exports, exports)
+	}
+
+	b2cl := []GSTR1B2CLRow{
+		{InvoiceNumber: "WH1/26-27/4", InvoiceDate: "2026-04-10", InvoiceValue: 118000, PlaceOfSupply: "29", Rate: 18, TaxableValue: 100000, IgstValue: 18000},
+	}
+	if !reflect.DeepEqual(report.B2CL, b2cl) {
+		t.Errorf("B2CL = %+v, want %+v", report.B2CL, b2cl)
+	}
+
+	// a small inter-state invoice stays in B2CS, and intra-state invoices are summed by place of supply and rate
+	b2cs := []GSTR1B2CSRow{
+		{Type: "OE", SupplyType: "INTER", PlaceOfSupply: "29", Rate: 18, TaxableValue: 1000, IgstValue: 180},
+		{Type: "OE", SupplyType: "INTRA", PlaceOfSupply: "27", Rate: 18, TaxableValue: 2000, CgstValue: 180, SgstValue: 180},
+		{Type: "OE", SupplyType: "INTRA", PlaceOfSupply: "27", Rate: 0, TaxableValue: 500},
+	}
+	if !reflect.DeepEqual(report.B2CS, b2cs) {
+		t.Errorf("B2CS = %+v, want %+v", report.B2CS, b2cs)
+	}
+
+	if len(report.HSN) != 3 {
+		t.Fatalf("HSN has %d rows, want one per rate: %+v", len(report.HSN), report.HSN)
+	}
+	hsn := GSTR1HSNRow{HsnCode: "8471", Description: "Automatic data processing machines and units thereof", Uqc: "PCS", TotalQuantity: 7, TotalValue: 135700, Rate: 18, TaxableValue: 115000, IgstValue: 19980, CgstValue: 360, SgstValue: 360}
+	if report.HSN[0] != hsn {
+		t.Errorf("HSN at 18%% = %+v, want %+v", report.HSN[0], hsn)
+	}
+}
+
+func TestBuildGSTR1B2CLThreshold(t *testing.T) {
+	line := outwardLine{SalesInvoiceId: "1", PlaceOfSupply: "29", SupplierState: "27", Currency: BaseCurrency, Rate: 18, TaxableValue: 84745.76, IgstValue: 15254.24, TotalValue: 100000}
+
+	// an invoice of exactly the threshold is not large
+	if report := buildGSTR1([]outwardLine{line}); len(report.B2CL) != 0 || len(report.B2CS) != 1 {
+		t.Errorf("invoice of %g went to B2CL %d, B2CS %d, want B2CS", line.TotalValue, len(report.B2CL), len(report.B2CS))
+	}
+
+	line.TotalValue = 100000.01
+	if report := buildGSTR1([]outwardLine{line}); len(report.B2CL) != 1 || len(report.B2CS) != 0 {
+		t.Errorf("invoice of %g went to B2CL %d, B2CS %d, want B2CL", line.TotalValue, len(report.B2CL), len(report.B2CS))
+	}
+}
+
+func TestSummarizeOutwardSupplies(t *testing.T) {
+	report := summarizeOutwardSupplies(returnLines())
+
+	outward := []GSTR3BRow{
+		{Section: "3.1(a)", Description: "Outward taxable supplies (other than zero rated, nil rated and exempted)", TaxableValue: 105200, IgstValue: 18180, CgstValue: 365, SgstValue: 365},
+		{Section: "3.1(b)", Description: "Outward taxable supplies (zero rated)", TaxableValue: 60000, IgstValue: 1800},
+		{Section: "3.1(c)", Description: "Other outward supplies (nil rated, exempted)", TaxableValue: 500},
+	}
+	if !reflect.DeepEqual(report.OutwardSupplies, outward) {
+		t.Errorf("OutwardSupplies = %+v, want %+v", report.OutwardSupplies, outward)
+	}
+
+	unregistered := []GSTR3BRow{
+		{Section: "3.2", Description: "Supplies to unregistered persons in " + stateName("29"), TaxableValue: 101000, IgstValue: 18180},
+	}
+	if !reflect.DeepEqual(report.InterStateUnregistered, unregistered) {
+		t.Errorf("InterStateUnregistered = %+v, want %+v", report.InterStateUnregistered, unregistered)
+	}
+}
+
+func TestTaxPayableInCash(t *testing.T) {
+	cases := []struct {
+		name    string
+		payable GSTR3BRow
+		credit  GSTR3BRow
+		cash    GSTR3BRow
+	}{
+		{"no credit", GSTR3BRow{IgstValue: 1000, CgstValue: 500, SgstValue: 500}, GSTR3BRow{}, GSTR3BRow{IgstValue: 1000, CgstValue: 500, SgstValue: 500}},
+		{"IGST credit against IGST, then CGST", GSTR3BRow{IgstValue: 1000, CgstValue: 500, SgstValue: 500}, GSTR3BRow{IgstValue: 1200}, GSTR3BRow{CgstValue: 300, SgstValue: 500}},
+		{"IGST credit covering everything", GSTR3BRow{IgstValue: 1000, CgstValue: 500, SgstValue: 500}, GSTR3BRow{IgstValue: 3000}, GSTR3BRow{}},
+		{"CGST credit against CGST, then IGST", GSTR3BRow{IgstValue: 100, CgstValue: 500, SgstValue: 500}, GSTR3BRow{CgstValue: 700, SgstValue: 200}, GSTR3BRow{SgstValue: 300}},
+		{"CGST credit never against SGST", GSTR3BRow{SgstValue: 500}, GSTR3BRow{CgstValue: 500}, GSTR3BRow{SgstValue: 500}},
+		{"SGST credit against IGST", GSTR3BRow{IgstValue: 400}, GSTR3BRow{SgstValue: 150}, GSTR3BRow{IgstValue: 250}},
+	}
+
+	for _, c := range cases {
+		cash := taxPayableInCash(c.payable, c.credit)
+		c.cash.Section, c.cash.Description = "6.1", "Tax payable in cash"
+		if cash != c.cash {
+			t.Errorf("%s: taxPayableInCash = %+v, want %+v", c.name, cash, c.cash)
+		}
+	}
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rounakdatta/ainv-backend-go/src/einvoice"
+	"github.com/rounakdatta/ainv-backend-go/src/validation"
+	"github.com/rounakdatta/ainv-backend-go/src/xlsx"
+)
+
+// the masters that can be imported in bulk, named as in their create APIs
+const (
+	ImportItemMaster = "itemmaster"
+	ImportWarehouse  = "warehouse"
+	ImportClient     = "client"
+	ImportCustomer   = "customer"
+)
+
+// maxImportSize is the largest import file accepted over the API
+const maxImportSize = 32 << 20
+
+// ImportRowError is a problem with one row of an import file; row 1 is the header
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportResult reports an import; in a dry run imported counts the rows that would have been imported
+type ImportResult struct {
+	Success  bool             `json:"success"`
+	Entity   string           `json:"entity"`
+	DryRun   bool             `json:"dryRun"`
+	Rows     int              `json:"rows"`
+	Imported int              `json:"imported"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// importRow is a row of an import file with its cells keyed by column
+type importRow struct {
+	number int
+	cells  map[string]string
+}
+
+func (row importRow) get(column string) string {
+	return strings.TrimSpace(row.cells[column])
+}
+
+// importSchema is how the rows of a master are checked and inserted; check reports what is wrong with a
+// row through fail and returns its insert query, key identifies the row within the file and exists finds it
+// among the masters already saved
+type importSchema struct {
+	columns  []string
+	required []string
+	check    func(row importRow, fail func(column string, err error)) string
+	key      func(row importRow) string
+	exists   func(row importRow) string
+}
+
+var importSchemas = map[string]importSchema{
+	ImportItemMaster: {
+		columns:  []string{"itemName", "itemVariant", "hsnCode", "uomRaw", "uomSmall", "uomBig", "rawPerSmall", "smallPerBig"},
+		required: []string{"itemName", "hsnCode", "uomRaw", "uomSmall", "uomBig", "rawPerSmall", "smallPerBig"},
+		check:    checkItemMasterRow,
+		key: func(row importRow) string {
+			return strings.ToLower(row.get("itemName") + "\x00" + row.get("itemVariant"))
+		},
+		exists: func(row importRow) string {
+			return fmt.Sprintf(`SELECT COUNT(*) FROM itemMaster WHERE itemName = '%s' AND itemVariant = '%s'`, escapeQuotes(row.get("itemName")), escapeQuotes(row.get("itemVariant")))
+		},
+	},
+	ImportWarehouse: {
+		columns:  []string{"warehouseName", "warehouseLocation", "gstin", "contactName", "contactNumber", "address", "pincode"},
+		required: []string{"warehouseName", "gstin"},
+		check:    checkWarehouseRow,
+		key: func(row importRow) string {
+			return strings.ToLower(row.get("warehouseName"))
+		},
+		exists: func(row importRow) string {
+			return fmt.Sprintf(`SELECT COUNT(*) FROM warehouse WHERE warehouseName = '%s'`, escapeQuotes(row.get("warehouseName")))
+		},
+	},
+	ImportClient: {
+		columns:  []string{"clientName", "valuationMethod"},
+		required: []string{"clientName"},
+		check:    checkClientRow,
+		key: func(row importRow) string {
+			return strings.ToLower(row.get("clientName"))
+		},
+		exists: func(row importRow) string {
+			return fmt.Sprintf(`SELECT COUNT(*) FROM client WHERE clientName = '%s'`, escapeQuotes(row.get("clientName")))
+		},
+	},
+	ImportCustomer: {
+		columns:  []string{"customerName", "gstin", "placeOfSupply", "email", "creditLimit", "paymentTerms", "creditPolicy", "address", "city", "pincode"},
+		required: []string{"customerName"},
+		check:    checkCustomerRow,
+		key: func(row importRow) string {
+			return strings.ToLower(row.get("customerName"))
+		},
+		exists: func(row importRow) string {
+			return fmt.Sprintf(`SELECT COUNT(*) FROM customer WHERE customerName = '%s'`, escapeQuotes(row.get("customerName")))
+		},
+	},
+}
+
+// checkUnit accepts a unit of measure that maps to a unit quantity code of GST
+func checkUnit(unit string) error {
+	if einvoice.UnitCode(unit) == "OTH" && !strings.EqualFold(unit, "OTH") {
+		return fmt.Errorf("unit %q is not a GST unit quantity code or a known alias of one", unit)
+	}
+	return nil
+}
+
+// checkRatio accepts a positive number of smaller units in a larger one
+func checkRatio(ratio string) (string, error) {
+	value, err := strconv.ParseFloat(ratio, 64)
+	if err != nil || value <= 0 {
+		return "", fmt.Errorf("ratio %q must be a positive number", ratio)
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64), nil
+}
+
+func checkItemMasterRow(row importRow, fail func(column string, err error)) string {
+	hsnCode := validation.NormalizeHSN(row.get("hsnCode"))
+	if err := validation.ValidateHSN(hsnCode); err != nil {
+		fail("hsnCode", err)
+	}
+
+	for _, column := range []string{"uomRaw", "uomSmall", "uomBig"} {
+		if err := checkUnit(row.get(column)); err != nil {
+			fail(column, err)
+		}
+	}
+
+	rawPerSmall, err := checkRatio(row.get("rawPerSmall"))
+	if err != nil {
+		fail("rawPerSmall", err)
+	}
+	smallPerBig, err := checkRatio(row.get("smallPerBig"))
+	if err != nil {
+		fail("smallPerBig", err)
+	}
+
+	return fmt.Sprintf(`INSERT INTO itemMaster
+		(itemName, itemVariant, hsnCode, uomRaw, uomSmall, uomBig, rawPerSmall, smallPerBig)
+		VALUES
+		('%s', '%s', '%s', '%s', '%s', '%s', %s, %s)`, escapeQuotes(row.get("itemName")), escapeQuotes(row.get("itemVariant")), hsnCode,
+		escapeQuotes(row.get("uomRaw")), escapeQuotes(row.get("uomSmall")), escapeQuotes(row.get("uomBig")), rawPerSmall, smallPerBig)
+}
+
+func checkWarehouseRow(row importRow, fail func(column string, err error)) string {
+	gstin := validation.NormalizeGSTIN(row.get("gstin"))
+	if err := validation.ValidateGSTIN(gstin); err != nil {
+		fail("gstin", err)
+	}
+
+	pincode := validation.NormalizePincode(row.get("pincode"))
+	if err := validation.ValidatePincode(pincode); err != nil {
+		fail("pincode", err)
+	}
+
+	return fmt.Sprintf(`INSERT INTO warehouse
+		(warehouseName, warehouseLocation, gstin, contactName, contactNumber, address, pincode)
+		VALUES
+		('%s', '%s', '%s', '%s', '%s', NULLIF('%s', ''), NULLIF('%s', ''))`, escapeQuotes(row.get("warehouseName")), escapeQuotes(row.get("warehouseLocation")), gstin,
+		escapeQuotes(row.get("contactName")), escapeQuotes(row.get("contactNumber")), escapeQuotes(row.get("address")), pincode)
+}
+
+func checkClientRow(row importRow, fail func(column string, err error)) string {
+	valuationMethod, err := normalizeValuationMethod(row.get("valuationMethod"))
+	if err != nil {
+		fail("valuationMethod", err)
+	}
+
+	return fmt.Sprintf(`INSERT INTO client
+		(clientName, valuationMethod)
+		VALUES
+		('%s', '%s')`, escapeQuotes(row.get("clientName")), valuationMethod)
+}
+
+func checkCustomerRow(row importRow, fail func(column string, err error)) string {
+	gstin := validation.NormalizeGSTIN(row.get("gstin"))
+	placeOfSupply, err := resolvePlaceOfSupply(gstin, row.get("placeOfSupply"))
+	if err != nil {
+		column := "placeOfSupply"
+		if gstin != "" {
+			column = "gstin"
+		}
+		fail(column, err)
+	}
+
+	pincode := validation.NormalizePincode(row.get("pincode"))
+	if err := validation.ValidatePincode(pincode); err != nil {
+		fail("pincode", err)
+	}
+
+	email := validation.NormalizeEmail(row.get("email"))
+	if err := validation.ValidateEmail(email); err != nil {
+		fail("email", err)
+	}
+
+	creditLimit, paymentTerms, creditPolicy, err := creditTerms(row.get("creditLimit"), row.get("paymentTerms"), row.get("creditPolicy"))
+	if err != nil {
+		fail("", err)
+	}
+
+	return fmt.Sprintf(`INSERT INTO customer
+		(customerName, gstin, placeOfSupply, email, creditLimit, paymentTerms, creditPolicy, address, city, pincode)
+		VALUES
+		('%s', NULLIF('%s', ''), NULLIF('%s', ''), NULLIF('%s', ''), NULLIF('%s', ''), '%d', '%s', NULLIF('%s', ''), NULLIF('%s', ''), NULLIF('%s', ''))`,
+		escapeQuotes(row.get("customerName")), gstin, placeOfSupply, escapeQuotes(email), creditLimit, paymentTerms, creditPolicy,
+		escapeQuotes(row.get("address")), escapeQuotes(row.get("city")), pincode)
+}
+
+// readImportFile reads the rows of a CSV, XLSX or JSON file, telling them apart by the extension of its name
+func readImportFile(name string, r io.ReaderAt, size int64) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return readJSONRecords(io.NewSectionReader(r, 0, size))
+	case ".xlsx":
+		return xlsx.ReadRows(r, size)
+	case ".csv":
+		reader := csv.NewReader(io.NewSectionReader(r, 0, size))
+		reader.FieldsPerRecord = -1
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		// spreadsheets saving as CSV often start the file with a byte order mark
+		if len(records) > 0 && len(records[0]) > 0 {
+			records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+		}
+		return records, nil
+	}
+
+	return nil, fmt.Errorf("file %q must be a .csv, .xlsx or .json file", name)
+}
+
+// readJSONRecords lays out a JSON array of objects as rows under a header of every key the objects use
+func readJSONRecords(r io.Reader) ([][]string, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	var objects []map[string]interface{}
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, errors.New("file must be a JSON array of objects")
+	}
+
+	var header []string
+	index := map[string]int{}
+	for _, object := range objects {
+		for key := range object {
+			if _, ok := index[key]; !ok {
+				index[key] = len(header)
+				header = append(header, key)
+			}
+		}
+	}
+	sort.Strings(header)
+	for i, key := range header {
+		index[key] = i
+	}
+
+	records := [][]string{header}
+	for _, object := range objects {
+		record := make([]string, len(header))
+		for key, value := range object {
+			if value != nil {
+				record[index[key]] = fmt.Sprint(value)
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// parseImportRows matches the header of an import, which names the columns in any order and case, and reads
+// the rows under it, skipping blank ones and reporting required cells left empty
+func parseImportRows(entity string, columns []string, required []string, records [][]string) ([]importRow, []ImportRowError) {
+	errs := []ImportRowError{}
+	fail := func(row int, column string, message string) {
+		errs = append(errs, ImportRowError{Row: row, Column: column, Message: message})
+	}
+
+	if len(records) == 0 {
+		fail(1, "", "file is empty")
+		return nil, errs
+	}
+
+	known := map[string]string{}
+	for _, column := range columns {
+		known[strings.ToLower(column)] = column
+	}
+	header := make([]string, len(records[0]))
+	present := map[string]bool{}
+	for i, title := range records[0] {
+		title = strings.TrimSpace(title)
+		if title == "" {
+			continue
+		}
+		column, ok := known[strings.ToLower(title)]
+		if !ok {
+			fail(1, title, fmt.Sprintf("is not a column of %s, the columns are %s", entity, strings.Join(columns, ", ")))
+			continue
+		}
+		if present[column] {
+			fail(1, column, "appears more than once")
+		}
+		header[i] = column
+		present[column] = true
+	}
+	for _, column := range required {
+		if !present[column] {
+			fail(1, column, "is missing from the header")
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var rows []importRow
+	for i, record := range records[1:] {
+		row := importRow{number: i + 2, cells: map[string]string{}}
+		blank := true
+		for j, cell := range record {
+			if j < len(header) && header[j] != "" {
+				row.cells[header[j]] = cell
+			}
+			if strings.TrimSpace(cell) != "" {
+				blank = false
+			}
+		}
+		if blank {
+			continue
+		}
+
+		for _, column := range required {
+			if row.get(column) == "" {
+				fail(row.number, column, "is required")
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		fail(2, "", "file has no rows to import")
+	}
+
+	return rows, errs
+}
+
+// importMasters checks every row of an import and, when all of them are good, inserts them together;
+// a dry run checks the rows against the database the same way and then discards them
+func importMasters(entity string, records [][]string, dryRun bool) (ImportResult, error) {
+	result := ImportResult{Entity: entity, DryRun: dryRun}
+
+	schema, ok := importSchemas[entity]
+	if !ok {
+		return result, fmt.Errorf("%q cannot be imported, only %s, %s, %s, %s and %s can", entity, ImportItemMaster, ImportWarehouse, ImportClient, ImportCustomer, ImportOpeningStock)
+	}
+
+	rows, errs := parseImportRows(entity, schema.columns, schema.required, records)
+	result.Rows = len(rows)
+	result.Errors = errs
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	fail := func(row int, column string, message string) {
+		result.Errors = append(result.Errors, ImportRowError{Row: row, Column: column, Message: message})
+	}
+
+	var queries []string
+	seen := map[string]int{}
+
+	for _, row := range rows {
+		query := schema.check(row, func(column string, err error) {
+			fail(row.number, column, err.Error())
+		})
+
+		key := schema.key(row)
+		if first, ok := seen[key]; ok {
+			fail(row.number, "", fmt.Sprintf("duplicates row %d", first))
+		} else {
+			seen[key] = row.number
+		}
+
+		queries = append(queries, query)
+	}
+
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	// every row goes in or none do
+	tx, err := db.Begin()
+	if err != nil {
+		return result, err
+	}
+
+	for i, row := range rows {
+		var count int
+		if err := tx.QueryRow(schema.exists(row)).Scan(&count); err != nil {
+			tx.Rollback()
+			return result, err
+		}
+		if count > 0 {
+			fail(row.number, "", fmt.Sprintf("%s already exists", entity))
+			continue
+		}
+
+		if _, err := tx.Exec(queries[i]); err != nil {
+			log.Println(err)
+			fail(row.number, "", err.Error())
+			continue
+		}
+		result.Imported++
+	}
+
+	if len(result.Errors) > 0 || dryRun {
+		if err := tx.Rollback(); err != nil {
+			return result, err
+		}
+		if len(result.Errors) > 0 {
+			result.Imported = 0
+			return result, nil
+		}
+	} else if err := tx.Commit(); err != nil {
+		return result, err
+	}
+
+	result.Success = true
+	return result, nil
+}
+
+// runImport imports the records as the entity, opening stock being posted as transactions and the rest as masters
+func runImport(entity string, records [][]string, entryDate string, dryRun bool) (ImportResult, error) {
+	if entity == ImportOpeningStock {
+		return importOpeningStock(records, entryDate, dryRun)
+	}
+
+	return importMasters(entity, records, dryRun)
+}
+
+// ImportMaster bulk imports item masters, warehouses, clients, customers or opening stock from an uploaded CSV, XLSX or JSON file
+func ImportMaster(w http.ResponseWriter, r *http.Request) {
+
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		writeFailure(w, "file must be uploaded as multipart form data")
+		return
+	}
+
+	file, fileHeader, err := r.FormFile("file")
+	if err != nil {
+		writeFailure(w, "file is required")
+		return
+	}
+	defer file.Close()
+
+	records, err := readImportFile(fileHeader.Filename, file, fileHeader.Size)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.FormValue("dryRun"))
+
+	result, err := runImport(mux.Vars(r)["entity"], records, r.FormValue("entryDate"), dryRun)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	payloadJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// runImportCommand imports a file from the command line, given as import <entity> <file> [--dry-run] [--date=YYYY-MM-DD],
+// printing the result and failing when any row is rejected
+func runImportCommand(args []string) error {
+	var positional []string
+	dryRun := false
+	entryDate := ""
+	for _, arg := range args {
+		switch {
+		case arg == "--dry-run" || arg == "-dry-run":
+			dryRun = true
+		case strings.HasPrefix(arg, "--date="):
+			entryDate = strings.TrimPrefix(arg, "--date=")
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) != 2 {
+		return errors.New("usage: ainv import <itemmaster|warehouse|client|customer|openingstock> <file.csv|file.xlsx|file.json> [--dry-run] [--date=YYYY-MM-DD]")
+	}
+
+	file, err := os.Open(positional[1])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	records, err := readImportFile(file.Name(), file, info.Size())
+	if err != nil {
+		return err
+	}
+
+	result, err := runImport(positional[0], records, entryDate, dryRun)
+	if err != nil {
+		return err
+	}
+
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(output))
+
+	if !result.Success {
+		return fmt.Errorf("%s has %d problems, nothing was imported", positional[1], len(result.Errors))
+	}
+	return nil
+}
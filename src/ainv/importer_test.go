@@ -0,0 +1,157 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseImportRows(t *testing.T) {
+	schema := importSchemas[ImportClient]
+
+	cases := []struct {
+		name    string
+		records [][]string
+		rows    int
+		errors  []ImportRowError
+	}{
+		{
+			name:    "columns in any order and case, blank rows skipped",
+			records: [][]string{{"ValuationMethod", " clientname "}, {"fifo", "Acme"}, {"", " "}, {"", "Globex"}},
+			rows:    2,
+			errors:  []ImportRowError{},
+		},
+		{
+			name:    "unknown, repeated and missing columns",
+			records: [][]string{{"clientCode", "valuationMethod", "VALUATIONMETHOD"}, {"1", "FIFO", "WAVG"}},
+			errors: []ImportRowError{
+				{Row: 1, Column: "clientCode", Message: "is not a column of client, the columns are clientName, valuationMethod"},
+				{Row: 1, Column: "valuationMethod", Message: "appears more than once"},
+				{Row: 1, Column: "clientName", Message: "is missing from the header"},
+			},
+		},
+		{
+			name:    "required cells left empty",
+			records: [][]string{{"clientName", "valuationMethod"}, {"Acme", ""}, {"  ", "WAVG"}},
+			rows:    2,
+			errors:  []ImportRowError{{Row: 3, Column: "clientName", Message: "is required"}},
+		},
+		{
+			name:    "empty file",
+			records: nil,
+			errors:  []ImportRowError{{Row: 1, Message: "file is empty"}},
+		},
+		{
+			name:    "header only",
+			records: [][]string{{"clientName"}, {""}},
+			errors:  []ImportRowError{{Row: 2, Message: "file has no rows to import"}},
+		},
+	}
+
+	for _, c := range cases {
+		rows, errs := parseImportRows(ImportClient, schema.columns, schema.required, c.records)
+		if len(rows) != c.rows {
+			t.Errorf("%s: %d rows, want %d", c.name, len(rows), c.rows)
+		}
+		if !reflect.DeepEqual(errs, c.errors) {
+			t.Errorf("%s: errors = %v, want %v", c.name, errs, c.errors)
+		}
+	}
+}
+
+// checkImportRow runs the check of a master over a single row and returns the columns it failed
+func checkImportRow(entity string, cells map[string]string) []string {
+	var failed []string
+	importSchemas[entity].check(importRow{number: 2, cells: cells}, func(column string, err error) {
+		failed = append(failed, column)
+	})
+	return failed
+}
+
+func TestCheckImportRows(t *testing.T) {
+	cases := []struct {
+		name   string
+		entity string
+		cells  map[string]string
+		failed []string
+	}{
+		{
+			name:   "item master",
+			entity: ImportItemMaster,
+			cells:  map[string]string{"itemName": "Mug", "hsnCode": "6912.00", "uomRaw": "pcs", "uomSmall": "box", "uomBig": "ctn", "rawPerSmall": "12", "smallPerBig": "4"},
+		},
+		{
+			name:   "item master with a bad code, unit and ratios",
+			entity: ImportItemMaster,
+			cells:  map[string]string{"itemName": "Mug", "hsnCode": "69A2", "uomRaw": "pcs", "uomSmall": "handful", "uomBig": "ctn", "rawPerSmall": "0", "smallPerBig": "four"},
+			failed: []string{"hsnCode", "uomSmall", "rawPerSmall", "smallPerBig"},
+		},
+		{
+			name:   "warehouse",
+			entity: ImportWarehouse,
+			cells:  map[string]string{"warehouseName": "Bhiwandi", "gstin": "27aapfu0939f1zv", "pincode": "421 302"},
+		},
+		{
+			name:   "warehouse with a bad GSTIN and pincode",
+			entity: ImportWarehouse,
+			cells:  map[string]string{"warehouseName": "Bhiwandi", "gstin": "27AAPFU0939F1ZX", "pincode": "4213"},
+			failed: []string{"gstin", "pincode"},
+		},
+		{
+			name:   "client with an unknown valuation method",
+			entity: ImportClient,
+			cells:  map[string]string{"clientName": "Acme", "valuationMethod": "LIFO"},
+			failed: []string{"valuationMethod"},
+		},
+		{
+			name:   "customer",
+			entity: ImportCustomer,
+			cells:  map[string]string{"customerName": "Cafe Pune", "placeOfSupply": "27", "email": "accounts@cafe.example", "creditLimit": "50000", "paymentTerms": "30"},
+		},
+		{
+			name:   "customer with a bad state, email and terms",
+			entity: ImportCustomer,
+			cells:  map[string]string{"customerName": "Cafe Pune", "placeOfSupply": "45", "email": "Cafe <accounts@cafe.example>", "paymentTerms": "-5"},
+			failed: []string{"placeOfSupply", "email", ""},
+		},
+		{
+			name:   "customer whose GSTIN is wrong",
+			entity: ImportCustomer,
+			cells:  map[string]string{"customerName": "Cafe Pune", "gstin": "XXAAPFU0939F1ZV"},
+			failed: []string{"gstin"},
+		},
+	}
+
+	for _, c := range cases {
+		if failed := checkImportRow(c.entity, c.cells); !reflect.DeepEqual(failed, c.failed) {
+			t.Errorf("%s: failed %q, want %q", c.name, failed, c.failed)
+		}
+	}
+}
+
+func TestReadImportFile(t *testing.T) {
+	csvFile := "\ufeffclientName,valuationMethod\nAcme,FIFO\nGlobex\n"
+	records, err := readImportFile("clients.CSV", strings.NewReader(csvFile), int64(len(csvFile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"clientName", "valuationMethod"}, {"Acme", "FIFO"}, {"Globex"}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("CSV records = %q, want %q", records, want)
+	}
+
+	jsonFile := `[{"clientName": "Acme", "creditLimit": 50000}, {"clientName": "Globex", "valuationMethod": null}]`
+	records, err = readImportFile("clients.json", strings.NewReader(jsonFile), int64(len(jsonFile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"clientName", "creditLimit", "valuationMethod"}, {"Acme", "50000", ""}, {"Globex", "", ""}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("JSON records = %q, want %q", records, want)
+	}
+
+	if _, err := readImportFile("clients.json", strings.NewReader(`{"clientName": "Acme"}`), 22); err == nil {
+		t.Error("a JSON object that is not an array was read")
+	}
+	if _, err := readImportFile("clients.txt", strings.NewReader(""), 0); err == nil {
+		t.Error("a .txt file was read")
+	}
+}
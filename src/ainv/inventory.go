@@ -0,0 +1,76 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// InventoryChange is the effect of one document line on the running totals in inventoryContents
+type InventoryChange struct {
+	CurrentValue float64
+	ChangeValue  float64
+	FinalValue   float64
+}
+
+// ItemRates are the unit conversions of an item, as stored on its transaction lines
+type ItemRates struct {
+	SmallPerBig float64
+	RawPerSmall float64
+}
+
+// lookupItemRates reads the unit conversions of an item from the item master
+func lookupItemRates(tx *sql.Tx, itemId string) (ItemRates, error) {
+	var rates ItemRates
+
+	ratesQuery := fmt.Sprintf(`SELECT smallPerBig, rawPerSmall FROM itemMaster WHERE id = '%s'`, itemId)
+
+	err := tx.QueryRow(ratesQuery).Scan(&rates.SmallPerBig, &rates.RawPerSmall)
+	if err == sql.ErrNoRows {
+		return rates, fmt.Errorf("item %s does not exist", itemId)
+	}
+
+	return rates, err
+}
+
+// postInventoryChange applies a movement of big cartons to inventoryContents inside a database transaction, never taking stock below zero
+func postInventoryChange(tx *sql.Tx, itemId string, warehouseId string, clientId string, direction string, bigQuantity float64, rates ItemRates) (InventoryChange, error) {
+	var change InventoryChange
+
+	change.ChangeValue = bigQuantity
+	if direction == "out" {
+		change.ChangeValue = -bigQuantity
+	}
+
+	smallboxQuantity := change.ChangeValue * rates.SmallPerBig
+	itemQuantity := smallboxQuantity * rates.RawPerSmall
+
+	// the row is locked so that concurrent documents cannot both spend the same stock
+	currentQuery := fmt.Sprintf(`SELECT bigcartonQuantity FROM inventoryContents
+		WHERE itemId = '%s' AND warehouseId = '%s' AND clientId = '%s' FOR UPDATE`, itemId, warehouseId, clientId)
+
+	err := tx.QueryRow(currentQuery).Scan(&change.CurrentValue)
+	exists := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return change, err
+	}
+
+	change.FinalValue = change.CurrentValue + change.ChangeValue
+	if change.FinalValue < -driftTolerance {
+		return change, fmt.Errorf("item %s has only %g in stock at warehouse %s", itemId, change.CurrentValue, warehouseId)
+	}
+
+	var executionQuery string
+	if exists {
+		executionQuery = fmt.Sprintf(`UPDATE inventoryContents
+			SET bigcartonQuantity = bigcartonQuantity + %f, smallboxQuantity = smallboxQuantity + %f, itemQuantity = itemQuantity + %f
+			WHERE itemId = '%s' AND warehouseId = '%s' AND clientId = '%s'`, change.ChangeValue, smallboxQuantity, itemQuantity, itemId, warehouseId, clientId)
+	} else {
+		executionQuery = fmt.Sprintf(`INSERT INTO inventoryContents
+			(itemId, itemQuantity, smallboxQuantity, bigcartonQuantity, warehouseId, clientId)
+			VALUES
+			('%s', '%f', '%f', '%f', '%s', '%s')`, itemId, itemQuantity, smallboxQuantity, change.ChangeValue, warehouseId, clientId)
+	}
+
+	_, err = tx.Exec(executionQuery)
+	return change, err
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// LedgerQuantity is a quantity of an item in all three UOMs
+type LedgerQuantity struct {
+	BigcartonQuantity float64 `json:"bigcartonQuantity"`
+	SmallboxQuantity  float64 `json:"smallboxQuantity"`
+	ItemQuantity      float64 `json:"itemQuantity"`
+}
+
+// add applies a movement to the quantity
+func (quantity LedgerQuantity) add(movement StockMovement) LedgerQuantity {
+	return LedgerQuantity{
+		BigcartonQuantity: quantity.BigcartonQuantity + movement.Signed(movement.BigQuantity),
+		SmallboxQuantity:  quantity.SmallboxQuantity + movement.Signed(movement.SmallQuantity),
+		ItemQuantity:      quantity.ItemQuantity + movement.Signed(movement.RawQuantity),
+	}
+}
+
+// LedgerEntry is a single movement in the stock ledger of an item, with the balance after it
+type LedgerEntry struct {
+	TransactionId  string         `json:"transactionId"`
+	EntryDate      string         `json:"entryDate"`
+	Direction      string         `json:"direction"`
+	DocumentNumber string         `json:"documentNumber"`
+	Counterparty   string         `json:"counterparty"`
+	Change         LedgerQuantity `json:"change"`
+	Balance        LedgerQuantity `json:"balance"`
+}
+
+// ItemLedger is the movement history of one item in one warehouse for one client over a date range
+type ItemLedger struct {
+	ItemId        string         `json:"itemId"`
+	ItemName      string         `json:"itemName"`
+	ItemVariant   string         `json:"itemVariant"`
+	WarehouseName string         `json:"warehouseName"`
+	ClientName    string         `json:"clientName"`
+	UomRaw        string         `json:"uomRaw"`
+	UomSmall      string         `json:"uomSmall"`
+	UomBig        string         `json:"uomBig"`
+	FromDate      string         `json:"fromDate"`
+	ToDate        string         `json:"toDate"`
+	Opening       LedgerQuantity `json:"opening"`
+	Entries       []LedgerEntry  `json:"entries"`
+	Closing       LedgerQuantity `json:"closing"`
+}
+
+// BuildItemLedger folds the movements before the range into the opening balance and lists the rest with a running balance
+func BuildItemLedger(movements []StockMovement, fromDate string) (LedgerQuantity, []LedgerEntry, LedgerQuantity) {
+	var opening LedgerQuantity
+	var balance LedgerQuantity
+	var entries []LedgerEntry
+
+	for _, movement := range movements {
+		balance = balance.add(movement)
+
+		if movement.EntryDate < fromDate {
+			opening = balance
+			continue
+		}
+
+		entries = append(entries, LedgerEntry{
+			TransactionId:  movement.TransactionId,
+			EntryDate:      movement.EntryDate,
+			Direction:      movement.Direction,
+			DocumentNumber: movement.DocumentNumber,
+			Counterparty:   movement.Counterparty,
+			Change:         LedgerQuantity{}.add(movement),
+			Balance:        balance,
+		})
+	}
+
+	return opening, entries, balance
+}
+
+// ledgerCSVHeader is the header row of the stock ledger export
+var ledgerCSVHeader = []string{
+	"Item", "Variant", "Warehouse", "Client", "Date", "Entry", "Document", "Counterparty",
+	"Change (Big)", "Change (Small)", "Change (Raw)",
+	"Balance (Big)", "Balance (Small)", "Balance (Raw)",
+	"UOM (Big)", "UOM (Small)", "UOM (Raw)",
+}
+
+// ledgerCSVRows flattens the ledgers into CSV rows, with the opening and closing balance around each
+func ledgerCSVRows(ledgers []ItemLedger) [][]string {
+	var rows [][]string
+
+	for _, ledger := range ledgers {
+		row := func(date string, entry string, document string, counterparty string, change LedgerQuantity, balance LedgerQuantity) []string {
+			return []string{
+				ledger.ItemName, ledger.ItemVariant, ledger.WarehouseName, ledger.ClientName, date, entry, document, counterparty,
+				formatQuantity(change.BigcartonQuantity), formatQuantity(change.SmallboxQuantity), formatQuantity(change.ItemQuantity),
+				formatQuantity(balance.BigcartonQuantity), formatQuantity(balance.SmallboxQuantity), formatQuantity(balance.ItemQuantity),
+				ledger.UomBig, ledger.UomSmall, ledger.UomRaw,
+			}
+		}
+
+		rows = append(rows, row(ledger.FromDate, "Opening", "", "", LedgerQuantity{}, ledger.Opening))
+		for _, entry := range ledger.Entries {
+			rows = append(rows, row(entry.EntryDate, entry.Direction, entry.DocumentNumber, entry.Counterparty, entry.Change, entry.Balance))
+		}
+		rows = append(rows, row(ledger.ToDate, "Closing", "", "", LedgerQuantity{}, ledger.Closing))
+	}
+
+	return rows
+}
+
+// SearchItemLedger returns the stock ledger per item, warehouse and client over a date range as JSON or CSV
+func SearchItemLedger(w http.ResponseWriter, r *http.Request) {
+
+	fromDate := r.FormValue("fromDate")
+	filter := stockFilter{
+		ItemId:      r.FormValue("itemId"),
+		WarehouseId: r.FormValue("warehouseId"),
+		ClientId:    r.FormValue("clientId"),
+		ToDate:      r.FormValue("toDate"),
+	}
+
+	var payload []ItemLedger
+
+	keys, groups := groupStockMovements(loadStockMovements(filter))
+
+	for _, key := range keys {
+		opening, entries, closing := BuildItemLedger(groups[key], fromDate)
+		names := lookupStockNames(key)
+
+		singleObject := ItemLedger{
+			ItemId:        key.ItemId,
+			ItemName:      names.ItemName,
+			ItemVariant:   names.ItemVariant,
+			WarehouseName: names.WarehouseName,
+			ClientName:    names.ClientName,
+			UomRaw:        names.UomRaw,
+			UomSmall:      names.UomSmall,
+			UomBig:        names.UomBig,
+			FromDate:      fromDate,
+			ToDate:        filter.ToDate,
+			Opening:       opening,
+			Entries:       entries,
+			Closing:       closing,
+		}
+
+		payload = append(payload, singleObject)
+	}
+
+	if requestedFormat(r) == FormatCSV {
+		writeCSV(w, "stock-ledger", ledgerCSVHeader, ledgerCSVRows(payload))
+		return
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
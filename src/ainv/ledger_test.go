@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildItemLedger(t *testing.T) {
+	_, groups := groupStockMovements(stockMovements())
+	movements := groups[stockKey{"1", "1", "1"}]
+
+	opening, entries, closing := BuildItemLedger(movements, "2026-04-05")
+
+	if want := (LedgerQuantity{10, 4, 12}); opening != want {
+		t.Errorf("opening = %+v, want %+v", opening, want)
+	}
+	if want := (LedgerQuantity{11, 4, 12}); closing != want {
+		t.Errorf("closing = %+v, want %+v", closing, want)
+	}
+
+	want := []LedgerEntry{
+		{TransactionId: "3", EntryDate: "2026-04-05", Direction: "out", Change: LedgerQuantity{-2, -4, -12}, Balance: LedgerQuantity{8, 0, 0}},
+		{TransactionId: "4", EntryDate: "2026-04-09", Direction: "in", Change: LedgerQuantity{3, 4, 12}, Balance: LedgerQuantity{11, 4, 12}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries = %+v, want %+v", entries, want)
+	}
+
+	// a range starting after the last movement holds no entries and opens where it closes
+	opening, entries, closing = BuildItemLedger(movements, "2026-05-01")
+	if len(entries) != 0 || opening != closing {
+		t.Errorf("later range: opening %+v, %d entries, closing %+v", opening, len(entries), closing)
+	}
+}
+
+func TestLedgerCSVRows(t *testing.T) {
+	ledger := ItemLedger{
+		ItemName: "Ceramic Mug", ItemVariant: "Blue", WarehouseName: "Bhiwandi", ClientName: "Acme",
+		UomBig: "carton", UomSmall: "box", UomRaw: "pcs", FromDate: "2026-04-01", ToDate: "2026-04-30",
+		Opening: LedgerQuantity{10, 4, 12},
+		Entries: []LedgerEntry{
+			{EntryDate: "2026-04-05", Direction: "out", DocumentNumber: "WH1/26-27/00001", Counterparty: "Cafe Pune", Change: LedgerQuantity{-2.5, 0, 0}, Balance: LedgerQuantity{7.5, 4, 12}},
+		},
+		Closing: LedgerQuantity{7.5, 4, 12},
+	}
+
+	rows := ledgerCSVRows([]ItemLedger{ledger})
+	if len(rows) != 3 {
+		t.Fatalf("%d rows, want the opening, one entry and the closing", len(rows))
+	}
+	for _, row := range rows {
+		if len(row) != len(ledgerCSVHeader) {
+			t.Errorf("row %v has %d cells for %d columns", row, len(row), len(ledgerCSVHeader))
+		}
+	}
+
+	want := []string{"Ceramic Mug", "Blue", "Bhiwandi", "Acme", "2026-04-05", "out", "WH1/26-27/00001", "Cafe Pune", "-2.5", "0", "0", "7.5", "4", "12", "carton", "box", "pcs"}
+	if !reflect.DeepEqual(rows[1], want) {
+		t.Errorf("entry row = %v, want %v", rows[1], want)
+	}
+	if rows[0][5] != "Opening" || rows[0][4] != "2026-04-01" || rows[2][5] != "Closing" || rows[2][11] != "7.5" {
+		t.Errorf("opening %v, closing %v", rows[0], rows[2])
+	}
+}
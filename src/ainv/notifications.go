@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rounakdatta/ainv-backend-go/src/validation"
+)
+
+// the reminders raised for an invoice with an outstanding amount
+const (
+	NotificationDueSoon = "due_soon"
+	NotificationOverdue = "overdue"
+)
+
+// the delivery status of a notification in the outbox
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+)
+
+// a notification is given up on after this many failed deliveries
+const maxNotificationAttempts = 5
+
+// Notification is a payment reminder waiting in or delivered from the outbox
+type Notification struct {
+	NotificationId string  `json:"notificationId"`
+	Kind           string  `json:"kind"`
+	SalesInvoiceId string  `json:"salesInvoiceId"`
+	SalesInvoice   string  `json:"salesInvoice"`
+	CustomerId     string  `json:"customerId"`
+	CustomerName   string  `json:"customerName"`
+	Recipient      string  `json:"recipient"`
+	DueDate        string  `json:"dueDate"`
+	Outstanding    float64 `json:"outstanding"`
+	Subject        string  `json:"subject"`
+	Message        string  `json:"message"`
+	Status         string  `json:"status"`
+	Channel        string  `json:"channel"`
+	Attempts       int     `json:"attempts"`
+	LastError      string  `json:"lastError"`
+	CreatedAt      string  `json:"createdAt"`
+	SentAt         string  `json:"sentAt"`
+}
+
+// NotificationSender delivers a notification over one channel
+type NotificationSender interface {
+	Channel() string
+	Send(notification Notification) error
+}
+
+// notificationSender is the sender the outbox is delivered through, chosen at startup
+var notificationSender NotificationSender = logSender{}
+
+// dueSoonDays is how many days ahead of its due date an invoice is reminded of
+var dueSoonDays = 3
+
+// logSender writes notifications to the service log
+type logSender struct{}
+
+func (logSender) Channel() string { return "log" }
+
+func (logSender) Send(notification Notification) error {
+	log.Printf("Notification %s to %s: %s", notification.NotificationId, notification.CustomerName, notification.Subject)
+	return nil
+}
+
+// smtpSender mails notifications through an SMTP relay, such as a local stand-in for development
+type smtpSender struct {
+	address   string
+	from      string
+	fallback  string
+	plainAuth smtp.Auth
+}
+
+func (smtpSender) Channel() string { return "smtp" }
+
+func (sender smtpSender) Send(notification Notification) error {
+	recipient := notification.Recipient
+	if recipient == "" {
+		recipient = sender.fallback
+	}
+	if recipient == "" {
+		return fmt.Errorf("customer %s has no email", notification.CustomerName)
+	}
+	if err := validation.ValidateEmail(recipient); err != nil {
+		return err
+	}
+
+	// the subject carries the invoice number as typed, which must not break out of its header
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(notification.Subject)
+
+	mail := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", sender.from, recipient, subject, notification.Message)
+
+	return smtp.SendMail(sender.address, sender.plainAuth, sender.from, []string{recipient}, []byte(mail))
+}
+
+// webhookSender posts notifications as JSON to a URL
+type webhookSender struct {
+	url    string
+	client *http.Client
+}
+
+func (webhookSender) Channel() string { return "webhook" }
+
+func (sender webhookSender) Send(notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	response, err := sender.client.Post(sender.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", response.Status)
+	}
+
+	return nil
+}
+
+// notificationSenderFromEnv picks the sender named by NOTIFICATION_SENDER, logging by default
+func notificationSenderFromEnv() NotificationSender {
+	switch os.Getenv("NOTIFICATION_SENDER") {
+	case "smtp":
+		address := os.Getenv("SMTP_ADDRESS")
+		if address == "" {
+			address = "localhost:1025"
+		}
+
+		var plainAuth smtp.Auth
+		if username := os.Getenv("SMTP_USERNAME"); username != "" {
+			plainAuth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), strings.Split(address, ":")[0])
+		}
+
+		return smtpSender{address: address, from: os.Getenv("SMTP_FROM"), fallback: os.Getenv("SMTP_FALLBACK_TO"), plainAuth: plainAuth}
+	case "webhook":
+		return webhookSender{url: os.Getenv("WEBHOOK_URL"), client: &http.Client{Timeout: 10 * time.Second}}
+	}
+
+	return logSender{}
+}
+
+// DetectDueInvoices queues a reminder for every invoice with an outstanding amount due within the given days or already overdue
+func DetectDueInvoices(today string, days int) (int, error) {
+	dueQuery := fmt.Sprintf(`SELECT
+		si.id, si.tracker, cu.id, cu.customerName, IFNULL(cu.email, ''),
+		%s AS dueDate,
+		SUM(tr.totalValue) - SUM(tr.paidAmount) AS outstanding
+		FROM transaction tr, salesInvoice si, customer cu
+		WHERE tr.salesInvoice = si.id AND tr.customerId = cu.id AND tr.comeOrGo = 'out' AND tr.isError = 0
+		GROUP BY si.id, si.tracker, si.entryDate, cu.id, cu.customerName, cu.email, cu.paymentTerms
+		HAVING outstanding > %f AND dueDate <= DATE_ADD('%s', INTERVAL %d DAY)`, dueDateExpression, paymentTolerance, today, days)
+
+	dueInvoices, err := db.Query(dueQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer dueInvoices.Close()
+
+	var notifications []Notification
+
+	for dueInvoices.Next() {
+		var notification Notification
+
+		err := dueInvoices.Scan(&notification.SalesInvoiceId, &notification.SalesInvoice, &notification.CustomerId, &notification.CustomerName, &notification.Recipient, &notification.DueDate, &notification.Outstanding)
+		if err != nil {
+			return 0, err
+		}
+
+		// a customer email saved before emails were checked is left out, so that the reminder still goes to the fallback
+		if err := validation.ValidateEmail(notification.Recipient); err != nil {
+			log.Printf("invoice %s: %s", notification.SalesInvoice, err)
+			notification.Recipient = ""
+		}
+
+		if notification.DueDate < today {
+			notification.Kind = NotificationOverdue
+			notification.Subject = fmt.Sprintf("Invoice %s is overdue", notification.SalesInvoice)
+			notification.Message = fmt.Sprintf("Dear %s, an amount of %.2f against invoice %s was due on %s and is yet to be received.", notification.CustomerName, notification.Outstanding, notification.SalesInvoice, notification.DueDate)
+		} else {
+			notification.Kind = NotificationDueSoon
+			notification.Subject = fmt.Sprintf("Invoice %s is due on %s", notification.SalesInvoice, notification.DueDate)
+			notification.Message = fmt.Sprintf("Dear %s, this is a reminder that an amount of %.2f against invoice %s is due on %s.", notification.CustomerName, notification.Outstanding, notification.SalesInvoice, notification.DueDate)
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	queued := 0
+
+	// an invoice is reminded once per kind and due date, so moving the expected date raises a fresh reminder
+	for _, notification := range notifications {
+		notificationInsertQuery := `INSERT IGNORE INTO notification
+			(kind, salesInvoiceId, customerId, recipient, dueDate, outstanding, subject, message, status, createdAt)
+			VALUES
+			(?, ?, ?, ?, ?, ROUND(?, 2), ?, ?, ?, NOW())`
+
+		// one reminder that cannot be queued does not hold back the others
+		insertResult, err := db.Exec(notificationInsertQuery, notification.Kind, notification.SalesInvoiceId, notification.CustomerId, notification.Recipient, notification.DueDate, notification.Outstanding, notification.Subject, notification.Message, NotificationPending)
+		if err != nil {
+			log.Printf("invoice %s: %s", notification.SalesInvoice, err)
+			continue
+		}
+
+		inserted, _ := insertResult.RowsAffected()
+		queued += int(inserted)
+	}
+
+	return queued, nil
+}
+
+// DispatchNotifications delivers the pending notifications in the outbox through the sender
+func DispatchNotifications(sender NotificationSender) (int, error) {
+	pendingQuery := fmt.Sprintf(`SELECT
+		nt.id, nt.kind, nt.salesInvoiceId, si.tracker, nt.customerId, cu.customerName, nt.recipient, nt.dueDate, nt.outstanding, nt.subject, nt.message
+		FROM notification nt
+		JOIN salesInvoice si ON si.id = nt.salesInvoiceId
+		JOIN customer cu ON cu.id = nt.customerId
+		WHERE nt.status = '%s'
+		ORDER BY nt.id`, NotificationPending)
+
+	pendingNotifications, err := db.Query(pendingQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer pendingNotifications.Close()
+
+	var notifications []Notification
+
+	for pendingNotifications.Next() {
+		var notification Notification
+
+		err := pendingNotifications.Scan(&notification.NotificationId, &notification.Kind, &notification.SalesInvoiceId, &notification.SalesInvoice, &notification.CustomerId, &notification.CustomerName, &notification.Recipient, &notification.DueDate, &notification.Outstanding, &notification.Subject, &notification.Message)
+		if err != nil {
+			return 0, err
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	sent := 0
+
+	for _, notification := range notifications {
+		var err error
+
+		if sendErr := sender.Send(notification); sendErr != nil {
+			log.Println(sendErr)
+
+			// a notification keeps being retried until it has failed too often
+			_, err = db.Exec(`UPDATE notification
+				SET attempts = attempts + 1, channel = ?, lastError = LEFT(?, 255),
+				status = IF(attempts >= ?, ?, status)
+				WHERE id = ?`, sender.Channel(), sendErr.Error(), maxNotificationAttempts, NotificationFailed, notification.NotificationId)
+		} else {
+			sent++
+
+			_, err = db.Exec(`UPDATE notification
+				SET attempts = attempts + 1, channel = ?, lastError = '', status = ?, sentAt = NOW()
+				WHERE id = ?`, sender.Channel(), NotificationSent, notification.NotificationId)
+		}
+
+		if err != nil {
+			return sent, err
+		}
+	}
+
+	return sent, nil
+}
+
+// RunNotifications looks for due invoices and delivers the outbox once
+func RunNotifications() (int, int, error) {
+	queued, err := DetectDueInvoices(time.Now().Format("2006-01-02"), dueSoonDays)
+	if err != nil {
+		return queued, 0, err
+	}
+
+	sent, err := DispatchNotifications(notificationSender)
+	if err != nil {
+		return queued, sent, err
+	}
+
+	log.Printf("Notification run queued %d and sent %d reminders over %s", queued, sent, notificationSender.Channel())
+	return queued, sent, nil
+}
+
+// StartNotificationJob runs the notifications in the background at the given interval
+func StartNotificationJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			runScheduledNotifications()
+		}
+	}()
+}
+
+// runScheduledNotifications runs the notifications once without letting a failed query bring the server down
+func runScheduledNotifications() {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Println("Notification run failed:", recovered)
+		}
+	}()
+
+	if _, _, err := RunNotifications(); err != nil {
+		log.Println(err)
+	}
+}
+
+// TriggerNotifications runs the notifications right away and returns the status
+func TriggerNotifications(w http.ResponseWriter, r *http.Request) {
+
+	queued, sent, err := RunNotifications()
+
+	var result map[string]interface{}
+
+	if err != nil {
+		log.Println(err)
+		result = map[string]interface{}{
+			"success": false,
+		}
+	} else {
+		result = map[string]interface{}{
+			"success": true,
+			"queued":  queued,
+			"sent":    sent,
+		}
+	}
+
+	payloadJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// GetNotifications returns the notifications in the outbox, optionally of one status, kind or customer
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+
+	status := r.FormValue("status")
+	kind := r.FormValue("kind")
+	customerId := r.FormValue("customerId")
+
+	conditions := []string{"1 = 1"}
+	if isFiltered(status) {
+		conditions = append(conditions, fmt.Sprintf("nt.status = '%s'", status))
+	}
+	if isFiltered(kind) {
+		conditions = append(conditions, fmt.Sprintf("nt.kind = '%s'", kind))
+	}
+	if isFiltered(customerId) {
+		conditions = append(conditions, fmt.Sprintf("nt.customerId = '%s'", customerId))
+	}
+
+	var payload []Notification
+
+	notificationsQuery := fmt.Sprintf(`SELECT
+		nt.id, nt.kind, nt.salesInvoiceId, IFNULL(si.tracker, 'N/A'), nt.customerId, IFNULL(cu.customerName, 'N/A'),
+		nt.recipient, nt.dueDate, nt.outstanding, nt.subject, nt.message, nt.status, nt.channel, nt.attempts, nt.lastError,
+		nt.createdAt, IFNULL(nt.sentAt, '')
+		FROM notification nt
+		LEFT JOIN salesInvoice si ON si.id = nt.salesInvoiceId
+		LEFT JOIN customer cu ON cu.id = nt.customerId
+		WHERE %s
+		ORDER BY nt.createdAt DESC, nt.id DESC`, strings.Join(conditions, " AND "))
+
+	allNotifications, err := db.Query(notificationsQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allNotifications.Next() {
+		var notification Notification
+
+		err := allNotifications.Scan(&notification.NotificationId, &notification.Kind, &notification.SalesInvoiceId, &notification.SalesInvoice, &notification.CustomerId, &notification.CustomerName, &notification.Recipient, &notification.DueDate, &notification.Outstanding, &notification.Subject, &notification.Message, &notification.Status, &notification.Channel, &notification.Attempts, &notification.LastError, &notification.CreatedAt, &notification.SentAt)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		payload = append(payload, notification)
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
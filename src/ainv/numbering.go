@@ -0,0 +1,330 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the document types numbered by a series
+const (
+	DocumentSalesInvoice = "INV"
+	DocumentBillOfEntry  = "BOE"
+)
+
+var documentTypes = map[string]bool{
+	DocumentSalesInvoice: true,
+	DocumentBillOfEntry:  true,
+}
+
+// defaultNumberPadding is how many digits the running number is padded to unless configured
+const defaultNumberPadding = 5
+
+// maxInvoiceNumberLength is the longest sales invoice number the e-invoice portal accepts
+const maxInvoiceNumberLength = 16
+
+// invoiceNumberPattern is the shape of a sales invoice number the e-invoice portal accepts
+var invoiceNumberPattern = regexp.MustCompile(`^[a-zA-Z1-9][a-zA-Z0-9/-]*$`)
+
+// NumberingSeries is the running number of one document type at one warehouse in one financial year
+type NumberingSeries struct {
+	SeriesId           string `json:"seriesId"`
+	WarehouseId        string `json:"warehouseId"`
+	WarehouseName      string `json:"warehouseName"`
+	DocumentType       string `json:"documentType"`
+	FinancialYear      string `json:"financialYear"`
+	Prefix             string `json:"prefix"`
+	Padding            int    `json:"padding"`
+	NextNumber         int    `json:"nextNumber"`
+	NextDocumentNumber string `json:"nextDocumentNumber"`
+}
+
+// FinancialYear returns the April to March financial year a date falls in, such as 2026-27
+func FinancialYear(date string) (string, error) {
+	parsed, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "", fmt.Errorf("date %q is not in YYYY-MM-DD format", date)
+	}
+
+	startYear := parsed.Year()
+	if parsed.Month() < time.April {
+		startYear--
+	}
+
+	return fmt.Sprintf("%d-%02d", startYear, (startYear+1)%100), nil
+}
+
+// formatDocumentNumber lays a running number out as PREFIX/TYPE/YEAR/NUMBER, such as WH1/BOE/2026-27/00042. A sales
+// invoice is the exception and leaves out its type and the century, as in WH1/26-27/00042: laid out in full, as
+// WH1/INV/2026-27/00042, it would run to 21 characters where an e-invoice allows 16.
+func formatDocumentNumber(prefix string, documentType string, financialYear string, padding int, number int) string {
+	if documentType == DocumentSalesInvoice {
+		return fmt.Sprintf("%s/%s/%0*d", prefix, financialYear[2:], padding, number)
+	}
+
+	return fmt.Sprintf("%s/%s/%s/%0*d", prefix, documentType, financialYear, padding, number)
+}
+
+// checkInvoiceNumber tells whether a sales invoice number can be reported on an e-invoice
+func checkInvoiceNumber(number string) error {
+	if len(number) > maxInvoiceNumberLength || !invoiceNumberPattern.MatchString(number) {
+		return fmt.Errorf("sales invoice number %s must be at most %d letters, digits, / or - and not start with 0, as an e-invoice requires", number, maxInvoiceNumberLength)
+	}
+
+	return nil
+}
+
+// defaultSeries is the prefix and padding of a series not configured yet, which starts at 1 with the warehouse ID as its
+// prefix, padded less where an invoice number would otherwise run too long
+func defaultSeries(warehouseId string, documentType string, financialYear string) (string, int, error) {
+	prefix := "WH" + warehouseId
+	padding := defaultNumberPadding
+
+	if documentType == DocumentSalesInvoice {
+		room := maxInvoiceNumberLength - len(formatDocumentNumber(prefix, documentType, financialYear, 0, 0)) + 1
+		if room < 1 {
+			return "", 0, fmt.Errorf("the default prefix %s leaves no room for the number of a sales invoice, configure a shorter prefix for warehouse %s", prefix, warehouseId)
+		}
+		if room < padding {
+			padding = room
+		}
+	}
+
+	return prefix, padding, nil
+}
+
+// allocateDocumentNumber takes the next number of a series inside a database transaction, so that a rolled back document gives its number back
+func allocateDocumentNumber(tx *sql.Tx, warehouseId string, documentType string, date string) (string, error) {
+	financialYear, err := FinancialYear(date)
+	if err != nil {
+		return "", err
+	}
+
+	// a series that cannot take the default prefix has to have been configured with a prefix of its own
+	defaultPrefix, padding, defaultErr := defaultSeries(warehouseId, documentType, financialYear)
+	if defaultErr == nil {
+		seriesInsertQuery := fmt.Sprintf(`INSERT IGNORE INTO numberingSeries
+			(warehouseId, documentType, financialYear, prefix, padding, nextNumber)
+			VALUES
+			('%s', '%s', '%s', '%s', '%d', 1)`, warehouseId, documentType, financialYear, defaultPrefix, padding)
+
+		if _, err := tx.Exec(seriesInsertQuery); err != nil {
+			return "", err
+		}
+	}
+
+	var seriesId int64
+	var prefix string
+	var nextNumber int
+
+	// the series row stays locked until the document is committed, which keeps the numbers gapless
+	seriesQuery := fmt.Sprintf(`SELECT id, prefix, padding, nextNumber FROM numberingSeries
+		WHERE warehouseId = '%s' AND documentType = '%s' AND financialYear = '%s' FOR UPDATE`, warehouseId, documentType, financialYear)
+
+	err = tx.QueryRow(seriesQuery).Scan(&seriesId, &prefix, &padding, &nextNumber)
+	if err == sql.ErrNoRows && defaultErr != nil {
+		return "", defaultErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	number := formatDocumentNumber(prefix, documentType, financialYear, padding, nextNumber)
+	if documentType == DocumentSalesInvoice {
+		if err := checkInvoiceNumber(number); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(`UPDATE numberingSeries SET nextNumber = nextNumber + 1 WHERE id = '%d'`, seriesId)); err != nil {
+		return "", err
+	}
+
+	return number, nil
+}
+
+// createDocumentHeader inserts a bill of entry or sales invoice header inside the transaction that adds its first line,
+// numbering it from the series of the warehouse when no number is given
+func createDocumentHeader(tx *sql.Tx, documentType string, warehouseId string, date string, number string, insertQuery func(number string) string) (int64, string, error) {
+	if number == "" {
+		var err error
+		number, err = allocateDocumentNumber(tx, warehouseId, documentType, date)
+		if err != nil {
+			return 0, "", err
+		}
+	}
+
+	headerResult, err := tx.Exec(insertQuery(number))
+	if err != nil {
+		return 0, "", err
+	}
+	headerId, _ := headerResult.LastInsertId()
+
+	return headerId, number, nil
+}
+
+// CreateNumberingSeries configures the prefix, padding and starting number of a series that has not issued a number yet,
+// and returns the status; once a series is in use its numbers are fixed
+func CreateNumberingSeries(w http.ResponseWriter, r *http.Request) {
+
+	warehouseId := r.FormValue("warehouseId")
+	documentType := strings.ToUpper(r.FormValue("documentType"))
+	financialYear := r.FormValue("financialYear")
+	prefix := strings.TrimSpace(r.FormValue("prefix"))
+
+	if _, err := strconv.ParseUint(warehouseId, 10, 64); err != nil {
+		writeFailure(w, fmt.Sprintf("warehouseId %q must be the id of a warehouse", warehouseId))
+		return
+	}
+	if !documentTypes[documentType] {
+		writeFailure(w, fmt.Sprintf("document type %q is not numbered", documentType))
+		return
+	}
+	if expected, err := FinancialYear(strings.Split(financialYear, "-")[0] + "-04-01"); err != nil || expected != financialYear {
+		writeFailure(w, "financial year must look like 2026-27")
+		return
+	}
+	if prefix == "" || len(prefix) > 16 || strings.Contains(prefix, "/") {
+		writeFailure(w, "prefix must be 1 to 16 characters without /")
+		return
+	}
+
+	padding := defaultNumberPadding
+	paddingGiven := r.FormValue("padding") != ""
+	if raw := r.FormValue("padding"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 10 {
+			writeFailure(w, "padding must be between 1 and 10 digits")
+			return
+		}
+		padding = parsed
+	}
+
+	startNumber := 1
+	startGiven := r.FormValue("startNumber") != ""
+	if raw := r.FormValue("startNumber"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			writeFailure(w, "startNumber must be a positive number")
+			return
+		}
+		startNumber = parsed
+	}
+
+	// an invoice series has to start on a number an e-invoice accepts
+	if documentType == DocumentSalesInvoice {
+		if err := checkInvoiceNumber(formatDocumentNumber(prefix, documentType, financialYear, padding, startNumber)); err != nil {
+			writeFailure(w, err.Error())
+			return
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	var warehouses int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM warehouse WHERE id = ?`, warehouseId).Scan(&warehouses); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+	if warehouses == 0 {
+		writeFailure(w, fmt.Sprintf("warehouse %s does not exist", warehouseId))
+		return
+	}
+
+	// the running number of a series in use is never moved, nor is the way it is laid out, or numbers would repeat,
+	// be skipped or change their look halfway through the year
+	var seriesId int64
+	var currentPrefix string
+	var currentPadding, currentStart, nextNumber int
+	err = tx.QueryRow(`SELECT id, prefix, padding, startNumber, nextNumber FROM numberingSeries
+		WHERE warehouseId = ? AND documentType = ? AND financialYear = ? FOR UPDATE`, warehouseId, documentType, financialYear).Scan(&seriesId, &currentPrefix, &currentPadding, &currentStart, &nextNumber)
+
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = tx.Exec(`INSERT INTO numberingSeries
+			(warehouseId, documentType, financialYear, prefix, padding, startNumber, nextNumber)
+			VALUES
+			(?, ?, ?, ?, ?, ?, ?)`, warehouseId, documentType, financialYear, prefix, padding, startNumber, startNumber)
+	case err != nil:
+	case nextNumber > currentStart:
+		if prefix != currentPrefix || (paddingGiven && padding != currentPadding) || (startGiven && startNumber != currentStart) {
+			writeFailure(w, fmt.Sprintf("the series has issued numbers up to %s and can no longer change", formatDocumentNumber(currentPrefix, documentType, financialYear, currentPadding, nextNumber-1)))
+			return
+		}
+	default:
+		_, err = tx.Exec(`UPDATE numberingSeries SET prefix = ?, padding = ?, startNumber = ?, nextNumber = ? WHERE id = ?`, prefix, padding, startNumber, startNumber, seriesId)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	var result map[string]bool
+
+	if err != nil {
+		log.Println(err)
+		result = map[string]bool{
+			"success": false,
+		}
+	} else {
+		result = map[string]bool{
+			"success": true,
+		}
+	}
+
+	payloadJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// GetNumberingSeries returns the numbering series along with the number each will issue next
+func GetNumberingSeries(w http.ResponseWriter, r *http.Request) {
+
+	var payload []NumberingSeries
+
+	seriesQuery := `SELECT
+		ns.id, ns.warehouseId, IFNULL(wh.warehouseName, 'N/A'), ns.documentType, ns.financialYear, ns.prefix, ns.padding, ns.nextNumber
+		FROM numberingSeries ns
+		LEFT JOIN warehouse wh ON wh.id = ns.warehouseId
+		ORDER BY ns.financialYear DESC, ns.warehouseId, ns.documentType`
+
+	allSeries, err := db.Query(seriesQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allSeries.Next() {
+		var series NumberingSeries
+
+		err := allSeries.Scan(&series.SeriesId, &series.WarehouseId, &series.WarehouseName, &series.DocumentType, &series.FinancialYear, &series.Prefix, &series.Padding, &series.NextNumber)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		series.NextDocumentNumber = formatDocumentNumber(series.Prefix, series.DocumentType, series.FinancialYear, series.Padding, series.NextNumber)
+		payload = append(payload, series)
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
@@ -0,0 +1,87 @@
+package main
+
+import "testing"
+
+func TestFinancialYear(t *testing.T) {
+	cases := []struct {
+		date string
+		year string
+		ok   bool
+	}{
+		{"2026-04-01", "2026-27", true},
+		{"2027-03-31", "2026-27", true},
+		{"2099-12-31", "2099-00", true},
+		{"2026-4-1", "", false},
+		{"", "", false},
+	}
+
+	for _, c := range cases {
+		year, err := FinancialYear(c.date)
+		if (err == nil) != c.ok || year != c.year {
+			t.Errorf("FinancialYear(%q) = %q, %v, want %q", c.date, year, err, c.year)
+		}
+	}
+}
+
+func TestFormatDocumentNumber(t *testing.T) {
+	cases := []struct {
+		prefix       string
+		documentType string
+		padding      int
+		number       int
+		formatted    string
+	}{
+		{"WH1", DocumentSalesInvoice, 5, 42, "WH1/26-27/00042"},
+		{"WH1", DocumentBillOfEntry, 5, 42, "WH1/BOE/2026-27/00042"},
+		{"MUM", DocumentSalesInvoice, 3, 12345, "MUM/26-27/12345"},
+	}
+
+	for _, c := range cases {
+		if formatted := formatDocumentNumber(c.prefix, c.documentType, "2026-27", c.padding, c.number); formatted != c.formatted {
+			t.Errorf("formatDocumentNumber(%s, %s, %d, %d) = %s, want %s", c.prefix, c.documentType, c.padding, c.number, formatted, c.formatted)
+		}
+	}
+}
+
+func TestCheckInvoiceNumber(t *testing.T) {
+	cases := []struct {
+		number string
+		ok     bool
+	}{
+		{"WH1/26-27/00042", true},
+		{"WH123456/26-27/1", true},
+		{"WH1234567/26-27/1", false},
+		{"0WH/26-27/00042", false},
+		{"WH 1/26-27/00042", false},
+	}
+
+	for _, c := range cases {
+		if err := checkInvoiceNumber(c.number); (err == nil) != c.ok {
+			t.Errorf("checkInvoiceNumber(%s) = %v, want ok %v", c.number, err, c.ok)
+		}
+	}
+}
+
+func TestDefaultSeries(t *testing.T) {
+	cases := []struct {
+		warehouseId  string
+		documentType string
+		prefix       string
+		padding      int
+		ok           bool
+	}{
+		{"1", DocumentSalesInvoice, "WH1", 5, true},
+		{"1", DocumentBillOfEntry, "WH1", 5, true},
+		{"1234", DocumentSalesInvoice, "WH1234", 3, true},
+		{"123456", DocumentSalesInvoice, "WH123456", 1, true},
+		{"1234567", DocumentSalesInvoice, "", 0, false},
+		{"1234567", DocumentBillOfEntry, "WH1234567", 5, true},
+	}
+
+	for _, c := range cases {
+		prefix, padding, err := defaultSeries(c.warehouseId, c.documentType, "2026-27")
+		if (err == nil) != c.ok || prefix != c.prefix || padding != c.padding {
+			t.Errorf("defaultSeries(%s, %s) = %s, %d, %v, want %s, %d", c.warehouseId, c.documentType, prefix, padding, err, c.prefix, c.padding)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DirectionOpening marks the transactions that load the stock a warehouse or client already held when it started
+const DirectionOpening = "opening"
+
+// ImportOpeningStock is the import of opening stock, which is posted as transactions rather than masters
+const ImportOpeningStock = "openingstock"
+
+var openingStockColumns = []string{"itemId", "warehouseId", "clientId", "bigQuantity", "value", "unitCost", "remarks"}
+
+var openingStockRequired = []string{"itemId", "warehouseId", "clientId", "bigQuantity"}
+
+// openingStockLine is a checked row of an opening stock import; value is the cost of the stock in the base currency
+type openingStockLine struct {
+	row         importRow
+	itemId      string
+	warehouseId string
+	clientId    string
+	bigQuantity float64
+	value       float64
+	unitCost    float64
+	hasValue    bool
+	remarks     string
+}
+
+// checkOpeningStockRow reads a row of an opening stock import, which values the stock at either its total or per piece cost
+func checkOpeningStockRow(row importRow, fail func(column string, err error)) openingStockLine {
+	line := openingStockLine{
+		row:         row,
+		itemId:      row.get("itemId"),
+		warehouseId: row.get("warehouseId"),
+		clientId:    row.get("clientId"),
+		remarks:     row.get("remarks"),
+	}
+
+	for _, column := range []string{"itemId", "warehouseId", "clientId"} {
+		if id := row.get(column); id != "" {
+			if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+				fail(column, fmt.Errorf("%q must be the id of the %s", id, strings.TrimSuffix(column, "Id")))
+			}
+		}
+	}
+
+	if bigQuantity := row.get("bigQuantity"); bigQuantity != "" {
+		quantity, err := strconv.ParseFloat(bigQuantity, 64)
+		if err != nil || quantity <= 0 {
+			fail("bigQuantity", fmt.Errorf("quantity %q must be a positive number", bigQuantity))
+		}
+		line.bigQuantity = quantity
+	}
+
+	value := row.get("value")
+	unitCost := row.get("unitCost")
+	switch {
+	case value != "" && unitCost != "":
+		fail("", errors.New("give either value or unitCost, not both"))
+	case value != "":
+		amount, err := strconv.ParseFloat(value, 64)
+		if err != nil || amount < 0 {
+			fail("value", fmt.Errorf("value %q must be a non-negative amount", value))
+		}
+		line.value = roundPaise(amount)
+		line.hasValue = true
+	case unitCost != "":
+		cost, err := strconv.ParseFloat(unitCost, 64)
+		if err != nil || cost < 0 {
+			fail("unitCost", fmt.Errorf("unit cost %q must be a non-negative amount", unitCost))
+		}
+		line.unitCost = cost
+	default:
+		fail("", errors.New("value or unitCost is required to value the stock"))
+	}
+
+	return line
+}
+
+// checkOpeningStockRows reads every row of an opening stock import, each stock opening in one row only
+func checkOpeningStockRows(rows []importRow) ([]openingStockLine, []ImportRowError) {
+	var lines []openingStockLine
+	var errs []ImportRowError
+	seen := map[stockKey]int{}
+
+	for _, row := range rows {
+		number := row.number
+		line := checkOpeningStockRow(row, func(column string, err error) {
+			errs = append(errs, ImportRowError{Row: number, Column: column, Message: err.Error()})
+		})
+
+		key := stockKey{ItemId: line.itemId, WarehouseId: line.warehouseId, ClientId: line.clientId}
+		if first, ok := seen[key]; ok {
+			errs = append(errs, ImportRowError{Row: number, Message: fmt.Sprintf("duplicates row %d, stock opens once per item, warehouse and client", first)})
+		} else {
+			seen[key] = number
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, errs
+}
+
+// importOpeningStock posts the stock held at the start as one opening transaction per item, warehouse and client,
+// all under a single opening stock document dated entryDate; every row goes in or none do
+func importOpeningStock(records [][]string, entryDate string, dryRun bool) (ImportResult, error) {
+	result := ImportResult{Entity: ImportOpeningStock, DryRun: dryRun}
+
+	if entryDate == "" {
+		entryDate = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", entryDate); err != nil {
+		return result, fmt.Errorf("entry date %q must be in YYYY-MM-DD format", entryDate)
+	}
+
+	rows, errs := parseImportRows(ImportOpeningStock, openingStockColumns, openingStockRequired, records)
+	result.Rows = len(rows)
+	result.Errors = errs
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	fail := func(row int, column string, message string) {
+		result.Errors = append(result.Errors, ImportRowError{Row: row, Column: column, Message: message})
+	}
+
+	lines, rowErrors := checkOpeningStockRows(rows)
+	result.Errors = append(result.Errors, rowErrors...)
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, err
+	}
+
+	openingQuery := fmt.Sprintf(`INSERT INTO openingStock (tracker, entryDate) VALUES ('', '%s')`, entryDate)
+	openingResult, err := tx.Exec(openingQuery)
+	if err != nil {
+		tx.Rollback()
+		return result, err
+	}
+	openingId, _ := openingResult.LastInsertId()
+
+	trackerQuery := fmt.Sprintf(`UPDATE openingStock SET tracker = CONCAT('OPENING-', id) WHERE id = '%d'`, openingId)
+	if _, err := tx.Exec(trackerQuery); err != nil {
+		tx.Rollback()
+		return result, err
+	}
+
+	for _, line := range lines {
+		missing := false
+		for _, party := range []struct{ table, column, id string }{
+			{"warehouse", "warehouseId", line.warehouseId},
+			{"client", "clientId", line.clientId},
+		} {
+			var count int
+			existsQuery := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE id = '%s'`, party.table, party.id)
+			if err := tx.QueryRow(existsQuery).Scan(&count); err != nil {
+				tx.Rollback()
+				return result, err
+			}
+			if count == 0 {
+				fail(line.row.number, party.column, fmt.Sprintf("%s %s does not exist", party.table, party.id))
+				missing = true
+			}
+		}
+		if missing {
+			continue
+		}
+
+		// stock opens before anything else moves it, an opening loaded later would be replayed ahead of the movements
+		// already made and rewrite their valuation
+		var movements int
+		movedQuery := `SELECT COUNT(*) FROM transaction
+			WHERE itemId = ? AND warehouseId = ? AND clientId = ? AND isError = 0`
+		if err := tx.QueryRow(movedQuery, line.itemId, line.warehouseId, line.clientId).Scan(&movements); err != nil {
+			tx.Rollback()
+			return result, err
+		}
+		if movements > 0 {
+			fail(line.row.number, "", "the item already has stock movements in this warehouse for this client, opening stock can only come before them")
+			continue
+		}
+
+		rates, err := lookupItemRates(tx, line.itemId)
+		if err != nil {
+			fail(line.row.number, "itemId", err.Error())
+			continue
+		}
+
+		change, err := postInventoryChange(tx, line.itemId, line.warehouseId, line.clientId, DirectionOpening, line.bigQuantity, rates)
+		if err != nil {
+			tx.Rollback()
+			return result, err
+		}
+
+		totalPcs := line.bigQuantity * rates.SmallPerBig * rates.RawPerSmall
+		value := line.value
+		if !line.hasValue {
+			value = roundPaise(line.unitCost * totalPcs)
+		}
+		costPerPiece := 0.0
+		if totalPcs > 0 {
+			costPerPiece = value / totalPcs
+		}
+
+		remarks := line.remarks
+		if remarks == "" {
+			remarks = "Opening stock"
+		}
+
+		// opening stock carries no tax and is valued in the base currency, its cost being the landed cost of every piece
+		transactionQuery := fmt.Sprintf(`INSERT INTO transaction
+		(billOfEntry, salesInvoice, openingStock, itemId, warehouseId, comeOrGo, clientId, customerId, bigQuantity, currentValue, changeValue, finalValue, secretRate1, secretRate2, totalPcs, assdValue, dutyValue, gstValue, cgstValue, sgstValue, igstValue, placeOfSupply, totalValue, currency, exchangeRate, valuePerPiece, totalPieces, landedCostPerPiece, isPaid, paidAmount, date, delvDate1, delvDate2, remarks)
+		VALUES
+		(NULL, NULL, '%d', '%s', '%s', '%s', '%s', NULL, '%f', '%f', '%f', '%f', '%f', '%f', '%f', '%.2f', '0', '0', '0', '0', '0', NULL, '%.2f', '%s', '1', '%f', '%f', '%f', false, '0', NULL, '', '', '%s')`, openingId, line.itemId, line.warehouseId, DirectionOpening, line.clientId, line.bigQuantity, change.CurrentValue, change.ChangeValue, change.FinalValue, rates.SmallPerBig, rates.RawPerSmall, totalPcs, value, value, BaseCurrency, costPerPiece, totalPcs, costPerPiece, escapeQuotes(remarks))
+
+		if _, err := tx.Exec(transactionQuery); err != nil {
+			tx.Rollback()
+			return result, err
+		}
+		result.Imported++
+	}
+
+	if len(result.Errors) > 0 || dryRun {
+		if err := tx.Rollback(); err != nil {
+			return result, err
+		}
+		if len(result.Errors) > 0 {
+			result.Imported = 0
+			return result, nil
+		}
+	} else if err := tx.Commit(); err != nil {
+		return result, err
+	}
+
+	result.Success = true
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckOpeningStockRows(t *testing.T) {
+	header := openingStockColumns
+
+	cases := []struct {
+		name    string
+		records [][]string
+		lines   int
+		errors  []ImportRowError
+	}{
+		{
+			name: "valued by total and by piece",
+			records: [][]string{
+				header,
+				{"1", "2", "3", "10", "5000", "", ""},
+				{"1", "4", "3", "2.5", "", "12.5", "carried over"},
+			},
+			lines: 2,
+		},
+		{
+			name: "ids, quantity and cost must be numbers",
+			records: [][]string{
+				header,
+				{"one", "2", "3", "-1", "", "-4", ""},
+			},
+			lines: 1,
+			errors: []ImportRowError{
+				{Row: 2, Column: "itemId", Message: `"one" must be the id of the item`},
+				{Row: 2, Column: "bigQuantity", Message: `quantity "-1" must be a positive number`},
+				{Row: 2, Column: "unitCost", Message: `unit cost "-4" must be a non-negative amount`},
+			},
+		},
+		{
+			name: "value and unit cost are exclusive but one is needed",
+			records: [][]string{
+				header,
+				{"1", "2", "3", "10", "5000", "50", ""},
+				{"1", "2", "4", "10", "", "", ""},
+			},
+			lines: 2,
+			errors: []ImportRowError{
+				{Row: 2, Message: "give either value or unitCost, not both"},
+				{Row: 3, Message: "value or unitCost is required to value the stock"},
+			},
+		},
+		{
+			name: "stock opens once per item, warehouse and client",
+			records: [][]string{
+				header,
+				{"1", "2", "3", "10", "5000", "", ""},
+				{"1", "2", "4", "10", "5000", "", ""},
+				{"1", "2", "3", "5", "2500", "", ""},
+			},
+			lines: 3,
+			errors: []ImportRowError{
+				{Row: 4, Message: "duplicates row 2, stock opens once per item, warehouse and client"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		rows, errs := parseImportRows(ImportOpeningStock, openingStockColumns, openingStockRequired, c.records)
+		if len(errs) > 0 {
+			t.Errorf("%s: rows rejected %v", c.name, errs)
+			continue
+		}
+
+		lines, errs := checkOpeningStockRows(rows)
+		if len(lines) != c.lines {
+			t.Errorf("%s: %d lines, want %d", c.name, len(lines), c.lines)
+		}
+		if !reflect.DeepEqual(errs, c.errors) {
+			t.Errorf("%s: errors = %v, want %v", c.name, errs, c.errors)
+		}
+	}
+}
+
+func TestCheckOpeningStockRowValue(t *testing.T) {
+	rows, _ := parseImportRows(ImportOpeningStock, openingStockColumns, openingStockRequired, [][]string{
+		openingStockColumns,
+		{"1", "2", "3", "10", "1234.567", "", ""},
+		{"1", "2", "4", "10", "", "0.75", ""},
+	})
+
+	lines, errs := checkOpeningStockRows(rows)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	if !lines[0].hasValue || lines[0].value != 1234.57 {
+		t.Errorf("value = %v (given %v), want 1234.57", lines[0].value, lines[0].hasValue)
+	}
+	if lines[1].hasValue || lines[1].unitCost != 0.75 {
+		t.Errorf("unit cost = %v (value given %v), want 0.75", lines[1].unitCost, lines[1].hasValue)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxPageSize is the largest page a list can be asked for
+const maxPageSize = 1000
+
+// sortOrders are the directions a list can be sorted in
+var sortOrders = map[string]string{"asc": "ASC", "desc": "DESC"}
+
+// listSorting is how a list can be sorted: the SQL expression behind every sort field, and the field and direction
+// it is sorted by when none is asked for, whose expression also breaks ties so that pages never overlap
+type listSorting struct {
+	fields       map[string]string
+	defaultField string
+	defaultOrder string
+}
+
+// Page is a slice of a list along with where it lies in the whole
+type Page struct {
+	Items     interface{} `json:"items"`
+	Total     int         `json:"total"`
+	Limit     int         `json:"limit"`
+	Offset    int         `json:"offset"`
+	SortBy    string      `json:"sortBy"`
+	SortOrder string      `json:"sortOrder"`
+}
+
+// pageRequest is the part of a list asked for; without a limit it is the whole list, written as a plain array as before
+type pageRequest struct {
+	Paged     bool
+	Limit     int
+	Offset    int
+	SortBy    string
+	SortOrder string
+	orderBy   string
+}
+
+// parsePageRequest reads limit, offset, sortBy and sortOrder against the fields a list can be sorted by
+func parsePageRequest(r *http.Request, sorting listSorting) (pageRequest, error) {
+	page := pageRequest{SortBy: sorting.defaultField, SortOrder: sorting.defaultOrder}
+
+	if limit := r.FormValue("limit"); limit != "" {
+		limitNum, err := strconv.Atoi(limit)
+		if err != nil || limitNum < 1 || limitNum > maxPageSize {
+			return page, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		page.Paged = true
+		page.Limit = limitNum
+	}
+
+	if offset := r.FormValue("offset"); offset != "" {
+		offsetNum, err := strconv.Atoi(offset)
+		if err != nil || offsetNum < 0 {
+			return page, errors.New("offset must be a non-negative number")
+		}
+		page.Offset = offsetNum
+	}
+
+	if sortBy := r.FormValue("sortBy"); sortBy != "" {
+		if _, ok := sorting.fields[sortBy]; !ok {
+			var fields []string
+			for field := range sorting.fields {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			return page, fmt.Errorf("sortBy must be one of %s", strings.Join(fields, ", "))
+		}
+		page.SortBy = sortBy
+	}
+
+	if sortOrder := strings.ToLower(r.FormValue("sortOrder")); sortOrder != "" {
+		if _, ok := sortOrders[sortOrder]; !ok {
+			return page, errors.New("sortOrder must be asc or desc")
+		}
+		page.SortOrder = sortOrder
+	}
+
+	page.orderBy = fmt.Sprintf("%s %s", sorting.fields[page.SortBy], sortOrders[page.SortOrder])
+	if page.SortBy != sorting.defaultField {
+		page.orderBy += fmt.Sprintf(", %s %s", sorting.fields[sorting.defaultField], sortOrders[sorting.defaultOrder])
+	}
+
+	return page, nil
+}
+
+// clause is the ORDER BY, and for a page the LIMIT, to end the query of the list with
+func (page pageRequest) clause() string {
+	clause := " ORDER BY " + page.orderBy
+	if page.Paged {
+		clause += fmt.Sprintf(" LIMIT %d OFFSET %d", page.Limit, page.Offset)
+	}
+
+	return clause
+}
+
+// countRows counts the rows a list query returns before it is cut into pages
+func countRows(query string) (int, error) {
+	var total int
+	err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM (%s) counted", query)).Scan(&total)
+
+	return total, err
+}
+
+// writeList writes the items of a list, wrapped with the total and the page when one was asked for
+func writeList(w http.ResponseWriter, page pageRequest, total int, items interface{}) {
+	var payload interface{} = items
+	if page.Paged {
+		// an empty page still has an array of items
+		if reflect.ValueOf(items).IsNil() {
+			items = []interface{}{}
+		}
+		payload = Page{
+			Items:     items,
+			Total:     total,
+			Limit:     page.Limit,
+			Offset:    page.Offset,
+			SortBy:    page.SortBy,
+			SortOrder: page.SortOrder,
+		}
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// the ways the lists and searches can be sorted
+var (
+	clientSorting = listSorting{
+		fields:       map[string]string{"clientId": "id", "clientName": "clientName", "valuationMethod": "valuationMethod"},
+		defaultField: "clientId",
+		defaultOrder: "asc",
+	}
+	customerSorting = listSorting{
+		fields: map[string]string{
+			"customerId": "id", "customerName": "customerName", "gstin": "gstin", "placeOfSupply": "placeOfSupply",
+			"city": "city", "creditLimit": "creditLimit", "paymentTerms": "paymentTerms",
+		},
+		defaultField: "customerId",
+		defaultOrder: "asc",
+	}
+	billSorting = listSorting{
+		fields:       map[string]string{"billOfEntryId": "id", "billOfEntryNumber": "tracker", "billOfEntryDate": "entryDate", "currency": "currency"},
+		defaultField: "billOfEntryId",
+		defaultOrder: "asc",
+	}
+	invoiceSorting = listSorting{
+		fields:       map[string]string{"salesInvoiceId": "id", "salesInvoiceNumber": "tracker", "salesInvoiceDate": "entryDate", "customerName": "customerName"},
+		defaultField: "salesInvoiceId",
+		defaultOrder: "asc",
+	}
+	salesSorting = listSorting{
+		fields: map[string]string{
+			"transactionId": "tr.id", "entryDate": "entryDate", "itemName": "im.itemName", "warehouseName": "wh.warehouseName",
+			"clientName": "cl.clientName", "customerName": "cu.customerName", "totalPcs": "tr.totalPcs", "totalValue": "tr.totalValue",
+			"baseTotalValue": "tr.totalValue * tr.exchangeRate", "outstanding": "tr.totalValue - tr.paidAmount",
+			"paymentStatus": "tr.paymentStatus", "paymentDate": "tr.date",
+		},
+		defaultField: "transactionId",
+		defaultOrder: "asc",
+	}
+	overviewSorting = listSorting{
+		fields: map[string]string{
+			"billOfEntryId": "temp.billOfEntryId", "billOfEntry": "temp.billOfEntry", "salesInvoice": "temp.salesInvoice",
+			"direction": "temp.direction", "entryDate": "temp.entryDate", "client": "temp.client", "customer": "temp.customer",
+			"bigQuantity": "temp.bigQuantity", "totalValue": "temp.totalValue", "paidAmount": "temp.paidAmount",
+		},
+		defaultField: "billOfEntryId",
+		defaultOrder: "desc",
+	}
+)
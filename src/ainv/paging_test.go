@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePageRequest(t *testing.T) {
+	cases := []struct {
+		query  string
+		clause string
+		paged  bool
+		valid  bool
+	}{
+		{"", " ORDER BY id ASC", false, true},
+		{"limit=50&offset=100", " ORDER BY id ASC LIMIT 50 OFFSET 100", true, true},
+		{"limit=20&sortBy=clientName&sortOrder=DESC", " ORDER BY clientName DESC, id ASC LIMIT 20 OFFSET 0", true, true},
+		{"sortBy=clientId&sortOrder=desc", " ORDER BY id DESC", false, true},
+		{"limit=0", "", false, false},
+		{"limit=1001", "", false, false},
+		{"limit=ten", "", false, false},
+		{"offset=-1", "", false, false},
+		{"sortBy=clientName%20DESC", "", false, false},
+		{"sortOrder=up", "", false, false},
+	}
+
+	for _, c := range cases {
+		page, err := parsePageRequest(httptest.NewRequest("GET", "/api/get/clients?"+c.query, nil), clientSorting)
+		if (err == nil) != c.valid {
+			t.Errorf("%q: error = %v, want valid %v", c.query, err, c.valid)
+			continue
+		}
+		if !c.valid {
+			continue
+		}
+		if page.Paged != c.paged || page.clause() != c.clause {
+			t.Errorf("%q: paged %v with %q, want %v with %q", c.query, page.Paged, page.clause(), c.paged, c.clause)
+		}
+	}
+}
+
+func TestWriteList(t *testing.T) {
+	type client struct {
+		ClientId string `json:"clientId"`
+	}
+	clients := []client{{"1"}, {"2"}}
+
+	cases := []struct {
+		page  pageRequest
+		total int
+		items interface{}
+		body  string
+	}{
+		{pageRequest{}, 2, clients, `[{"clientId":"1"},{"clientId":"2"}]`},
+		{pageRequest{}, 0, []client(nil), `null`},
+		{
+			pageRequest{Paged: true, Limit: 2, Offset: 4, SortBy: "clientName", SortOrder: "desc"}, 7, clients,
+			`{"items":[{"clientId":"1"},{"clientId":"2"}],"total":7,"limit":2,"offset":4,"sortBy":"clientName","sortOrder":"desc"}`,
+		},
+		{
+			pageRequest{Paged: true, Limit: 2, Offset: 8, SortBy: "clientId", SortOrder: "asc"}, 7, []client(nil),
+			`{"items":[],"total":7,"limit":2,"offset":8,"sortBy":"clientId","sortOrder":"asc"}`,
+		},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		writeList(w, c.page, c.total, c.items)
+
+		if w.Header().Get("Content-Type") != "application/json" || w.Body.String() != c.body {
+			t.Errorf("writeList(%+v) = %s, want %s", c.page, w.Body.String(), c.body)
+		}
+	}
+}
@@ -0,0 +1,535 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// amounts within half a paisa of each other are treated as settled
+const paymentTolerance = 0.005
+
+// the payment status of a transaction line, derived from the receipts allocated to it
+const (
+	PaymentUnpaid  = "unpaid"
+	PaymentPartial = "partial"
+	PaymentPaid    = "paid"
+)
+
+// PaymentAdjustment is the mode of the receipts recorded when a paid amount is corrected by hand
+const PaymentAdjustment = "adjustment"
+
+// paymentModes are the modes a receipt can be made in
+var paymentModes = map[string]bool{
+	"cash":            true,
+	"cheque":          true,
+	"neft":            true,
+	"rtgs":            true,
+	"imps":            true,
+	"upi":             true,
+	"card":            true,
+	PaymentAdjustment: true,
+}
+
+// PaymentAllocation is the part of a receipt applied against a sales invoice or a single transaction line
+type PaymentAllocation struct {
+	SalesInvoiceId string  `json:"salesInvoiceId"`
+	TransactionId  string  `json:"transactionId"`
+	Amount         float64 `json:"amount"`
+}
+
+// Payment is a receipt from a customer along with how it has been allocated
+type Payment struct {
+	PaymentId    string              `json:"paymentId"`
+	CustomerId   string              `json:"customerId"`
+	CustomerName string              `json:"customerName"`
+	PaymentDate  string              `json:"paymentDate"`
+	Mode         string              `json:"mode"`
+	Reference    string              `json:"reference"`
+	Amount       float64             `json:"amount"`
+	Allocated    float64             `json:"allocated"`
+	Credit       float64             `json:"credit"`
+	Allocations  []PaymentAllocation `json:"allocations"`
+}
+
+// parseAllocations reads the allocations of a receipt, sent as a JSON array in a form value
+func parseAllocations(raw string) ([]PaymentAllocation, error) {
+	var allocations []PaymentAllocation
+	if strings.TrimSpace(raw) == "" {
+		return allocations, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &allocations); err != nil {
+		return nil, fmt.Errorf("allocations must be a JSON array: %v", err)
+	}
+
+	for _, allocation := range allocations {
+		if allocation.Amount <= 0 {
+			return nil, errors.New("every allocation must have a positive amount")
+		}
+		if allocation.TransactionId == "" && allocation.SalesInvoiceId == "" {
+			return nil, errors.New("every allocation must name a salesInvoiceId or a transactionId")
+		}
+	}
+
+	return allocations, nil
+}
+
+// transactionOutstanding returns the part of a transaction line of the customer not yet covered by receipts, locking the
+// line so that a concurrent receipt waits for this one instead of allocating against the same outstanding amount
+func transactionOutstanding(tx *sql.Tx, customerId string, transactionId string) (float64, error) {
+	var outstanding float64
+
+	outstandingQuery := `SELECT
+		tr.totalValue - IFNULL((SELECT SUM(amount) FROM paymentAllocation WHERE transactionId = tr.id), 0)
+		FROM transaction tr WHERE tr.id = ? AND tr.customerId = ? AND tr.comeOrGo = 'out' AND tr.isError = 0
+		FOR UPDATE`
+
+	err := tx.QueryRow(outstandingQuery, transactionId, customerId).Scan(&outstanding)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("transaction %s is not an outbound sale to customer %s", transactionId, customerId)
+	}
+
+	return outstanding, err
+}
+
+// allocateToTransaction applies part of a receipt against a single transaction line of the paying customer
+func allocateToTransaction(tx *sql.Tx, paymentId int64, customerId string, transactionId string, amount float64) error {
+	if amount <= 0 {
+		return fmt.Errorf("allocation of %.2f to transaction %s must be positive", amount, transactionId)
+	}
+
+	outstanding, err := transactionOutstanding(tx, customerId, transactionId)
+	if err != nil {
+		return err
+	}
+	if amount > outstanding+paymentTolerance {
+		return fmt.Errorf("allocation of %.2f exceeds the outstanding %.2f on transaction %s", amount, outstanding, transactionId)
+	}
+
+	_, err = tx.Exec(`INSERT INTO paymentAllocation (paymentId, transactionId, amount) VALUES (?, ?, ROUND(?, 2))`, paymentId, transactionId, amount)
+	return err
+}
+
+// allocateOutstanding spreads an amount over the outstanding lines of the customer matched by the condition, oldest
+// first, and returns what it used
+func allocateOutstanding(tx *sql.Tx, paymentId int64, customerId string, condition string, conditionArgs []interface{}, amount float64) (float64, error) {
+	linesQuery := fmt.Sprintf(`SELECT
+		tr.id,
+		tr.totalValue - IFNULL((SELECT SUM(amount) FROM paymentAllocation WHERE transactionId = tr.id), 0) AS outstanding
+		FROM transaction tr
+		WHERE tr.comeOrGo = 'out' AND tr.isError = 0 AND tr.customerId = ? AND %s
+		HAVING outstanding > 0
+		ORDER BY %s, tr.id
+		FOR UPDATE`, condition, transactionEntryDate)
+
+	rows, err := tx.Query(linesQuery, append([]interface{}{customerId}, conditionArgs...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	type outstandingLine struct {
+		TransactionId string
+		Outstanding   float64
+	}
+	var lines []outstandingLine
+
+	for rows.Next() {
+		var line outstandingLine
+		if err := rows.Scan(&line.TransactionId, &line.Outstanding); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		lines = append(lines, line)
+	}
+	rows.Close()
+
+	used := 0.0
+	for _, line := range lines {
+		remaining := roundPaise(amount - used)
+		if remaining <= 0 {
+			break
+		}
+
+		share := line.Outstanding
+		if remaining < share {
+			share = remaining
+		}
+
+		if err := allocateToTransaction(tx, paymentId, customerId, line.TransactionId, share); err != nil {
+			return used, err
+		}
+		used += share
+	}
+
+	return roundPaise(used), nil
+}
+
+// applyAllocations applies the allocations of a receipt of the customer and returns the amount allocated
+func applyAllocations(tx *sql.Tx, paymentId int64, customerId string, allocations []PaymentAllocation) (float64, error) {
+	allocated := 0.0
+
+	for _, allocation := range allocations {
+		if allocation.TransactionId != "" {
+			if err := allocateToTransaction(tx, paymentId, customerId, allocation.TransactionId, allocation.Amount); err != nil {
+				return allocated, err
+			}
+			allocated += allocation.Amount
+			continue
+		}
+
+		var invoiceCustomerId string
+		err := tx.QueryRow(`SELECT customerId FROM salesInvoice WHERE id = ?`, allocation.SalesInvoiceId).Scan(&invoiceCustomerId)
+		if err == sql.ErrNoRows || (err == nil && invoiceCustomerId != customerId) {
+			return allocated, fmt.Errorf("sales invoice %s is not an invoice of customer %s", allocation.SalesInvoiceId, customerId)
+		}
+		if err != nil {
+			return allocated, err
+		}
+
+		// whatever does not fit the invoice stays with the receipt as customer credit
+		used, err := allocateOutstanding(tx, paymentId, customerId, "tr.salesInvoice = ?", []interface{}{allocation.SalesInvoiceId}, allocation.Amount)
+		if err != nil {
+			return allocated, err
+		}
+		allocated += used
+	}
+
+	return roundPaise(allocated), nil
+}
+
+// paymentUnallocated returns the customer of a receipt and the part of it held as customer credit, locking the
+// receipt so that its credit cannot be allocated twice at once
+func paymentUnallocated(tx *sql.Tx, paymentId int64) (string, float64, error) {
+	var customerId string
+	var unallocated float64
+
+	unallocatedQuery := `SELECT
+		p.customerId, p.amount - IFNULL((SELECT SUM(amount) FROM paymentAllocation WHERE paymentId = p.id), 0)
+		FROM payment p WHERE p.id = ?
+		FOR UPDATE`
+
+	err := tx.QueryRow(unallocatedQuery, paymentId).Scan(&customerId, &unallocated)
+	if err == sql.ErrNoRows {
+		return "", 0, fmt.Errorf("payment %d does not exist", paymentId)
+	}
+
+	return customerId, unallocated, err
+}
+
+// refreshPaymentStatus derives the paid amount and payment status of transaction lines from their receipts
+func refreshPaymentStatus(tx *sql.Tx, condition string) error {
+	refreshQuery := fmt.Sprintf(`UPDATE transaction tr
+		SET tr.paidAmount = IFNULL((SELECT SUM(amount) FROM paymentAllocation WHERE transactionId = tr.id), 0),
+		tr.isPaid = CASE WHEN tr.paidAmount >= tr.totalValue - %f THEN true ELSE false END,
+		tr.paymentStatus = CASE
+			WHEN tr.paidAmount >= tr.totalValue - %f THEN '%s'
+			WHEN tr.paidAmount > 0 THEN '%s'
+			ELSE '%s' END
+		WHERE tr.comeOrGo = 'out' AND %s`, paymentTolerance, paymentTolerance, PaymentPaid, PaymentPartial, PaymentUnpaid, condition)
+
+	_, err := tx.Exec(refreshQuery)
+	return err
+}
+
+// allocatedCondition matches the transaction lines a receipt has been allocated to
+func allocatedCondition(paymentId int64) string {
+	return fmt.Sprintf("tr.id IN (SELECT transactionId FROM paymentAllocation WHERE paymentId = '%d')", paymentId)
+}
+
+// recordPayment stores a receipt with its allocations inside a database transaction and returns its ID
+func recordPayment(tx *sql.Tx, payment Payment, allocations []PaymentAllocation, autoAllocate bool) (int64, error) {
+	paymentInsertQuery := `INSERT INTO payment
+		(customerId, paymentDate, mode, reference, amount)
+		VALUES
+		(?, ?, ?, ?, ROUND(?, 2))`
+
+	paymentResult, err := tx.Exec(paymentInsertQuery, payment.CustomerId, payment.PaymentDate, payment.Mode, payment.Reference, payment.Amount)
+	if err != nil {
+		return 0, err
+	}
+	paymentId, _ := paymentResult.LastInsertId()
+
+	allocated, err := applyAllocations(tx, paymentId, payment.CustomerId, allocations)
+	if err != nil {
+		return 0, err
+	}
+	if payment.Amount > 0 && allocated > payment.Amount+paymentTolerance {
+		return 0, fmt.Errorf("allocations of %.2f exceed the receipt of %.2f", allocated, payment.Amount)
+	}
+
+	if autoAllocate && payment.Amount-allocated > paymentTolerance {
+		if _, err := allocateOutstanding(tx, paymentId, payment.CustomerId, "true", nil, payment.Amount-allocated); err != nil {
+			return 0, err
+		}
+	}
+
+	return paymentId, refreshPaymentStatus(tx, allocatedCondition(paymentId))
+}
+
+// writePaymentResult writes the status of a receipt along with the credit left on it
+func writePaymentResult(w http.ResponseWriter, tx *sql.Tx, paymentId int64) {
+	_, credit, err := paymentUnallocated(tx, paymentId)
+	if err != nil {
+		tx.Rollback()
+		writeFailure(w, err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	result := map[string]interface{}{
+		"success":   true,
+		"paymentId": paymentId,
+		"credit":    credit,
+	}
+
+	payloadJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// CreatePayment records a receipt from a customer, allocated across sales invoices or transactions, and returns the status
+func CreatePayment(w http.ResponseWriter, r *http.Request) {
+
+	payment := Payment{
+		CustomerId:  r.FormValue("customerId"),
+		PaymentDate: r.FormValue("paymentDate"),
+		Mode:        strings.ToLower(r.FormValue("mode")),
+		Reference:   r.FormValue("reference"),
+	}
+	autoAllocate := r.FormValue("autoAllocate") == "true"
+
+	amount, err := strconv.ParseFloat(r.FormValue("amount"), 64)
+	if err != nil || amount <= 0 {
+		writeFailure(w, "amount must be a positive number")
+		return
+	}
+	payment.Amount = roundPaise(amount)
+
+	if !paymentModes[payment.Mode] || payment.Mode == PaymentAdjustment {
+		writeFailure(w, fmt.Sprintf("payment mode %q is not supported", payment.Mode))
+		return
+	}
+
+	allocations, err := parseAllocations(r.FormValue("allocations"))
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	paymentId, err := recordPayment(tx, payment, allocations, autoAllocate)
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		writeFailure(w, err.Error())
+		return
+	}
+
+	writePaymentResult(w, tx, paymentId)
+}
+
+// AllocatePayment applies the customer credit left on an earlier receipt and returns the status
+func AllocatePayment(w http.ResponseWriter, r *http.Request) {
+
+	paymentId, err := strconv.ParseInt(r.FormValue("paymentId"), 10, 64)
+	if err != nil {
+		writeFailure(w, "paymentId must be a number")
+		return
+	}
+
+	allocations, err := parseAllocations(r.FormValue("allocations"))
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	customerId, credit, err := paymentUnallocated(tx, paymentId)
+	if err == nil {
+		var allocated float64
+		allocated, err = applyAllocations(tx, paymentId, customerId, allocations)
+		if err == nil && allocated > credit+paymentTolerance {
+			err = fmt.Errorf("allocations of %.2f exceed the credit of %.2f left on the receipt", allocated, credit)
+		}
+	}
+	if err == nil {
+		err = refreshPaymentStatus(tx, allocatedCondition(paymentId))
+	}
+
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		writeFailure(w, err.Error())
+		return
+	}
+
+	writePaymentResult(w, tx, paymentId)
+}
+
+// GetPayments returns the receipts, optionally of a single customer, with their allocations and credit
+func GetPayments(w http.ResponseWriter, r *http.Request) {
+
+	customerId := r.FormValue("customerId")
+
+	var payload []Payment
+
+	getPaymentsQuery := `SELECT
+		p.id, p.customerId, cu.customerName, p.paymentDate, p.mode, p.reference, p.amount,
+		IFNULL((SELECT SUM(amount) FROM paymentAllocation WHERE paymentId = p.id), 0)
+		FROM payment p, customer cu
+		WHERE p.customerId = cu.id`
+	if isFiltered(customerId) {
+		getPaymentsQuery = getPaymentsQuery + fmt.Sprintf(" AND p.customerId = '%s'", customerId)
+	}
+	getPaymentsQuery = getPaymentsQuery + " ORDER BY p.paymentDate DESC, p.id DESC"
+
+	allPayments, err := db.Query(getPaymentsQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allPayments.Next() {
+		var payment Payment
+
+		err := allPayments.Scan(&payment.PaymentId, &payment.CustomerId, &payment.CustomerName, &payment.PaymentDate, &payment.Mode, &payment.Reference, &payment.Amount, &payment.Allocated)
+		if err != nil {
+			panic(err.Error())
+		}
+		payment.Credit = roundPaise(payment.Amount - payment.Allocated)
+
+		payload = append(payload, payment)
+	}
+
+	for i := range payload {
+		allocationsQuery := fmt.Sprintf(`SELECT
+			IFNULL(tr.salesInvoice, ''), pa.transactionId, pa.amount
+			FROM paymentAllocation pa, transaction tr
+			WHERE pa.transactionId = tr.id AND pa.paymentId = '%s'`, payload[i].PaymentId)
+
+		allAllocations, err := db.Query(allocationsQuery)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		for allAllocations.Next() {
+			var allocation PaymentAllocation
+
+			err := allAllocations.Scan(&allocation.SalesInvoiceId, &allocation.TransactionId, &allocation.Amount)
+			if err != nil {
+				panic(err.Error())
+			}
+
+			payload[i].Allocations = append(payload[i].Allocations, allocation)
+		}
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// GetCustomerCredit returns the overpayments a customer has not yet had allocated
+func GetCustomerCredit(w http.ResponseWriter, r *http.Request) {
+
+	customerId := r.FormValue("customerId")
+
+	var credit float64
+
+	creditQuery := fmt.Sprintf(`SELECT
+		IFNULL(SUM(p.amount - IFNULL((SELECT SUM(amount) FROM paymentAllocation WHERE paymentId = p.id), 0)), 0)
+		FROM payment p WHERE p.customerId = '%s'`, customerId)
+
+	err := db.QueryRow(creditQuery).Scan(&credit)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	result := map[string]interface{}{
+		"customerId": customerId,
+		"credit":     roundPaise(credit),
+	}
+
+	payloadJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// adjustPaidAmount records the correction needed for a transaction line to show the given paid amount inside the
+// database transaction of the caller
+func adjustPaidAmount(tx *sql.Tx, transactionId string, paidAmount float64) error {
+	var customerId string
+	var currentPaid float64
+
+	currentQuery := `SELECT
+		tr.customerId, IFNULL((SELECT SUM(amount) FROM paymentAllocation WHERE transactionId = tr.id), 0)
+		FROM transaction tr WHERE tr.id = ? AND tr.comeOrGo = 'out'
+		FOR UPDATE`
+
+	err := tx.QueryRow(currentQuery, transactionId).Scan(&customerId, &currentPaid)
+	if err != nil {
+		return err
+	}
+
+	adjustment := roundPaise(paidAmount - currentPaid)
+	if adjustment == 0 {
+		return nil
+	}
+
+	payment := Payment{
+		CustomerId:  customerId,
+		PaymentDate: time.Now().Format("2006-01-02"),
+		Mode:        PaymentAdjustment,
+		Reference:   fmt.Sprintf("paid amount of transaction %s set to %.2f", transactionId, paidAmount),
+		Amount:      adjustment,
+	}
+	allocations := []PaymentAllocation{{TransactionId: transactionId, Amount: adjustment}}
+
+	// allocations only take positive amounts, so a correction downwards is written straight against the line locked above
+	if adjustment < 0 {
+		allocations = nil
+	}
+
+	paymentId, err := recordPayment(tx, payment, allocations, false)
+	if err == nil && adjustment < 0 {
+		_, err = tx.Exec(`INSERT INTO paymentAllocation (paymentId, transactionId, amount) VALUES (?, ?, ROUND(?, 2))`, paymentId, transactionId, adjustment)
+		if err == nil {
+			err = refreshPaymentStatus(tx, allocatedCondition(paymentId))
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rounakdatta/ainv-backend-go/src/pdf"
+	"github.com/rounakdatta/ainv-backend-go/src/validation"
+)
+
+// the usable width of a printed page
+const printWidth = pdf.PageWidth - 2*pdf.Margin
+
+// printHeader is the company and warehouse a document is issued from
+type printHeader struct {
+	CompanyName       string
+	WarehouseName     string
+	WarehouseLocation string
+	Gstin             string
+	State             string
+}
+
+// loadPrintHeader reads the details of the warehouse a document is issued from; the company name comes from COMPANY_NAME
+func loadPrintHeader(warehouseId string) printHeader {
+	header := printHeader{CompanyName: os.Getenv("COMPANY_NAME")}
+	if header.CompanyName == "" {
+		header.CompanyName = "ainv"
+	}
+
+	warehouseQuery := fmt.Sprintf(`SELECT warehouseName, warehouseLocation, IFNULL(gstin, '') FROM warehouse WHERE id = '%s'`, warehouseId)
+	db.QueryRow(warehouseQuery).Scan(&header.WarehouseName, &header.WarehouseLocation, &header.Gstin)
+
+	if stateCode, err := validation.GSTINStateCode(header.Gstin); err == nil {
+		header.State = stateName(stateCode)
+	}
+
+	return header
+}
+
+// stateName shows a GST state code along with the name of the state
+func stateName(stateCode string) string {
+	if name, ok := validation.StateCodes[stateCode]; ok {
+		return fmt.Sprintf("%s (%s)", name, stateCode)
+	}
+
+	return stateCode
+}
+
+// itemUnits returns the big and raw units of measure of an item
+func itemUnits(itemId string) (string, string) {
+	var uomBig string
+	var uomRaw string
+
+	unitsQuery := fmt.Sprintf(`SELECT uomBig, uomRaw FROM itemMaster WHERE id = '%s'`, itemId)
+	db.QueryRow(unitsQuery).Scan(&uomBig, &uomRaw)
+
+	return uomBig, uomRaw
+}
+
+var smallNumberWords = []string{
+	"", "One", "Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine", "Ten",
+	"Eleven", "Twelve", "Thirteen", "Fourteen", "Fifteen", "Sixteen", "Seventeen", "Eighteen", "Nineteen",
+}
+
+var tensWords = []string{"", "", "Twenty", "Thirty", "Forty", "Fifty", "Sixty", "Seventy", "Eighty", "Ninety"}
+
+// numberInWords spells out a whole number in the Indian system of lakhs and crores
+func numberInWords(number int64) string {
+	if number == 0 {
+		return "Zero"
+	}
+
+	var words []string
+
+	below100 := func(n int64) {
+		if n < 20 {
+			words = append(words, smallNumberWords[n])
+			return
+		}
+
+		words = append(words, tensWords[n/10])
+		if n%10 > 0 {
+			words = append(words, smallNumberWords[n%10])
+		}
+	}
+
+	if crores := number / 10000000; crores > 0 {
+		words = append(words, numberInWords(crores), "Crore")
+	}
+	if lakhs := number / 100000 % 100; lakhs > 0 {
+		below100(lakhs)
+		words = append(words, "Lakh")
+	}
+	if thousands := number / 1000 % 100; thousands > 0 {
+		below100(thousands)
+		words = append(words, "Thousand")
+	}
+	if hundreds := number / 100 % 10; hundreds > 0 {
+		below100(hundreds)
+		words = append(words, "Hundred")
+	}
+	if rest := number % 100; rest > 0 {
+		below100(rest)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// amountInWords spells out an amount, such as Rupees One Hundred and Fifty Paise Only, and a negative one from Minus
+func amountInWords(amount float64, currency string) string {
+	paise := int64(math.Round(math.Abs(amount) * 100))
+	whole, fraction := paise/100, paise%100
+
+	major, minor := currency, "Cents"
+	if currency == BaseCurrency {
+		major, minor = "Rupees", "Paise"
+	}
+
+	words := major + " " + numberInWords(whole)
+	if fraction > 0 {
+		words += " and " + numberInWords(fraction) + " " + minor
+	}
+	if amount < 0 && paise > 0 {
+		words = "Minus " + words
+	}
+
+	return words + " Only"
+}
+
+// formatIndianAmount formats an amount with two decimals and the digits grouped as lakhs and crores
+func formatIndianAmount(amount float64) string {
+	formatted := fmt.Sprintf("%.2f", math.Abs(amount))
+	whole, fraction := formatted[:len(formatted)-3], formatted[len(formatted)-3:]
+
+	if len(whole) > 3 {
+		head, tail := whole[:len(whole)-3], whole[len(whole)-3:]
+
+		var groups []string
+		for len(head) > 2 {
+			groups = append([]string{head[len(head)-2:]}, groups...)
+			head = head[:len(head)-2]
+		}
+		groups = append([]string{head}, groups...)
+
+		whole = strings.Join(groups, ",") + "," + tail
+	}
+
+	// an amount that rounds to nothing is not shown as negative
+	if amount < 0 && strings.Trim(formatted, "0.") != "" {
+		whole = "-" + whole
+	}
+
+	return whole + fraction
+}
+
+// drawHeading writes the title of a document and the company and warehouse it is issued from, and returns where the body starts
+func drawHeading(page *pdf.Page, title string, status string, header printHeader, details [][2]string) float64 {
+	page.TextCenter(pdf.PageWidth/2, pdf.Margin+12, 14, pdf.Bold, title)
+	if status == InvoiceDraft || status == InvoiceCancelled {
+		page.TextCenter(pdf.PageWidth/2, pdf.Margin+26, 10, pdf.Bold, strings.ToUpper(status))
+	}
+
+	company := []string{header.CompanyName, header.WarehouseName, header.WarehouseLocation}
+	if header.Gstin != "" {
+		company = append(company, "GSTIN: "+header.Gstin)
+	}
+	if header.State != "" {
+		company = append(company, "State: "+header.State)
+	}
+
+	return drawParties(page, pdf.Margin+40, company, details)
+}
+
+// drawParties writes a block of lines on the left, the first in bold, and labelled details on the right, and returns the position below them
+func drawParties(page *pdf.Page, y float64, left []string, right [][2]string) float64 {
+	for i, line := range left {
+		font := pdf.Regular
+		if i == 0 {
+			font = pdf.Bold
+		}
+		page.Text(pdf.Margin, y+float64(i)*12, 9, font, pdf.Fit(line, printWidth/2-10, 9, font))
+	}
+
+	for i, detail := range right {
+		x := pdf.PageWidth / 2
+		page.Text(x, y+float64(i)*12, 9, pdf.Bold, detail[0])
+		page.Text(x+90, y+float64(i)*12, 9, pdf.Regular, pdf.Fit(detail[1], printWidth/2-90, 9, pdf.Regular))
+	}
+
+	lines := len(left)
+	if len(right) > lines {
+		lines = len(right)
+	}
+
+	y += float64(lines)*12 + 2
+	page.Line(pdf.Margin, y, pdf.PageWidth-pdf.Margin, y)
+
+	return y + 14
+}
+
+// drawTotals writes labelled amounts aligned to the right edge, the last in bold
+func drawTotals(document *pdf.Document, page *pdf.Page, y float64, totals [][2]string) (*pdf.Page, float64) {
+	page, y = document.Ensure(page, y, float64(len(totals))*12+6)
+	y += 6
+
+	for i, total := range totals {
+		font := pdf.Regular
+		if i == len(totals)-1 {
+			font = pdf.Bold
+		}
+
+		y += 12
+		page.TextRight(pdf.PageWidth-pdf.Margin-90, y, 9, font, total[0])
+		page.TextRight(pdf.PageWidth-pdf.Margin, y, 9, font, total[1])
+	}
+
+	return page, y + 6
+}
+
+// drawParagraph writes text wrapped to the page width
+func drawParagraph(document *pdf.Document, page *pdf.Page, y float64, font pdf.Font, text string) (*pdf.Page, float64) {
+	for _, line := range pdf.Wrap(text, printWidth, 9, font) {
+		page, y = document.Ensure(page, y, 12)
+		y += 12
+		page.Text(pdf.Margin, y, 9, font, line)
+	}
+
+	return page, y + 4
+}
+
+// drawSignatures writes the signature blocks at the foot of a document
+func drawSignatures(document *pdf.Document, page *pdf.Page, y float64, companyName string, receiver string) {
+	page, y = document.Ensure(page, y, 60)
+	y += 24
+
+	page.TextRight(pdf.PageWidth-pdf.Margin, y, 9, pdf.Bold, "For "+companyName)
+	page.TextRight(pdf.PageWidth-pdf.Margin, y+36, 9, pdf.Regular, "Authorised Signatory")
+	if receiver != "" {
+		page.Text(pdf.Margin, y+36, 9, pdf.Regular, receiver)
+	}
+}
+
+// hsnSummaryRow is the tax on all the lines of one HSN or SAC code
+type hsnSummaryRow struct {
+	TaxableValue float64
+	CgstValue    float64
+	SgstValue    float64
+	IgstValue    float64
+}
+
+// summarizeHSN totals the taxable value and tax of the invoice lines by HSN code
+func summarizeHSN(lines []SalesInvoiceLine) ([]string, map[string]hsnSummaryRow) {
+	summary := map[string]hsnSummaryRow{}
+
+	for _, line := range lines {
+		row := summary[line.HsnCode]
+		row.TaxableValue = roundPaise(row.TaxableValue + line.TaxableValue)
+		row.CgstValue = roundPaise(row.CgstValue + line.CgstValue)
+		row.SgstValue = roundPaise(row.SgstValue + line.SgstValue)
+		row.IgstValue = roundPaise(row.IgstValue + line.IgstValue)
+		summary[line.HsnCode] = row
+	}
+
+	var codes []string
+	for code := range summary {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	return codes, summary
+}
+
+// invoicePrintHeader is the header of the warehouse the first line of an invoice ships from
+func invoicePrintHeader(lines []SalesInvoiceLine) printHeader {
+	if len(lines) == 0 {
+		return loadPrintHeader("")
+	}
+
+	return loadPrintHeader(lines[0].WarehouseId)
+}
+
+// invoiceParties are the customer and invoice details printed below the heading of a sales document
+func invoiceParties(invoice SalesInvoiceDocument, numberLabel string) ([]string, [][2]string) {
+	var gstin string
+	db.QueryRow(fmt.Sprintf(`SELECT IFNULL(gstin, '') FROM customer WHERE id = '%s'`, invoice.CustomerId)).Scan(&gstin)
+
+	customer := []string{"Bill To", invoice.CustomerName}
+	if gstin != "" {
+		customer = append(customer, "GSTIN: "+gstin)
+	}
+
+	details := [][2]string{
+		{numberLabel, invoice.SalesInvoiceNumber},
+		{"Date", invoice.SalesInvoiceDate},
+		{"Place of Supply", stateName(invoice.PlaceOfSupply)},
+	}
+	if invoice.Currency != BaseCurrency {
+		details = append(details, [2]string{"Currency", fmt.Sprintf("%s @ %g", invoice.Currency, invoice.ExchangeRate)})
+	}
+	if invoice.EwayBillNumber != "" {
+		details = append(details, [2]string{"E-way Bill", invoice.EwayBillNumber})
+	}
+
+	return customer, details
+}
+
+// renderTaxInvoice lays out a GST tax invoice with its lines, tax totals, amount in words and HSN summary
+func renderTaxInvoice(invoice SalesInvoiceDocument) *pdf.Document {
+	document := pdf.New()
+	page := document.AddPage()
+
+	header := invoicePrintHeader(invoice.Lines)
+	y := drawHeading(page, "TAX INVOICE", invoice.Status, header, nil)
+
+	customer, details := invoiceParties(invoice, "Invoice No")
+	y = drawParties(page, y, customer, details)
+
+	// a registered e-invoice carries its IRN, which is too long for the details column
+	if invoice.Irn != "" {
+		page.Text(pdf.Margin, y, 8, pdf.Bold, "IRN: "+invoice.Irn)
+		page.Text(pdf.Margin, y+11, 8, pdf.Regular, fmt.Sprintf("Ack No: %s   Ack Date: %s", invoice.AckNumber, invoice.AckDate))
+		y += 24
+	}
+
+	columns := []pdf.Column{
+		{Title: "#", Width: 18},
+		{Title: "Description", Width: 115},
+		{Title: "HSN/SAC", Width: 45},
+		{Title: "Qty", Width: 40, AlignRight: true},
+		{Title: "Pcs", Width: 40, AlignRight: true},
+		{Title: "Rate", Width: 45, AlignRight: true},
+		{Title: "Discount", Width: 40, AlignRight: true},
+		{Title: "Taxable", Width: 55, AlignRight: true},
+		{Title: "GST %", Width: 30, AlignRight: true},
+		{Title: "Tax", Width: 35, AlignRight: true},
+		{Title: "Total", Width: 60, AlignRight: true},
+	}
+
+	var rows [][]string
+	for _, line := range invoice.Lines {
+		uomBig, _ := itemUnits(line.ItemId)
+
+		rows = append(rows, []string{
+			fmt.Sprint(line.LineNumber),
+			strings.TrimSpace(line.ItemName + " " + line.ItemVariant),
+			line.HsnCode,
+			strings.TrimSpace(formatQuantity(line.BigQuantity) + " " + uomBig),
+			formatQuantity(line.TotalPcs),
+			formatIndianAmount(line.UnitPrice),
+			formatIndianAmount(line.DiscountValue),
+			formatIndianAmount(line.TaxableValue),
+			formatQuantity(line.GstRate),
+			formatIndianAmount(line.GstValue),
+			formatIndianAmount(line.TotalValue),
+		})
+	}
+
+	page, y = document.Table(page, y, columns, rows)
+
+	totals := [][2]string{
+		{"Gross Value", formatIndianAmount(invoice.Totals.GrossValue)},
+		{"Discount", formatIndianAmount(invoice.Totals.DiscountValue)},
+		{"Taxable Value", formatIndianAmount(invoice.Totals.TaxableValue)},
+		{"CGST", formatIndianAmount(invoice.Totals.CgstValue)},
+		{"SGST", formatIndianAmount(invoice.Totals.SgstValue)},
+		{"IGST", formatIndianAmount(invoice.Totals.IgstValue)},
+		{"Invoice Total (" + invoice.Currency + ")", formatIndianAmount(invoice.Totals.TotalValue)},
+	}
+	page, y = drawTotals(document, page, y, totals)
+	page, y = drawParagraph(document, page, y, pdf.Bold, "Amount in words: "+amountInWords(invoice.Totals.TotalValue, invoice.Currency))
+
+	hsnColumns := []pdf.Column{
+		{Title: "HSN/SAC", Width: 90},
+		{Title: "Taxable Value", Width: 90, AlignRight: true},
+		{Title: "CGST", Width: 80, AlignRight: true},
+		{Title: "SGST", Width: 80, AlignRight: true},
+		{Title: "IGST", Width: 80, AlignRight: true},
+		{Title: "Total Tax", Width: 103, AlignRight: true},
+	}
+
+	codes, summary := summarizeHSN(invoice.Lines)
+
+	var hsnRows [][]string
+	for _, code := range codes {
+		row := summary[code]
+		hsnRows = append(hsnRows, []string{
+			code,
+			formatIndianAmount(row.TaxableValue),
+			formatIndianAmount(row.CgstValue),
+			formatIndianAmount(row.SgstValue),
+			formatIndianAmount(row.IgstValue),
+			formatIndianAmount(row.CgstValue + row.SgstValue + row.IgstValue),
+		})
+	}
+
+	page, y = document.Ensure(page, y, 60)
+	page.Text(pdf.Margin, y+10, 9, pdf.Bold, "HSN Summary")
+	page, y = document.Table(page, y+16, hsnColumns, hsnRows)
+
+	drawSignatures(document, page, y, header.CompanyName, "")
+	return document
+}
+
+// renderDeliveryChallan lays out a delivery challan listing the goods of a sales invoice without their prices
+func renderDeliveryChallan(invoice SalesInvoiceDocument) *pdf.Document {
+	document := pdf.New()
+	page := document.AddPage()
+
+	header := invoicePrintHeader(invoice.Lines)
+	y := drawHeading(page, "DELIVERY CHALLAN", invoice.Status, header, nil)
+
+	customer, details := invoiceParties(invoice, "Challan No")
+	customer[0] = "Deliver To"
+	y = drawParties(page, y, customer, details)
+
+	columns := []pdf.Column{
+		{Title: "#", Width: 18},
+		{Title: "Description", Width: 215},
+		{Title: "HSN/SAC", Width: 60},
+		{Title: "Quantity", Width: 70, AlignRight: true},
+		{Title: "Unit", Width: 50},
+		{Title: "Pieces", Width: 60, AlignRight: true},
+		{Title: "Unit", Width: 50},
+	}
+
+	var rows [][]string
+	var totalPcs float64
+	for _, line := range invoice.Lines {
+		uomBig, uomRaw := itemUnits(line.ItemId)
+		totalPcs += line.TotalPcs
+
+		rows = append(rows, []string{
+			fmt.Sprint(line.LineNumber),
+			strings.TrimSpace(line.ItemName + " " + line.ItemVariant),
+			line.HsnCode,
+			formatQuantity(line.BigQuantity),
+			uomBig,
+			formatQuantity(line.TotalPcs),
+			uomRaw,
+		})
+	}
+
+	page, y = document.Table(page, y, columns, rows)
+	page, y = drawTotals(document, page, y, [][2]string{{"Total Pieces", formatQuantity(totalPcs)}})
+	page, y = drawParagraph(document, page, y, pdf.Regular, "The goods listed above are sent for delivery and this challan is not a tax invoice.")
+
+	drawSignatures(document, page, y, header.CompanyName, "Receiver's Signature")
+	return document
+}
+
+// renderGoodsReceivedNote lays out a goods received note for the lines of a bill of entry with their values and landed cost
+func renderGoodsReceivedNote(bill BillOfEntryDocument) *pdf.Document {
+	document := pdf.New()
+	page := document.AddPage()
+
+	warehouseId := ""
+	if len(bill.Lines) > 0 {
+		warehouseId = bill.Lines[0].WarehouseId
+	}
+	header := loadPrintHeader(warehouseId)
+
+	details := [][2]string{
+		{"Bill of Entry No", bill.BillOfEntryNumber},
+		{"Date", bill.BillOfEntryDate},
+		{"Status", bill.Status},
+	}
+	if bill.Currency != BaseCurrency {
+		details = append(details, [2]string{"Currency", fmt.Sprintf("%s @ %g", bill.Currency, bill.ExchangeRate)})
+	}
+
+	y := drawHeading(page, "GOODS RECEIVED NOTE", "", header, nil)
+	y = drawParties(page, y, []string{"Received For", bill.ClientName}, details)
+
+	columns := []pdf.Column{
+		{Title: "#", Width: 18},
+		{Title: "Description", Width: 105},
+		{Title: "HSN", Width: 40},
+		{Title: "Qty", Width: 40, AlignRight: true},
+		{Title: "Pcs", Width: 40, AlignRight: true},
+		{Title: "Assessable", Width: 60, AlignRight: true},
+		{Title: "Duty", Width: 50, AlignRight: true},
+		{Title: "IGST", Width: 50, AlignRight: true},
+		{Title: "Total", Width: 65, AlignRight: true},
+		{Title: "Landed/Pc", Width: 55, AlignRight: true},
+	}
+
+	var rows [][]string
+	for _, line := range bill.Lines {
+		uomBig, _ := itemUnits(line.ItemId)
+
+		rows = append(rows, []string{
+			fmt.Sprint(line.LineNumber),
+			strings.TrimSpace(line.ItemName + " " + line.ItemVariant),
+			line.HsnCode,
+			strings.TrimSpace(formatQuantity(line.BigQuantity) + " " + uomBig),
+			formatQuantity(line.TotalPcs),
+			formatIndianAmount(line.AssdValue),
+			formatIndianAmount(line.DutyValue),
+			formatIndianAmount(line.GstValue),
+			formatIndianAmount(line.TotalValue),
+			fmt.Sprintf("%.4f", line.LandedCostPerPiece),
+		})
+	}
+
+	page, y = document.Table(page, y, columns, rows)
+
+	totals := [][2]string{
+		{"Assessable Value", formatIndianAmount(bill.Totals.AssdValue)},
+		{"Customs Duty", formatIndianAmount(bill.Totals.DutyValue)},
+		{"IGST on Import", formatIndianAmount(bill.Totals.GstValue)},
+		{"Total (" + BaseCurrency + ")", formatIndianAmount(bill.Totals.TotalValue)},
+	}
+	if bill.Currency != BaseCurrency {
+		totals = append([][2]string{{"Invoice Value (" + bill.Currency + ")", formatIndianAmount(bill.Totals.ForeignInvoiceValue)}}, totals...)
+	}
+	page, y = drawTotals(document, page, y, totals)
+
+	drawSignatures(document, page, y, header.CompanyName, "Checked By")
+	return document
+}
+
+// writePDF sends a rendered document inline under a filename
+func writePDF(w http.ResponseWriter, filename string, document *pdf.Document) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s.pdf"`, strings.NewReplacer("/", "-", `"`, "").Replace(filename)))
+
+	if _, err := document.WriteTo(w); err != nil {
+		log.Println(err)
+	}
+}
+
+// PrintTaxInvoice returns the tax invoice of a sales invoice as a PDF
+func PrintTaxInvoice(w http.ResponseWriter, r *http.Request) {
+
+	invoice, err := loadSalesInvoice(db, mux.Vars(r)["id"])
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	writePDF(w, "invoice-"+invoice.SalesInvoiceNumber, renderTaxInvoice(invoice))
+}
+
+// PrintDeliveryChallan returns the delivery challan of a sales invoice as a PDF
+func PrintDeliveryChallan(w http.ResponseWriter, r *http.Request) {
+
+	invoice, err := loadSalesInvoice(db, mux.Vars(r)["id"])
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	writePDF(w, "challan-"+invoice.SalesInvoiceNumber, renderDeliveryChallan(invoice))
+}
+
+// PrintGoodsReceivedNote returns the goods received note of a bill of entry as a PDF
+func PrintGoodsReceivedNote(w http.ResponseWriter, r *http.Request) {
+
+	bill, err := loadBillOfEntry(db, mux.Vars(r)["id"])
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	writePDF(w, "grn-"+bill.BillOfEntryNumber, renderGoodsReceivedNote(bill))
+}
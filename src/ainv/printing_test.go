@@ -0,0 +1,82 @@
+package main
+
+import "testing"
+
+func TestNumberInWords(t *testing.T) {
+	cases := []struct {
+		number int64
+		words  string
+	}{
+		{0, "Zero"},
+		{7, "Seven"},
+		{19, "Nineteen"},
+		{20, "Twenty"},
+		{42, "Forty Two"},
+		{100, "One Hundred"},
+		{101, "One Hundred One"},
+		{1000, "One Thousand"},
+		{99999, "Ninety Nine Thousand Nine Hundred Ninety Nine"},
+		{100000, "One Lakh"},
+		{1234567, "Twelve Lakh Thirty Four Thousand Five Hundred Sixty Seven"},
+		{10000000, "One Crore"},
+		{10000001, "One Crore One"},
+		{1000000000, "One Hundred Crore"},
+		{12345678901, "One Thousand Two Hundred Thirty Four Crore Fifty Six Lakh Seventy Eight Thousand Nine Hundred One"},
+	}
+
+	for _, c := range cases {
+		if words := numberInWords(c.number); words != c.words {
+			t.Errorf("numberInWords(%d) = %q, want %q", c.number, words, c.words)
+		}
+	}
+}
+
+func TestAmountInWords(t *testing.T) {
+	cases := []struct {
+		amount   float64
+		currency string
+		words    string
+	}{
+		{0, BaseCurrency, "Rupees Zero Only"},
+		{100000, BaseCurrency, "Rupees One Lakh Only"},
+		{10000000, BaseCurrency, "Rupees One Crore Only"},
+		{1234567.89, BaseCurrency, "Rupees Twelve Lakh Thirty Four Thousand Five Hundred Sixty Seven and Eighty Nine Paise Only"},
+		{0.5, BaseCurrency, "Rupees Zero and Fifty Paise Only"},
+		{99.999, BaseCurrency, "Rupees One Hundred Only"},
+		{100.5, "USD", "USD One Hundred and Fifty Cents Only"},
+		{-1500.25, BaseCurrency, "Minus Rupees One Thousand Five Hundred and Twenty Five Paise Only"},
+		{-0.001, BaseCurrency, "Rupees Zero Only"},
+	}
+
+	for _, c := range cases {
+		if words := amountInWords(c.amount, c.currency); words != c.words {
+			t.Errorf("amountInWords(%v, %s) = %q, want %q", c.amount, c.currency, words, c.words)
+		}
+	}
+}
+
+func TestFormatIndianAmount(t *testing.T) {
+	cases := []struct {
+		amount    float64
+		formatted string
+	}{
+		{0, "0.00"},
+		{5.5, "5.50"},
+		{123, "123.00"},
+		{1000, "1,000.00"},
+		{100000, "1,00,000.00"},
+		{1234567.89, "12,34,567.89"},
+		{10000000, "1,00,00,000.00"},
+		{123456789012.345, "1,23,45,67,89,012.35"},
+		{99.999, "100.00"},
+		{-1234567.89, "-12,34,567.89"},
+		{-999, "-999.00"},
+		{-0.001, "0.00"},
+	}
+
+	for _, c := range cases {
+		if formatted := formatIndianAmount(c.amount); formatted != c.formatted {
+			t.Errorf("formatIndianAmount(%v) = %q, want %q", c.amount, formatted, c.formatted)
+		}
+	}
+}
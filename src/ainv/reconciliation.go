@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+)
+
+// quantities closer than this are treated as equal, absorbing the float rounding of the running totals
+const driftTolerance = 0.001
+
+// StockDrift is a stock whose running total in inventoryContents disagrees with the replayed ledger
+type StockDrift struct {
+	RunId                     string  `json:"runId"`
+	DetectedAt                string  `json:"detectedAt"`
+	ItemId                    string  `json:"itemId"`
+	ItemName                  string  `json:"itemName"`
+	WarehouseId               string  `json:"warehouseId"`
+	WarehouseName             string  `json:"warehouseName"`
+	ClientId                  string  `json:"clientId"`
+	ClientName                string  `json:"clientName"`
+	LedgerBigcartonQuantity   float64 `json:"ledgerBigcartonQuantity"`
+	LedgerSmallboxQuantity    float64 `json:"ledgerSmallboxQuantity"`
+	LedgerItemQuantity        float64 `json:"ledgerItemQuantity"`
+	ContentsBigcartonQuantity float64 `json:"contentsBigcartonQuantity"`
+	ContentsSmallboxQuantity  float64 `json:"contentsSmallboxQuantity"`
+	ContentsItemQuantity      float64 `json:"contentsItemQuantity"`
+}
+
+// loadInventoryContents reads the running totals keyed by item, warehouse and client
+func loadInventoryContents() map[stockKey]StockBalance {
+	contents := map[stockKey]StockBalance{}
+
+	allContents, err := db.Query(`SELECT itemId, warehouseId, clientId, bigcartonQuantity, smallboxQuantity, itemQuantity FROM inventoryContents`)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allContents.Next() {
+		var balance StockBalance
+
+		err := allContents.Scan(&balance.ItemId, &balance.WarehouseId, &balance.ClientId, &balance.BigcartonQuantity, &balance.SmallboxQuantity, &balance.ItemQuantity)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		contents[stockKey{balance.ItemId, balance.WarehouseId, balance.ClientId}] = balance
+	}
+
+	return contents
+}
+
+// hasDrifted reports whether two balances disagree in any UOM
+func hasDrifted(ledger StockBalance, contents StockBalance) bool {
+	return math.Abs(ledger.BigcartonQuantity-contents.BigcartonQuantity) > driftTolerance ||
+		math.Abs(ledger.SmallboxQuantity-contents.SmallboxQuantity) > driftTolerance ||
+		math.Abs(ledger.ItemQuantity-contents.ItemQuantity) > driftTolerance
+}
+
+// FindStockDrift compares the replayed ledger against inventoryContents and returns every stock that disagrees
+func FindStockDrift() []StockDrift {
+	var drifts []StockDrift
+
+	ledger := map[stockKey]StockBalance{}
+	for _, balance := range replayStockBalances(stockFilter{}) {
+		ledger[stockKey{balance.ItemId, balance.WarehouseId, balance.ClientId}] = balance
+	}
+	contents := loadInventoryContents()
+
+	// stocks present on either side only are compared against zero
+	keys := map[stockKey]bool{}
+	for key := range ledger {
+		keys[key] = true
+	}
+	for key := range contents {
+		keys[key] = true
+	}
+
+	for key := range keys {
+		if !hasDrifted(ledger[key], contents[key]) {
+			continue
+		}
+
+		names := lookupStockNames(key)
+
+		drifts = append(drifts, StockDrift{
+			ItemId:                    key.ItemId,
+			ItemName:                  names.ItemName,
+			WarehouseId:               key.WarehouseId,
+			WarehouseName:             names.WarehouseName,
+			ClientId:                  key.ClientId,
+			ClientName:                names.ClientName,
+			LedgerBigcartonQuantity:   ledger[key].BigcartonQuantity,
+			LedgerSmallboxQuantity:    ledger[key].SmallboxQuantity,
+			LedgerItemQuantity:        ledger[key].ItemQuantity,
+			ContentsBigcartonQuantity: contents[key].BigcartonQuantity,
+			ContentsSmallboxQuantity:  contents[key].SmallboxQuantity,
+			ContentsItemQuantity:      contents[key].ItemQuantity,
+		})
+	}
+
+	return drifts
+}
+
+// RunReconciliation records a reconciliation run along with the drift it found
+func RunReconciliation() (int64, error) {
+	drifts := FindStockDrift()
+
+	runResult, err := db.Exec(fmt.Sprintf(`INSERT INTO reconciliationRun (runAt, driftCount) VALUES (NOW(), '%d')`, len(drifts)))
+	if err != nil {
+		return 0, err
+	}
+	runId, _ := runResult.LastInsertId()
+
+	for _, drift := range drifts {
+		driftInsertQuery := fmt.Sprintf(`INSERT INTO stockDrift
+			(runId, itemId, warehouseId, clientId, ledgerBigcartonQuantity, ledgerSmallboxQuantity, ledgerItemQuantity, contentsBigcartonQuantity, contentsSmallboxQuantity, contentsItemQuantity)
+			VALUES
+			('%d', '%s', '%s', '%s', '%f', '%f', '%f', '%f', '%f', '%f')`, runId, drift.ItemId, drift.WarehouseId, drift.ClientId, drift.LedgerBigcartonQuantity, drift.LedgerSmallboxQuantity, drift.LedgerItemQuantity, drift.ContentsBigcartonQuantity, drift.ContentsSmallboxQuantity, drift.ContentsItemQuantity)
+
+		_, err := db.Exec(driftInsertQuery)
+		if err != nil {
+			return runId, err
+		}
+	}
+
+	log.Printf("Reconciliation run %d found %d drifted stocks", runId, len(drifts))
+	return runId, nil
+}
+
+// StartReconciliationJob runs the reconciliation in the background at the given interval
+func StartReconciliationJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			runScheduledReconciliation()
+		}
+	}()
+}
+
+// runScheduledReconciliation runs one reconciliation without letting a failed query bring the server down
+func runScheduledReconciliation() {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Println("Reconciliation run failed:", recovered)
+		}
+	}()
+
+	if _, err := RunReconciliation(); err != nil {
+		log.Println(err)
+	}
+}
+
+// ReconcileStock triggers a reconciliation run right away and returns the status
+func ReconcileStock(w http.ResponseWriter, r *http.Request) {
+
+	runId, err := RunReconciliation()
+
+	var result map[string]interface{}
+
+	if err != nil {
+		log.Println(err)
+		result = map[string]interface{}{
+			"success": false,
+		}
+	} else {
+		result = map[string]interface{}{
+			"success": true,
+			"runId":   runId,
+		}
+	}
+
+	payloadJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// GetStockDrift returns the drift flagged by a reconciliation run, the latest one by default
+func GetStockDrift(w http.ResponseWriter, r *http.Request) {
+
+	runId := r.FormValue("runId")
+	if runId == "" {
+		runId = "(SELECT MAX(id) FROM reconciliationRun)"
+	} else {
+		runId = fmt.Sprintf("'%s'", runId)
+	}
+
+	var payload []StockDrift
+
+	getDriftQuery := fmt.Sprintf(`SELECT
+		sd.runId, rr.runAt,
+		sd.itemId, IFNULL(im.itemName, 'N/A'),
+		sd.warehouseId, IFNULL(CONCAT(wh.warehouseName, ", ", wh.warehouseLocation), 'N/A'),
+		sd.clientId, IFNULL(cl.clientName, 'N/A'),
+		sd.ledgerBigcartonQuantity, sd.ledgerSmallboxQuantity, sd.ledgerItemQuantity,
+		sd.contentsBigcartonQuantity, sd.contentsSmallboxQuantity, sd.contentsItemQuantity
+		FROM stockDrift sd
+		JOIN reconciliationRun rr ON rr.id = sd.runId
+		LEFT JOIN itemMaster im ON im.id = sd.itemId
+		LEFT JOIN warehouse wh ON wh.id = sd.warehouseId
+		LEFT JOIN client cl ON cl.id = sd.clientId
+		WHERE sd.runId = %s`, runId)
+
+	allDrifts, err := db.Query(getDriftQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allDrifts.Next() {
+		var drift StockDrift
+
+		err := allDrifts.Scan(&drift.RunId, &drift.DetectedAt, &drift.ItemId, &drift.ItemName, &drift.WarehouseId, &drift.WarehouseName, &drift.ClientId, &drift.ClientName, &drift.LedgerBigcartonQuantity, &drift.LedgerSmallboxQuantity, &drift.LedgerItemQuantity, &drift.ContentsBigcartonQuantity, &drift.ContentsSmallboxQuantity, &drift.ContentsItemQuantity)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		payload = append(payload, drift)
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
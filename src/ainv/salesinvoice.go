@@ -0,0 +1,642 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rounakdatta/ainv-backend-go/src/validation"
+)
+
+// the lifecycle of a sales invoice; only issued invoices move stock and count as receivables
+const (
+	InvoiceDraft     = "draft"
+	InvoiceIssued    = "issued"
+	InvoiceCancelled = "cancelled"
+)
+
+// SalesInvoiceLine is an item sold on a sales invoice
+type SalesInvoiceLine struct {
+	LineNumber    int     `json:"lineNumber"`
+	ItemId        string  `json:"itemId"`
+	ItemName      string  `json:"itemName"`
+	ItemVariant   string  `json:"itemVariant"`
+	HsnCode       string  `json:"hsnCode"`
+	WarehouseId   string  `json:"warehouseId"`
+	ClientId      string  `json:"clientId"`
+	BigQuantity   float64 `json:"bigQuantity"`
+	TotalPcs      float64 `json:"totalPcs"`
+	UnitPrice     float64 `json:"unitPrice"`
+	GrossValue    float64 `json:"grossValue"`
+	DiscountValue float64 `json:"discountValue"`
+	TaxableValue  float64 `json:"taxableValue"`
+	GstRate       float64 `json:"gstRate"`
+	GstValue      float64 `json:"gstValue"`
+	CgstValue     float64 `json:"cgstValue"`
+	SgstValue     float64 `json:"sgstValue"`
+	IgstValue     float64 `json:"igstValue"`
+	TotalValue    float64 `json:"totalValue"`
+	TransactionId string  `json:"transactionId"`
+}
+
+// DocumentTotals are the amounts of a document summed over its lines
+type DocumentTotals struct {
+	GrossValue    float64 `json:"grossValue"`
+	DiscountValue float64 `json:"discountValue"`
+	TaxableValue  float64 `json:"taxableValue"`
+	GstValue      float64 `json:"gstValue"`
+	CgstValue     float64 `json:"cgstValue"`
+	SgstValue     float64 `json:"sgstValue"`
+	IgstValue     float64 `json:"igstValue"`
+	TotalValue    float64 `json:"totalValue"`
+}
+
+// SalesInvoiceDocument is a sales invoice header with its lines and totals
+type SalesInvoiceDocument struct {
+	SalesInvoiceId     string             `json:"salesInvoiceId"`
+	SalesInvoiceNumber string             `json:"salesInvoiceNumber"`
+	SalesInvoiceDate   string             `json:"salesInvoiceDate"`
+	CustomerId         string             `json:"customerId"`
+	CustomerName       string             `json:"customerName"`
+	PlaceOfSupply      string             `json:"placeOfSupply"`
+	Currency           string             `json:"currency"`
+	ExchangeRate       float64            `json:"exchangeRate"`
+	DiscountValue      float64            `json:"discountValue"`
+	Status             string             `json:"status"`
+	Remarks            string             `json:"remarks"`
+	Lines              []SalesInvoiceLine `json:"lines"`
+	Totals             DocumentTotals     `json:"totals"`
+	PaidAmount         float64            `json:"paidAmount"`
+	Outstanding        float64            `json:"outstanding"`
+	Irn                string             `json:"irn"`
+	AckNumber          string             `json:"ackNumber"`
+	AckDate            string             `json:"ackDate"`
+	SignedQrCode       string             `json:"signedQrCode"`
+	EwayBillNumber     string             `json:"ewayBillNumber"`
+	EwayBillDate       string             `json:"ewayBillDate"`
+	EwayBillValidUpto  string             `json:"ewayBillValidUpto"`
+}
+
+// parseSalesInvoiceLines reads the lines of an invoice, sent as a JSON array in a form value
+func parseSalesInvoiceLines(raw string) ([]SalesInvoiceLine, error) {
+	var lines []SalesInvoiceLine
+
+	if err := json.Unmarshal([]byte(raw), &lines); err != nil {
+		return nil, fmt.Errorf("lines must be a JSON array: %v", err)
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("an invoice needs at least one line")
+	}
+
+	for i, line := range lines {
+		if line.ItemId == "" || line.WarehouseId == "" || line.ClientId == "" {
+			return nil, fmt.Errorf("line %d must name an itemId, warehouseId and clientId", i+1)
+		}
+		if line.BigQuantity <= 0 {
+			return nil, fmt.Errorf("line %d must have a positive bigQuantity", i+1)
+		}
+		if line.UnitPrice < 0 || line.DiscountValue < 0 {
+			return nil, fmt.Errorf("line %d cannot have a negative unitPrice or discountValue", i+1)
+		}
+		if line.GstRate < 0 || line.GstRate > 100 {
+			return nil, fmt.Errorf("line %d has a GST rate of %g%%", i+1, line.GstRate)
+		}
+
+		lines[i].LineNumber = i + 1
+	}
+
+	return lines, nil
+}
+
+// priceInvoiceLines works out the taxable value, GST and total of every line, spreading the invoice discount over the lines in proportion to their value
+func priceInvoiceLines(lines []SalesInvoiceLine, invoiceDiscount float64) error {
+	var netTotal float64
+
+	for i := range lines {
+		lines[i].GrossValue = roundPaise(lines[i].TotalPcs * lines[i].UnitPrice)
+		if lines[i].DiscountValue > lines[i].GrossValue {
+			return fmt.Errorf("line %d has a discount larger than its value", lines[i].LineNumber)
+		}
+
+		netTotal += lines[i].GrossValue - lines[i].DiscountValue
+	}
+
+	if invoiceDiscount > netTotal+paymentTolerance {
+		return fmt.Errorf("discount of %.2f exceeds the invoice value of %.2f", invoiceDiscount, netTotal)
+	}
+
+	// the last line takes whatever is left of the discount so that the shares add up to it exactly
+	remaining := invoiceDiscount
+	for i := range lines {
+		share := remaining
+		if i < len(lines)-1 && netTotal > 0 {
+			share = roundPaise(invoiceDiscount * (lines[i].GrossValue - lines[i].DiscountValue) / netTotal)
+		}
+		remaining = roundPaise(remaining - share)
+
+		lines[i].DiscountValue = roundPaise(lines[i].DiscountValue + share)
+		lines[i].TaxableValue = roundPaise(lines[i].GrossValue - lines[i].DiscountValue)
+		lines[i].GstValue = roundPaise(lines[i].TaxableValue * lines[i].GstRate / 100)
+		lines[i].TotalValue = roundPaise(lines[i].TaxableValue + lines[i].GstValue)
+	}
+
+	return nil
+}
+
+// sumDocumentLines adds up the amounts of the lines of a document
+func sumDocumentLines(lines []SalesInvoiceLine) DocumentTotals {
+	var totals DocumentTotals
+
+	for _, line := range lines {
+		totals.GrossValue = roundPaise(totals.GrossValue + line.GrossValue)
+		totals.DiscountValue = roundPaise(totals.DiscountValue + line.DiscountValue)
+		totals.TaxableValue = roundPaise(totals.TaxableValue + line.TaxableValue)
+		totals.GstValue = roundPaise(totals.GstValue + line.GstValue)
+		totals.CgstValue = roundPaise(totals.CgstValue + line.CgstValue)
+		totals.SgstValue = roundPaise(totals.SgstValue + line.SgstValue)
+		totals.IgstValue = roundPaise(totals.IgstValue + line.IgstValue)
+		totals.TotalValue = roundPaise(totals.TotalValue + line.TotalValue)
+	}
+
+	return totals
+}
+
+// loadSalesInvoice reads a sales invoice with its lines; invoices raised line by line through CreateTransaction show their transactions as lines
+func loadSalesInvoice(q querier, salesInvoiceId string) (SalesInvoiceDocument, error) {
+	var invoice SalesInvoiceDocument
+
+	headerQuery := fmt.Sprintf(`SELECT
+		si.id, si.tracker, si.entryDate, si.customerId, IFNULL(cu.customerName, 'N/A'),
+		IFNULL(si.placeOfSupply, IFNULL(cu.placeOfSupply, '')), si.currency, si.exchangeRate, si.discountValue, si.status, si.remarks,
+		IFNULL(si.irn, ''), IFNULL(si.ackNumber, ''), IFNULL(si.ackDate, ''), IFNULL(si.signedQrCode, ''),
+		IFNULL(si.ewayBillNumber, ''), IFNULL(si.ewayBillDate, ''), IFNULL(si.ewayBillValidUpto, '')
+		FROM salesInvoice si
+		LEFT JOIN customer cu ON cu.id = si.customerId
+		WHERE si.id = '%s'`, salesInvoiceId)
+
+	err := q.QueryRow(headerQuery).Scan(&invoice.SalesInvoiceId, &invoice.SalesInvoiceNumber, &invoice.SalesInvoiceDate, &invoice.CustomerId, &invoice.CustomerName, &invoice.PlaceOfSupply, &invoice.Currency, &invoice.ExchangeRate, &invoice.DiscountValue, &invoice.Status, &invoice.Remarks, &invoice.Irn, &invoice.AckNumber, &invoice.AckDate, &invoice.SignedQrCode, &invoice.EwayBillNumber, &invoice.EwayBillDate, &invoice.EwayBillValidUpto)
+	if err == sql.ErrNoRows {
+		return invoice, fmt.Errorf("sales invoice %s does not exist", salesInvoiceId)
+	}
+	if err != nil {
+		return invoice, err
+	}
+
+	linesQuery := fmt.Sprintf(`SELECT
+		sl.lineNumber, sl.itemId, IFNULL(im.itemName, 'N/A'), IFNULL(im.itemVariant, ''), IFNULL(im.hsnCode, ''),
+		sl.warehouseId, sl.clientId, sl.bigQuantity, sl.totalPcs, sl.unitPrice, sl.discountValue, sl.taxableValue,
+		sl.gstRate, sl.gstValue, sl.cgstValue, sl.sgstValue, sl.igstValue, sl.totalValue, IFNULL(sl.transactionId, '')
+		FROM salesInvoiceLine sl
+		LEFT JOIN itemMaster im ON im.id = sl.itemId
+		WHERE sl.salesInvoiceId = '%s'
+		ORDER BY sl.lineNumber`, salesInvoiceId)
+
+	invoice.Lines, err = scanSalesInvoiceLines(q, linesQuery)
+	if err != nil {
+		return invoice, err
+	}
+
+	if len(invoice.Lines) == 0 {
+		transactionLinesQuery := fmt.Sprintf(`SELECT
+			0, tr.itemId, IFNULL(im.itemName, 'N/A'), IFNULL(im.itemVariant, ''), IFNULL(im.hsnCode, ''),
+			tr.warehouseId, tr.clientId, tr.bigQuantity, tr.totalPcs, IFNULL(tr.valuePerPiece, 0), 0, tr.assdValue,
+			IF(tr.assdValue > 0, ROUND(tr.gstValue * 100 / tr.assdValue, 2), 0), tr.gstValue, tr.cgstValue, tr.sgstValue, tr.igstValue, tr.totalValue, tr.id
+			FROM transaction tr
+			LEFT JOIN itemMaster im ON im.id = tr.itemId
+			WHERE tr.salesInvoice = '%s' AND tr.isError = 0
+			ORDER BY tr.id`, salesInvoiceId)
+
+		invoice.Lines, err = scanSalesInvoiceLines(q, transactionLinesQuery)
+		if err != nil {
+			return invoice, err
+		}
+
+		for i := range invoice.Lines {
+			invoice.Lines[i].LineNumber = i + 1
+		}
+	}
+
+	invoice.Totals = sumDocumentLines(invoice.Lines)
+
+	paidQuery := fmt.Sprintf(`SELECT IFNULL(SUM(paidAmount), 0) FROM transaction WHERE salesInvoice = '%s' AND isError = 0`, salesInvoiceId)
+	if err := q.QueryRow(paidQuery).Scan(&invoice.PaidAmount); err != nil {
+		return invoice, err
+	}
+
+	if invoice.Status == InvoiceIssued {
+		invoice.Outstanding = roundPaise(invoice.Totals.TotalValue - invoice.PaidAmount)
+	}
+
+	return invoice, nil
+}
+
+// scanSalesInvoiceLines reads invoice lines selected in the column order of salesInvoiceLine
+func scanSalesInvoiceLines(q querier, linesQuery string) ([]SalesInvoiceLine, error) {
+	var lines []SalesInvoiceLine
+
+	allLines, err := q.Query(linesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer allLines.Close()
+
+	for allLines.Next() {
+		var line SalesInvoiceLine
+
+		err := allLines.Scan(&line.LineNumber, &line.ItemId, &line.ItemName, &line.ItemVariant, &line.HsnCode, &line.WarehouseId, &line.ClientId, &line.BigQuantity, &line.TotalPcs, &line.UnitPrice, &line.DiscountValue, &line.TaxableValue, &line.GstRate, &line.GstValue, &line.CgstValue, &line.SgstValue, &line.IgstValue, &line.TotalValue, &line.TransactionId)
+		if err != nil {
+			return nil, err
+		}
+
+		line.GrossValue = roundPaise(line.TotalPcs * line.UnitPrice)
+		lines = append(lines, line)
+	}
+
+	return lines, allLines.Err()
+}
+
+// issueSalesInvoice posts every line of an invoice as an outbound transaction and takes the stock out of the warehouses
+func issueSalesInvoice(tx *sql.Tx, invoice SalesInvoiceDocument) ([]int64, error) {
+	var transactionIds []int64
+
+	for _, line := range invoice.Lines {
+		rates, err := lookupItemRates(tx, line.ItemId)
+		if err != nil {
+			return nil, err
+		}
+
+		change, err := postInventoryChange(tx, line.ItemId, line.WarehouseId, line.ClientId, "out", line.BigQuantity, rates)
+		if err != nil {
+			return nil, err
+		}
+
+		transactionQuery := fmt.Sprintf(`INSERT INTO transaction
+		(billOfEntry, salesInvoice, itemId, warehouseId, comeOrGo, clientId, customerId, bigQuantity, currentValue, changeValue, finalValue, secretRate1, secretRate2, totalPcs, assdValue, dutyValue, gstValue, cgstValue, sgstValue, igstValue, placeOfSupply, totalValue, currency, exchangeRate, valuePerPiece, totalPieces, isPaid, paidAmount, paymentStatus, date, delvDate1, delvDate2, remarks)
+		VALUES
+		(NULL, '%s', '%s', '%s', 'out', '%s', '%s', '%f', '%f', '%f', '%f', '%f', '%f', '%f', '%.2f', '0', '%.2f', '%.2f', '%.2f', '%.2f', NULLIF('%s', ''), '%.2f', '%s', '%f', '%f', '%f', false, '0', '%s', NULL, '', '', '%s')`, invoice.SalesInvoiceId, line.ItemId, line.WarehouseId, line.ClientId, invoice.CustomerId, line.BigQuantity, change.CurrentValue, change.ChangeValue, change.FinalValue, rates.SmallPerBig, rates.RawPerSmall, line.TotalPcs, line.TaxableValue, line.GstValue, line.CgstValue, line.SgstValue, line.IgstValue, invoice.PlaceOfSupply, line.TotalValue, invoice.Currency, invoice.ExchangeRate, line.UnitPrice, line.TotalPcs, PaymentUnpaid, escapeQuotes(invoice.Remarks))
+
+		transactionResult, err := tx.Exec(transactionQuery)
+		if err != nil {
+			return nil, err
+		}
+		transactionId, _ := transactionResult.LastInsertId()
+		transactionIds = append(transactionIds, transactionId)
+
+		lineUpdateQuery := fmt.Sprintf(`UPDATE salesInvoiceLine SET transactionId = '%d' WHERE salesInvoiceId = '%s' AND lineNumber = '%d'`, transactionId, invoice.SalesInvoiceId, line.LineNumber)
+		if _, err := tx.Exec(lineUpdateQuery); err != nil {
+			return nil, err
+		}
+	}
+
+	return transactionIds, setSalesInvoiceStatus(tx, invoice.SalesInvoiceId, InvoiceIssued)
+}
+
+// cancelSalesInvoice puts the stock of an issued invoice back and marks its transactions as errors, refusing invoices
+// already paid against or registered with the government, whose IRN or e-way bill would otherwise stay live
+func cancelSalesInvoice(tx *sql.Tx, invoice SalesInvoiceDocument) error {
+	if invoice.PaidAmount > paymentTolerance {
+		return fmt.Errorf("sales invoice %s has %.2f received against it", invoice.SalesInvoiceNumber, invoice.PaidAmount)
+	}
+	if invoice.Irn != "" {
+		return fmt.Errorf("sales invoice %s is registered with IRN %s and must be cancelled on the invoice registration portal", invoice.SalesInvoiceNumber, invoice.Irn)
+	}
+	if invoice.EwayBillNumber != "" {
+		return fmt.Errorf("sales invoice %s has e-way bill %s which must be cancelled on the e-way bill portal", invoice.SalesInvoiceNumber, invoice.EwayBillNumber)
+	}
+
+	transactionsQuery := fmt.Sprintf(`SELECT id, itemId, warehouseId, clientId, bigQuantity, secretRate1, secretRate2
+		FROM transaction WHERE salesInvoice = '%s' AND comeOrGo = 'out' AND isError = 0`, invoice.SalesInvoiceId)
+
+	allTransactions, err := tx.Query(transactionsQuery)
+	if err != nil {
+		return err
+	}
+
+	type postedLine struct {
+		transactionId string
+		itemId        string
+		warehouseId   string
+		clientId      string
+		bigQuantity   float64
+		rates         ItemRates
+	}
+	var posted []postedLine
+
+	for allTransactions.Next() {
+		var line postedLine
+
+		err := allTransactions.Scan(&line.transactionId, &line.itemId, &line.warehouseId, &line.clientId, &line.bigQuantity, &line.rates.SmallPerBig, &line.rates.RawPerSmall)
+		if err != nil {
+			allTransactions.Close()
+			return err
+		}
+
+		posted = append(posted, line)
+	}
+	allTransactions.Close()
+
+	for _, line := range posted {
+		if _, err := postInventoryChange(tx, line.itemId, line.warehouseId, line.clientId, "in", line.bigQuantity, line.rates); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf(`UPDATE transaction SET isError = 1 WHERE id = '%s'`, line.transactionId)); err != nil {
+			return err
+		}
+	}
+
+	return setSalesInvoiceStatus(tx, invoice.SalesInvoiceId, InvoiceCancelled)
+}
+
+// setSalesInvoiceStatus moves a sales invoice to a new status
+func setSalesInvoiceStatus(tx *sql.Tx, salesInvoiceId string, status string) error {
+	_, err := tx.Exec(fmt.Sprintf(`UPDATE salesInvoice SET status = '%s' WHERE id = '%s'`, status, salesInvoiceId))
+	return err
+}
+
+// checkInvoiceCredit holds an invoice that takes the customer past its credit limit unless the customer is only flagged or an authorized user overrides it
+func checkInvoiceCredit(r *http.Request, invoice SalesInvoiceDocument) (CreditCheck, *CreditOverride, error) {
+	creditCheck, err := CheckCreditLimit(invoice.CustomerId, invoice.Totals.TotalValue*invoice.ExchangeRate)
+	if err != nil {
+		return creditCheck, nil, err
+	}
+
+	if !creditCheck.Exceeded || creditCheck.Policy == CreditPolicyFlag {
+		return creditCheck, nil, nil
+	}
+
+	override, err := authorizeCreditOverride(r.FormValue("overrideUsername"), r.FormValue("overridePassword"), r.FormValue("overrideReason"))
+	if err != nil {
+		return creditCheck, nil, fmt.Errorf("%s: outstanding %.2f plus %.2f exceeds the credit limit of %.2f", err.Error(), creditCheck.Outstanding, creditCheck.NewValue, creditCheck.CreditLimit)
+	}
+
+	return creditCheck, &override, nil
+}
+
+// issueWithCreditCheck issues an invoice inside the database transaction, noting any credit limit it went past
+func issueWithCreditCheck(r *http.Request, tx *sql.Tx, invoice SalesInvoiceDocument) error {
+	creditCheck, creditOverride, err := checkInvoiceCredit(r, invoice)
+	if err != nil {
+		return err
+	}
+
+	transactionIds, err := issueSalesInvoice(tx, invoice)
+	if err != nil {
+		return err
+	}
+
+	if creditCheck.Exceeded {
+		return recordCreditException(tx, transactionIds[0], creditCheck, creditOverride)
+	}
+
+	return nil
+}
+
+// writeSalesInvoice writes a sales invoice document as JSON
+func writeSalesInvoice(w http.ResponseWriter, salesInvoiceId string) {
+	invoice, err := loadSalesInvoice(db, salesInvoiceId)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	payloadJSON, err := json.Marshal(invoice)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// CreateSalesInvoice creates a sales invoice with all its lines in one go, as a draft or issued right away, and returns it
+func CreateSalesInvoice(w http.ResponseWriter, r *http.Request) {
+
+	invoice := SalesInvoiceDocument{
+		SalesInvoiceNumber: strings.TrimSpace(r.FormValue("salesInvoiceNumber")),
+		SalesInvoiceDate:   r.FormValue("salesInvoiceDate"),
+		CustomerId:         r.FormValue("customerId"),
+		PlaceOfSupply:      r.FormValue("placeOfSupply"),
+		Currency:           NormalizeCurrency(r.FormValue("currency")),
+		Status:             r.FormValue("status"),
+		Remarks:            r.FormValue("remarks"),
+	}
+
+	if invoice.Status == "" {
+		invoice.Status = InvoiceDraft
+	}
+	if invoice.Status != InvoiceDraft && invoice.Status != InvoiceIssued {
+		writeFailure(w, fmt.Sprintf("a new invoice must be %s or %s", InvoiceDraft, InvoiceIssued))
+		return
+	}
+	if invoice.SalesInvoiceDate == "" || invoice.CustomerId == "" {
+		writeFailure(w, "salesInvoiceDate and customerId are required")
+		return
+	}
+
+	if discount := r.FormValue("discountValue"); discount != "" {
+		discountNum, err := strconv.ParseFloat(discount, 64)
+		if err != nil || discountNum < 0 {
+			writeFailure(w, "discountValue must be a non-negative amount")
+			return
+		}
+		invoice.DiscountValue = roundPaise(discountNum)
+	}
+
+	if invoice.PlaceOfSupply != "" {
+		if _, ok := validation.StateCodes[invoice.PlaceOfSupply]; !ok {
+			writeFailure(w, fmt.Sprintf("place of supply %q is not a known state code", invoice.PlaceOfSupply))
+			return
+		}
+	}
+
+	lines, err := parseSalesInvoiceLines(r.FormValue("lines"))
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	invoice.ExchangeRate, err = resolveExchangeRate(invoice.Currency, r.FormValue("exchangeRate"), invoice.SalesInvoiceDate)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	warehouseIds := make([]string, len(lines))
+	for i, line := range lines {
+		warehouseIds[i] = line.WarehouseId
+	}
+	if _, err := sellerGstin(tx, warehouseIds); err != nil {
+		tx.Rollback()
+		writeFailure(w, err.Error())
+		return
+	}
+
+	// without a number of its own the invoice takes the next one from the series of the warehouse of its first line
+	if invoice.SalesInvoiceNumber == "" {
+		invoice.SalesInvoiceNumber, err = allocateDocumentNumber(tx, lines[0].WarehouseId, DocumentSalesInvoice, invoice.SalesInvoiceDate)
+		if err != nil {
+			tx.Rollback()
+			writeFailure(w, err.Error())
+			return
+		}
+	}
+
+	var existing int
+	tx.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM salesInvoice WHERE tracker = '%s'`, invoice.SalesInvoiceNumber)).Scan(&existing)
+	if existing > 0 {
+		tx.Rollback()
+		writeFailure(w, fmt.Sprintf("sales invoice %s already exists", invoice.SalesInvoiceNumber))
+		return
+	}
+
+	for i := range lines {
+		rates, err := lookupItemRates(tx, lines[i].ItemId)
+		if err != nil {
+			tx.Rollback()
+			writeFailure(w, err.Error())
+			return
+		}
+		lines[i].TotalPcs = lines[i].BigQuantity * rates.SmallPerBig * rates.RawPerSmall
+	}
+
+	if err := priceInvoiceLines(lines, invoice.DiscountValue); err != nil {
+		tx.Rollback()
+		writeFailure(w, err.Error())
+		return
+	}
+
+	// the place of supply not given falls back to the customer, or to the warehouse of the first line
+	for i, line := range lines {
+		gstSplit := ComputeGSTSplit(tx, "out", line.WarehouseId, invoice.CustomerId, invoice.PlaceOfSupply, fmt.Sprintf("%.2f", line.GstValue))
+		if invoice.PlaceOfSupply == "" {
+			invoice.PlaceOfSupply = gstSplit.PlaceOfSupply
+		}
+
+		lines[i].CgstValue = gstSplit.CgstValue
+		lines[i].SgstValue = gstSplit.SgstValue
+		lines[i].IgstValue = gstSplit.IgstValue
+	}
+
+	headerInsertQuery := fmt.Sprintf(`INSERT INTO salesInvoice
+		(tracker, entryDate, customerId, status, placeOfSupply, currency, exchangeRate, discountValue, remarks)
+		VALUES
+		('%s', '%s', '%s', '%s', NULLIF('%s', ''), '%s', '%f', '%.2f', '%s')`, invoice.SalesInvoiceNumber, invoice.SalesInvoiceDate, invoice.CustomerId, InvoiceDraft, invoice.PlaceOfSupply, invoice.Currency, invoice.ExchangeRate, invoice.DiscountValue, escapeQuotes(invoice.Remarks))
+
+	headerResult, err := tx.Exec(headerInsertQuery)
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		writeFailure(w, err.Error())
+		return
+	}
+	salesInvoiceId, _ := headerResult.LastInsertId()
+	invoice.SalesInvoiceId = strconv.FormatInt(salesInvoiceId, 10)
+
+	for _, line := range lines {
+		lineInsertQuery := fmt.Sprintf(`INSERT INTO salesInvoiceLine
+			(salesInvoiceId, lineNumber, itemId, warehouseId, clientId, bigQuantity, totalPcs, unitPrice, discountValue, taxableValue, gstRate, gstValue, cgstValue, sgstValue, igstValue, totalValue)
+			VALUES
+			('%d', '%d', '%s', '%s', '%s', '%f', '%f', '%f', '%.2f', '%.2f', '%.2f', '%.2f', '%.2f', '%.2f', '%.2f', '%.2f')`, salesInvoiceId, line.LineNumber, line.ItemId, line.WarehouseId, line.ClientId, line.BigQuantity, line.TotalPcs, line.UnitPrice, line.DiscountValue, line.TaxableValue, line.GstRate, line.GstValue, line.CgstValue, line.SgstValue, line.IgstValue, line.TotalValue)
+
+		if _, err := tx.Exec(lineInsertQuery); err != nil {
+			log.Println(err)
+			tx.Rollback()
+			writeFailure(w, err.Error())
+			return
+		}
+	}
+
+	invoice.Lines = lines
+	invoice.Totals = sumDocumentLines(lines)
+
+	if invoice.Status == InvoiceIssued {
+		if err := issueWithCreditCheck(r, tx, invoice); err != nil {
+			log.Println(err)
+			tx.Rollback()
+			writeFailure(w, err.Error())
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	writeSalesInvoice(w, invoice.SalesInvoiceId)
+}
+
+// UpdateSalesInvoiceStatus issues a draft invoice, or cancels a draft or issued one, and returns it
+func UpdateSalesInvoiceStatus(w http.ResponseWriter, r *http.Request) {
+
+	salesInvoiceId := r.FormValue("salesInvoiceId")
+	status := r.FormValue("status")
+
+	tx, err := db.Begin()
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	// the header is locked so that two requests cannot both issue or cancel the same invoice
+	var current string
+	err = tx.QueryRow(fmt.Sprintf(`SELECT status FROM salesInvoice WHERE id = '%s' FOR UPDATE`, salesInvoiceId)).Scan(&current)
+	if err != nil {
+		tx.Rollback()
+		writeFailure(w, fmt.Sprintf("sales invoice %s does not exist", salesInvoiceId))
+		return
+	}
+
+	invoice, err := loadSalesInvoice(tx, salesInvoiceId)
+	if err != nil {
+		tx.Rollback()
+		writeFailure(w, err.Error())
+		return
+	}
+
+	switch {
+	case current == InvoiceDraft && status == InvoiceIssued:
+		err = issueWithCreditCheck(r, tx, invoice)
+	case current == InvoiceDraft && status == InvoiceCancelled:
+		err = setSalesInvoiceStatus(tx, salesInvoiceId, InvoiceCancelled)
+	case current == InvoiceIssued && status == InvoiceCancelled:
+		err = cancelSalesInvoice(tx, invoice)
+	default:
+		err = fmt.Errorf("a %s invoice cannot be moved to %q", current, status)
+	}
+
+	if err != nil {
+		log.Println(err)
+		tx.Rollback()
+		writeFailure(w, err.Error())
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	writeSalesInvoice(w, salesInvoiceId)
+}
+
+// GetSalesInvoice returns a sales invoice with its lines and totals
+func GetSalesInvoice(w http.ResponseWriter, r *http.Request) {
+
+	writeSalesInvoice(w, mux.Vars(r)["id"])
+}
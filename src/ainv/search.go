@@ -0,0 +1,424 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// the kinds of record the global search finds
+const (
+	SearchItem         = "item"
+	SearchCustomer     = "customer"
+	SearchClient       = "client"
+	SearchBillOfEntry  = "billOfEntry"
+	SearchSalesInvoice = "salesInvoice"
+)
+
+var searchTypes = []string{SearchItem, SearchCustomer, SearchClient, SearchBillOfEntry, SearchSalesInvoice}
+
+// defaultSearchLimit and maxSearchLimit bound how many results the global search returns
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// how well a text matches the query, from an exact match down to one with a few typos
+const (
+	scoreExact       = 100
+	scorePrefix      = 90
+	scoreWordPrefix  = 75
+	scoreContains    = 60
+	scoreAllWords    = 50
+	scoreFuzzy       = 30
+	scorePerTypo     = 5
+	minFuzzyWordSize = 4
+)
+
+// SearchResult is a record found by the global search, ranked by its score
+type SearchResult struct {
+	Type         string `json:"type"`
+	Id           string `json:"id"`
+	Title        string `json:"title"`
+	Subtitle     string `json:"subtitle"`
+	MatchedField string `json:"matchedField"`
+	Score        int    `json:"score"`
+}
+
+// searchCandidate is a record that may match the query, with the fields it can be found by
+type searchCandidate struct {
+	result SearchResult
+	fields [][2]string
+}
+
+// normalizeSearchText lowercases text and turns everything but letters and digits into single spaces
+func normalizeSearchText(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	}), " ")
+}
+
+// editDistance is the number of single character insertions, deletions and substitutions between two words
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = current[j-1] + 1
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if previous[j-1]+cost < current[j] {
+				current[j] = previous[j-1] + cost
+			}
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+// allowedTypos is how many typos a query word may carry, none for the short ones that would match nearly anything nor
+// for numbers, which are a different document when a digit is off
+func allowedTypos(word string) int {
+	switch size := len([]rune(word)); {
+	case size < minFuzzyWordSize || isNumber(word):
+		return 0
+	case size < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// wordTypos is the fewest typos between a query word and a word of the text, or its start of the same length so that
+// a misspelt prefix still matches; ok is false when every word is further away than the query word allows
+func wordTypos(queryWord string, words []string) (int, bool) {
+	best, found := 0, false
+	for _, word := range words {
+		typos := editDistance(queryWord, word)
+		if runes := []rune(word); len(runes) > len([]rune(queryWord)) {
+			if prefixTypos := editDistance(queryWord, string(runes[:len([]rune(queryWord))])); prefixTypos < typos {
+				typos = prefixTypos
+			}
+		}
+		if typos <= allowedTypos(queryWord) && (!found || typos < best) {
+			best, found = typos, true
+		}
+	}
+
+	return best, found
+}
+
+// isNumber tells whether a word is made of digits only
+func isNumber(word string) bool {
+	return strings.Trim(word, "0123456789") == ""
+}
+
+// startsAnyWord tells whether a query word starts one of the words of a text, a number also starting a zero padded one
+// so that 42 finds the 00042 of a document number
+func startsAnyWord(queryWord string, words []string) bool {
+	numeric := isNumber(queryWord)
+	for _, word := range words {
+		if strings.HasPrefix(word, queryWord) || (numeric && strings.HasPrefix(strings.TrimLeft(word, "0"), queryWord)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchScore scores a normalized text against a normalized query, 0 meaning it does not match at all
+func matchScore(query, text string) int {
+	if query == "" || text == "" {
+		return 0
+	}
+
+	switch {
+	case text == query:
+		return scoreExact
+	case strings.HasPrefix(text, query):
+		return scorePrefix
+	case strings.Contains(" "+text, " "+query):
+		return scoreWordPrefix
+	case isNumber(query) && startsAnyWord(query, strings.Fields(text)):
+		return scoreWordPrefix
+	case strings.Contains(text, query):
+		return scoreContains
+	}
+
+	queryWords := strings.Fields(query)
+	words := strings.Fields(text)
+
+	allPrefixes := true
+	for _, queryWord := range queryWords {
+		if !startsAnyWord(queryWord, words) {
+			allPrefixes = false
+			break
+		}
+	}
+	if allPrefixes {
+		return scoreAllWords
+	}
+
+	totalTypos := 0
+	for _, queryWord := range queryWords {
+		if startsAnyWord(queryWord, words) {
+			continue
+		}
+		typos, ok := wordTypos(queryWord, words)
+		if !ok {
+			return 0
+		}
+		totalTypos += typos
+	}
+
+	return scoreFuzzy - totalTypos*scorePerTypo
+}
+
+// scoreCandidate fills in the best score of the candidate over its fields and the field it came from
+func scoreCandidate(query string, candidate searchCandidate) (SearchResult, bool) {
+	result := candidate.result
+	for _, field := range candidate.fields {
+		if score := matchScore(query, normalizeSearchText(field[1])); score > result.Score {
+			result.Score = score
+			result.MatchedField = field[0]
+		}
+	}
+
+	return result, result.Score > 0
+}
+
+// searchPieces splits every word of a normalized query into one more piece than the typos it may carry; whatever the
+// word matches, exactly, by prefix, past the zeros of a number or with its typos, holds at least one of those pieces
+// as it is, since every typo breaks no more than one of them
+func searchPieces(query string) [][]string {
+	var pieces [][]string
+	for _, word := range strings.Fields(query) {
+		runes := []rune(word)
+		count := allowedTypos(word) + 1
+
+		var wordPieces []string
+		for i := 0; i < count; i++ {
+			wordPieces = append(wordPieces, string(runes[i*len(runes)/count:(i+1)*len(runes)/count]))
+		}
+		pieces = append(pieces, wordPieces)
+	}
+
+	return pieces
+}
+
+// searchPrefilter narrows the records read to those whose text, an SQL expression, holds a piece of every query word,
+// so that neither masters nor documents are scored one by one in full
+func searchPrefilter(query string, text string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for _, wordPieces := range searchPieces(query) {
+		var alternatives []string
+		for _, piece := range wordPieces {
+			alternatives = append(alternatives, text+" LIKE ?")
+			args = append(args, "%"+likePattern(piece)+"%")
+		}
+		conditions = append(conditions, "("+strings.Join(alternatives, " OR ")+")")
+	}
+
+	if len(conditions) == 0 {
+		return "false", nil
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// searchTexts is the text of the records of each type that the prefilter looks in
+var searchTexts = map[string]string{
+	SearchItem:         "CONCAT_WS(' ', itemName, itemVariant, hsnCode)",
+	SearchCustomer:     "customerName",
+	SearchClient:       "clientName",
+	SearchBillOfEntry:  "tracker",
+	SearchSalesInvoice: "tracker",
+}
+
+// candidateLoader reads the records of a type that a normalized query may match
+type candidateLoader func(searchType, query string) ([]searchCandidate, error)
+
+// loadSearchCandidates reads from the database the records of a type that the query may match
+func loadSearchCandidates(searchType, query string) ([]searchCandidate, error) {
+	prefilter, args := searchPrefilter(query, searchTexts[searchType])
+
+	var candidatesQuery string
+	switch searchType {
+	case SearchItem:
+		candidatesQuery = `SELECT id, itemName, IFNULL(itemVariant, ''), IFNULL(hsnCode, '') FROM itemMaster WHERE ` + prefilter
+	case SearchCustomer:
+		candidatesQuery = `SELECT id, customerName, IFNULL(city, ''), IFNULL(gstin, '') FROM customer WHERE ` + prefilter
+	case SearchClient:
+		candidatesQuery = `SELECT id, clientName, '', '' FROM client WHERE ` + prefilter
+	case SearchBillOfEntry:
+		candidatesQuery = `SELECT id, tracker, IFNULL(entryDate, ''), '' FROM billOfEntry WHERE ` + prefilter
+	case SearchSalesInvoice:
+		candidatesQuery = `SELECT id, tracker, IFNULL(entryDate, ''),
+			IFNULL((SELECT customerName FROM customer WHERE customer.id = salesInvoice.customerId), '')
+			FROM salesInvoice WHERE ` + prefilter
+	}
+
+	rows, err := db.Query(candidatesQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []searchCandidate
+	for rows.Next() {
+		var id, name, detail, extra string
+		if err := rows.Scan(&id, &name, &detail, &extra); err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, newSearchCandidate(searchType, id, name, detail, extra))
+	}
+
+	return candidates, rows.Err()
+}
+
+// newSearchCandidate lays out a record read for the search with the fields it can be found by
+func newSearchCandidate(searchType, id, name, detail, extra string) searchCandidate {
+	candidate := searchCandidate{result: SearchResult{Type: searchType, Id: id, Title: name}}
+	switch searchType {
+	case SearchItem:
+		candidate.result.Subtitle = detail
+		if extra != "" {
+			candidate.result.Subtitle = strings.Trim(detail+" · HSN "+extra, " ·")
+		}
+		// the name and variant together let a query such as "bolt m8" span both
+		candidate.fields = [][2]string{{"itemName", name}, {"itemName", name + " " + detail}, {"itemVariant", detail}, {"hsnCode", extra}}
+	case SearchCustomer:
+		candidate.result.Subtitle = detail
+		candidate.fields = [][2]string{{"customerName", name}}
+	case SearchClient:
+		candidate.fields = [][2]string{{"clientName", name}}
+	case SearchBillOfEntry:
+		candidate.result.Subtitle = detail
+		candidate.fields = [][2]string{{"billOfEntryNumber", name}}
+	case SearchSalesInvoice:
+		candidate.result.Subtitle = strings.Trim(extra+" · "+detail, " ·")
+		candidate.fields = [][2]string{{"salesInvoiceNumber", name}}
+	}
+
+	return candidate
+}
+
+// globalSearch finds the records of the given types matching the query, best match first
+func globalSearch(load candidateLoader, query string, types []string, limit int) ([]SearchResult, error) {
+	query = normalizeSearchText(query)
+	results := []SearchResult{}
+	if query == "" {
+		return results, nil
+	}
+
+	for _, searchType := range types {
+		candidates, err := load(searchType, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range candidates {
+			if result, ok := scoreCandidate(query, candidate); ok {
+				results = append(results, result)
+			}
+		}
+	}
+
+	// equal scores go to the shorter title, which is the closer match for the same query
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if len(results[i].Title) != len(results[j].Title) {
+			return len(results[i].Title) < len(results[j].Title)
+		}
+		return strings.ToLower(results[i].Title) < strings.ToLower(results[j].Title)
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// parseSearchTypes reads the types to search, all of them when none are given
+func parseSearchTypes(value string) ([]string, error) {
+	requested := splitList(value)
+	if len(requested) == 0 {
+		return searchTypes, nil
+	}
+
+	var types []string
+	for _, searchType := range requested {
+		known := false
+		for _, candidate := range searchTypes {
+			if strings.EqualFold(searchType, candidate) {
+				types = append(types, candidate)
+				known = true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("types must be among %s", strings.Join(searchTypes, ", "))
+		}
+	}
+
+	return types, nil
+}
+
+// GlobalSearch finds items, customers, clients, bills of entry and sales invoices by prefix or a close spelling of q
+func GlobalSearch(w http.ResponseWriter, r *http.Request) {
+
+	query := strings.TrimSpace(r.FormValue("q"))
+	if query == "" {
+		writeFailure(w, "q is required")
+		return
+	}
+
+	types, err := parseSearchTypes(r.FormValue("types"))
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	limit := defaultSearchLimit
+	if value := r.FormValue("limit"); value != "" {
+		limitNum, err := strconv.Atoi(value)
+		if err != nil || limitNum < 1 || limitNum > maxSearchLimit {
+			writeFailure(w, fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit))
+			return
+		}
+		limit = limitNum
+	}
+
+	payload, err := globalSearch(loadSearchCandidates, query, types, limit)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
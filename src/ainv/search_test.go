@@ -0,0 +1,206 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"bolt", "bolt", 0},
+		{"", "bolt", 4},
+		{"bolt", "", 4},
+		{"bolt", "bolts", 1},
+		{"bolt", "blot", 2},
+		{"speker", "speaker", 1},
+		{"kitten", "sitting", 3},
+		{"crème", "creme", 1},
+	}
+
+	for _, c := range cases {
+		if distance := editDistance(c.a, c.b); distance != c.distance {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", c.a, c.b, distance, c.distance)
+		}
+	}
+}
+
+func TestMatchScore(t *testing.T) {
+	cases := []struct {
+		query string
+		text  string
+		score int
+	}{
+		{"bolt", "Bolt", scoreExact},
+		{"bolt", "Bolt M8", scorePrefix},
+		{"bolt", "bolts", scorePrefix},
+		{"bolt", "M8 Bolt", scoreWordPrefix},
+		{"bolt", "robolt", scoreContains},
+		{"m8 bolt", "Bolt M8", scoreAllWords},
+		{"wh1 42", "WH1/26-27/00042", scoreAllWords},
+		{"wh1 4", "WH1/26-27/00042", scoreAllWords},
+		{"wh1 43", "WH1/26-27/00042", 0},
+		{"42", "WH1/26-27/00042", scoreWordPrefix},
+		{"42", "WH1/26-27/00142", scoreContains},
+		{"00043", "WH1/26-27/00042", 0},
+		{"mubai 7", "MUMBAI/26-27/00007", scoreFuzzy - scorePerTypo},
+		{"boe 42", "WH1/BOE/2026-27/00042", scoreAllWords},
+		{"bluetoth", "Bluetooth Speaker", scoreFuzzy - scorePerTypo},
+		{"bluetoth speker", "Bluetooth Speaker", scoreFuzzy - 2*scorePerTypo},
+		{"bluetoth speker", "Bluetooth", 0},
+		{"blutoth", "Bluetooth", 0},
+		{"bot", "Bolt", 0},
+		{"", "Bolt", 0},
+		{"bolt", "", 0},
+	}
+
+	for _, c := range cases {
+		if score := matchScore(normalizeSearchText(c.query), normalizeSearchText(c.text)); score != c.score {
+			t.Errorf("matchScore(%q, %q) = %d, want %d", c.query, c.text, score, c.score)
+		}
+	}
+}
+
+func TestScoreCandidate(t *testing.T) {
+	candidate := searchCandidate{
+		result: SearchResult{Type: SearchItem, Id: "7", Title: "Hex Bolt"},
+		fields: [][2]string{{"itemName", "Hex Bolt"}, {"itemName", "Hex Bolt M8"}, {"itemVariant", "M8"}, {"hsnCode", "73181500"}},
+	}
+
+	cases := []struct {
+		query string
+		field string
+		score int
+	}{
+		{"hex bolt", "itemName", scoreExact},
+		{"bolt m8", "itemName", scoreWordPrefix},
+		{"m8 hex", "itemName", scoreAllWords},
+		{"m8", "itemVariant", scoreExact},
+		{"7318", "hsnCode", scorePrefix},
+	}
+
+	for _, c := range cases {
+		result, ok := scoreCandidate(normalizeSearchText(c.query), candidate)
+		if !ok || result.MatchedField != c.field || result.Score != c.score {
+			t.Errorf("scoreCandidate(%q) = %s %d, want %s %d", c.query, result.MatchedField, result.Score, c.field, c.score)
+		}
+	}
+
+	if _, ok := scoreCandidate("nut", candidate); ok {
+		t.Error("scoreCandidate of an unrelated query matched")
+	}
+}
+
+func TestSearchPrefilter(t *testing.T) {
+	cases := []struct {
+		query  string
+		filter string
+		args   []interface{}
+	}{
+		{"", "false", nil},
+		{"42", "(tracker LIKE ?)", []interface{}{"%42%"}},
+		{"wh1 42", "(tracker LIKE ?) AND (tracker LIKE ?)", []interface{}{"%wh1%", "%42%"}},
+		{"mubai", "(tracker LIKE ? OR tracker LIKE ?)", []interface{}{"%mu%", "%bai%"}},
+		{"00042", "(tracker LIKE ?)", []interface{}{"%00042%"}},
+		{"bluetoth", "(tracker LIKE ? OR tracker LIKE ? OR tracker LIKE ?)", []interface{}{"%bl%", "%uet%", "%oth%"}},
+	}
+
+	for _, c := range cases {
+		filter, args := searchPrefilter(c.query, "tracker")
+		if filter != c.filter || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("searchPrefilter(%q) = %q %v, want %q %v", c.query, filter, args, c.filter, c.args)
+		}
+	}
+}
+
+// fixtureLoader serves the records of a fixture as the database would, keeping only those holding a piece of every
+// query word
+func fixtureLoader(records map[string][][4]string) candidateLoader {
+	return func(searchType, query string) ([]searchCandidate, error) {
+		var candidates []searchCandidate
+		for _, record := range records[searchType] {
+			text := strings.ToLower(strings.Join(record[1:], " "))
+			if searchType != SearchItem {
+				text = strings.ToLower(record[1])
+			}
+
+			kept := true
+			for _, wordPieces := range searchPieces(query) {
+				held := false
+				for _, piece := range wordPieces {
+					held = held || strings.Contains(text, piece)
+				}
+				kept = kept && held
+			}
+			if kept {
+				candidates = append(candidates, newSearchCandidate(searchType, record[0], record[1], record[2], record[3]))
+			}
+		}
+
+		return candidates, nil
+	}
+}
+
+func TestGlobalSearch(t *testing.T) {
+	load := fixtureLoader(map[string][][4]string{
+		SearchItem: {
+			{"1", "Hex Bolt", "M8", "73181500"},
+			{"2", "Bluetooth Speaker", "Black", "85182200"},
+		},
+		SearchCustomer: {
+			{"3", "Mumbai Traders", "Mumbai", "27AAPFU0939F1ZV"},
+		},
+		SearchClient: {
+			{"4", "Acme Imports", "", ""},
+		},
+		SearchBillOfEntry: {
+			{"5", "WH1/BOE/2026-27/00042", "2026-05-02", ""},
+		},
+		SearchSalesInvoice: {
+			{"6", "WH1/26-27/00042", "2026-05-04", "Mumbai Traders"},
+			{"7", "WH1/26-27/00142", "2026-06-11", "Mumbai Traders"},
+			{"8", "MUMBAI/26-27/00007", "2026-06-12", "Mumbai Traders"},
+		},
+	})
+
+	cases := []struct {
+		query string
+		types []string
+		ids   []string
+	}{
+		{"42", []string{SearchBillOfEntry, SearchSalesInvoice}, []string{"6", "5", "7"}},
+		{"wh1 26-27 00042", searchTypes, []string{"6"}},
+		{"1/26-27/0014", []string{SearchSalesInvoice}, []string{"7"}},
+		{"mubai 7", []string{SearchSalesInvoice}, []string{"8"}},
+		{"mubai", []string{SearchCustomer, SearchSalesInvoice}, []string{"3", "8"}},
+		{"bluetoth speker", searchTypes, []string{"2"}},
+		{"bolt m8", searchTypes, []string{"1"}},
+		{"acme", searchTypes, []string{"4"}},
+		{"8518", []string{SearchItem}, []string{"2"}},
+		{"!!", searchTypes, []string{}},
+		{"nut", searchTypes, []string{}},
+	}
+
+	for _, c := range cases {
+		results, err := globalSearch(load, c.query, c.types, defaultSearchLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := []string{}
+		for _, result := range results {
+			ids = append(ids, result.Id)
+		}
+		if !reflect.DeepEqual(ids, c.ids) {
+			t.Errorf("globalSearch(%q) = %v, want %v", c.query, results, c.ids)
+		}
+	}
+
+	if results, _ := globalSearch(load, "wh1", searchTypes, 2); len(results) != 2 {
+		t.Errorf("globalSearch with a limit of 2 returned %d results", len(results))
+	}
+}
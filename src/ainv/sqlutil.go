@@ -0,0 +1,22 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// querier and execer are satisfied by both the connection pool and a database transaction
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// escapeQuotes makes text safe to place inside a single quoted SQL string; new queries should pass their values as
+// placeholders instead
+func escapeQuotes(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(text)
+}
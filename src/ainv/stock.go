@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// transactionEntryDate resolves the date of a transaction line from its bill of entry, sales invoice, opening stock or
+// stock transfer
+const transactionEntryDate = `COALESCE(
+	(SELECT entryDate FROM billOfEntry WHERE billOfEntry.id = tr.billOfEntry),
+	(SELECT entryDate FROM salesInvoice WHERE salesInvoice.id = tr.salesInvoice),
+	(SELECT entryDate FROM openingStock WHERE openingStock.id = tr.openingStock),
+	(SELECT entryDate FROM stockTransfer WHERE stockTransfer.id = tr.stockTransfer))`
+
+// StockMovement is a single inbound or outbound transaction line as it affects stock
+type StockMovement struct {
+	TransactionId    string
+	EntryDate        string
+	ItemId           string
+	WarehouseId      string
+	ClientId         string
+	Direction        string
+	DocumentNumber   string
+	Counterparty     string
+	BigQuantity      float64
+	SmallQuantity    float64
+	RawQuantity      float64
+	TotalPcs         float64
+	UnitCost         float64
+	BaseTaxableValue float64
+	IsTransfer       bool
+}
+
+// stockFilter narrows down the stock movements to replay, with "all" or empty meaning no filter
+type stockFilter struct {
+	ItemId      string
+	WarehouseId string
+	ClientId    string
+	FromDate    string
+	ToDate      string
+}
+
+// isInbound reports whether a movement direction adds to stock, as receipts and opening stock do
+func isInbound(direction string) bool {
+	return direction == "in" || direction == DirectionOpening
+}
+
+// Signed returns the quantity with the sign of the direction of the movement
+func (movement StockMovement) Signed(quantity float64) float64 {
+	if isInbound(movement.Direction) {
+		return quantity
+	}
+
+	return -quantity
+}
+
+// isFiltered reports whether a filter value actually narrows the search
+func isFiltered(value string) bool {
+	return value != "" && value != "all"
+}
+
+// conditions builds the WHERE conditions of the filter against the transaction table aliased as tr
+func (filter stockFilter) conditions() []string {
+	conditions := []string{"tr.isError = 0"}
+
+	if isFiltered(filter.ItemId) {
+		conditions = append(conditions, fmt.Sprintf("tr.itemId = '%s'", filter.ItemId))
+	}
+	if isFiltered(filter.WarehouseId) {
+		conditions = append(conditions, fmt.Sprintf("tr.warehouseId = '%s'", filter.WarehouseId))
+	}
+	if isFiltered(filter.ClientId) {
+		conditions = append(conditions, fmt.Sprintf("tr.clientId = '%s'", filter.ClientId))
+	}
+	if filter.FromDate != "" {
+		conditions = append(conditions, fmt.Sprintf("%s >= '%s'", transactionEntryDate, filter.FromDate))
+	}
+	if filter.ToDate != "" {
+		conditions = append(conditions, fmt.Sprintf("%s <= '%s'", transactionEntryDate, filter.ToDate))
+	}
+
+	return conditions
+}
+
+// loadStockMovements reads the transaction ledger in the order it happened, along with the document and counterparty
+// of every movement, which for a sale are its invoice number and customer
+func loadStockMovements(filter stockFilter) []StockMovement {
+	var movements []StockMovement
+
+	movementsQuery := fmt.Sprintf(`SELECT
+		tr.id,
+		IFNULL(%s, ''),
+		tr.itemId,
+		tr.warehouseId,
+		tr.clientId,
+		tr.comeOrGo,
+		IFNULL(COALESCE(
+			(SELECT tracker FROM billOfEntry WHERE billOfEntry.id = tr.billOfEntry),
+			(SELECT tracker FROM salesInvoice WHERE salesInvoice.id = tr.salesInvoice),
+			(SELECT tracker FROM openingStock WHERE openingStock.id = tr.openingStock),
+			(SELECT tracker FROM stockTransfer WHERE stockTransfer.id = tr.stockTransfer)), 'N/A'),
+		IFNULL(CASE WHEN tr.stockTransfer IS NOT NULL
+			THEN (SELECT warehouseName FROM stockTransfer st JOIN warehouse wh ON wh.id = IF(tr.comeOrGo = 'out', st.toWarehouseId, st.fromWarehouseId) WHERE st.id = tr.stockTransfer)
+			WHEN tr.comeOrGo IN ('in', 'opening')
+			THEN (SELECT clientName FROM client WHERE client.id = tr.clientId)
+			ELSE (SELECT customerName FROM customer WHERE customer.id = tr.customerId) END, 'N/A'),
+		tr.bigQuantity,
+		tr.bigQuantity * tr.secretRate1,
+		tr.bigQuantity * tr.secretRate1 * tr.secretRate2,
+		tr.totalPcs,
+		IFNULL(tr.landedCostPerPiece, 0),
+		(tr.totalValue - tr.gstValue) * tr.exchangeRate,
+		tr.stockTransfer IS NOT NULL
+		FROM transaction tr
+		WHERE %s
+		ORDER BY 2, tr.id`, transactionEntryDate, strings.Join(filter.conditions(), " AND "))
+
+	allMovements, err := db.Query(movementsQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allMovements.Next() {
+		var movement StockMovement
+
+		err := allMovements.Scan(&movement.TransactionId, &movement.EntryDate, &movement.ItemId, &movement.WarehouseId, &movement.ClientId, &movement.Direction, &movement.DocumentNumber, &movement.Counterparty, &movement.BigQuantity, &movement.SmallQuantity, &movement.RawQuantity, &movement.TotalPcs, &movement.UnitCost, &movement.BaseTaxableValue, &movement.IsTransfer)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		movements = append(movements, movement)
+	}
+
+	return movements
+}
+
+// stockKey identifies the stock of one item held in one warehouse for one client
+type stockKey struct {
+	ItemId      string
+	WarehouseId string
+	ClientId    string
+}
+
+// groupStockMovements splits the ledger by item, warehouse and client, keeping the order of each group
+func groupStockMovements(movements []StockMovement) ([]stockKey, map[stockKey][]StockMovement) {
+	var keys []stockKey
+	groups := map[stockKey][]StockMovement{}
+
+	for _, movement := range movements {
+		key := stockKey{movement.ItemId, movement.WarehouseId, movement.ClientId}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+
+		groups[key] = append(groups[key], movement)
+	}
+
+	return keys, groups
+}
@@ -0,0 +1,587 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// the Tally voucher types documents are exported as
+const (
+	VoucherSales    = "Sales"
+	VoucherPurchase = "Purchase"
+	VoucherReceipt  = "Receipt"
+)
+
+// exportSalesCancel is how the cancellation of an exported sales invoice is recorded in tallyExport, apart from the
+// voucher that created it
+const exportSalesCancel = "SalesCancel"
+
+// the parties a ledger mapping belongs to; default mappings apply to every party without one of its own
+const (
+	PartyCustomer = "customer"
+	PartyClient   = "client"
+	PartyDefault  = "default"
+)
+
+// ledgerRoles are the ledgers a voucher posts to, with the Tally ledger used when nothing is mapped;
+// the party ledger falls back to the name of the customer or client
+var ledgerRoles = map[string]string{
+	"party":     "",
+	"sales":     "Sales",
+	"purchase":  "Purchase",
+	"customs":   "Customs Duty",
+	"cgst":      "Output CGST",
+	"sgst":      "Output SGST",
+	"igst":      "Output IGST",
+	"inputIgst": "Input IGST",
+	"cash":      "Cash",
+	"bank":      "Bank",
+	"roundOff":  "Round Off",
+}
+
+// TallyLedger maps a ledger role of a customer, a client or every party to a ledger in Tally
+type TallyLedger struct {
+	LedgerId   string `json:"ledgerId"`
+	PartyType  string `json:"partyType"`
+	PartyId    string `json:"partyId"`
+	PartyName  string `json:"partyName"`
+	LedgerRole string `json:"ledgerRole"`
+	LedgerName string `json:"ledgerName"`
+}
+
+// tallyEnvelope is an import request of Tally carrying vouchers
+type tallyEnvelope struct {
+	XMLName    xml.Name       `xml:"ENVELOPE"`
+	Request    string         `xml:"HEADER>TALLYREQUEST"`
+	ReportName string         `xml:"BODY>IMPORTDATA>REQUESTDESC>REPORTNAME"`
+	Company    string         `xml:"BODY>IMPORTDATA>REQUESTDESC>STATICVARIABLES>SVCURRENTCOMPANY,omitempty"`
+	Vouchers   []TallyVoucher `xml:"BODY>IMPORTDATA>REQUESTDATA>TALLYMESSAGE>VOUCHER"`
+}
+
+// TallyVoucher is a voucher with its ledger entries; debits are negative amounts deemed positive, as Tally expects.
+// A cancellation names the voucher it cancels through its date, tag and type attributes and carries no entries
+type TallyVoucher struct {
+	VchType         string             `xml:"VCHTYPE,attr"`
+	Action          string             `xml:"ACTION,attr"`
+	CancelDate      string             `xml:"DATE,attr,omitempty"`
+	TagName         string             `xml:"TAGNAME,attr,omitempty"`
+	TagValue        string             `xml:"TAGVALUE,attr,omitempty"`
+	Date            string             `xml:"DATE"`
+	VoucherTypeName string             `xml:"VOUCHERTYPENAME"`
+	VoucherNumber   string             `xml:"VOUCHERNUMBER"`
+	Reference       string             `xml:"REFERENCE,omitempty"`
+	PartyLedgerName string             `xml:"PARTYLEDGERNAME"`
+	Narration       string             `xml:"NARRATION,omitempty"`
+	Entries         []TallyLedgerEntry `xml:"ALLLEDGERENTRIES.LIST"`
+
+	documentId string
+	exportType string
+	balance    float64
+}
+
+// TallyLedgerEntry is a posting of a voucher to a ledger
+type TallyLedgerEntry struct {
+	LedgerName       string `xml:"LEDGERNAME"`
+	IsDeemedPositive string `xml:"ISDEEMEDPOSITIVE"`
+	Amount           string `xml:"AMOUNT"`
+}
+
+// newTallyVoucher starts a voucher of a document dated YYYY-MM-DD
+func newTallyVoucher(voucherType string, documentId string, date string, number string, party string) TallyVoucher {
+	if len(date) > 10 {
+		date = date[:10]
+	}
+
+	return TallyVoucher{
+		VchType:         voucherType,
+		Action:          "Create",
+		Date:            strings.Replace(date, "-", "", -1),
+		VoucherTypeName: voucherType,
+		VoucherNumber:   number,
+		PartyLedgerName: party,
+		documentId:      documentId,
+		exportType:      voucherType,
+	}
+}
+
+// newCancelVoucher cancels in Tally the voucher exported earlier for a document that has since been cancelled
+func newCancelVoucher(voucherType string, exportType string, documentId string, date string, number string, party string) TallyVoucher {
+	voucher := newTallyVoucher(voucherType, documentId, date, number, party)
+	voucher.Action = "Cancel"
+	voucher.CancelDate = voucher.Date
+	voucher.TagName = "Voucher Number"
+	voucher.TagValue = number
+	voucher.exportType = exportType
+
+	return voucher
+}
+
+// debit posts an amount to the debit side of a ledger, leaving out nil amounts
+func (voucher *TallyVoucher) debit(ledger string, amount float64) {
+	amount = roundPaise(amount)
+	if amount == 0 {
+		return
+	}
+
+	voucher.Entries = append(voucher.Entries, TallyLedgerEntry{LedgerName: ledger, IsDeemedPositive: "Yes", Amount: fmt.Sprintf("%.2f", -amount)})
+	voucher.balance = roundPaise(voucher.balance - amount)
+}
+
+// credit posts an amount to the credit side of a ledger, leaving out nil amounts
+func (voucher *TallyVoucher) credit(ledger string, amount float64) {
+	amount = roundPaise(amount)
+	if amount == 0 {
+		return
+	}
+
+	voucher.Entries = append(voucher.Entries, TallyLedgerEntry{LedgerName: ledger, IsDeemedPositive: "No", Amount: fmt.Sprintf("%.2f", amount)})
+	voucher.balance = roundPaise(voucher.balance + amount)
+}
+
+// roundOff posts whatever keeps the voucher from balancing, such as rounding of the invoice total, to a ledger
+func (voucher *TallyVoucher) roundOff(ledger string) {
+	if voucher.balance < 0 {
+		voucher.credit(ledger, -voucher.balance)
+	} else if voucher.balance > 0 {
+		voucher.debit(ledger, voucher.balance)
+	}
+}
+
+// ledgerMap resolves ledger roles to Tally ledgers through the mappings of the parties and the defaults
+type ledgerMap map[string]string
+
+func ledgerKey(partyType string, partyId string, role string) string {
+	return partyType + "|" + partyId + "|" + role
+}
+
+// loadLedgerMap reads all the ledger mappings
+func loadLedgerMap() ledgerMap {
+	ledgers := ledgerMap{}
+
+	allLedgers, err := db.Query(`SELECT partyType, partyId, ledgerRole, ledgerName FROM tallyLedger`)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allLedgers.Next() {
+		var partyType, partyId, role, name string
+
+		if err := allLedgers.Scan(&partyType, &partyId, &role, &name); err != nil {
+			panic(err.Error())
+		}
+
+		ledgers[ledgerKey(partyType, partyId, role)] = name
+	}
+
+	return ledgers
+}
+
+// ledger returns the ledger of a role for a party, falling back to the default mapping and then to the given name
+func (ledgers ledgerMap) ledger(partyType string, partyId string, role string, fallback string) string {
+	if name, ok := ledgers[ledgerKey(partyType, partyId, role)]; ok {
+		return name
+	}
+	if name, ok := ledgers[ledgerKey(PartyDefault, "0", role)]; ok {
+		return name
+	}
+	if fallback != "" {
+		return fallback
+	}
+
+	return ledgerRoles[role]
+}
+
+// notExportedCondition leaves out the documents already exported as vouchers of a type, unless they are to be exported again
+func notExportedCondition(voucherType string, idColumn string, includeExported bool) string {
+	if includeExported {
+		return ""
+	}
+
+	return fmt.Sprintf(" AND %s NOT IN (SELECT documentId FROM tallyExport WHERE voucherType = '%s')", idColumn, voucherType)
+}
+
+// loadSalesVouchers turns the sales invoices of a period into Sales vouchers, in the base currency, locking what it reads
+// so that a concurrent export waits for this one to be marked
+func loadSalesVouchers(q querier, fromDate string, toDate string, ledgers ledgerMap, includeExported bool) []TallyVoucher {
+	var vouchers []TallyVoucher
+
+	salesQuery := fmt.Sprintf(`SELECT
+		si.id, si.tracker, si.entryDate, si.customerId, IFNULL(cu.customerName, 'N/A'),
+		SUM(tr.assdValue * tr.exchangeRate), SUM(tr.cgstValue * tr.exchangeRate), SUM(tr.sgstValue * tr.exchangeRate),
+		SUM(tr.igstValue * tr.exchangeRate), SUM(tr.totalValue * tr.exchangeRate)
+		FROM salesInvoice si
+		JOIN transaction tr ON tr.salesInvoice = si.id AND tr.comeOrGo = 'out' AND tr.isError = 0
+		LEFT JOIN customer cu ON cu.id = si.customerId
+		WHERE si.entryDate BETWEEN '%s' AND '%s'%s
+		GROUP BY si.id, si.tracker, si.entryDate, si.customerId, cu.customerName
+		ORDER BY si.entryDate, si.id
+		FOR UPDATE`, fromDate, toDate, notExportedCondition(VoucherSales, "si.id", includeExported))
+
+	allInvoices, err := q.Query(salesQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allInvoices.Next() {
+		var salesInvoiceId, tracker, entryDate, customerId, customerName string
+		var taxableValue, cgstValue, sgstValue, igstValue, totalValue float64
+
+		err := allInvoices.Scan(&salesInvoiceId, &tracker, &entryDate, &customerId, &customerName, &taxableValue, &cgstValue, &sgstValue, &igstValue, &totalValue)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		party := ledgers.ledger(PartyCustomer, customerId, "party", customerName)
+
+		voucher := newTallyVoucher(VoucherSales, salesInvoiceId, entryDate, tracker, party)
+		voucher.Narration = "Sales invoice " + tracker
+		voucher.debit(party, totalValue)
+		voucher.credit(ledgers.ledger(PartyCustomer, customerId, "sales", ""), taxableValue)
+		voucher.credit(ledgers.ledger(PartyCustomer, customerId, "cgst", ""), cgstValue)
+		voucher.credit(ledgers.ledger(PartyCustomer, customerId, "sgst", ""), sgstValue)
+		voucher.credit(ledgers.ledger(PartyCustomer, customerId, "igst", ""), igstValue)
+		voucher.roundOff(ledgers.ledger(PartyCustomer, customerId, "roundOff", ""))
+
+		vouchers = append(vouchers, voucher)
+	}
+
+	return vouchers
+}
+
+// loadSalesCancellations cancels the Sales vouchers of the invoices cancelled since they were exported, whatever the
+// period, since the voucher is already in the books
+func loadSalesCancellations(q querier, ledgers ledgerMap, includeExported bool) []TallyVoucher {
+	var vouchers []TallyVoucher
+
+	cancelledQuery := fmt.Sprintf(`SELECT
+		si.id, si.tracker, si.entryDate, si.customerId, IFNULL(cu.customerName, 'N/A')
+		FROM salesInvoice si
+		JOIN tallyExport te ON te.voucherType = '%s' AND te.documentId = si.id
+		LEFT JOIN customer cu ON cu.id = si.customerId
+		WHERE si.status = '%s'%s
+		ORDER BY si.entryDate, si.id
+		FOR UPDATE`, VoucherSales, InvoiceCancelled, notExportedCondition(exportSalesCancel, "si.id", includeExported))
+
+	allInvoices, err := q.Query(cancelledQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allInvoices.Next() {
+		var salesInvoiceId, tracker, entryDate, customerId, customerName string
+
+		if err := allInvoices.Scan(&salesInvoiceId, &tracker, &entryDate, &customerId, &customerName); err != nil {
+			panic(err.Error())
+		}
+
+		voucher := newCancelVoucher(VoucherSales, exportSalesCancel, salesInvoiceId, entryDate, tracker, ledgers.ledger(PartyCustomer, customerId, "party", customerName))
+		voucher.Narration = "Sales invoice " + tracker + " cancelled"
+
+		vouchers = append(vouchers, voucher)
+	}
+
+	return vouchers
+}
+
+// loadPurchaseVouchers turns the bills of entry of a period into Purchase vouchers, in the base currency
+func loadPurchaseVouchers(q querier, fromDate string, toDate string, ledgers ledgerMap, includeExported bool) []TallyVoucher {
+	var vouchers []TallyVoucher
+
+	// billOfEntry.customerId holds the client the goods were received for
+	purchaseQuery := fmt.Sprintf(`SELECT
+		be.id, be.tracker, be.entryDate, be.customerId, IFNULL(cl.clientName, 'N/A'),
+		SUM(tr.assdValue * tr.exchangeRate), SUM(tr.dutyValue * tr.exchangeRate), SUM(tr.igstValue * tr.exchangeRate),
+		SUM(tr.totalValue * tr.exchangeRate)
+		FROM billOfEntry be
+		JOIN transaction tr ON tr.billOfEntry = be.id AND tr.comeOrGo = 'in' AND tr.isError = 0
+		LEFT JOIN client cl ON cl.id = be.customerId
+		WHERE be.entryDate BETWEEN '%s' AND '%s'%s
+		GROUP BY be.id, be.tracker, be.entryDate, be.customerId, cl.clientName
+		ORDER BY be.entryDate, be.id
+		FOR UPDATE`, fromDate, toDate, notExportedCondition(VoucherPurchase, "be.id", includeExported))
+
+	allBills, err := q.Query(purchaseQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allBills.Next() {
+		var billOfEntryId, tracker, entryDate, clientId, clientName string
+		var assdValue, dutyValue, igstValue, totalValue float64
+
+		err := allBills.Scan(&billOfEntryId, &tracker, &entryDate, &clientId, &clientName, &assdValue, &dutyValue, &igstValue, &totalValue)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		party := ledgers.ledger(PartyClient, clientId, "party", clientName)
+
+		voucher := newTallyVoucher(VoucherPurchase, billOfEntryId, entryDate, tracker, party)
+		voucher.Reference = tracker
+		voucher.Narration = "Bill of entry " + tracker
+		voucher.debit(ledgers.ledger(PartyClient, clientId, "purchase", ""), assdValue)
+		voucher.debit(ledgers.ledger(PartyClient, clientId, "customs", ""), dutyValue)
+		voucher.debit(ledgers.ledger(PartyClient, clientId, "inputIgst", ""), igstValue)
+		voucher.credit(party, totalValue)
+		voucher.roundOff(ledgers.ledger(PartyClient, clientId, "roundOff", ""))
+
+		vouchers = append(vouchers, voucher)
+	}
+
+	return vouchers
+}
+
+// loadReceiptVouchers turns the payments received in a period into Receipt vouchers; adjustments are left out, since
+// they only carry over or correct paid amounts typed in by hand and post no money received
+func loadReceiptVouchers(q querier, fromDate string, toDate string, ledgers ledgerMap, includeExported bool) []TallyVoucher {
+	var vouchers []TallyVoucher
+
+	receiptQuery := fmt.Sprintf(`SELECT
+		p.id, p.paymentDate, p.customerId, IFNULL(cu.customerName, 'N/A'), p.mode, p.reference, p.amount
+		FROM payment p
+		LEFT JOIN customer cu ON cu.id = p.customerId
+		WHERE p.paymentDate BETWEEN '%s' AND '%s' AND p.mode <> '%s'%s
+		ORDER BY p.paymentDate, p.id
+		FOR UPDATE`, fromDate, toDate, PaymentAdjustment, notExportedCondition(VoucherReceipt, "p.id", includeExported))
+
+	allPayments, err := q.Query(receiptQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allPayments.Next() {
+		var paymentId, paymentDate, customerId, customerName, mode, reference string
+		var amount float64
+
+		err := allPayments.Scan(&paymentId, &paymentDate, &customerId, &customerName, &mode, &reference, &amount)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		party := ledgers.ledger(PartyCustomer, customerId, "party", customerName)
+
+		// cash is received into the cash ledger, every other mode into the bank
+		account := "bank"
+		if mode == "cash" {
+			account = "cash"
+		}
+
+		voucher := newTallyVoucher(VoucherReceipt, paymentId, paymentDate, paymentId, party)
+		voucher.Reference = reference
+		voucher.Narration = fmt.Sprintf("Received by %s %s", mode, reference)
+		voucher.debit(ledgers.ledger(PartyCustomer, customerId, account, ""), amount)
+		voucher.credit(party, amount)
+
+		vouchers = append(vouchers, voucher)
+	}
+
+	return vouchers
+}
+
+// markExported records the vouchers as exported, so that later exports leave their documents out
+func markExported(exec execer, vouchers []TallyVoucher) error {
+	if len(vouchers) == 0 {
+		return nil
+	}
+
+	var values []string
+	for _, voucher := range vouchers {
+		values = append(values, fmt.Sprintf("('%s', '%s', NOW())", voucher.exportType, voucher.documentId))
+	}
+
+	exportInsertQuery := fmt.Sprintf(`INSERT IGNORE INTO tallyExport (voucherType, documentId, exportedAt) VALUES %s`, strings.Join(values, ", "))
+
+	_, err := exec.Exec(exportInsertQuery)
+	return err
+}
+
+// tallyXML lays the vouchers out as a Tally import request of the company
+func tallyXML(company string, vouchers []TallyVoucher) ([]byte, error) {
+	payloadXML, err := xml.MarshalIndent(tallyEnvelope{
+		Request:    "Import Data",
+		ReportName: "Vouchers",
+		Company:    company,
+		Vouchers:   vouchers,
+	}, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), payloadXML...), nil
+}
+
+// ExportTally returns the sales invoices, bills of entry and payments of a period not exported yet as Tally XML vouchers
+func ExportTally(w http.ResponseWriter, r *http.Request) {
+
+	fromDate := r.FormValue("fromDate")
+	toDate := r.FormValue("toDate")
+	includeExported := r.FormValue("includeExported") == "true"
+	preview := r.FormValue("markExported") == "false"
+
+	if _, err := time.Parse("2006-01-02", fromDate); err != nil {
+		writeFailure(w, "fromDate must be in YYYY-MM-DD format")
+		return
+	}
+	if _, err := time.Parse("2006-01-02", toDate); err != nil || toDate < fromDate {
+		writeFailure(w, "toDate must be in YYYY-MM-DD format and not before fromDate")
+		return
+	}
+
+	voucherTypes := map[string]bool{}
+	for _, voucherType := range strings.Split(strings.ToLower(r.FormValue("voucherTypes")), ",") {
+		if voucherType = strings.TrimSpace(voucherType); isFiltered(voucherType) {
+			voucherTypes[voucherType] = true
+		}
+	}
+	wanted := func(voucherType string) bool {
+		return len(voucherTypes) == 0 || voucherTypes[strings.ToLower(voucherType)]
+	}
+
+	ledgers := loadLedgerMap()
+
+	// the documents are read locked and marked in one database transaction, so that a concurrent export waits and
+	// then leaves them out
+	tx, err := db.Begin()
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	var vouchers []TallyVoucher
+	if wanted(VoucherSales) {
+		vouchers = append(vouchers, loadSalesVouchers(tx, fromDate, toDate, ledgers, includeExported)...)
+		vouchers = append(vouchers, loadSalesCancellations(tx, ledgers, includeExported)...)
+	}
+	if wanted(VoucherPurchase) {
+		vouchers = append(vouchers, loadPurchaseVouchers(tx, fromDate, toDate, ledgers, includeExported)...)
+	}
+	if wanted(VoucherReceipt) {
+		vouchers = append(vouchers, loadReceiptVouchers(tx, fromDate, toDate, ledgers, includeExported)...)
+	}
+
+	payloadXML, err := tallyXML(os.Getenv("COMPANY_NAME"), vouchers)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"tally-%s-%s.xml\"", fromDate, toDate))
+
+	// the vouchers are only marked once the download has gone out, so that a failed one can be made again
+	if _, err := w.Write(payloadXML); err != nil {
+		log.Println(err)
+		return
+	}
+
+	if !preview {
+		if err := markExported(tx, vouchers); err != nil {
+			log.Println(err)
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// CreateTallyLedger maps a ledger role of a customer, a client or every party to a Tally ledger and returns the status
+func CreateTallyLedger(w http.ResponseWriter, r *http.Request) {
+
+	partyType := r.FormValue("partyType")
+	partyId := r.FormValue("partyId")
+	ledgerRole := r.FormValue("ledgerRole")
+	ledgerName := strings.TrimSpace(r.FormValue("ledgerName"))
+
+	if partyType != PartyCustomer && partyType != PartyClient && partyType != PartyDefault {
+		writeFailure(w, fmt.Sprintf("partyType must be %s, %s or %s", PartyCustomer, PartyClient, PartyDefault))
+		return
+	}
+	if partyType == PartyDefault {
+		partyId = "0"
+	}
+	if _, ok := ledgerRoles[ledgerRole]; !ok {
+		writeFailure(w, fmt.Sprintf("ledger role %q is not known", ledgerRole))
+		return
+	}
+	if ledgerName == "" {
+		writeFailure(w, "ledgerName is required")
+		return
+	}
+
+	ledgerUpsertQuery := fmt.Sprintf(`INSERT INTO tallyLedger
+		(partyType, partyId, ledgerRole, ledgerName)
+		VALUES
+		('%s', '%s', '%s', '%s')
+		ON DUPLICATE KEY UPDATE ledgerName = VALUES(ledgerName)`, partyType, partyId, ledgerRole, escapeQuotes(ledgerName))
+
+	_, err := db.Exec(ledgerUpsertQuery)
+
+	var result map[string]bool
+
+	if err != nil {
+		log.Println(err)
+		result = map[string]bool{
+			"success": false,
+		}
+	} else {
+		result = map[string]bool{
+			"success": true,
+		}
+	}
+
+	payloadJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// GetTallyLedgers returns the ledger mappings along with the names of their parties
+func GetTallyLedgers(w http.ResponseWriter, r *http.Request) {
+
+	var payload []TallyLedger
+
+	ledgersQuery := fmt.Sprintf(`SELECT
+		tl.id, tl.partyType, tl.partyId,
+		CASE tl.partyType WHEN '%s' THEN IFNULL(cu.customerName, 'N/A') WHEN '%s' THEN IFNULL(cl.clientName, 'N/A') ELSE '' END,
+		tl.ledgerRole, tl.ledgerName
+		FROM tallyLedger tl
+		LEFT JOIN customer cu ON tl.partyType = '%s' AND cu.id = tl.partyId
+		LEFT JOIN client cl ON tl.partyType = '%s' AND cl.id = tl.partyId
+		ORDER BY tl.partyType, tl.partyId, tl.ledgerRole`, PartyCustomer, PartyClient, PartyCustomer, PartyClient)
+
+	allLedgers, err := db.Query(ledgersQuery)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allLedgers.Next() {
+		var ledger TallyLedger
+
+		err := allLedgers.Scan(&ledger.LedgerId, &ledger.PartyType, &ledger.PartyId, &ledger.PartyName, &ledger.LedgerRole, &ledger.LedgerName)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		payload = append(payload, ledger)
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTallyVoucherBalances(t *testing.T) {
+	cases := []struct {
+		name     string
+		post     func(voucher *TallyVoucher)
+		entries  []TallyLedgerEntry
+		balanced bool
+	}{
+		{
+			name: "sales with intra-state tax",
+			post: func(voucher *TallyVoucher) {
+				voucher.debit("Acme Traders", 1180)
+				voucher.credit("Sales", 1000)
+				voucher.credit("Output CGST", 90)
+				voucher.credit("Output SGST", 90)
+			},
+			entries: []TallyLedgerEntry{
+				{"Acme Traders", "Yes", "-1180.00"},
+				{"Sales", "No", "1000.00"},
+				{"Output CGST", "No", "90.00"},
+				{"Output SGST", "No", "90.00"},
+			},
+			balanced: true,
+		},
+		{
+			name: "nil amounts are left out",
+			post: func(voucher *TallyVoucher) {
+				voucher.debit("Acme Traders", 500)
+				voucher.credit("Sales", 500)
+				voucher.credit("Output IGST", 0)
+				voucher.credit("Output CGST", 0.001)
+			},
+			entries: []TallyLedgerEntry{
+				{"Acme Traders", "Yes", "-500.00"},
+				{"Sales", "No", "500.00"},
+			},
+			balanced: true,
+		},
+		{
+			name: "rounding of the total goes to round off",
+			post: func(voucher *TallyVoucher) {
+				voucher.debit("Acme Traders", 1181)
+				voucher.credit("Sales", 1000)
+				voucher.credit("Output IGST", 180.6)
+				voucher.roundOff("Round Off")
+			},
+			entries: []TallyLedgerEntry{
+				{"Acme Traders", "Yes", "-1181.00"},
+				{"Sales", "No", "1000.00"},
+				{"Output IGST", "No", "180.60"},
+				{"Round Off", "No", "0.40"},
+			},
+			balanced: true,
+		},
+		{
+			name: "a shortfall is debited to round off",
+			post: func(voucher *TallyVoucher) {
+				voucher.debit("Acme Traders", 100)
+				voucher.credit("Sales", 100.25)
+				voucher.roundOff("Round Off")
+			},
+			entries: []TallyLedgerEntry{
+				{"Acme Traders", "Yes", "-100.00"},
+				{"Sales", "No", "100.25"},
+				{"Round Off", "Yes", "-0.25"},
+			},
+			balanced: true,
+		},
+		{
+			name: "without round off an unbalanced voucher stays so",
+			post: func(voucher *TallyVoucher) {
+				voucher.debit("Acme Traders", 100)
+				voucher.credit("Sales", 90)
+			},
+			entries: []TallyLedgerEntry{
+				{"Acme Traders", "Yes", "-100.00"},
+				{"Sales", "No", "90.00"},
+			},
+			balanced: false,
+		},
+	}
+
+	for _, c := range cases {
+		voucher := newTallyVoucher(VoucherSales, "1", "2026-04-15", "WH1/26-27/00001", "Acme Traders")
+		c.post(&voucher)
+
+		if len(voucher.Entries) != len(c.entries) {
+			t.Errorf("%s: entries = %v, want %v", c.name, voucher.Entries, c.entries)
+			continue
+		}
+		for i, entry := range voucher.Entries {
+			if entry != c.entries[i] {
+				t.Errorf("%s: entry %d = %v, want %v", c.name, i, entry, c.entries[i])
+			}
+		}
+		if balanced := voucher.balance == 0; balanced != c.balanced {
+			t.Errorf("%s: balance = %.2f, want balanced %v", c.name, voucher.balance, c.balanced)
+		}
+	}
+}
+
+func TestTallyXML(t *testing.T) {
+	sale := newTallyVoucher(VoucherSales, "7", "2026-04-15 00:00:00", "WH1/26-27/00007", "Acme Traders")
+	sale.Narration = "Sales invoice WH1/26-27/00007"
+	sale.debit("Acme Traders", 118)
+	sale.credit("Sales", 100)
+	sale.credit("Output IGST", 18)
+
+	cancel := newCancelVoucher(VoucherSales, exportSalesCancel, "3", "2026-04-02", "WH1/26-27/00003", "Acme Traders")
+
+	cases := []struct {
+		name     string
+		company  string
+		vouchers []TallyVoucher
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "sales voucher",
+			company:  "Ainv Logistics",
+			vouchers: []TallyVoucher{sale},
+			contains: []string{
+				`<?xml version="1.0" encoding="UTF-8"?>`,
+				`<TALLYREQUEST>Import Data</TALLYREQUEST>`,
+				`<SVCURRENTCOMPANY>Ainv Logistics</SVCURRENTCOMPANY>`,
+				`<VOUCHER VCHTYPE="Sales" ACTION="Create">`,
+				`<DATE>20260415</DATE>`,
+				`<VOUCHERNUMBER>WH1/26-27/00007</VOUCHERNUMBER>`,
+				`<LEDGERNAME>Acme Traders</LEDGERNAME>`,
+				`<ISDEEMEDPOSITIVE>Yes</ISDEEMEDPOSITIVE>`,
+				`<AMOUNT>-118.00</AMOUNT>`,
+				`<AMOUNT>18.00</AMOUNT>`,
+			},
+			excludes: []string{`TAGNAME`, `<REFERENCE>`},
+		},
+		{
+			name:     "cancellation of an exported invoice",
+			vouchers: []TallyVoucher{cancel},
+			contains: []string{
+				`<VOUCHER VCHTYPE="Sales" ACTION="Cancel" DATE="20260402" TAGNAME="Voucher Number" TAGVALUE="WH1/26-27/00003">`,
+				`<VOUCHERNUMBER>WH1/26-27/00003</VOUCHERNUMBER>`,
+			},
+			excludes: []string{`<ALLLEDGERENTRIES.LIST>`, `SVCURRENTCOMPANY`},
+		},
+		{
+			name:     "party names are escaped",
+			vouchers: []TallyVoucher{newTallyVoucher(VoucherReceipt, "9", "2026-04-20", "9", "Shah & Sons <Mumbai>")},
+			contains: []string{`<PARTYLEDGERNAME>Shah &amp; Sons &lt;Mumbai&gt;</PARTYLEDGERNAME>`},
+		},
+	}
+
+	for _, c := range cases {
+		payload, err := tallyXML(c.company, c.vouchers)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+
+		for _, want := range c.contains {
+			if !strings.Contains(string(payload), want) {
+				t.Errorf("%s: payload lacks %s\n%s", c.name, want, payload)
+			}
+		}
+		for _, unwanted := range c.excludes {
+			if strings.Contains(string(payload), unwanted) {
+				t.Errorf("%s: payload has %s\n%s", c.name, unwanted, payload)
+			}
+		}
+	}
+}
+
+func TestLedgerMap(t *testing.T) {
+	ledgers := ledgerMap{
+		ledgerKey(PartyCustomer, "4", "sales"): "Export Sales",
+		ledgerKey(PartyDefault, "0", "sales"):  "Domestic Sales",
+		ledgerKey(PartyDefault, "0", "party"):  "Sundry Debtors",
+	}
+
+	cases := []struct {
+		partyId  string
+		role     string
+		fallback string
+		ledger   string
+	}{
+		{"4", "sales", "", "Export Sales"},
+		{"5", "sales", "", "Domestic Sales"},
+		{"5", "party", "Acme Traders", "Sundry Debtors"},
+		{"5", "cgst", "", "Output CGST"},
+		{"5", "bank", "HDFC Current", "HDFC Current"},
+	}
+
+	for _, c := range cases {
+		if ledger := ledgers.ledger(PartyCustomer, c.partyId, c.role, c.fallback); ledger != c.ledger {
+			t.Errorf("ledger(%s, %s, %q) = %q, want %q", c.partyId, c.role, c.fallback, ledger, c.ledger)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// StockTransferLine is an item moved between warehouses, valued in the base currency at what it cost at the source
+type StockTransferLine struct {
+	LineNumber       int     `json:"lineNumber"`
+	ItemId           string  `json:"itemId"`
+	ItemName         string  `json:"itemName"`
+	ItemVariant      string  `json:"itemVariant"`
+	HsnCode          string  `json:"hsnCode"`
+	BigQuantity      float64 `json:"bigQuantity"`
+	TotalPcs         float64 `json:"totalPcs"`
+	RawUnit          string  `json:"rawUnit"`
+	Value            float64 `json:"value"`
+	OutTransactionId string  `json:"outTransactionId"`
+	InTransactionId  string  `json:"inTransactionId"`
+}
+
+// StockTransfer is the stock of a client moved from one warehouse to another under a delivery challan
+type StockTransfer struct {
+	StockTransferId     string              `json:"stockTransferId"`
+	StockTransferNumber string              `json:"stockTransferNumber"`
+	StockTransferDate   string              `json:"stockTransferDate"`
+	FromWarehouseId     string              `json:"fromWarehouseId"`
+	FromWarehouseName   string              `json:"fromWarehouseName"`
+	ToWarehouseId       string              `json:"toWarehouseId"`
+	ToWarehouseName     string              `json:"toWarehouseName"`
+	ClientId            string              `json:"clientId"`
+	ClientName          string              `json:"clientName"`
+	Remarks             string              `json:"remarks"`
+	Lines               []StockTransferLine `json:"lines"`
+	TotalValue          float64             `json:"totalValue"`
+	EwayBillNumber      string              `json:"ewayBillNumber"`
+	EwayBillDate        string              `json:"ewayBillDate"`
+	EwayBillValidUpto   string              `json:"ewayBillValidUpto"`
+}
+
+// parseStockTransferLines reads the lines of a transfer, sent as a JSON array in a form value; an item moves once per
+// transfer so that every line is costed against the stock left by the ones before it
+func parseStockTransferLines(raw string) ([]StockTransferLine, error) {
+	var lines []StockTransferLine
+
+	if err := json.Unmarshal([]byte(raw), &lines); err != nil {
+		return nil, fmt.Errorf("lines must be a JSON array: %v", err)
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("a stock transfer needs at least one line")
+	}
+
+	seen := map[string]int{}
+	for i, line := range lines {
+		if line.ItemId == "" {
+			return nil, fmt.Errorf("line %d must name an itemId", i+1)
+		}
+		if line.BigQuantity <= 0 {
+			return nil, fmt.Errorf("line %d must have a positive bigQuantity", i+1)
+		}
+		if first, ok := seen[line.ItemId]; ok {
+			return nil, fmt.Errorf("line %d repeats the item of line %d", i+1, first)
+		}
+		seen[line.ItemId] = i + 1
+
+		lines[i].LineNumber = i + 1
+	}
+
+	return lines, nil
+}
+
+// transferCost is what the pieces leaving a warehouse on a date cost, under the valuation method of their client
+func transferCost(method string, key stockKey, date string, totalPcs float64) float64 {
+	movements := loadStockMovements(stockFilter{ItemId: key.ItemId, WarehouseId: key.WarehouseId, ClientId: key.ClientId, ToDate: date})
+	movements = append(movements, StockMovement{TransactionId: "transfer", EntryDate: date, Direction: "out", TotalPcs: totalPcs})
+
+	return ValueStock(method, movements).Cogs["transfer"]
+}
+
+// postTransferTransaction records one side of a transfer line, the inbound side carrying the cost of the stock onwards
+func postTransferTransaction(tx *sql.Tx, transferId int64, direction string, warehouseId string, clientId string, line StockTransferLine, change InventoryChange, rates ItemRates, remarks string) error {
+	costPerPiece := 0.0
+	if line.TotalPcs > 0 {
+		costPerPiece = line.Value / line.TotalPcs
+	}
+
+	var landedCostPerPiece interface{}
+	if direction == "in" {
+		landedCostPerPiece = costPerPiece
+	}
+
+	// a transfer carries no tax and is valued in the base currency
+	_, err := tx.Exec(`INSERT INTO transaction
+		(billOfEntry, salesInvoice, stockTransfer, itemId, warehouseId, comeOrGo, clientId, customerId, bigQuantity, currentValue, changeValue, finalValue, secretRate1, secretRate2, totalPcs, assdValue, dutyValue, gstValue, cgstValue, sgstValue, igstValue, placeOfSupply, totalValue, currency, exchangeRate, valuePerPiece, totalPieces, landedCostPerPiece, isPaid, paidAmount, date, delvDate1, delvDate2, remarks)
+		VALUES
+		(NULL, NULL, ?, ?, ?, ?, ?, NULL, ?, ?, ?, ?, ?, ?, ?, ?, 0, 0, 0, 0, 0, NULL, ?, ?, 1, ?, ?, ?, false, 0, NULL, '', '', ?)`,
+		transferId, line.ItemId, warehouseId, direction, clientId, line.BigQuantity, change.CurrentValue, change.ChangeValue, change.FinalValue, rates.SmallPerBig, rates.RawPerSmall, line.TotalPcs, line.Value, line.Value, BaseCurrency, costPerPiece, line.TotalPcs, landedCostPerPiece, remarks)
+
+	return err
+}
+
+// loadStockTransfer reads a stock transfer with its lines, each line being the outbound side of the move
+func loadStockTransfer(q querier, stockTransferId string) (StockTransfer, error) {
+	var transfer StockTransfer
+
+	err := q.QueryRow(`SELECT
+		st.id, st.tracker, st.entryDate, st.fromWarehouseId, IFNULL(fw.warehouseName, 'N/A'), st.toWarehouseId, IFNULL(tw.warehouseName, 'N/A'),
+		st.clientId, IFNULL(cl.clientName, 'N/A'), st.remarks,
+		IFNULL(st.ewayBillNumber, ''), IFNULL(st.ewayBillDate, ''), IFNULL(st.ewayBillValidUpto, '')
+		FROM stockTransfer st
+		LEFT JOIN warehouse fw ON fw.id = st.fromWarehouseId
+		LEFT JOIN warehouse tw ON tw.id = st.toWarehouseId
+		LEFT JOIN client cl ON cl.id = st.clientId
+		WHERE st.id = ?`, stockTransferId).Scan(&transfer.StockTransferId, &transfer.StockTransferNumber, &transfer.StockTransferDate, &transfer.FromWarehouseId, &transfer.FromWarehouseName, &transfer.ToWarehouseId, &transfer.ToWarehouseName, &transfer.ClientId, &transfer.ClientName, &transfer.Remarks, &transfer.EwayBillNumber, &transfer.EwayBillDate, &transfer.EwayBillValidUpto)
+	if err == sql.ErrNoRows {
+		return transfer, fmt.Errorf("stock transfer %s does not exist", stockTransferId)
+	}
+	if err != nil {
+		return transfer, err
+	}
+
+	allLines, err := q.Query(`SELECT
+		tr.itemId, IFNULL(im.itemName, 'N/A'), IFNULL(im.itemVariant, ''), IFNULL(im.hsnCode, ''), tr.bigQuantity, tr.totalPcs, IFNULL(im.uomRaw, ''), tr.totalValue, tr.id,
+		IFNULL((SELECT MIN(ti.id) FROM transaction ti WHERE ti.stockTransfer = tr.stockTransfer AND ti.itemId = tr.itemId AND ti.comeOrGo = 'in'), '')
+		FROM transaction tr
+		LEFT JOIN itemMaster im ON im.id = tr.itemId
+		WHERE tr.stockTransfer = ? AND tr.comeOrGo = 'out' AND tr.isError = 0
+		ORDER BY tr.id`, stockTransferId)
+	if err != nil {
+		return transfer, err
+	}
+	defer allLines.Close()
+
+	for allLines.Next() {
+		var line StockTransferLine
+
+		err := allLines.Scan(&line.ItemId, &line.ItemName, &line.ItemVariant, &line.HsnCode, &line.BigQuantity, &line.TotalPcs, &line.RawUnit, &line.Value, &line.OutTransactionId, &line.InTransactionId)
+		if err != nil {
+			return transfer, err
+		}
+
+		line.LineNumber = len(transfer.Lines) + 1
+		transfer.Lines = append(transfer.Lines, line)
+		transfer.TotalValue += line.Value
+	}
+	transfer.TotalValue = roundPaise(transfer.TotalValue)
+
+	return transfer, allLines.Err()
+}
+
+// writeStockTransfer writes a stock transfer as JSON
+func writeStockTransfer(w http.ResponseWriter, stockTransferId string) {
+	transfer, err := loadStockTransfer(db, stockTransferId)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	payloadJSON, err := json.Marshal(transfer)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// CreateStockTransfer moves the stock of a client from one warehouse to another and returns the transfer; the stock
+// leaves the source at its cost there and arrives at the destination carrying that cost
+func CreateStockTransfer(w http.ResponseWriter, r *http.Request) {
+
+	transfer := StockTransfer{
+		StockTransferDate: r.FormValue("stockTransferDate"),
+		FromWarehouseId:   r.FormValue("fromWarehouseId"),
+		ToWarehouseId:     r.FormValue("toWarehouseId"),
+		ClientId:          r.FormValue("clientId"),
+		Remarks:           r.FormValue("remarks"),
+	}
+
+	if transfer.StockTransferDate == "" {
+		transfer.StockTransferDate = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", transfer.StockTransferDate); err != nil {
+		writeFailure(w, "stockTransferDate must be in YYYY-MM-DD format")
+		return
+	}
+	if transfer.FromWarehouseId == "" || transfer.ToWarehouseId == "" || transfer.ClientId == "" {
+		writeFailure(w, "fromWarehouseId, toWarehouseId and clientId are required")
+		return
+	}
+	if transfer.FromWarehouseId == transfer.ToWarehouseId {
+		writeFailure(w, "stock can only be transferred between two different warehouses")
+		return
+	}
+
+	lines, err := parseStockTransferLines(r.FormValue("lines"))
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	consignor, err := loadWarehouseParty(tx, transfer.FromWarehouseId)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+	consignee, err := loadWarehouseParty(tx, transfer.ToWarehouseId)
+	if err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	// goods moving to another registration are a supply, which has to be invoiced with its tax
+	if consignor.Gstin != consignee.Gstin {
+		writeFailure(w, fmt.Sprintf("warehouses %s and %s are registered under different GSTINs, stock moving between them must be invoiced", transfer.FromWarehouseId, transfer.ToWarehouseId))
+		return
+	}
+
+	var method string
+	err = tx.QueryRow(`SELECT valuationMethod FROM client WHERE id = ?`, transfer.ClientId).Scan(&method)
+	if err == sql.ErrNoRows {
+		writeFailure(w, fmt.Sprintf("client %s does not exist", transfer.ClientId))
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	headerResult, err := tx.Exec(`INSERT INTO stockTransfer (tracker, entryDate, fromWarehouseId, toWarehouseId, clientId, remarks)
+		VALUES ('', ?, ?, ?, ?, ?)`, transfer.StockTransferDate, transfer.FromWarehouseId, transfer.ToWarehouseId, transfer.ClientId, transfer.Remarks)
+	if err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+	transferId, _ := headerResult.LastInsertId()
+
+	if _, err := tx.Exec(`UPDATE stockTransfer SET tracker = CONCAT('TRF-', id) WHERE id = ?`, transferId); err != nil {
+		log.Println(err)
+		writeFailure(w, err.Error())
+		return
+	}
+
+	for _, line := range lines {
+		rates, err := lookupItemRates(tx, line.ItemId)
+		if err != nil {
+			writeFailure(w, err.Error())
+			return
+		}
+		line.TotalPcs = line.BigQuantity * rates.SmallPerBig * rates.RawPerSmall
+
+		outChange, err := postInventoryChange(tx, line.ItemId, transfer.FromWarehouseId, transfer.ClientId, "out", line.BigQuantity, rates)
+		if err != nil {
+			writeFailure(w, err.Error())
+			return
+		}
+
+		source := stockKey{ItemId: line.ItemId, WarehouseId: transfer.FromWarehouseId, ClientId: transfer.ClientId}
+		line.Value = roundPaise(transferCost(method, source, transfer.StockTransferDate, line.TotalPcs))
+
+		inChange, err := postInventoryChange(tx, line.ItemId, transfer.ToWarehouseId, transfer.ClientId, "in", line.BigQuantity, rates)
+		if err != nil {
+			writeFailure(w, err.Error())
+			return
+		}
+
+		if err := postTransferTransaction(tx, transferId, "out", transfer.FromWarehouseId, transfer.ClientId, line, outChange, rates, transfer.Remarks); err != nil {
+			log.Println(err)
+			writeFailure(w, err.Error())
+			return
+		}
+		if err := postTransferTransaction(tx, transferId, "in", transfer.ToWarehouseId, transfer.ClientId, line, inChange, rates, transfer.Remarks); err != nil {
+			log.Println(err)
+			writeFailure(w, err.Error())
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeFailure(w, err.Error())
+		return
+	}
+
+	writeStockTransfer(w, strconv.FormatInt(transferId, 10))
+}
+
+// GetStockTransfer returns a stock transfer with its lines
+func GetStockTransfer(w http.ResponseWriter, r *http.Request) {
+	writeStockTransfer(w, mux.Vars(r)["id"])
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// the stock valuation methods a client can choose from
+const (
+	ValuationFIFO            = "FIFO"
+	ValuationWeightedAverage = "WAVG"
+)
+
+// costLayer is a lot of pieces received together at the same unit cost
+type costLayer struct {
+	Pcs      float64
+	UnitCost float64
+}
+
+// CostOfGoodsSold is the cost of an outbound movement under the valuation method of its client
+type CostOfGoodsSold struct {
+	TransactionId string  `json:"transactionId"`
+	SalesInvoice  string  `json:"salesInvoice"`
+	EntryDate     string  `json:"entryDate"`
+	ItemName      string  `json:"itemName"`
+	ItemVariant   string  `json:"itemVariant"`
+	WarehouseName string  `json:"warehouseName"`
+	ClientName    string  `json:"clientName"`
+	CustomerName  string  `json:"customerName"`
+	Method        string  `json:"method"`
+	TotalPcs      float64 `json:"totalPcs"`
+	Cogs          float64 `json:"cogs"`
+	SalesValue    float64 `json:"salesValue"`
+	Margin        float64 `json:"margin"`
+}
+
+// StockValuation is the closing stock value of one item in one warehouse for one client
+type StockValuation struct {
+	ItemId        string  `json:"itemId"`
+	ItemName      string  `json:"itemName"`
+	ItemVariant   string  `json:"itemVariant"`
+	WarehouseName string  `json:"warehouseName"`
+	ClientName    string  `json:"clientName"`
+	Method        string  `json:"method"`
+	AsOfDate      string  `json:"asOfDate"`
+	ClosingPcs    float64 `json:"closingPcs"`
+	ClosingValue  float64 `json:"closingValue"`
+	AverageCost   float64 `json:"averageCost"`
+}
+
+// valuationResult is the outcome of replaying the movements of one stock
+type valuationResult struct {
+	ClosingPcs   float64
+	ClosingValue float64
+	Cogs         map[string]float64
+}
+
+// normalizeValuationMethod validates a valuation method, defaulting to FIFO
+func normalizeValuationMethod(method string) (string, error) {
+	method = strings.ToUpper(strings.TrimSpace(method))
+
+	switch method {
+	case "":
+		return ValuationFIFO, nil
+	case ValuationFIFO, ValuationWeightedAverage:
+		return method, nil
+	}
+
+	return "", fmt.Errorf("valuation method %q must be %s or %s", method, ValuationFIFO, ValuationWeightedAverage)
+}
+
+// ValueStock replays the movements of a single stock, in order, under the given valuation method
+func ValueStock(method string, movements []StockMovement) valuationResult {
+	if method == ValuationWeightedAverage {
+		return valueWeightedAverage(movements)
+	}
+
+	return valueFIFO(movements)
+}
+
+// valueFIFO consumes the oldest cost layers first. Stock that goes out without having come in is costed at the last
+// known cost and held as a negative layer, which the next receipts settle before they add a layer of their own, so that
+// an oversold stock closes below zero just as it does under the weighted average
+func valueFIFO(movements []StockMovement) valuationResult {
+	result := valuationResult{Cogs: map[string]float64{}}
+	var layers []costLayer
+	var lastCost float64
+
+	for _, movement := range movements {
+		if isInbound(movement.Direction) {
+			remaining := movement.TotalPcs
+
+			for remaining > 0 && len(layers) > 0 && layers[0].Pcs < 0 {
+				settled := math.Min(remaining, -layers[0].Pcs)
+				layers[0].Pcs += settled
+				remaining -= settled
+
+				if layers[0].Pcs >= 0 {
+					layers = layers[1:]
+				}
+			}
+
+			if remaining > 0 {
+				layers = append(layers, costLayer{remaining, movement.UnitCost})
+			}
+			lastCost = movement.UnitCost
+			continue
+		}
+
+		remaining := movement.TotalPcs
+		var cogs float64
+
+		for remaining > 0 && len(layers) > 0 && layers[0].Pcs > 0 {
+			consumed := math.Min(remaining, layers[0].Pcs)
+
+			cogs += consumed * layers[0].UnitCost
+			layers[0].Pcs -= consumed
+			remaining -= consumed
+
+			if layers[0].Pcs <= 0 {
+				layers = layers[1:]
+			}
+		}
+
+		if remaining > 0 {
+			cogs += remaining * lastCost
+			layers = append(layers, costLayer{-remaining, lastCost})
+		}
+		result.Cogs[movement.TransactionId] = roundPaise(cogs)
+	}
+
+	for _, layer := range layers {
+		result.ClosingPcs += layer.Pcs
+		result.ClosingValue += layer.Pcs * layer.UnitCost
+	}
+	result.ClosingValue = roundPaise(result.ClosingValue)
+
+	return result
+}
+
+// valueWeightedAverage costs every outbound movement at the moving average cost of the stock. Receipts into an oversold
+// stock first settle the shortfall at the average it was costed at, and only the rest moves the average
+func valueWeightedAverage(movements []StockMovement) valuationResult {
+	result := valuationResult{Cogs: map[string]float64{}}
+	var averageCost float64
+
+	for _, movement := range movements {
+		if isInbound(movement.Direction) {
+			remaining := movement.TotalPcs
+
+			if result.ClosingPcs < 0 {
+				settled := math.Min(remaining, -result.ClosingPcs)
+				result.ClosingPcs += settled
+				result.ClosingValue += settled * averageCost
+				remaining -= settled
+			}
+
+			result.ClosingValue += remaining * movement.UnitCost
+			result.ClosingPcs += remaining
+
+			if result.ClosingPcs > 0 {
+				averageCost = result.ClosingValue / result.ClosingPcs
+			}
+			continue
+		}
+
+		cogs := movement.TotalPcs * averageCost
+		result.Cogs[movement.TransactionId] = roundPaise(cogs)
+		result.ClosingPcs -= movement.TotalPcs
+		result.ClosingValue -= cogs
+	}
+	result.ClosingValue = roundPaise(result.ClosingValue)
+
+	return result
+}
+
+// clientValuationMethods returns the valuation method chosen by each client
+func clientValuationMethods() map[string]string {
+	methods := map[string]string{}
+
+	allClients, err := db.Query(`SELECT id, valuationMethod FROM client`)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	for allClients.Next() {
+		var clientId string
+		var method string
+
+		err := allClients.Scan(&clientId, &method)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		methods[clientId] = method
+	}
+
+	return methods
+}
+
+// stockNames holds the display names of the item, warehouse and client of a stock
+type stockNames struct {
+	ItemName      string
+	ItemVariant   string
+	WarehouseName string
+	ClientName    string
+	UomRaw        string
+	UomSmall      string
+	UomBig        string
+}
+
+// lookupStockNames returns the display names of a stock
+func lookupStockNames(key stockKey) stockNames {
+	var names stockNames
+
+	namesQuery := fmt.Sprintf(`SELECT
+		(SELECT itemName FROM itemMaster WHERE id = '%s'),
+		(SELECT itemVariant FROM itemMaster WHERE id = '%s'),
+		(SELECT CONCAT(warehouseName, ", ", warehouseLocation) FROM warehouse WHERE id = '%s'),
+		(SELECT clientName FROM client WHERE id = '%s'),
+		(SELECT uomRaw FROM itemMaster WHERE id = '%s'),
+		(SELECT uomSmall FROM itemMaster WHERE id = '%s'),
+		(SELECT uomBig FROM itemMaster WHERE id = '%s')`, key.ItemId, key.ItemId, key.WarehouseId, key.ClientId, key.ItemId, key.ItemId, key.ItemId)
+
+	db.QueryRow(namesQuery).Scan(&names.ItemName, &names.ItemVariant, &names.WarehouseName, &names.ClientName, &names.UomRaw, &names.UomSmall, &names.UomBig)
+	return names
+}
+
+// SearchValuation returns the closing stock value per item, warehouse and client as of a date
+func SearchValuation(w http.ResponseWriter, r *http.Request) {
+
+	filter := stockFilter{
+		ItemId:      r.FormValue("itemId"),
+		WarehouseId: r.FormValue("warehouseId"),
+		ClientId:    r.FormValue("clientId"),
+		ToDate:      r.FormValue("asOfDate"),
+	}
+
+	var payload []StockValuation
+
+	methods := clientValuationMethods()
+	keys, groups := groupStockMovements(loadStockMovements(filter))
+
+	for _, key := range keys {
+		method := methods[key.ClientId]
+		valuation := ValueStock(method, groups[key])
+		names := lookupStockNames(key)
+
+		var averageCost float64
+		if valuation.ClosingPcs > 0 {
+			averageCost = valuation.ClosingValue / valuation.ClosingPcs
+		}
+
+		singleObject := StockValuation{
+			ItemId:        key.ItemId,
+			ItemName:      names.ItemName,
+			ItemVariant:   names.ItemVariant,
+			WarehouseName: names.WarehouseName,
+			ClientName:    names.ClientName,
+			Method:        method,
+			AsOfDate:      filter.ToDate,
+			ClosingPcs:    valuation.ClosingPcs,
+			ClosingValue:  valuation.ClosingValue,
+			AverageCost:   averageCost,
+		}
+
+		payload = append(payload, singleObject)
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
+
+// SearchCostOfGoodsSold returns the cost of every sale within a date range
+func SearchCostOfGoodsSold(w http.ResponseWriter, r *http.Request) {
+
+	fromDate := r.FormValue("fromDate")
+	filter := stockFilter{
+		ItemId:      r.FormValue("itemId"),
+		WarehouseId: r.FormValue("warehouseId"),
+		ClientId:    r.FormValue("clientId"),
+		ToDate:      r.FormValue("toDate"),
+	}
+
+	var payload []CostOfGoodsSold
+
+	methods := clientValuationMethods()
+	keys, groups := groupStockMovements(loadStockMovements(filter))
+
+	for _, key := range keys {
+		method := methods[key.ClientId]
+		valuation := ValueStock(method, groups[key])
+		names := lookupStockNames(key)
+
+		for _, movement := range groups[key] {
+			// stock sent to another warehouse is still held, so it is no cost of goods sold
+			if isInbound(movement.Direction) || movement.IsTransfer || movement.EntryDate < fromDate {
+				continue
+			}
+
+			cogs := valuation.Cogs[movement.TransactionId]
+
+			singleObject := CostOfGoodsSold{
+				TransactionId: movement.TransactionId,
+				SalesInvoice:  movement.DocumentNumber,
+				EntryDate:     movement.EntryDate,
+				ItemName:      names.ItemName,
+				ItemVariant:   names.ItemVariant,
+				WarehouseName: names.WarehouseName,
+				ClientName:    names.ClientName,
+				CustomerName:  movement.Counterparty,
+				Method:        method,
+				TotalPcs:      movement.TotalPcs,
+				Cogs:          cogs,
+				SalesValue:    movement.BaseTaxableValue,
+				Margin:        roundPaise(movement.BaseTaxableValue - cogs),
+			}
+
+			payload = append(payload, singleObject)
+		}
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		log.Println(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payloadJSON)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValueStock(t *testing.T) {
+	in := func(id string, pcs, cost float64) StockMovement {
+		return StockMovement{TransactionId: id, Direction: "in", TotalPcs: pcs, UnitCost: cost}
+	}
+	out := func(id string, pcs float64) StockMovement {
+		return StockMovement{TransactionId: id, Direction: "out", TotalPcs: pcs}
+	}
+
+	cases := []struct {
+		name      string
+		method    string
+		movements []StockMovement
+		pcs       float64
+		value     float64
+		cogs      map[string]float64
+	}{
+		{
+			name:      "FIFO consumes the oldest layer first",
+			method:    ValuationFIFO,
+			movements: []StockMovement{in("1", 10, 100), in("2", 10, 120), out("3", 15)},
+			pcs:       5,
+			value:     600,
+			cogs:      map[string]float64{"3": 1600},
+		},
+		{
+			name:      "weighted average costs at the moving average",
+			method:    ValuationWeightedAverage,
+			movements: []StockMovement{in("1", 10, 100), in("2", 10, 120), out("3", 15)},
+			pcs:       5,
+			value:     550,
+			cogs:      map[string]float64{"3": 1650},
+		},
+		{
+			name:      "FIFO carries an oversold shortfall below zero",
+			method:    ValuationFIFO,
+			movements: []StockMovement{in("1", 10, 100), out("2", 15)},
+			pcs:       -5,
+			value:     -500,
+			cogs:      map[string]float64{"2": 1500},
+		},
+		{
+			name:      "weighted average carries an oversold shortfall below zero",
+			method:    ValuationWeightedAverage,
+			movements: []StockMovement{in("1", 10, 100), out("2", 15)},
+			pcs:       -5,
+			value:     -500,
+			cogs:      map[string]float64{"2": 1500},
+		},
+		{
+			name:      "FIFO settles the shortfall before adding a layer",
+			method:    ValuationFIFO,
+			movements: []StockMovement{in("1", 10, 100), out("2", 15), in("3", 20, 120), out("4", 5)},
+			pcs:       10,
+			value:     1200,
+			cogs:      map[string]float64{"2": 1500, "4": 600},
+		},
+		{
+			name:      "weighted average settles the shortfall before moving the average",
+			method:    ValuationWeightedAverage,
+			movements: []StockMovement{in("1", 10, 100), out("2", 15), in("3", 20, 120), out("4", 5)},
+			pcs:       10,
+			value:     1200,
+			cogs:      map[string]float64{"2": 1500, "4": 600},
+		},
+		{
+			name:      "stock sold before any receipt is costed at nothing",
+			method:    ValuationFIFO,
+			movements: []StockMovement{out("1", 4), in("2", 6, 50)},
+			pcs:       2,
+			value:     100,
+			cogs:      map[string]float64{"1": 0},
+		},
+	}
+
+	for _, c := range cases {
+		result := ValueStock(c.method, c.movements)
+		if result.ClosingPcs != c.pcs || result.ClosingValue != c.value {
+			t.Errorf("%s: closing %g pcs worth %.2f, want %g worth %.2f", c.name, result.ClosingPcs, result.ClosingValue, c.pcs, c.value)
+		}
+		if !reflect.DeepEqual(result.Cogs, c.cogs) {
+			t.Errorf("%s: cogs = %v, want %v", c.name, result.Cogs, c.cogs)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Package einvoice holds the GST e-invoice (INV-01) document uploaded to the invoice registration portal,
+// along with the schema it has to satisfy
+package einvoice
+
+// SchemaVersion is the version of the e-invoice schema the documents follow
+const SchemaVersion = "1.1"
+
+// Invoice is an e-invoice as uploaded to the invoice registration portal
+type Invoice struct {
+	Version    string             `json:"Version"`
+	TranDtls   TransactionDetails `json:"TranDtls"`
+	DocDtls    DocumentDetails    `json:"DocDtls"`
+	SellerDtls Seller             `json:"SellerDtls"`
+	BuyerDtls  Buyer              `json:"BuyerDtls"`
+	ItemList   []Item             `json:"ItemList"`
+	ValDtls    ValueDetails       `json:"ValDtls"`
+}
+
+// TransactionDetails tell the tax scheme and the kind of supply
+type TransactionDetails struct {
+	TaxSch      string `json:"TaxSch"`
+	SupTyp      string `json:"SupTyp"`
+	RegRev      string `json:"RegRev"`
+	IgstOnIntra string `json:"IgstOnIntra"`
+}
+
+// DocumentDetails identify the invoice; the date is in DD/MM/YYYY
+type DocumentDetails struct {
+	Typ string `json:"Typ"`
+	No  string `json:"No"`
+	Dt  string `json:"Dt"`
+}
+
+// Seller is the registered supplier issuing the invoice
+type Seller struct {
+	Gstin string `json:"Gstin"`
+	LglNm string `json:"LglNm"`
+	Addr1 string `json:"Addr1"`
+	Loc   string `json:"Loc"`
+	Pin   int    `json:"Pin"`
+	Stcd  string `json:"Stcd"`
+}
+
+// Buyer is the recipient; Pos is the state code of the place of supply
+type Buyer struct {
+	Gstin string `json:"Gstin"`
+	LglNm string `json:"LglNm"`
+	Pos   string `json:"Pos"`
+	Addr1 string `json:"Addr1"`
+	Loc   string `json:"Loc"`
+	Pin   int    `json:"Pin"`
+	Stcd  string `json:"Stcd"`
+}
+
+// Item is a line of the invoice; all amounts are in rupees
+type Item struct {
+	SlNo       string  `json:"SlNo"`
+	PrdDesc    string  `json:"PrdDesc"`
+	IsServc    string  `json:"IsServc"`
+	HsnCd      string  `json:"HsnCd"`
+	Qty        float64 `json:"Qty"`
+	Unit       string  `json:"Unit"`
+	UnitPrice  float64 `json:"UnitPrice"`
+	TotAmt     float64 `json:"TotAmt"`
+	Discount   float64 `json:"Discount"`
+	AssAmt     float64 `json:"AssAmt"`
+	GstRt      float64 `json:"GstRt"`
+	IgstAmt    float64 `json:"IgstAmt"`
+	CgstAmt    float64 `json:"CgstAmt"`
+	SgstAmt    float64 `json:"SgstAmt"`
+	TotItemVal float64 `json:"TotItemVal"`
+}
+
+// ValueDetails are the totals of the invoice
+type ValueDetails struct {
+	AssVal    float64 `json:"AssVal"`
+	CgstVal   float64 `json:"CgstVal"`
+	SgstVal   float64 `json:"SgstVal"`
+	IgstVal   float64 `json:"IgstVal"`
+	Discount  float64 `json:"Discount"`
+	TotInvVal float64 `json:"TotInvVal"`
+}
+
+// unitAliases map the units people commonly type to the unit quantity codes of GST
+var unitAliases = map[string]string{
+	"PC":     "PCS",
+	"PIECE":  "PCS",
+	"PIECES": "PCS",
+	"NO":     "NOS",
+	"NUMBER": "NOS",
+	"KG":     "KGS",
+	"KILO":   "KGS",
+	"G":      "GMS",
+	"GM":     "GMS",
+	"GRAM":   "GMS",
+	"L":      "LTR",
+	"LITRE":  "LTR",
+	"ML":     "MLT",
+	"M":      "MTR",
+	"METRE":  "MTR",
+	"BOXES":  "BOX",
+	"PACK":   "PAC",
+	"PACKET": "PAC",
+	"PAIR":   "PRS",
+	"DOZEN":  "DOZ",
+	"CARTON": "CTN",
+	"ROLL":   "ROL",
+	"TONNE":  "TON",
+}
+
+// UnitCode returns the unit quantity code for a unit of measure, or OTH when it has none
+func UnitCode(unit string) string {
+	code := ""
+	for _, c := range unit {
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		if c >= 'A' && c <= 'Z' {
+			code += string(c)
+		}
+	}
+
+	if alias, ok := unitAliases[code]; ok {
+		return alias
+	}
+	for _, known := range unitCodes {
+		if code == known {
+			return code
+		}
+	}
+
+	return "OTH"
+}
@@ -0,0 +1,268 @@
+package einvoice
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kind is the JSON type a field must have
+type Kind int
+
+const (
+	Object Kind = iota
+	Array
+	String
+	Number
+)
+
+func (kind Kind) String() string {
+	return [...]string{"an object", "an array", "a string", "a number"}[kind]
+}
+
+// Field is a node of the schema: the constraints on one value of the document and, for objects and arrays, on what they hold
+type Field struct {
+	Name      string
+	Kind      Kind
+	Required  bool
+	MinLength int
+	MaxLength int
+	Pattern   *regexp.Regexp
+	Enum      []string
+	Minimum   float64
+	Maximum   float64
+	Rates     []float64
+	MinItems  int
+	MaxItems  int
+	Fields    []Field
+	Items     *Field
+}
+
+// ValidationError is a value of the document that breaks the schema
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// the builders the schema below is written with
+func text(name string, required bool, minLength int, maxLength int) Field {
+	return Field{Name: name, Kind: String, Required: required, MinLength: minLength, MaxLength: maxLength}
+}
+
+func pattern(field Field, expression string) Field {
+	field.Pattern = regexp.MustCompile(expression)
+	return field
+}
+
+func oneOf(name string, values ...string) Field {
+	return Field{Name: name, Kind: String, Required: true, Enum: values}
+}
+
+func amount(name string, required bool, maximum float64) Field {
+	return Field{Name: name, Kind: Number, Required: required, Minimum: 0, Maximum: maximum}
+}
+
+func object(name string, fields ...Field) Field {
+	return Field{Name: name, Kind: Object, Required: true, Fields: fields}
+}
+
+// unitCodes are the unit quantity codes of GST
+var unitCodes = []string{
+	"BAG", "BAL", "BDL", "BKL", "BOU", "BOX", "BTL", "BUN", "CAN", "CBM", "CCM", "CMS", "CTN", "DOZ", "DRM",
+	"GGK", "GMS", "GRS", "GYD", "KGS", "KLR", "KME", "LTR", "MLT", "MTR", "MTS", "NOS", "OTH", "PAC", "PCS",
+	"PRS", "QTL", "ROL", "SET", "SQF", "SQM", "SQY", "TBS", "TGM", "THD", "TON", "TUB", "UGS", "UNT", "YDS",
+}
+
+// the largest amounts the schema allows, on a line and on the invoice
+const (
+	maxLineAmount    = 999999999999.99
+	maxInvoiceAmount = 99999999999999.99
+)
+
+// Schema is the INV-01 e-invoice schema, limited to the parts of it this application fills in
+var Schema = object("",
+	text("Version", true, 1, 6),
+	object("TranDtls",
+		oneOf("TaxSch", "GST"),
+		oneOf("SupTyp", "B2B", "SEZWP", "SEZWOP", "EXPWP", "EXPWOP", "DEXP"),
+		oneOf("RegRev", "Y", "N"),
+		oneOf("IgstOnIntra", "Y", "N"),
+	),
+	object("DocDtls",
+		oneOf("Typ", "INV", "CRN", "DBN"),
+		pattern(text("No", true, 1, 16), `^[a-zA-Z1-9][a-zA-Z0-9/-]{0,15}$`),
+		pattern(text("Dt", true, 10, 10), `^[0-3][0-9]/[0-1][0-9]/20[1-9][0-9]$`),
+	),
+	object("SellerDtls",
+		pattern(text("Gstin", true, 15, 15), `^[0-9]{2}[0-9A-Z]{13}$`),
+		text("LglNm", true, 3, 100),
+		text("Addr1", true, 1, 100),
+		text("Loc", true, 3, 50),
+		Field{Name: "Pin", Kind: Number, Required: true, Minimum: 100000, Maximum: 999999},
+		pattern(text("Stcd", true, 1, 2), `^[0-9]{1,2}$`),
+	),
+	object("BuyerDtls",
+		pattern(text("Gstin", true, 3, 15), `^([0-9]{2}[0-9A-Z]{13}|URP)$`),
+		text("LglNm", true, 3, 100),
+		pattern(text("Pos", true, 1, 2), `^[0-9]{1,2}$`),
+		text("Addr1", true, 1, 100),
+		text("Loc", true, 3, 50),
+		Field{Name: "Pin", Kind: Number, Required: true, Minimum: 100000, Maximum: 999999},
+		pattern(text("Stcd", true, 1, 2), `^[0-9]{1,2}$`),
+	),
+	Field{Name: "ItemList", Kind: Array, Required: true, MinItems: 1, MaxItems: 1000, Items: &Field{Kind: Object, Fields: []Field{
+		pattern(text("SlNo", true, 1, 6), `^[0-9]+$`),
+		text("PrdDesc", false, 3, 300),
+		oneOf("IsServc", "Y", "N"),
+		pattern(text("HsnCd", true, 4, 8), `^[0-9]{4,8}$`),
+		Field{Name: "Qty", Kind: Number, Minimum: 0, Maximum: 9999999999.999},
+		Field{Name: "Unit", Kind: String, Enum: unitCodes},
+		amount("UnitPrice", true, 999999999999.999),
+		amount("TotAmt", true, maxLineAmount),
+		amount("Discount", false, maxLineAmount),
+		amount("AssAmt", true, maxLineAmount),
+		Field{Name: "GstRt", Kind: Number, Required: true, Rates: []float64{0, 0.1, 0.25, 1, 1.5, 3, 5, 6, 7.5, 12, 18, 28}},
+		amount("IgstAmt", false, maxLineAmount),
+		amount("CgstAmt", false, maxLineAmount),
+		amount("SgstAmt", false, maxLineAmount),
+		amount("TotItemVal", true, maxLineAmount),
+	}}},
+	object("ValDtls",
+		amount("AssVal", true, maxInvoiceAmount),
+		amount("CgstVal", false, maxInvoiceAmount),
+		amount("SgstVal", false, maxInvoiceAmount),
+		amount("IgstVal", false, maxInvoiceAmount),
+		amount("Discount", false, maxInvoiceAmount),
+		amount("TotInvVal", true, maxInvoiceAmount),
+	),
+)
+
+// Validate checks a document against the schema and returns every value that breaks it
+func Validate(document interface{}) ([]ValidationError, error) {
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, err
+	}
+
+	var errs []ValidationError
+	Schema.check("", decoded, &errs)
+
+	return errs, nil
+}
+
+// check validates one value, adding what is wrong with it and with anything it holds to errs
+func (field Field) check(path string, value interface{}, errs *[]ValidationError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch field.Kind {
+	case Object:
+		properties, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be %s", field.Kind)
+			return
+		}
+
+		known := map[string]bool{}
+		for _, child := range field.Fields {
+			known[child.Name] = true
+
+			childValue, present := properties[child.Name]
+			if !present || childValue == nil || childValue == "" {
+				if child.Required {
+					*errs = append(*errs, ValidationError{Path: join(path, child.Name), Message: "is required"})
+				}
+				continue
+			}
+			child.check(join(path, child.Name), childValue, errs)
+		}
+
+		var unknown []string
+		for name := range properties {
+			if !known[name] {
+				unknown = append(unknown, name)
+			}
+		}
+		sort.Strings(unknown)
+		for _, name := range unknown {
+			*errs = append(*errs, ValidationError{Path: join(path, name), Message: "is not part of the schema"})
+		}
+
+	case Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be %s", field.Kind)
+			return
+		}
+		if len(items) < field.MinItems || (field.MaxItems > 0 && len(items) > field.MaxItems) {
+			fail("must have %d to %d entries, has %d", field.MinItems, field.MaxItems, len(items))
+		}
+		for i, item := range items {
+			field.Items.check(fmt.Sprintf("%s[%d]", path, i), item, errs)
+		}
+
+	case String:
+		s, ok := value.(string)
+		if !ok {
+			fail("must be %s", field.Kind)
+			return
+		}
+		if field.MaxLength > 0 && (len(s) < field.MinLength || len(s) > field.MaxLength) {
+			fail("must be %d to %d characters, %q is %d", field.MinLength, field.MaxLength, s, len(s))
+		}
+		if field.Pattern != nil && !field.Pattern.MatchString(s) {
+			fail("%q does not match %s", s, field.Pattern)
+		}
+		if len(field.Enum) > 0 && !contains(field.Enum, s) {
+			fail("%q must be one of %s", s, strings.Join(field.Enum, ", "))
+		}
+
+	case Number:
+		n, ok := value.(float64)
+		if !ok {
+			fail("must be %s", field.Kind)
+			return
+		}
+		if len(field.Rates) > 0 {
+			allowed := false
+			for _, rate := range field.Rates {
+				if n == rate {
+					allowed = true
+				}
+			}
+			if !allowed {
+				fail("%g is not a GST rate", n)
+			}
+		} else if n < field.Minimum || n > field.Maximum {
+			fail("%g must be between %g and %g", n, field.Minimum, field.Maximum)
+		}
+	}
+}
+
+func join(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
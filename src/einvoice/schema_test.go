@@ -0,0 +1,101 @@
+package einvoice
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// validInvoice is an intra-state B2B invoice with one line that satisfies the schema
+func validInvoice() Invoice {
+	return Invoice{
+		Version:  SchemaVersion,
+		TranDtls: TransactionDetails{TaxSch: "GST", SupTyp: "B2B", RegRev: "N", IgstOnIntra: "N"},
+		DocDtls:  DocumentDetails{Typ: "INV", No: "WH1/26-27/00042", Dt: "15/04/2026"},
+		SellerDtls: Seller{
+			Gstin: "27AAPFU0939F1ZV", LglNm: "Seller Traders", Addr1: "1 Market Road", Loc: "Mumbai", Pin: 400001, Stcd: "27",
+		},
+		BuyerDtls: Buyer{
+			Gstin: "27AAPFU0939F1ZV", LglNm: "Buyer Stores", Pos: "27", Addr1: "2 Station Road", Loc: "Pune", Pin: 411001, Stcd: "27",
+		},
+		ItemList: []Item{{
+			SlNo: "1", PrdDesc: "Steel bolts", IsServc: "N", HsnCd: "73181500", Qty: 10, Unit: "PCS",
+			UnitPrice: 100, TotAmt: 1000, AssAmt: 1000, GstRt: 18, CgstAmt: 90, SgstAmt: 90, TotItemVal: 1180,
+		}},
+		ValDtls: ValueDetails{AssVal: 1000, CgstVal: 90, SgstVal: 90, TotInvVal: 1180},
+	}
+}
+
+func TestValidateAcceptsValidInvoice(t *testing.T) {
+	errs, err := Validate(validInvoice())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) > 0 {
+		t.Errorf("Validate of a valid invoice = %v, want no errors", errs)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	cases := []struct {
+		name    string
+		change  func(invoice *Invoice)
+		path    string
+		message string
+	}{
+		{"missing field", func(invoice *Invoice) { invoice.SellerDtls.LglNm = "" }, "SellerDtls.LglNm", "is required"},
+		{"length", func(invoice *Invoice) { invoice.BuyerDtls.Loc = "Pu" }, "BuyerDtls.Loc", "must be 3 to 50 characters"},
+		{"pattern", func(invoice *Invoice) { invoice.DocDtls.Dt = "2026-04-15" }, "DocDtls.Dt", "does not match"},
+		{"pattern on a line", func(invoice *Invoice) { invoice.ItemList[0].HsnCd = "73AB" }, "ItemList[0].HsnCd", "does not match"},
+		{"enum", func(invoice *Invoice) { invoice.TranDtls.SupTyp = "B2C" }, "TranDtls.SupTyp", "must be one of"},
+		{"unit enum", func(invoice *Invoice) { invoice.ItemList[0].Unit = "PIECES" }, "ItemList[0].Unit", "must be one of"},
+		{"rate", func(invoice *Invoice) { invoice.ItemList[0].GstRt = 17 }, "ItemList[0].GstRt", "is not a GST rate"},
+		{"range", func(invoice *Invoice) { invoice.SellerDtls.Pin = 99999 }, "SellerDtls.Pin", "must be between"},
+		{"no lines", func(invoice *Invoice) { invoice.ItemList = []Item{} }, "ItemList", "must have 1 to 1000 entries"},
+	}
+
+	for _, c := range cases {
+		invoice := validInvoice()
+		c.change(&invoice)
+
+		errs, err := Validate(invoice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(errs) != 1 || errs[0].Path != c.path || !strings.Contains(errs[0].Message, c.message) {
+			t.Errorf("%s: Validate = %v, want one error at %s containing %q", c.name, errs, c.path, c.message)
+		}
+	}
+}
+
+func TestValidateRejectsUnknownAndMistypedFields(t *testing.T) {
+	encoded, err := json.Marshal(validInvoice())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(encoded, &document); err != nil {
+		t.Fatal(err)
+	}
+	document["EwbDtls"] = map[string]interface{}{"Distance": 10}
+	document["ValDtls"].(map[string]interface{})["TotInvVal"] = "1180"
+
+	errs, err := Validate(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ValidationError{
+		{Path: "ValDtls.TotInvVal", Message: "must be a number"},
+		{Path: "EwbDtls", Message: "is not part of the schema"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Validate = %v, want %v", errs, want)
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Errorf("error %d = %v, want %v", i, errs[i], want[i])
+		}
+	}
+}
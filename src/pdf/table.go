@@ -0,0 +1,99 @@
+package pdf
+
+import "strings"
+
+// Column is a column of a table
+type Column struct {
+	Title      string
+	Width      float64
+	AlignRight bool
+}
+
+// the text size and row height of tables
+const (
+	tableTextSize  = 8.0
+	tableRowHeight = 14.0
+)
+
+// drawRow writes one row of cells inside a box, each cell clipped to its column
+func drawRow(page *Page, y float64, columns []Column, cells []string, font Font) {
+	x := Margin
+	for i, column := range columns {
+		if i > 0 {
+			page.Line(x, y, x, y+tableRowHeight)
+		}
+
+		if i < len(cells) {
+			text := Fit(cells[i], column.Width-6, tableTextSize, font)
+			if column.AlignRight {
+				page.TextRight(x+column.Width-3, y+10, tableTextSize, font, text)
+			} else {
+				page.Text(x+3, y+10, tableTextSize, font, text)
+			}
+		}
+
+		x += column.Width
+	}
+
+	page.Rect(Margin, y, x-Margin, tableRowHeight)
+}
+
+// Table draws a table with a bold header row starting at y, continuing onto new pages with the header repeated,
+// and returns the page and position it ended on
+func (document *Document) Table(page *Page, y float64, columns []Column, rows [][]string) (*Page, float64) {
+	titles := make([]string, len(columns))
+	for i, column := range columns {
+		titles[i] = column.Title
+	}
+
+	drawRow(page, y, columns, titles, Bold)
+	y += tableRowHeight
+
+	for _, row := range rows {
+		if y+tableRowHeight > PageHeight-Margin {
+			page = document.AddPage()
+			y = Margin
+			drawRow(page, y, columns, titles, Bold)
+			y += tableRowHeight
+		}
+
+		drawRow(page, y, columns, row, Regular)
+		y += tableRowHeight
+	}
+
+	return page, y
+}
+
+// Ensure returns a page with at least the given height free below y, starting a new page if needed
+func (document *Document) Ensure(page *Page, y float64, height float64) (*Page, float64) {
+	if y+height <= PageHeight-Margin {
+		return page, y
+	}
+
+	return document.AddPage(), Margin
+}
+
+// Wrap breaks text into lines that fit the width, on spaces
+func Wrap(text string, width float64, size float64, font Font) []string {
+	var lines []string
+	line := ""
+
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+
+		if line != "" && TextWidth(candidate, size, font) > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
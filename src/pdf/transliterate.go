@@ -0,0 +1,88 @@
+package pdf
+
+import "strings"
+
+// the standard fonts are set with their WinAnsi widths for printable ASCII only, so other characters are spelt out
+// in ASCII before they are measured or written
+var replacements = map[rune]string{
+	'₹':      "Rs.",
+	'€':      "EUR",
+	'£':      "GBP",
+	'‘':      "'",
+	'’':      "'",
+	'‚':      "'",
+	'′':      "'",
+	'“':      `"`,
+	'”':      `"`,
+	'„':      `"`,
+	'″':      `"`,
+	'‐':      "-",
+	'‑':      "-",
+	'‒':      "-",
+	'–':      "-",
+	'—':      "-",
+	'−':      "-",
+	'•':      "-",
+	'…':      "...",
+	'×':      "x",
+	'·':      ".",
+	'\t':     " ",
+	'\u00a0': " ",
+	'\u2009': " ",
+	'\u202f': " ",
+	'ß':      "ss",
+	'Æ':      "AE",
+	'æ':      "ae",
+	'Œ':      "OE",
+	'œ':      "oe",
+}
+
+// accented letters are written without their accents
+var unaccented = map[string]string{
+	"ÀÁÂÃÄÅĀ": "A",
+	"àáâãäåā": "a",
+	"ÇČ":      "C",
+	"çč":      "c",
+	"ÈÉÊËĒ":   "E",
+	"èéêëē":   "e",
+	"ÌÍÎÏĪ":   "I",
+	"ìíîïī":   "i",
+	"Ñ":       "N",
+	"ñ":       "n",
+	"ÒÓÔÕÖØŌ": "O",
+	"òóôõöøō": "o",
+	"Š":       "S",
+	"š":       "s",
+	"ÙÚÛÜŪ":   "U",
+	"ùúûüū":   "u",
+	"ÝŸ":      "Y",
+	"ýÿ":      "y",
+	"Ž":       "Z",
+	"ž":       "z",
+}
+
+func init() {
+	for letters, plain := range unaccented {
+		for _, letter := range letters {
+			replacements[letter] = plain
+		}
+	}
+}
+
+// transliterate spells text out in printable ASCII, replacing what has no spelling by a question mark
+func transliterate(text string) string {
+	var plain strings.Builder
+
+	for _, r := range text {
+		switch replacement, ok := replacements[r]; {
+		case ok:
+			plain.WriteString(replacement)
+		case r < 32 || r > 126:
+			plain.WriteByte('?')
+		default:
+			plain.WriteRune(r)
+		}
+	}
+
+	return plain.String()
+}
@@ -0,0 +1,156 @@
+// Package pdf writes simple A4 documents of text, lines and tables using the standard Helvetica fonts,
+// which every PDF viewer provides, so no font has to be embedded
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// the A4 page size and the margin kept free on every side, in points
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+	Margin     = 36.0
+)
+
+// Font is one of the two standard fonts a document can use
+type Font int
+
+const (
+	Regular Font = iota
+	Bold
+)
+
+// Document is a PDF being built page by page
+type Document struct {
+	pages []*Page
+}
+
+// Page is a single page; positions are measured in points from the top left corner
+type Page struct {
+	content bytes.Buffer
+}
+
+// New starts an empty document
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage appends a blank page and returns it
+func (document *Document) AddPage() *Page {
+	page := &Page{}
+	document.pages = append(document.pages, page)
+	return page
+}
+
+// escapeText encodes text as a PDF string, transliterating what the fonts cannot show
+func escapeText(text string) string {
+	var escaped strings.Builder
+
+	for _, r := range transliterate(text) {
+		if r == '(' || r == ')' || r == '\\' {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(r)
+	}
+
+	return escaped.String()
+}
+
+// Text writes text with its baseline at the given position
+func (page *Page) Text(x float64, y float64, size float64, font Font, text string) {
+	fmt.Fprintf(&page.content, "BT /F%d %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font+1, size, x, PageHeight-y, escapeText(text))
+}
+
+// TextRight writes text ending at the given position
+func (page *Page) TextRight(x float64, y float64, size float64, font Font, text string) {
+	page.Text(x-TextWidth(text, size, font), y, size, font, text)
+}
+
+// TextCenter writes text centred on the given position
+func (page *Page) TextCenter(x float64, y float64, size float64, font Font, text string) {
+	page.Text(x-TextWidth(text, size, font)/2, y, size, font, text)
+}
+
+// Line draws a thin line between two points
+func (page *Page) Line(x1 float64, y1 float64, x2 float64, y2 float64) {
+	fmt.Fprintf(&page.content, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, PageHeight-y1, x2, PageHeight-y2)
+}
+
+// Rect draws the outline of a rectangle given its top left corner
+func (page *Page) Rect(x float64, y float64, width float64, height float64) {
+	fmt.Fprintf(&page.content, "0.5 w %.2f %.2f %.2f %.2f re S\n", x, PageHeight-y-height, width, height)
+}
+
+// TextWidth measures text in points
+func TextWidth(text string, size float64, font Font) float64 {
+	widths := helveticaWidths
+	if font == Bold {
+		widths = helveticaBoldWidths
+	}
+
+	total := 0
+	for _, r := range transliterate(text) {
+		total += widths[r-32]
+	}
+
+	return float64(total) * size / 1000
+}
+
+// Fit shortens text with an ellipsis until it fits the width
+func Fit(text string, width float64, size float64, font Font) string {
+	if TextWidth(text, size, font) <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 && TextWidth(string(runes)+"...", size, font) > width {
+		runes = runes[:len(runes)-1]
+	}
+
+	return string(runes) + "..."
+}
+
+// WriteTo writes the finished document
+func (document *Document) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	if len(document.pages) == 0 {
+		document.AddPage()
+	}
+
+	// objects 1 to 4 are the catalog, the page tree and the two fonts; each page then takes two, itself and its content
+	var kids []string
+	for i := range document.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+
+	out.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(document.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range document.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", PageWidth, PageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.content.Len(), page.content.String()))
+	}
+
+	xrefOffset := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOffset)
+
+	return out.WriteTo(w)
+}
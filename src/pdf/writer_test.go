@@ -0,0 +1,98 @@
+package pdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTransliterate(t *testing.T) {
+	cases := []struct {
+		text  string
+		plain string
+	}{
+		{"Total ₹ 1,180.00", "Total Rs. 1,180.00"},
+		{"Café Müller – “Pune”", `Cafe Muller - "Pune"`},
+		{"12 × 500\u00a0ml…", "12 x 500 ml..."},
+		{"नमस्ते", "??????"},
+		{"plain (ASCII)", "plain (ASCII)"},
+	}
+
+	for _, c := range cases {
+		if plain := transliterate(c.text); plain != c.plain {
+			t.Errorf("transliterate(%q) = %q, want %q", c.text, plain, c.plain)
+		}
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	if escaped := escapeText(`₹ (net) \ 5`); escaped != `Rs. \(net\) \\ 5` {
+		t.Errorf("escapeText = %s", escaped)
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	if width, plain := TextWidth("₹ 500", 10, Regular), TextWidth("Rs. 500", 10, Regular); width != plain {
+		t.Errorf("₹ 500 is %.2f wide, Rs. 500 is %.2f", width, plain)
+	}
+
+	// Helvetica sets digits at 556 thousandths and Bold widens most lower case letters
+	if width := TextWidth("100", 10, Regular); width != 16.68 {
+		t.Errorf("100 at 10pt is %.2f wide, want 16.68", width)
+	}
+	if TextWidth("bank", 10, Bold) <= TextWidth("bank", 10, Regular) {
+		t.Error("bold is not wider than regular")
+	}
+}
+
+func TestFit(t *testing.T) {
+	text := "Ceramic mugs for Café Müller at ₹ 75 each"
+	if fitted := Fit(text, 1000, 10, Regular); fitted != text {
+		t.Errorf("text that fits was shortened to %q", fitted)
+	}
+
+	fitted := Fit(text, 80, 10, Regular)
+	if !strings.HasSuffix(fitted, "...") || TextWidth(fitted, 10, Regular) > 80 {
+		t.Errorf("Fit = %q, %.2f wide", fitted, TextWidth(fitted, 10, Regular))
+	}
+}
+
+func TestWrap(t *testing.T) {
+	lines := Wrap("one two three four five six", 60, 10, Regular)
+	if len(lines) < 2 || strings.Join(lines, " ") != "one two three four five six" {
+		t.Errorf("Wrap = %q", lines)
+	}
+	for _, line := range lines {
+		if TextWidth(line, 10, Regular) > 60 {
+			t.Errorf("line %q is wider than 60", line)
+		}
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	document := New()
+	page := document.AddPage()
+	page.Text(Margin, Margin, 10, Bold, "Invoice total ₹ 1,180.00")
+	document.AddPage()
+
+	var out bytes.Buffer
+	if _, err := document.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	written := out.String()
+	if !strings.HasPrefix(written, "%PDF-1.4\n") || !strings.HasSuffix(written, "%%EOF\n") {
+		t.Error("the document is not framed as a PDF")
+	}
+	if !strings.Contains(written, "/Count 2") {
+		t.Error("the page tree does not hold both pages")
+	}
+	if !strings.Contains(written, "(Invoice total Rs. 1,180.00) Tj") {
+		t.Error("the rupee sign was not spelt out")
+	}
+	for _, r := range written {
+		if r > 126 {
+			t.Fatalf("the document holds %q, which the fonts cannot show", r)
+		}
+	}
+}
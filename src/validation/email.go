@@ -0,0 +1,27 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// NormalizeEmail trims the spaces users commonly type around an email address
+func NormalizeEmail(email string) string {
+	return strings.TrimSpace(email)
+}
+
+// ValidateEmail checks that an email is a single bare address, such as accounts@example.com, which can be placed in a
+// mail header as it is; an empty email is allowed
+func ValidateEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != email {
+		return fmt.Errorf("email %q must be a single address such as accounts@example.com", email)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+// Package validation holds the offline checks applied to master data before
+// it is written, such as GSTIN and HSN/SAC codes
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const gstinCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// 2 digit state code, 10 character PAN, entity number, 'Z' and the check character
+var gstinPattern = regexp.MustCompile(`^[0-9]{2}[A-Z]{5}[0-9]{4}[A-Z][1-9A-Z]Z[0-9A-Z]$`)
+
+// StateCodes maps the GST state codes to the state or union territory name
+var StateCodes = map[string]string{
+	"01": "Jammu and Kashmir",
+	"02": "Himachal Pradesh",
+	"03": "Punjab",
+	"04": "Chandigarh",
+	"05": "Uttarakhand",
+	"06": "Haryana",
+	"07": "Delhi",
+	"08": "Rajasthan",
+	"09": "Uttar Pradesh",
+	"10": "Bihar",
+	"11": "Sikkim",
+	"12": "Arunachal Pradesh",
+	"13": "Nagaland",
+	"14": "Manipur",
+	"15": "Mizoram",
+	"16": "Tripura",
+	"17": "Meghalaya",
+	"18": "Assam",
+	"19": "West Bengal",
+	"20": "Jharkhand",
+	"21": "Odisha",
+	"22": "Chhattisgarh",
+	"23": "Madhya Pradesh",
+	"24": "Gujarat",
+	"25": "Daman and Diu",
+	"26": "Dadra and Nagar Haveli and Daman and Diu",
+	"27": "Maharashtra",
+	"28": "Andhra Pradesh (Old)",
+	"29": "Karnataka",
+	"30": "Goa",
+	"31": "Lakshadweep",
+	"32": "Kerala",
+	"33": "Tamil Nadu",
+	"34": "Puducherry",
+	"35": "Andaman and Nicobar Islands",
+	"36": "Telangana",
+	"37": "Andhra Pradesh",
+	"38": "Ladakh",
+	"97": "Other Territory",
+	"99": "Centre Jurisdiction",
+}
+
+// ErrEmptyGSTIN is returned when no GSTIN has been supplied
+var ErrEmptyGSTIN = errors.New("gstin is empty")
+
+// NormalizeGSTIN trims and upper-cases a GSTIN as entered by the user
+func NormalizeGSTIN(gstin string) string {
+	return strings.ToUpper(strings.TrimSpace(gstin))
+}
+
+// GSTINChecksum computes the check character for the first 14 characters of a GSTIN
+func GSTINChecksum(body string) (byte, error) {
+	if len(body) < 14 {
+		return 0, fmt.Errorf("gstin body %q is shorter than 14 characters", body)
+	}
+
+	sum := 0
+	for i := 0; i < 14; i++ {
+		value := strings.IndexByte(gstinCharset, body[i])
+		if value < 0 {
+			return 0, fmt.Errorf("gstin contains invalid character %q", body[i])
+		}
+
+		factor := 1
+		if i%2 == 1 {
+			factor = 2
+		}
+
+		product := value * factor
+		sum += product/len(gstinCharset) + product%len(gstinCharset)
+	}
+
+	check := (len(gstinCharset) - sum%len(gstinCharset)) % len(gstinCharset)
+	return gstinCharset[check], nil
+}
+
+// ValidateGSTIN checks the format, state code and checksum of a GSTIN
+func ValidateGSTIN(gstin string) error {
+	gstin = NormalizeGSTIN(gstin)
+
+	if gstin == "" {
+		return ErrEmptyGSTIN
+	}
+	if !gstinPattern.MatchString(gstin) {
+		return fmt.Errorf("gstin %q is not in the 15 character GSTIN format", gstin)
+	}
+	if _, ok := StateCodes[gstin[:2]]; !ok {
+		return fmt.Errorf("gstin %q has unknown state code %s", gstin, gstin[:2])
+	}
+
+	check, err := GSTINChecksum(gstin)
+	if err != nil {
+		return err
+	}
+	if check != gstin[14] {
+		return fmt.Errorf("gstin %q fails checksum, expected check character %c", gstin, check)
+	}
+
+	return nil
+}
+
+// GSTINStateCode returns the 2 digit state code of a valid GSTIN
+func GSTINStateCode(gstin string) (string, error) {
+	if err := ValidateGSTIN(gstin); err != nil {
+		return "", err
+	}
+
+	return NormalizeGSTIN(gstin)[:2], nil
+}
@@ -0,0 +1,70 @@
+package validation
+
+import "testing"
+
+func TestValidateGSTIN(t *testing.T) {
+	cases := []struct {
+		gstin string
+		valid bool
+	}{
+		{"27AAPFU0939F1ZV", true},
+		{"29AAGCB7383J1Z4", true},
+		{"33AAACH7409R1Z8", true},
+		{" 27aapfu0939f1zv ", true},
+		{"", false},
+		{"27AAPFU0939F1Z", false},
+		{"27AAPFU0939F1ZVX", false},
+		{"27AAPFU0939F1YV", false},
+		{"2AAAPFU0939F1ZV", false},
+		{"00AAPFU0939F1ZV", false},
+		{"27AAPFU0939F1ZW", false},
+		{"29AAGCB7383J1Z5", false},
+	}
+
+	for _, c := range cases {
+		err := ValidateGSTIN(c.gstin)
+		if c.valid && err != nil {
+			t.Errorf("ValidateGSTIN(%q) = %v, want valid", c.gstin, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("ValidateGSTIN(%q) = nil, want an error", c.gstin)
+		}
+	}
+
+	if err := ValidateGSTIN(""); err != ErrEmptyGSTIN {
+		t.Errorf("ValidateGSTIN(\"\") = %v, want ErrEmptyGSTIN", err)
+	}
+}
+
+func TestGSTINChecksum(t *testing.T) {
+	cases := []struct {
+		body  string
+		check byte
+	}{
+		{"27AAPFU0939F1Z", 'V'},
+		{"29AAGCB7383J1Z", '4'},
+		{"33AAACH7409R1Z", '8'},
+	}
+
+	for _, c := range cases {
+		check, err := GSTINChecksum(c.body)
+		if err != nil || check != c.check {
+			t.Errorf("GSTINChecksum(%q) = %c, %v, want %c", c.body, check, err, c.check)
+		}
+	}
+
+	for _, body := range []string{"27AAPFU0939F1", "27aapfu0939f1z", "27AAPFU0939F1-"} {
+		if _, err := GSTINChecksum(body); err == nil {
+			t.Errorf("GSTINChecksum(%q) = nil error, want one", body)
+		}
+	}
+}
+
+func TestGSTINStateCode(t *testing.T) {
+	if code, err := GSTINStateCode("27aapfu0939f1zv"); err != nil || code != "27" {
+		t.Errorf("GSTINStateCode = %q, %v, want 27", code, err)
+	}
+	if _, err := GSTINStateCode("27AAPFU0939F1ZW"); err == nil {
+		t.Error("GSTINStateCode of a GSTIN failing its checksum = nil error, want one")
+	}
+}
@@ -0,0 +1,64 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrEmptyHSN is returned when no HSN/SAC code has been supplied
+var ErrEmptyHSN = errors.New("hsn code is empty")
+
+// NormalizeHSN strips the spaces and dots users commonly type into HSN codes
+func NormalizeHSN(code string) string {
+	code = strings.TrimSpace(code)
+	code = strings.Replace(code, ".", "", -1)
+	code = strings.Replace(code, " ", "", -1)
+	return code
+}
+
+// IsSAC reports whether the code is a Services Accounting Code rather than a goods HSN
+func IsSAC(code string) bool {
+	return strings.HasPrefix(NormalizeHSN(code), "99")
+}
+
+// LookupHSN returns the description of the most specific known prefix of an HSN/SAC code
+func LookupHSN(code string) (string, bool) {
+	code = NormalizeHSN(code)
+
+	for length := len(code); length >= 2; length-- {
+		if description, ok := hsnCodes[code[:length]]; ok {
+			return description, true
+		}
+	}
+
+	return "", false
+}
+
+// ValidateHSN checks that an HSN/SAC code is numeric, of a valid length and belongs to a known chapter
+func ValidateHSN(code string) error {
+	code = NormalizeHSN(code)
+
+	if code == "" {
+		return ErrEmptyHSN
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return fmt.Errorf("hsn code %q must contain only digits", code)
+		}
+	}
+
+	if IsSAC(code) {
+		if len(code) != 4 && len(code) != 6 {
+			return fmt.Errorf("sac code %q must be 4 or 6 digits", code)
+		}
+	} else if len(code) != 4 && len(code) != 6 && len(code) != 8 {
+		return fmt.Errorf("hsn code %q must be 4, 6 or 8 digits", code)
+	}
+
+	if _, ok := hsnCodes[code[:2]]; !ok {
+		return fmt.Errorf("hsn code %q belongs to unknown chapter %s", code, code[:2])
+	}
+
+	return nil
+}
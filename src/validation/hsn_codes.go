@@ -0,0 +1,153 @@
+package validation
+
+// hsnCodes is the offline HSN/SAC code list, keyed by chapter, heading or sub-heading.
+// Lookups fall back to the longest known prefix, so every code resolves at least to its chapter.
+var hsnCodes = map[string]string{
+	"01": "Live animals",
+	"02": "Meat and edible meat offal",
+	"03": "Fish and crustaceans, molluscs and other aquatic invertebrates",
+	"04": "Dairy produce; birds' eggs; natural honey; edible products of animal origin",
+	"05": "Products of animal origin, not elsewhere specified or included",
+	"06": "Live trees and other plants; bulbs, roots; cut flowers and ornamental foliage",
+	"07": "Edible vegetables and certain roots and tubers",
+	"08": "Edible fruit and nuts; peel of citrus fruit or melons",
+	"09": "Coffee, tea, mate and spices",
+	"10": "Cereals",
+	"11": "Products of the milling industry; malt; starches; inulin; wheat gluten",
+	"12": "Oil seeds and oleaginous fruits; miscellaneous grains, seeds and fruit",
+	"13": "Lac; gums, resins and other vegetable saps and extracts",
+	"14": "Vegetable plaiting materials; vegetable products not elsewhere specified",
+	"15": "Animal or vegetable fats and oils and their cleavage products",
+	"16": "Preparations of meat, of fish or of crustaceans, molluscs",
+	"17": "Sugars and sugar confectionery",
+	"18": "Cocoa and cocoa preparations",
+	"19": "Preparations of cereals, flour, starch or milk; pastrycooks' products",
+	"20": "Preparations of vegetables, fruit, nuts or other parts of plants",
+	"21": "Miscellaneous edible preparations",
+	"22": "Beverages, spirits and vinegar",
+	"23": "Residues and waste from the food industries; prepared animal fodder",
+	"24": "Tobacco and manufactured tobacco substitutes",
+	"25": "Salt; sulphur; earths and stone; plastering materials, lime and cement",
+	"26": "Ores, slag and ash",
+	"27": "Mineral fuels, mineral oils and products of their distillation",
+	"28": "Inorganic chemicals; compounds of precious metals, rare-earth metals",
+	"29": "Organic chemicals",
+	"30": "Pharmaceutical products",
+	"31": "Fertilisers",
+	"32": "Tanning or dyeing extracts; dyes, pigments, paints and varnishes; inks",
+	"33": "Essential oils and resinoids; perfumery, cosmetic or toilet preparations",
+	"34": "Soap, organic surface-active agents, washing and lubricating preparations, candles",
+	"35": "Albuminoidal substances; modified starches; glues; enzymes",
+	"36": "Explosives; pyrotechnic products; matches; pyrophoric alloys",
+	"37": "Photographic or cinematographic goods",
+	"38": "Miscellaneous chemical products",
+	"39": "Plastics and articles thereof",
+	"40": "Rubber and articles thereof",
+	"41": "Raw hides and skins (other than furskins) and leather",
+	"42": "Articles of leather; saddlery and harness; travel goods, handbags",
+	"43": "Furskins and artificial fur; manufactures thereof",
+	"44": "Wood and articles of wood; wood charcoal",
+	"45": "Cork and articles of cork",
+	"46": "Manufactures of straw, of esparto or of other plaiting materials; basketware",
+	"47": "Pulp of wood or of other fibrous cellulosic material; recovered paper",
+	"48": "Paper and paperboard; articles of paper pulp, of paper or of paperboard",
+	"49": "Printed books, newspapers, pictures and other products of the printing industry",
+	"50": "Silk",
+	"51": "Wool, fine or coarse animal hair; horsehair yarn and woven fabric",
+	"52": "Cotton",
+	"53": "Other vegetable textile fibres; paper yarn and woven fabrics of paper yarn",
+	"54": "Man-made filaments; strip and the like of man-made textile materials",
+	"55": "Man-made staple fibres",
+	"56": "Wadding, felt and nonwovens; special yarns; twine, cordage, ropes and cables",
+	"57": "Carpets and other textile floor coverings",
+	"58": "Special woven fabrics; tufted textile fabrics; lace; tapestries; trimmings",
+	"59": "Impregnated, coated, covered or laminated textile fabrics",
+	"60": "Knitted or crocheted fabrics",
+	"61": "Articles of apparel and clothing accessories, knitted or crocheted",
+	"62": "Articles of apparel and clothing accessories, not knitted or crocheted",
+	"63": "Other made up textile articles; sets; worn clothing; rags",
+	"64": "Footwear, gaiters and the like; parts of such articles",
+	"65": "Headgear and parts thereof",
+	"66": "Umbrellas, sun umbrellas, walking-sticks, whips, riding-crops",
+	"67": "Prepared feathers and down; artificial flowers; articles of human hair",
+	"68": "Articles of stone, plaster, cement, asbestos, mica or similar materials",
+	"69": "Ceramic products",
+	"70": "Glass and glassware",
+	"71": "Natural or cultured pearls, precious or semi-precious stones, precious metals",
+	"72": "Iron and steel",
+	"73": "Articles of iron or steel",
+	"74": "Copper and articles thereof",
+	"75": "Nickel and articles thereof",
+	"76": "Aluminium and articles thereof",
+	"78": "Lead and articles thereof",
+	"79": "Zinc and articles thereof",
+	"80": "Tin and articles thereof",
+	"81": "Other base metals; cermets; articles thereof",
+	"82": "Tools, implements, cutlery, spoons and forks, of base metal",
+	"83": "Miscellaneous articles of base metal",
+	"84": "Nuclear reactors, boilers, machinery and mechanical appliances; parts thereof",
+	"85": "Electrical machinery and equipment and parts thereof; sound and television apparatus",
+	"86": "Railway or tramway locomotives, rolling-stock and parts thereof",
+	"87": "Vehicles other than railway or tramway rolling-stock, and parts thereof",
+	"88": "Aircraft, spacecraft, and parts thereof",
+	"89": "Ships, boats and floating structures",
+	"90": "Optical, photographic, measuring, checking, medical or surgical instruments",
+	"91": "Clocks and watches and parts thereof",
+	"92": "Musical instruments; parts and accessories of such articles",
+	"93": "Arms and ammunition; parts and accessories thereof",
+	"94": "Furniture; bedding, mattresses, cushions; lamps and lighting fittings; prefabricated buildings",
+	"95": "Toys, games and sports requisites; parts and accessories thereof",
+	"96": "Miscellaneous manufactured articles",
+	"97": "Works of art, collectors' pieces and antiques",
+	"98": "Project imports, laboratory chemicals, passengers' baggage, personal importations",
+
+	"3401": "Soap; organic surface-active products for use as soap",
+	"3402": "Organic surface-active agents; washing and cleaning preparations",
+	"3923": "Plastic articles for the conveyance or packing of goods",
+	"3924": "Plastic tableware, kitchenware, other household and toilet articles",
+	"4819": "Cartons, boxes, cases, bags and other packing containers of paper",
+	"6109": "T-shirts, singlets and other vests, knitted or crocheted",
+	"7323": "Table, kitchen or other household articles of iron or steel",
+	"8414": "Air or vacuum pumps, compressors and fans",
+	"8418": "Refrigerators, freezers and other refrigerating equipment",
+	"8471": "Automatic data processing machines and units thereof",
+	"8504": "Electrical transformers, static converters and inductors",
+	"8516": "Electric water heaters, space heaters, hair dryers, electric irons",
+	"8517": "Telephone sets and other apparatus for transmission of voice, images or data",
+	"8528": "Monitors and projectors; television reception apparatus",
+	"8536": "Electrical apparatus for switching or protecting electrical circuits, below 1000 volts",
+	"8544": "Insulated wire, cable and other insulated electric conductors",
+	"9403": "Other furniture and parts thereof",
+	"9405": "Lamps and lighting fittings",
+
+	"99":     "Services",
+	"9954":   "Construction services",
+	"9961":   "Services in wholesale trade",
+	"9962":   "Services in retail trade",
+	"9963":   "Accommodation, food and beverage services",
+	"9964":   "Passenger transport services",
+	"9965":   "Goods transport services",
+	"996511": "Road transport services of goods",
+	"9966":   "Rental services of transport vehicles with operators",
+	"9967":   "Supporting services in transport",
+	"996711": "Cargo handling services",
+	"996729": "Storage and warehousing services",
+	"9968":   "Postal and courier services",
+	"9971":   "Financial and related services",
+	"9972":   "Real estate services",
+	"9973":   "Leasing or rental services without operator",
+	"9981":   "Research and development services",
+	"9982":   "Legal and accounting services",
+	"9983":   "Other professional, technical and business services",
+	"9984":   "Telecommunications, broadcasting and information supply services",
+	"9985":   "Support services",
+	"9986":   "Support services to agriculture, hunting, forestry, fishing, mining and utilities",
+	"9987":   "Maintenance, repair and installation (except construction) services",
+	"9988":   "Manufacturing services on physical inputs owned by others",
+	"9991":   "Public administration services",
+	"9992":   "Education services",
+	"9993":   "Human health and social care services",
+	"9995":   "Services of membership organisations",
+	"9996":   "Recreational, cultural and sporting services",
+	"9997":   "Other services",
+}
@@ -0,0 +1,70 @@
+package validation
+
+import "testing"
+
+func TestValidateHSN(t *testing.T) {
+	cases := []struct {
+		code  string
+		valid bool
+	}{
+		{"8471", true},
+		{"847130", true},
+		{"84713010", true},
+		{"8471.30.10", true},
+		{" 8471 30 ", true},
+		{"9983", true},
+		{"998314", true},
+		{"", false},
+		{"84", false},
+		{"84713", false},
+		{"847130101", false},
+		{"84A1", false},
+		{"99831410", false},
+		{"7701", false},
+	}
+
+	for _, c := range cases {
+		err := ValidateHSN(c.code)
+		if c.valid && err != nil {
+			t.Errorf("ValidateHSN(%q) = %v, want valid", c.code, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("ValidateHSN(%q) = nil, want an error", c.code)
+		}
+	}
+
+	if err := ValidateHSN(" "); err != ErrEmptyHSN {
+		t.Errorf("ValidateHSN(\" \") = %v, want ErrEmptyHSN", err)
+	}
+}
+
+func TestLookupHSN(t *testing.T) {
+	cases := []struct {
+		code        string
+		description string
+		found       bool
+	}{
+		{"8471", "Automatic data processing machines and units thereof", true},
+		{"84713010", "Automatic data processing machines and units thereof", true},
+		{"9983.14", "Other professional, technical and business services", true},
+		{"8501", hsnCodes["85"], true},
+		{"7701", "", false},
+		{"", "", false},
+	}
+
+	for _, c := range cases {
+		description, found := LookupHSN(c.code)
+		if description != c.description || found != c.found {
+			t.Errorf("LookupHSN(%q) = %q, %v, want %q, %v", c.code, description, found, c.description, c.found)
+		}
+	}
+}
+
+func TestIsSAC(t *testing.T) {
+	if !IsSAC("9983") || !IsSAC(" 99.83 ") {
+		t.Error("IsSAC of a services code = false, want true")
+	}
+	if IsSAC("8471") {
+		t.Error("IsSAC of a goods code = true, want false")
+	}
+}
@@ -0,0 +1,33 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NormalizePincode strips the spaces users commonly type into PIN codes
+func NormalizePincode(pincode string) string {
+	return strings.Replace(strings.TrimSpace(pincode), " ", "", -1)
+}
+
+// ValidatePincode checks that a postal PIN code is six digits not starting with zero; an empty code is allowed
+func ValidatePincode(pincode string) error {
+	pincode = NormalizePincode(pincode)
+
+	if pincode == "" {
+		return nil
+	}
+	if len(pincode) != 6 {
+		return fmt.Errorf("pincode %q must be 6 digits", pincode)
+	}
+	for _, c := range pincode {
+		if c < '0' || c > '9' {
+			return fmt.Errorf("pincode %q must contain only digits", pincode)
+		}
+	}
+	if pincode[0] == '0' {
+		return fmt.Errorf("pincode %q cannot start with 0", pincode)
+	}
+
+	return nil
+}
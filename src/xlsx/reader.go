@@ -0,0 +1,249 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// the largest sheet Excel itself allows, beyond which a row or cell reference can only be corrupt or hostile
+const (
+	maxRows    = 1048576
+	maxColumns = 16384
+)
+
+// maxPartSize is the most a part of a workbook may unpack to, so that a small upload cannot expand without bound
+const maxPartSize = 128 << 20
+
+type workbookPart struct {
+	Sheets []struct {
+		RelationshipId string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relationshipsPart struct {
+	Relationships []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// richText is a string made of runs, as shared and inline strings can be
+type richText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (text richText) String() string {
+	if len(text.Runs) == 0 {
+		return text.Text
+	}
+
+	var joined strings.Builder
+	for _, run := range text.Runs {
+		joined.WriteString(run.Text)
+	}
+	return joined.String()
+}
+
+type sharedStringsPart struct {
+	Items []richText `xml:"si"`
+}
+
+type worksheetPart struct {
+	Rows []struct {
+		Number int `xml:"r,attr"`
+		Cells  []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline richText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// ReadRows reads the cells of the first sheet of an XLSX file as text, one slice per row; rows the sheet
+// skips are returned empty so that the index of a row is always its number less one
+func ReadRows(r io.ReaderAt, size int64) ([][]string, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("file is not an XLSX workbook")
+	}
+
+	parts := map[string]*zip.File{}
+	for _, file := range archive.File {
+		parts[file.Name] = file
+	}
+
+	sheetName, err := firstSheet(parts)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared sharedStringsPart
+	if file, ok := parts["xl/sharedStrings.xml"]; ok {
+		if err := decodePart(file, &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	var sheet worksheetPart
+	if err := decodePart(parts[sheetName], &sheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for i, row := range sheet.Rows {
+		number := row.Number
+		if number == 0 {
+			number = len(rows) + 1
+		}
+		if number < len(rows)+1 {
+			return nil, fmt.Errorf("row %d of the sheet is out of order", i+1)
+		}
+		if number > maxRows {
+			return nil, fmt.Errorf("row %d is past the last row a sheet can have", number)
+		}
+		for len(rows) < number {
+			rows = append(rows, nil)
+		}
+
+		var cells []string
+		for _, cell := range row.Cells {
+			column := len(cells)
+			if cell.Ref != "" {
+				if column, err = columnIndex(cell.Ref); err != nil {
+					return nil, err
+				}
+			}
+			for len(cells) <= column {
+				cells = append(cells, "")
+			}
+
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(cell.Value)
+				if err != nil || index < 0 || index >= len(shared.Items) {
+					return nil, fmt.Errorf("cell %s refers to a missing shared string", cell.Ref)
+				}
+				cells[column] = shared.Items[index].String()
+			case "inlineStr":
+				cells[column] = cell.Inline.String()
+			case "", "n":
+				cells[column] = cleanNumber(cell.Value)
+			default:
+				cells[column] = cell.Value
+			}
+		}
+
+		rows[number-1] = cells
+	}
+
+	return rows, nil
+}
+
+// firstSheet finds the part holding the first sheet of the workbook
+func firstSheet(parts map[string]*zip.File) (string, error) {
+	const fallback = "xl/worksheets/sheet1.xml"
+
+	workbookFile, ok := parts["xl/workbook.xml"]
+	if !ok {
+		return "", errors.New("file is not an XLSX workbook")
+	}
+
+	var workbook workbookPart
+	var relationships relationshipsPart
+	if err := decodePart(workbookFile, &workbook); err != nil {
+		return "", err
+	}
+	if relsFile, ok := parts["xl/_rels/workbook.xml.rels"]; ok {
+		if err := decodePart(relsFile, &relationships); err != nil {
+			return "", err
+		}
+	}
+
+	if len(workbook.Sheets) > 0 {
+		for _, relationship := range relationships.Relationships {
+			if relationship.Id != workbook.Sheets[0].RelationshipId {
+				continue
+			}
+			name := strings.TrimPrefix(relationship.Target, "/")
+			if !strings.HasPrefix(relationship.Target, "/") {
+				name = path.Join("xl", relationship.Target)
+			}
+			if _, ok := parts[name]; ok {
+				return name, nil
+			}
+		}
+	}
+
+	if _, ok := parts[fallback]; ok {
+		return fallback, nil
+	}
+	return "", errors.New("workbook has no sheets")
+}
+
+func decodePart(file *zip.File, v interface{}) error {
+	tooLarge := fmt.Errorf("%s unpacks to more than %d MB", file.Name, maxPartSize>>20)
+	if file.UncompressedSize64 > maxPartSize {
+		return tooLarge
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// the size in the archive is only what it claims, so the reading itself stops past the limit
+	limited := &io.LimitedReader{R: reader, N: maxPartSize + 1}
+	if err := xml.NewDecoder(limited).Decode(v); err != nil {
+		if limited.N <= 0 {
+			return tooLarge
+		}
+		return fmt.Errorf("%s: %s", file.Name, err)
+	}
+	if limited.N <= 0 {
+		return tooLarge
+	}
+	return nil
+}
+
+// columnIndex returns the zero based column index of a cell reference such as AB12
+func columnIndex(ref string) (int, error) {
+	index := 0
+	letters := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		index = index*26 + int(c-'A'+1)
+		letters++
+		if index > maxColumns {
+			return 0, fmt.Errorf("cell reference %q is past the last column a sheet can have", ref)
+		}
+	}
+
+	if letters == 0 {
+		return 0, fmt.Errorf("cell reference %q is not valid", ref)
+	}
+	return index - 1, nil
+}
+
+// cleanNumber drops the binary floating point noise spreadsheets store numbers with, so 0.30000000000000004 reads as 0.3
+func cleanNumber(value string) string {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+
+	number, _ = strconv.ParseFloat(strconv.FormatFloat(number, 'g', 15, 64), 64)
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
@@ -0,0 +1,197 @@
+// Package xlsx writes single-sheet Office Open XML spreadsheets, streaming the rows
+// so that large exports need not be held in memory, and reads back the first sheet of one
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// the cell styles declared in styles.xml
+const (
+	styleDefault = 0
+	styleHeader  = 1
+	styleAmount  = 2
+	styleNumber  = 3
+)
+
+// Amount is a number shown with two decimals and thousands separators
+type Amount float64
+
+const contentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`
+
+const rootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const workbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+const workbookTemplate = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const styles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="#,##0.###"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="4">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+</cellXfs>
+</styleSheet>`
+
+const sheetHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>
+<sheetData>`
+
+const sheetFooter = `</sheetData>
+</worksheet>`
+
+// Writer streams the rows of a single sheet into an XLSX file
+type Writer struct {
+	archive *zip.Writer
+	sheet   io.Writer
+	row     int
+}
+
+// maxSheetNameLength is the longest sheet name Excel opens without calling the file corrupt
+const maxSheetNameLength = 31
+
+// SheetName turns a name into one Excel accepts for a sheet: at most 31 characters, none of them : \ / ? * [ or ],
+// and not starting or ending with an apostrophe
+func SheetName(name string) string {
+	name = strings.NewReplacer(":", "-", `\`, "-", "/", "-", "?", "-", "*", "-", "[", "(", "]", ")").Replace(name)
+	if runes := []rune(name); len(runes) > maxSheetNameLength {
+		name = string(runes[:maxSheetNameLength])
+	}
+	name = strings.Trim(name, "'")
+	if strings.TrimSpace(name) == "" {
+		return "Sheet1"
+	}
+
+	return name
+}
+
+// NewWriter starts an XLSX file with a single sheet and writes its header row; the sheet name is cut down to one
+// Excel accepts
+func NewWriter(w io.Writer, sheetName string, header []string) (*Writer, error) {
+	archive := zip.NewWriter(w)
+
+	var escapedName strings.Builder
+	xml.EscapeText(&escapedName, []byte(SheetName(sheetName)))
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", contentTypes},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", fmt.Sprintf(workbookTemplate, escapedName.String())},
+		{"xl/_rels/workbook.xml.rels", workbookRels},
+		{"xl/styles.xml", styles},
+	}
+
+	for _, part := range parts {
+		partWriter, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(partWriter, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, sheetHeader); err != nil {
+		return nil, err
+	}
+
+	writer := &Writer{archive: archive, sheet: sheet}
+
+	headerCells := make([]interface{}, len(header))
+	for i, title := range header {
+		headerCells[i] = title
+	}
+
+	return writer, writer.writeRow(headerCells, styleHeader)
+}
+
+// ColumnName returns the spreadsheet column letters of a zero based column index
+func ColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+
+	return name
+}
+
+// WriteRow appends a row; strings become text cells, Amount two decimal cells and other numbers plain numeric cells
+func (writer *Writer) WriteRow(cells []interface{}) error {
+	return writer.writeRow(cells, styleDefault)
+}
+
+func (writer *Writer) writeRow(cells []interface{}, textStyle int) error {
+	writer.row++
+
+	var row strings.Builder
+	fmt.Fprintf(&row, `<row r="%d">`, writer.row)
+
+	for i, cell := range cells {
+		ref := ColumnName(i) + strconv.Itoa(writer.row)
+
+		switch value := cell.(type) {
+		case Amount:
+			fmt.Fprintf(&row, `<c r="%s" s="%d"><v>%s</v></c>`, ref, styleAmount, strconv.FormatFloat(float64(value), 'f', 2, 64))
+		case float64:
+			fmt.Fprintf(&row, `<c r="%s" s="%d"><v>%s</v></c>`, ref, styleNumber, strconv.FormatFloat(value, 'f', -1, 64))
+		case int:
+			fmt.Fprintf(&row, `<c r="%s" s="%d"><v>%d</v></c>`, ref, styleNumber, value)
+		default:
+			var text strings.Builder
+			xml.EscapeText(&text, []byte(fmt.Sprint(value)))
+			fmt.Fprintf(&row, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, textStyle, text.String())
+		}
+	}
+
+	row.WriteString(`</row>`)
+
+	_, err := io.WriteString(writer.sheet, row.String())
+	return err
+}
+
+// Close finishes the sheet and the archive
+func (writer *Writer) Close() error {
+	if _, err := io.WriteString(writer.sheet, sheetFooter); err != nil {
+		return err
+	}
+
+	return writer.archive.Close()
+}